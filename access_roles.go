@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	roleGuest      = "guest"
+	roleReporter   = "reporter"
+	roleDeveloper  = "developer"
+	roleMaintainer = "maintainer"
+	roleOwner      = "owner"
+
+	memberRolesCacheTTL = time.Hour
+)
+
+// accessRoles are the names of the builtin roles of GitLab by access level.
+var accessRoles = map[gitlab.AccessLevelValue]string{
+	gitlab.GuestPermissions:      roleGuest,
+	gitlab.ReporterPermissions:   roleReporter,
+	gitlab.DeveloperPermissions:  roleDeveloper,
+	gitlab.MaintainerPermissions: roleMaintainer,
+	gitlab.OwnerPermissions:      roleOwner,
+}
+
+var defaultMaintainerRoles = []string{roleDeveloper, roleMaintainer, roleOwner}
+
+// projectMember is the member of project with the custom role, which is
+// returned by GitLab 16 or later.
+type projectMember struct {
+	Username    string                  `json:"username"`
+	AccessLevel gitlab.AccessLevelValue `json:"access_level"`
+	MemberRole  *memberRole             `json:"member_role"`
+}
+
+// memberRole is the custom role of a group. Its name is not returned along
+// with the member by some versions, and is looked up by the member roles API.
+type memberRole struct {
+	ID              int                     `json:"id"`
+	GroupID         int                     `json:"group_id"`
+	Name            string                  `json:"name"`
+	BaseAccessLevel gitlab.AccessLevelValue `json:"base_access_level"`
+}
+
+// roleNames returns the names of roles the member has, which are in lower
+// case.
+func (bot *robot) roleNames(m *projectMember) []string {
+	var r []string
+	if v, ok := accessRoles[m.AccessLevel]; ok {
+		r = append(r, v)
+	}
+
+	if m.MemberRole != nil {
+		if v := bot.memberRoleName(m.MemberRole); v != "" {
+			r = append(r, strings.ToLower(v))
+		}
+	}
+
+	return r
+}
+
+func (bot *robot) memberRoleName(role *memberRole) string {
+	if role.Name != "" || role.GroupID == 0 {
+		return role.Name
+	}
+
+	key := fmt.Sprintf("member-roles/%d", role.GroupID)
+
+	v, ok := bot.files.get(key)
+	if !ok {
+		roles, err := bot.cli.ListMemberRoles(role.GroupID)
+		if err != nil {
+			return ""
+		}
+
+		bot.files.set(key, roles, memberRolesCacheTTL)
+		v = roles
+	}
+
+	for _, item := range v.([]*memberRole) {
+		if item.ID == role.ID {
+			return item.Name
+		}
+	}
+
+	return ""
+}
+
+// filterByRoles returns the usernames of members having any of the roles.
+func (bot *robot) filterByRoles(members []*projectMember, roles []string) []string {
+	want := sets.NewString()
+	for _, v := range roles {
+		want.Insert(strings.ToLower(v))
+	}
+
+	r := make([]string, 0, len(members))
+	for _, m := range members {
+		if m != nil && want.HasAny(bot.roleNames(m)...) {
+			r = append(r, m.Username)
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+// actions applies an action plan to the platform where the event comes from.
+type actions interface {
+	addComment(comment string) error
+	addLabel(label string) error
+	createLabelIfNeed(label string) error
+	assign(ids []int) error
+}
+
+// noteEditor is implemented by the actions which can read and edit the
+// existing comments.
+type noteEditor interface {
+	listNotes() ([]*gitlab.Note, error)
+	updateNote(id int, body string) error
+}
+
+type gitlabActions struct {
+	cli       iClient
+	projectID int
+	number    int
+}
+
+func (a *gitlabActions) createLabelIfNeed(label string) error {
+	repoLabels, err := a.cli.GetProjectLabels(a.projectID)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range repoLabels {
+		if v.Name == label {
+			return nil
+		}
+	}
+
+	return a.cli.CreateProjectLabel(a.projectID, label, "")
+}
+
+// mrActions applies the plan to a merge request.
+type mrActions gitlabActions
+
+func (a *mrActions) addComment(comment string) error {
+	return a.cli.CreateMergeRequestComment(a.projectID, a.number, comment)
+}
+
+func (a *mrActions) addLabel(label string) error {
+	return a.cli.AddMergeRequestLabel(a.projectID, a.number, gitlab.Labels{label})
+}
+
+func (a *mrActions) createLabelIfNeed(label string) error {
+	return (*gitlabActions)(a).createLabelIfNeed(label)
+}
+
+func (a *mrActions) assign(ids []int) error {
+	return a.cli.AssignMergeRequest(a.projectID, a.number, ids)
+}
+
+func (a *mrActions) listNotes() ([]*gitlab.Note, error) {
+	return a.cli.ListMergeRequestNotes(a.projectID, a.number)
+}
+
+func (a *mrActions) updateNote(id int, body string) error {
+	return a.cli.UpdateMergeRequestNote(a.projectID, a.number, id, body)
+}
+
+// issueActions applies the plan to an issue.
+type issueActions gitlabActions
+
+func (a *issueActions) addComment(comment string) error {
+	return a.cli.CreateIssueComment(a.projectID, a.number, comment)
+}
+
+func (a *issueActions) addLabel(label string) error {
+	return a.cli.AddIssueLabels(a.projectID, a.number, gitlab.Labels{label})
+}
+
+func (a *issueActions) createLabelIfNeed(label string) error {
+	return (*gitlabActions)(a).createLabelIfNeed(label)
+}
+
+func (a *issueActions) assign(ids []int) error {
+	return nil
+}
+
+func (a *issueActions) listNotes() ([]*gitlab.Note, error) {
+	return a.cli.ListIssueNotes(a.projectID, a.number)
+}
+
+func (a *issueActions) updateNote(id int, body string) error {
+	return a.cli.UpdateIssueNote(a.projectID, a.number, id, body)
+}
+
+// mirrorMentionRe matches the mentions in the comment, which are not a part
+// of email addresses.
+var mirrorMentionRe = regexp.MustCompile(`(^|[^\w@.])@(\w(?:[\w.-]*\w)?)`)
+
+// mirrorComment renders the mentions of the comment posted to other
+// platforms as plain names, since they are the usernames of GitLab or sig
+// info which may be the ones of other users there. The author of the item
+// is the only one known on the platform, who is still mentioned.
+func mirrorComment(comment, author string) string {
+	return mirrorMentionRe.ReplaceAllStringFunc(comment, func(s string) string {
+		m := mirrorMentionRe.FindStringSubmatch(s)
+		if author != "" && m[2] == author {
+			return s
+		}
+
+		return m[1] + m[2]
+	})
+}
+
+// handleMirrorEvent handles the event of a repo on other platforms which is
+// the mirror of the GitLab project with the same path. The sig and
+// maintainers are resolved from GitLab and the plan is applied by acts.
+func (bot *robot) handleMirrorEvent(org, repo, author string, acts actions, meta *itemMeta, log *logrus.Entry) error {
+	botCfg, err := bot.botConfigFor(org, repo, log)
+	if err != nil || botCfg == nil {
+		return err
+	}
+
+	projectID, err := bot.cli.GetProjectID(fmt.Sprintf("%s/%s", org, repo))
+	if err != nil {
+		return err
+	}
+
+	// the steps which are specific to the merge request of GitLab are
+	// skipped by passing 0 as the number.
+	return bot.handle(org, repo, author, projectID, botCfg, log, acts, 0, meta)
+}
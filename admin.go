@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// adminServer serves the endpoints for operators. The requests must carry
+// the admin token as a bearer token, and the endpoints are not served if
+// the token is not configured.
+type adminServer struct {
+	getToken func() []byte
+}
+
+func (s *adminServer) handle(path string, h http.HandlerFunc) {
+	if s.getToken == nil {
+		return
+	}
+
+	http.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+			return
+		}
+
+		h(w, r)
+	})
+}
+
+// handleRobot serves the endpoints of the robot under the prefix, which is
+// /tenants/<name> for a tenant.
+func (s *adminServer) handleRobot(prefix string, r *robot) {
+	s.handle(prefix+"/admin/unconfigured-repos", r.unconfiguredReposHandler)
+	s.handle(prefix+"/admin/mention-fairness", r.mentionFairnessHandler)
+	s.handle(prefix+"/admin/stats", r.statsHandler)
+	s.handle(prefix+"/admin/error-budget", r.errorBudgetHandler)
+	s.handle(prefix+"/admin/summary-card", r.summaryCardHandler)
+	s.handle(prefix+"/compose", r.composeHandler)
+	s.handle(prefix+"/config/effective", r.effectiveConfigHandler)
+}
+
+func (s *adminServer) authorized(r *http.Request) bool {
+	if s.getToken == nil {
+		return false
+	}
+
+	token := s.getToken()
+	if len(token) == 0 {
+		return false
+	}
+
+	v := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	return subtle.ConstantTimeCompare([]byte(v), token) == 1
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.WithError(err).Error("write response")
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	alertKindWebhook  = "webhook"
+	alertKindSlack    = "slack"
+	alertKindWeCom    = "wecom"
+	alertKindDingTalk = "dingtalk"
+
+	alertMaxBackoff = 24 * time.Hour
+
+	errorClassUnauthorized = "unauthorized"
+	errorClassForbidden    = "forbidden"
+	errorClassNotFound     = "not_found"
+	errorClassRateLimited  = "rate_limited"
+	errorClassServer       = "server_error"
+	errorClassClient       = "client_error"
+	errorClassTimeout      = "timeout"
+	errorClassNetwork      = "network"
+	errorClassOther        = "other"
+)
+
+var alertsTotal = newCounterVec(
+	"welcome_alerts_total",
+	"Number of the alerts of repeated failed welcomes sent.",
+	"project", "result",
+)
+
+// classifyError returns the class of error, such as forbidden or timeout,
+// which hints the cause to the operators.
+func classifyError(err error) string {
+	var e *gitlab.ErrorResponse
+	if errors.As(err, &e) && e.Response != nil {
+		switch code := e.Response.StatusCode; {
+		case code == http.StatusUnauthorized:
+			return errorClassUnauthorized
+		case code == http.StatusForbidden:
+			return errorClassForbidden
+		case code == http.StatusNotFound:
+			return errorClassNotFound
+		case code == http.StatusTooManyRequests:
+			return errorClassRateLimited
+		case code >= 500:
+			return errorClassServer
+		default:
+			return errorClassClient
+		}
+	}
+
+	var ne net.Error
+	if errors.As(err, &ne) {
+		if ne.Timeout() {
+			return errorClassTimeout
+		}
+
+		return errorClassNetwork
+	}
+
+	return errorClassOther
+}
+
+type projectFailure struct {
+	at    time.Time
+	class string
+}
+
+type projectAlertState struct {
+	failures []projectFailure
+
+	// the alerts are sent no earlier than next, and the backoff doubles
+	// each time until the failures stop.
+	next    time.Time
+	backoff time.Duration
+}
+
+// alerter alerts the operators when a project fails to welcome repeatedly,
+// so that they hear about the misconfigurations before the contributors
+// complain. The repeated alerts of a project are sent with exponential
+// back-off.
+type alerter struct {
+	lock     sync.Mutex
+	projects map[string]*projectAlertState
+}
+
+func newAlerter() *alerter {
+	return &alerter{projects: make(map[string]*projectAlertState)}
+}
+
+// record records the failure, and returns the classes of failures in the
+// window if an alert is due.
+func (a *alerter) record(project string, err error, cfg *alertConfig) (map[string]int, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	s, ok := a.projects[project]
+	if !ok {
+		s = new(projectAlertState)
+		a.projects[project] = s
+	}
+
+	now := time.Now()
+	window := cfg.window()
+
+	start := now.Add(-window)
+	i := 0
+	for i < len(s.failures) && s.failures[i].at.Before(start) {
+		i++
+	}
+
+	// the failures have stopped for a window, so start over.
+	if i == len(s.failures) {
+		s.backoff = 0
+	}
+
+	s.failures = append(s.failures[i:], projectFailure{at: now, class: classifyError(err)})
+
+	if len(s.failures) <= cfg.MaxFailures || now.Before(s.next) {
+		return nil, false
+	}
+
+	if s.backoff == 0 {
+		s.backoff = window
+	} else if s.backoff *= 2; s.backoff > alertMaxBackoff {
+		s.backoff = alertMaxBackoff
+	}
+	s.next = now.Add(s.backoff)
+
+	classes := make(map[string]int)
+	for _, f := range s.failures {
+		classes[f.class]++
+	}
+
+	return classes, true
+}
+
+type alertMessage struct {
+	Community string         `json:"community"`
+	Project   string         `json:"project"`
+	Failures  int            `json:"failures"`
+	Minutes   int            `json:"minutes"`
+	Classes   map[string]int `json:"classes"`
+	LastError string         `json:"last_error"`
+}
+
+func (m *alertMessage) text() string {
+	classes := make([]string, 0, len(m.Classes))
+	for k, v := range m.Classes {
+		classes = append(classes, fmt.Sprintf("%s: %d", k, v))
+	}
+	sort.Strings(classes)
+
+	return fmt.Sprintf(
+		"[%s] welcome robot failed %d times in %d minutes on %s (%s). Last error: %s",
+		m.Community, m.Failures, m.Minutes, m.Project, strings.Join(classes, ", "), m.LastError,
+	)
+}
+
+// payload returns the body of the webhook of the kind.
+func (m *alertMessage) payload(kind string) ([]byte, error) {
+	switch kind {
+	case alertKindSlack:
+		return json.Marshal(map[string]string{"text": m.text()})
+
+	case alertKindWeCom, alertKindDingTalk:
+		return json.Marshal(map[string]interface{}{
+			"msgtype": "text",
+			"text":    map[string]string{"content": m.text()},
+		})
+
+	default:
+		return json.Marshal(m)
+	}
+}
+
+// alertFailure records the failed welcome of the project and sends the
+// alert if the failures exceed the limit of config.
+func (bot *robot) alertFailure(org, repo string, err error, cfg *botConfig, log *logrus.Entry) {
+	c := &cfg.Alert
+	if c.URL == "" {
+		return
+	}
+
+	project := org + "/" + repo
+
+	classes, ok := bot.alerts.record(project, err, c)
+	if !ok {
+		return
+	}
+
+	n := 0
+	for _, v := range classes {
+		n += v
+	}
+
+	m := &alertMessage{
+		Community: cfg.CommunityName,
+		Project:   project,
+		Failures:  n,
+		Minutes:   c.Minutes,
+		Classes:   classes,
+		LastError: err.Error(),
+	}
+
+	// the error may mention the author, so only its class is sent in the
+	// privacy mode.
+	if bot.privacy {
+		m.LastError = "[redacted]"
+	}
+
+	go func() {
+		body, err := m.payload(c.Kind)
+		if err == nil {
+			err = postJSON(bot.hc, c.URL, map[string]string{"Content-Type": "application/json"}, body)
+		}
+
+		if err != nil {
+			alertsTotal.inc(project, "failed")
+			log.WithError(err).Error("send the alert of failed welcomes")
+
+			return
+		}
+
+		alertsTotal.inc(project, "sent")
+	}()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// assignmentAuthorReviewers assigns the merge request to its author, who
+// drives it, and requests the review of the maintainers.
+const assignmentAuthorReviewers = "author_reviewers"
+
+type assignmentConfig struct {
+	// Policy is how to assign the merge request. It can be author_reviewers,
+	// which sets the author as the assignee and the maintainers and
+	// committers as the reviewers. need_assign applies if it is not set.
+	Policy string `json:"policy,omitempty"`
+
+	// MaxReviewers is the maximum number of reviewers, who are the ones
+	// mentioned the least recently. It is unlimited if not set.
+	MaxReviewers int `json:"max_reviewers,omitempty"`
+}
+
+func (c *assignmentConfig) validate() error {
+	switch c.Policy {
+	case "", assignmentAuthorReviewers:
+	default:
+		return fmt.Errorf("unsupported policy of assignment: %s", c.Policy)
+	}
+
+	if c.MaxReviewers < 0 {
+		return fmt.Errorf("max_reviewers of assignment can't be negative")
+	}
+
+	return nil
+}
+
+// reviewerSetter is implemented by the actions which can request the
+// review of users.
+type reviewerSetter interface {
+	setReviewers(ids []int) error
+}
+
+func (a *mrActions) setReviewers(ids []int) error {
+	return a.cli.SetReviewers(a.projectID, a.number, ids)
+}
+
+// planAssignment sets the assignee and reviewers of the merge request by
+// the policy of repo.
+func (bot *robot) planAssignment(plan *ActionPlan, author string, maintainers, committers []string, cfg *botConfig, log *logrus.Entry) {
+	c := &cfg.Assignment
+	if c.Policy != assignmentAuthorReviewers {
+		return
+	}
+
+	if ids := bot.userIDs([]string{author}, log); len(ids) > 0 {
+		plan.Assign = true
+		plan.Assignees = ids
+	}
+
+	reviewers := sets.NewString(maintainers...).Insert(committers...).Delete(author).List()
+	plan.Reviewers = bot.mentions.pick(reviewers, c.MaxReviewers, cfg.Fairness.Days)
+}
+
+// setReviewers requests the review of the reviewers of plan, if the
+// platform supports it.
+func (bot *robot) setReviewers(plan *ActionPlan, acts actions, log *logrus.Entry) error {
+	r, ok := acts.(reviewerSetter)
+	if !ok || len(plan.Reviewers) == 0 {
+		return nil
+	}
+
+	ids := bot.userIDs(plan.Reviewers, log)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return r.setReviewers(ids)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const backfillCommand = "backfill"
+
+type backfillOptions struct {
+	project  string
+	since    string
+	interval time.Duration
+
+	sinceTime time.Time
+}
+
+func (o *backfillOptions) addFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.project, "project", "", "Path of the project to backfill the welcomes, such as org/repo.")
+	fs.StringVar(&o.since, "since", "", "Only the merge requests and issues created since the date, such as 2024-01-01, are backfilled.")
+	fs.DurationVar(&o.interval, "backfill-interval", time.Second, "Minimum interval between two merge requests or issues being processed.")
+}
+
+func (o *backfillOptions) validate() error {
+	if strings.Count(o.project, "/") < 1 {
+		return errors.New("project must be like org/repo")
+	}
+
+	if o.since == "" {
+		return errors.New("since must be set")
+	}
+
+	t, err := time.Parse("2006-01-02", o.since)
+	if err != nil {
+		return fmt.Errorf("invalid since: %v", err)
+	}
+
+	o.sinceTime = t
+
+	if o.interval <= 0 {
+		return errors.New("backfill-interval must be positive")
+	}
+
+	return nil
+}
+
+// hasWelcome reports whether the welcome comment has been posted, which
+// is detected by its footer.
+func hasWelcome(notes []*gitlab.Note) bool {
+	for _, n := range notes {
+		if !n.System && strings.Contains(n.Body, commentFooterPrefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// welcomePosted reports whether the welcome comment has been posted to the
+// merge request or issue of acts.
+func welcomePosted(acts actions) (bool, error) {
+	ne, ok := acts.(noteEditor)
+	if !ok {
+		return false, errors.New("the comments can't be read")
+	}
+
+	notes, err := ne.listNotes()
+	if err != nil {
+		return false, err
+	}
+
+	return hasWelcome(notes), nil
+}
+
+// backfill processes the open merge requests and issues of the project
+// which have not been welcomed through the normal pipeline, one per
+// interval.
+func (bot *robot) backfill(o *backfillOptions) error {
+	i := strings.LastIndex(o.project, "/")
+	org, repo := o.project[:i], o.project[i+1:]
+
+	log := logrus.WithField("backfill", o.project)
+
+	cfg, err := bot.botConfigFor(org, repo, log)
+	if err != nil {
+		return err
+	}
+
+	if cfg == nil {
+		return fmt.Errorf("%s is not configured", o.project)
+	}
+
+	pid, err := bot.cli.GetProjectID(o.project)
+	if err != nil {
+		return err
+	}
+
+	mrs, err := bot.cli.ListOpenMergeRequests(pid, o.sinceTime)
+	if err != nil {
+		return err
+	}
+
+	issues, err := bot.cli.ListOpenIssues(pid, o.sinceTime)
+	if err != nil {
+		return err
+	}
+
+	limiter := time.NewTicker(o.interval)
+	defer limiter.Stop()
+
+	done, skipped, failed := 0, 0, 0
+
+	process := func(number int, author string, isMR bool, meta *itemMeta) {
+		l := log.WithFields(logrus.Fields{"number": number, "merge_request": isMR})
+
+		var a actions
+		var notes []*gitlab.Note
+		var err error
+
+		<-limiter.C
+
+		if isMR {
+			a = &mrActions{cli: bot.clientFor(org), projectID: pid, number: number}
+			notes, err = bot.cli.ListMergeRequestNotes(pid, number)
+		} else {
+			a = &issueActions{cli: bot.clientFor(org), projectID: pid, number: number}
+			notes, err = bot.cli.ListIssueNotes(pid, number)
+		}
+
+		if err != nil {
+			l.WithError(err).Error("list notes")
+			failed++
+
+			return
+		}
+
+		if hasWelcome(notes) {
+			skipped++
+
+			return
+		}
+
+		// the issues are handled with 0 as the number, the same as the
+		// events of them.
+		n := number
+		if !isMR {
+			n = 0
+		}
+
+		if err := bot.handle(org, repo, author, pid, cfg, l, a, n, meta); err != nil {
+			l.WithError(err).Error("backfill the welcome")
+			failed++
+
+			return
+		}
+
+		done++
+	}
+
+	for _, v := range mrs {
+		if v.Author != nil {
+			process(v.IID, v.Author.Username, true, mrMetaOf(v))
+		}
+	}
+
+	for _, v := range issues {
+		if v.Author != nil {
+			process(v.IID, v.Author.Username, false, issueMetaOf(v))
+		}
+	}
+
+	log.WithFields(logrus.Fields{
+		"welcomed": done,
+		"skipped":  skipped,
+		"failed":   failed,
+	}).Info("backfill is done")
+
+	if failed > 0 {
+		return fmt.Errorf("%d merge requests or issues failed", failed)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var bestEffortQueueDepth = newGaugeVec(
+	"welcome_best_effort_queue_depth",
+	"Number of the tasks of best-effort actions waiting in the queue.",
+)
+
+// bestEffortStep is a step of best-effort action, such as adding a label.
+type bestEffortStep struct {
+	step   string
+	target string
+	do     func() error
+}
+
+// bestEffortTask is the steps of an event run in order.
+type bestEffortTask struct {
+	projectID int
+	retry     stepRetryConfig
+	steps     []bestEffortStep
+	log       *logrus.Entry
+}
+
+// bestEffortQueue runs the best-effort actions, such as the labels and
+// assignment, at a limited rate in background, so that the critical
+// welcome comments never wait behind them during busy periods.
+type bestEffortQueue struct {
+	tasks    chan *bestEffortTask
+	interval time.Duration
+}
+
+func newBestEffortQueue(size int, interval time.Duration) *bestEffortQueue {
+	return &bestEffortQueue{
+		tasks:    make(chan *bestEffortTask, size),
+		interval: interval,
+	}
+}
+
+// enqueue returns false if the queue is full.
+func (q *bestEffortQueue) enqueue(t *bestEffortTask) bool {
+	select {
+	case q.tasks <- t:
+		bestEffortQueueDepth.add(1)
+
+		return true
+	default:
+		return false
+	}
+}
+
+// run runs the steps one per interval.
+func (q *bestEffortQueue) run(bot *robot) {
+	limiter := time.NewTicker(q.interval)
+	defer limiter.Stop()
+
+	for t := range q.tasks {
+		bestEffortQueueDepth.add(-1)
+
+		report := newStepReport()
+		for _, s := range t.steps {
+			<-limiter.C
+
+			if err := report.run(s.step, s.target, false, &t.retry, s.do, t.log); err != nil {
+				bot.errorBudget.record(t.projectID)
+			}
+		}
+
+		if report.failed > 0 {
+			t.log.WithField("steps", report.results).Warn("some best-effort steps of welcome failed")
+		}
+	}
+}
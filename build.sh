@@ -26,12 +26,19 @@ update_repo(){
 
 }
 
+ldflags(){
+    local version=$(git describe --tags --always --dirty 2>/dev/null || echo dev)
+    local commit=$(git rev-parse --short HEAD 2>/dev/null || echo unknown)
+
+    echo "-X main.version=$version -X main.gitCommit=$commit -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+}
+
 build(){
     update_repo
 
     tips "build binary"
 
-    go build -a -o $robot_name .
+    go build -a -ldflags "$(ldflags)" -o $robot_name .
 }
 
 image(){
@@ -44,7 +51,10 @@ image(){
 
     image_id=$(bash $image_sh | grep IMAGE_ID | awk '{print $2}')
 
-    docker build -t $image_id .
+    docker build -t $image_id \
+        --build-arg VERSION=$(git describe --tags --always --dirty 2>/dev/null || echo dev) \
+        --build-arg GIT_COMMIT=$(git rev-parse --short HEAD 2>/dev/null || echo unknown) \
+        --build-arg BUILD_DATE=$(date -u +%Y-%m-%dT%H:%M:%SZ) .
 }
 cmd_help(){
     if [ $# -eq 0 ]; then
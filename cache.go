@@ -0,0 +1,85 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// cacheSweepInterval is how often the expired entries are swept.
+const cacheSweepInterval = time.Minute
+
+type cacheItem struct {
+	value  interface{}
+	expiry time.Time
+}
+
+// expiringCache is a concurrency safe key/value cache whose entries
+// expire after the ttl given when they are set. The expired entries are
+// dropped when they are read, and swept by the writes at most once every
+// cacheSweepInterval, so that the ones never read again don't hold the
+// memory.
+type expiringCache struct {
+	lock      sync.RWMutex
+	items     map[string]cacheItem
+	nextSweep time.Time
+}
+
+func newExpiringCache() *expiringCache {
+	return &expiringCache{items: make(map[string]cacheItem)}
+}
+
+func (c *expiringCache) get(key string) (interface{}, bool) {
+	c.lock.RLock()
+	item, ok := c.items[key]
+	c.lock.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	if time.Now().After(item.expiry) {
+		c.delete(key)
+
+		return nil, false
+	}
+
+	return item.value, true
+}
+
+func (c *expiringCache) set(key string, value interface{}, ttl time.Duration) {
+	now := time.Now()
+
+	c.lock.Lock()
+	if now.After(c.nextSweep) {
+		c.sweep(now)
+	}
+	c.items[key] = cacheItem{value: value, expiry: now.Add(ttl)}
+	c.lock.Unlock()
+}
+
+// sweep must be called with the lock held.
+func (c *expiringCache) sweep(now time.Time) {
+	for k, item := range c.items {
+		if now.After(item.expiry) {
+			delete(c.items, k)
+		}
+	}
+
+	c.nextSweep = now.Add(cacheSweepInterval)
+}
+
+func (c *expiringCache) delete(key string) {
+	c.lock.Lock()
+	delete(c.items, key)
+	c.lock.Unlock()
+}
+
+func (c *expiringCache) deleteIf(f func(key string) bool) {
+	c.lock.Lock()
+	for k := range c.items {
+		if f(k) {
+			delete(c.items, k)
+		}
+	}
+	c.lock.Unlock()
+}
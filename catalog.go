@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	toneCasual = "casual"
+	toneFormal = "formal"
+
+	defaultLanguage = "en"
+)
+
+const formalWelcomeTemplateEN = `
+Dear ***{{ .Author }}***, thank you for your contribution to the {{ .Community }} Community.
+The instructions for interacting with the community robot are available **[here]({{ .CommandLink }})**.
+Should you have any questions, please contact the SIG [{{ .Sig }}](https://gitee.com/openeuler/community/tree/master/sig/{{ .SigPath }}) or one of its maintainers: {{ mention .Maintainers | join " , " }}
+{{- if .Committers }}, or one of its committers: {{ mention .Committers | join " , " }}{{ end }}
+{{- range .Roles }}{{ if .Mention }}, or one of its {{ .Name }}: {{ mention .Members | join " , " }}{{ end }}{{ end }}
+{{- range .Roles }}{{ if not .Mention }}
+The {{ .Name }}: {{ link .Members | join " , " }}{{ end }}{{ end }}`
+
+const casualWelcomeTemplateZH = `
+嗨 ***{{ .Author }}***，欢迎来到 {{ .Community }} 社区！
+我是这里的机器人，和我互动的方法请看 **[这里]({{ .CommandLink }})**。
+有任何问题都可以找 SIG [{{ .Sig }}](https://gitee.com/openeuler/community/tree/master/sig/{{ .SigPath }}) 或者它的 maintainer：{{ mention .Maintainers | join " , " }}
+{{- if .Committers }}，committer：{{ mention .Committers | join " , " }}{{ end }}
+{{- range .Roles }}{{ if .Mention }}，{{ .Name }}：{{ mention .Members | join " , " }}{{ end }}{{ end }}
+{{- range .Roles }}{{ if not .Mention }}
+{{ .Name }}：{{ link .Members | join " , " }}{{ end }}{{ end }}`
+
+const formalWelcomeTemplateZH = `
+尊敬的 ***{{ .Author }}***，感谢您对 {{ .Community }} 社区的贡献。
+社区机器人的使用说明请参阅 **[此处]({{ .CommandLink }})**。
+如有疑问，请联系 SIG [{{ .Sig }}](https://gitee.com/openeuler/community/tree/master/sig/{{ .SigPath }}) 的 maintainer：{{ mention .Maintainers | join " , " }}
+{{- if .Committers }}，或 committer：{{ mention .Committers | join " , " }}{{ end }}
+{{- range .Roles }}{{ if .Mention }}，或 {{ .Name }}：{{ mention .Members | join " , " }}{{ end }}{{ end }}
+{{- range .Roles }}{{ if not .Mention }}
+{{ .Name }}：{{ link .Members | join " , " }}{{ end }}{{ end }}`
+
+// welcomeCatalog is the builtin welcome templates by language and tone.
+var welcomeCatalog = map[string]map[string]string{
+	"en": {
+		toneCasual: defaultWelcomeTemplate,
+		toneFormal: formalWelcomeTemplateEN,
+	},
+	"zh": {
+		toneCasual: casualWelcomeTemplateZH,
+		toneFormal: formalWelcomeTemplateZH,
+	},
+}
+
+// catalogTemplate returns the welcome template of the language and tone.
+// The catalog of config takes precedence over the builtin one.
+func (c *botConfig) catalogTemplate() (string, bool) {
+	if v, ok := c.Catalog[c.Language][c.Tone]; ok {
+		return v, true
+	}
+
+	v, ok := welcomeCatalog[c.Language][c.Tone]
+
+	return v, ok
+}
+
+// welcomeTemplate returns the template of welcome comment for the event
+// type. The templates of config take precedence over the welcome_template,
+// which takes precedence over the catalog. The sigs are not mentioned by
+// the builtin template when welcoming by the ownership only.
+func (c *botConfig) welcomeTemplate(eventType string) string {
+	if v, ok := c.Templates[eventType]; ok {
+		return v
+	}
+
+	if c.WelcomeTemplate != "" {
+		return c.WelcomeTemplate
+	}
+
+	if c.OwnershipOnly {
+		return ownershipWelcomeTemplate
+	}
+
+	if v, ok := c.catalogTemplate(); ok {
+		return v
+	}
+
+	return defaultWelcomeTemplate
+}
+
+func (c *botConfig) validateCatalog() error {
+	for lang, tones := range c.Catalog {
+		for tone, text := range tones {
+			if _, err := parseTemplate(text); err != nil {
+				return fmt.Errorf("invalid catalog template of %s/%s: %v", lang, tone, err)
+			}
+		}
+	}
+
+	if _, ok := c.catalogTemplate(); !ok {
+		return fmt.Errorf("no welcome template of language %s in the %s tone", c.Language, c.Tone)
+	}
+
+	return nil
+}
+
+// eventTypes are the types of event which can have their own templates and
+// variables.
+var eventTypes = sets.NewString(objectKindMergeRequest, objectKindIssue)
+
+// builtinVariables are the variables by event type available to all the
+// templates.
+var builtinVariables = map[string]map[string]string{
+	objectKindMergeRequest: {"thanks": "thanks for contributing"},
+	objectKindIssue:        {"thanks": "thanks for reporting"},
+}
+
+// eventVariables returns the variables of the event type. The ones of config
+// take precedence over the builtin ones.
+func (c *botConfig) eventVariables(eventType string) map[string]string {
+	r := make(map[string]string)
+	for k, v := range builtinVariables[eventType] {
+		r[k] = v
+	}
+
+	for k, v := range c.Variables[eventType] {
+		r[k] = v
+	}
+
+	return r
+}
+
+func (c *botConfig) validateEventTemplates() error {
+	for k, text := range c.Templates {
+		if !eventTypes.Has(k) {
+			return fmt.Errorf("unsupported event type of templates: %s", k)
+		}
+
+		if _, err := parseTemplate(text); err != nil {
+			return fmt.Errorf("invalid template of %s: %v", k, err)
+		}
+	}
+
+	for k := range c.Variables {
+		if !eventTypes.Has(k) {
+			return fmt.Errorf("unsupported event type of variables: %s", k)
+		}
+	}
+
+	return nil
+}
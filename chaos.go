@@ -0,0 +1,266 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+// chaosScenarioEnv is the env var of the path of chaos scenario. The faults
+// are injected only if it is set, which must never be done in production.
+const chaosScenarioEnv = "WELCOME_CHAOS_SCENARIO"
+
+var chaosInjectedTotal = newCounterVec(
+	"welcome_chaos_injected_total",
+	"Number of the faults injected into the requests to the dependency by kind.",
+	"dependency", "kind",
+)
+
+// errChaosInjected is the network error injected.
+var errChaosInjected = errors.New("chaos: the connection is reset")
+
+// chaosRule injects the faults into the requests it matches.
+type chaosRule struct {
+	// Dependency is gitlab, community_repo or newcomer. It matches all the
+	// dependencies if empty.
+	Dependency string `json:"dependency,omitempty"`
+
+	// Method matches the HTTP method of request if set.
+	Method string `json:"method,omitempty"`
+
+	// Path is the regexp matching the path of request if set.
+	Path string `json:"path,omitempty"`
+
+	// LatencyMs is the latency added to the matched requests, and
+	// JitterMs is the random latency added on top of it.
+	LatencyMs int `json:"latency_ms,omitempty"`
+	JitterMs  int `json:"jitter_ms,omitempty"`
+
+	// ErrorRate is the rate, from 0 to 1, of the matched requests failed.
+	ErrorRate float64 `json:"error_rate,omitempty"`
+
+	// Status is the status code of the failed requests, such as 429 or
+	// 503. They fail with a network error if it is 0.
+	Status int `json:"status,omitempty"`
+
+	// RetryAfter is the Retry-After in seconds of the failed requests.
+	RetryAfter int `json:"retry_after,omitempty"`
+
+	pathRe *regexp.Regexp
+}
+
+func (r *chaosRule) validate() error {
+	switch r.Dependency {
+	case "", dependencyGitlab, dependencyCommunityRepo, dependencyNewcomer:
+	default:
+		return fmt.Errorf("unknown dependency: %s", r.Dependency)
+	}
+
+	if r.LatencyMs < 0 || r.JitterMs < 0 {
+		return errors.New("the latency can't be negative")
+	}
+
+	if r.ErrorRate < 0 || r.ErrorRate > 1 {
+		return errors.New("error_rate must be between 0 and 1")
+	}
+
+	if r.Status != 0 && (r.Status < 400 || r.Status > 599) {
+		return fmt.Errorf("status must be an error, not %d", r.Status)
+	}
+
+	if r.Path != "" {
+		re, err := regexp.Compile(r.Path)
+		if err != nil {
+			return fmt.Errorf("invalid path: %v", err)
+		}
+
+		r.pathRe = re
+	}
+
+	return nil
+}
+
+func (r *chaosRule) match(dependency string, req *http.Request) bool {
+	return (r.Dependency == "" || r.Dependency == dependency) &&
+		(r.Method == "" || strings.EqualFold(r.Method, req.Method)) &&
+		(r.pathRe == nil || r.pathRe.MatchString(req.URL.Path))
+}
+
+// chaosScenario is the file describing the faults to inject. The first
+// rule matching a request applies.
+type chaosScenario struct {
+	// Seed makes the faults reproducible. The current time is used if it
+	// is 0.
+	Seed int64 `json:"seed,omitempty"`
+
+	Rules []chaosRule `json:"rules,omitempty"`
+
+	lock sync.Mutex
+	rand *rand.Rand
+}
+
+func loadChaosScenario(path string) (*chaosScenario, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := new(chaosScenario)
+	if err := yaml.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+
+	for i := range s.Rules {
+		if err := s.Rules[i].validate(); err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i, err)
+		}
+	}
+
+	if s.Seed == 0 {
+		s.Seed = time.Now().UnixNano()
+	}
+	s.rand = rand.New(rand.NewSource(s.Seed))
+
+	return s, nil
+}
+
+// roll returns the latency to add and whether to fail the request.
+func (s *chaosScenario) roll(r *chaosRule) (time.Duration, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	d := time.Duration(r.LatencyMs) * time.Millisecond
+	if r.JitterMs > 0 {
+		d += time.Duration(s.rand.Intn(r.JitterMs)) * time.Millisecond
+	}
+
+	return d, s.rand.Float64() < r.ErrorRate
+}
+
+// chaosTransport injects the faults of scenario into the requests to the
+// dependency, before they are sent by base.
+type chaosTransport struct {
+	base       http.RoundTripper
+	scenario   *chaosScenario
+	dependency func(*http.Request) string
+}
+
+func (t *chaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	dep := t.dependency(req)
+
+	var rule *chaosRule
+	for i := range t.scenario.Rules {
+		if r := &t.scenario.Rules[i]; r.match(dep, req) {
+			rule = r
+
+			break
+		}
+	}
+
+	if rule == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	latency, fail := t.scenario.roll(rule)
+
+	if latency > 0 {
+		chaosInjectedTotal.inc(dep, "latency")
+
+		timer := time.NewTimer(latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+
+			return nil, req.Context().Err()
+		}
+	}
+
+	if !fail {
+		return t.base.RoundTrip(req)
+	}
+
+	if rule.Status == 0 {
+		chaosInjectedTotal.inc(dep, "network_error")
+
+		return nil, errChaosInjected
+	}
+
+	chaosInjectedTotal.inc(dep, strconv.Itoa(rule.Status))
+
+	resp := &http.Response{
+		Status:     fmt.Sprintf("%d %s", rule.Status, http.StatusText(rule.Status)),
+		StatusCode: rule.Status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(`{"message":"injected by chaos"}`)),
+		Request:    req,
+	}
+
+	if rule.RetryAfter > 0 {
+		resp.Header.Set("Retry-After", strconv.Itoa(rule.RetryAfter))
+	}
+
+	return resp, nil
+}
+
+// withChaos returns a copy of hc injecting the faults of scenario, which
+// is hc itself if there is no scenario.
+func withChaos(hc *http.Client, scenario *chaosScenario, dependency func(*http.Request) string) *http.Client {
+	if scenario == nil {
+		return hc
+	}
+
+	base := hc.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	c := *hc
+	c.Transport = &chaosTransport{base: base, scenario: scenario, dependency: dependency}
+
+	return &c
+}
+
+// gitlabDependency tells the dependency of the request of GitLab API.
+func gitlabDependency(req *http.Request) string {
+	if isCommunityRepoRead(req) {
+		return dependencyCommunityRepo
+	}
+
+	return dependencyGitlab
+}
+
+// chaosScenarioFromEnv loads the scenario of the env var. It is nil if the
+// env var is not set.
+func chaosScenarioFromEnv() (*chaosScenario, error) {
+	path := os.Getenv(chaosScenarioEnv)
+	if path == "" {
+		return nil, nil
+	}
+
+	s, err := loadChaosScenario(path)
+	if err != nil {
+		return nil, fmt.Errorf("load the chaos scenario %s: %v", path, err)
+	}
+
+	logrus.Warnf(
+		"CHAOS: injecting the faults of %s by %d rules with seed %d, never do it in production",
+		path, len(s.Rules), s.Seed,
+	)
+
+	return s, nil
+}
@@ -0,0 +1,676 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// defaultLabelColor is the color of the labels created by the robot.
+const defaultLabelColor = "#428BCA"
+
+// gitlabClient is the client of the GitLab APIs this robot needs. It sends
+// the requests by hc, so that the proxy and CA of deployment are applied.
+type gitlabClient struct {
+	cli *gitlab.Client
+}
+
+func newGitlabClient(getToken func() []byte, host string, hc *http.Client) (*gitlabClient, error) {
+	cli, err := gitlab.NewClient(string(getToken()), gitlab.WithBaseURL(host), gitlab.WithHTTPClient(hc))
+	if err != nil {
+		return nil, err
+	}
+
+	return &gitlabClient{cli: cli}, nil
+}
+
+func (c *gitlabClient) CreateMergeRequestComment(pid interface{}, mrID int, comment string) error {
+	_, _, err := c.cli.Notes.CreateMergeRequestNote(
+		pid, mrID, &gitlab.CreateMergeRequestNoteOptions{Body: &comment},
+	)
+
+	return err
+}
+
+func (c *gitlabClient) CreateIssueComment(pid interface{}, issueID int, comment string) error {
+	_, _, err := c.cli.Notes.CreateIssueNote(
+		pid, issueID, &gitlab.CreateIssueNoteOptions{Body: &comment},
+	)
+
+	return err
+}
+
+func (c *gitlabClient) AddMergeRequestLabel(pid interface{}, mrID int, labels gitlab.Labels) error {
+	_, _, err := c.cli.MergeRequests.UpdateMergeRequest(
+		pid, mrID, &gitlab.UpdateMergeRequestOptions{AddLabels: &labels},
+	)
+
+	return err
+}
+
+func (c *gitlabClient) AddIssueLabels(pid interface{}, issueID int, labels gitlab.Labels) error {
+	_, _, err := c.cli.Issues.UpdateIssue(
+		pid, issueID, &gitlab.UpdateIssueOptions{AddLabels: &labels},
+	)
+
+	return err
+}
+
+func (c *gitlabClient) RemoveMergeRequestLabel(pid interface{}, mrID int, labels gitlab.Labels) error {
+	_, _, err := c.cli.MergeRequests.UpdateMergeRequest(
+		pid, mrID, &gitlab.UpdateMergeRequestOptions{RemoveLabels: &labels},
+	)
+
+	return err
+}
+
+func (c *gitlabClient) RemoveIssueLabels(pid interface{}, issueID int, labels gitlab.Labels) error {
+	_, _, err := c.cli.Issues.UpdateIssue(
+		pid, issueID, &gitlab.UpdateIssueOptions{RemoveLabels: &labels},
+	)
+
+	return err
+}
+
+func (c *gitlabClient) AssignMergeRequest(pid interface{}, mrID int, ids []int) error {
+	_, _, err := c.cli.MergeRequests.UpdateMergeRequest(
+		pid, mrID, &gitlab.UpdateMergeRequestOptions{AssigneeIDs: &ids},
+	)
+
+	return err
+}
+
+func (c *gitlabClient) SetReviewers(pid interface{}, mrID int, ids []int) error {
+	_, _, err := c.cli.MergeRequests.UpdateMergeRequest(
+		pid, mrID, &gitlab.UpdateMergeRequestOptions{ReviewerIDs: &ids},
+	)
+
+	return err
+}
+
+func (c *gitlabClient) CloseMergeRequest(pid interface{}, mrID int) error {
+	_, _, err := c.cli.MergeRequests.UpdateMergeRequest(
+		pid, mrID, &gitlab.UpdateMergeRequestOptions{StateEvent: gitlab.String("close")},
+	)
+
+	return err
+}
+
+func (c *gitlabClient) CloseIssue(pid interface{}, issueID int) error {
+	_, _, err := c.cli.Issues.UpdateIssue(
+		pid, issueID, &gitlab.UpdateIssueOptions{StateEvent: gitlab.String("close")},
+	)
+
+	return err
+}
+
+func (c *gitlabClient) ListMergeRequestNoteAwardEmoji(pid interface{}, mrID, noteID int) ([]*gitlab.AwardEmoji, error) {
+	v, _, err := c.cli.AwardEmoji.ListMergeRequestAwardEmojiOnNote(
+		pid, mrID, noteID, &gitlab.ListAwardEmojiOptions{PerPage: 100},
+	)
+
+	return v, err
+}
+
+func (c *gitlabClient) ListIssueNoteAwardEmoji(pid interface{}, issueID, noteID int) ([]*gitlab.AwardEmoji, error) {
+	v, _, err := c.cli.AwardEmoji.ListIssuesAwardEmojiOnNote(
+		pid, issueID, noteID, &gitlab.ListAwardEmojiOptions{PerPage: 100},
+	)
+
+	return v, err
+}
+
+func (c *gitlabClient) CreateMergeRequestAwardEmoji(pid interface{}, mrID int, name string) error {
+	_, _, err := c.cli.AwardEmoji.CreateMergeRequestAwardEmoji(
+		pid, mrID, &gitlab.CreateAwardEmojiOptions{Name: name},
+	)
+
+	return err
+}
+
+func (c *gitlabClient) CreateIssueAwardEmoji(pid interface{}, issueID int, name string) error {
+	_, _, err := c.cli.AwardEmoji.CreateIssueAwardEmoji(
+		pid, issueID, &gitlab.CreateAwardEmojiOptions{Name: name},
+	)
+
+	return err
+}
+
+func (c *gitlabClient) AssignIssue(pid interface{}, issueID int, ids []int) error {
+	_, _, err := c.cli.Issues.UpdateIssue(
+		pid, issueID, &gitlab.UpdateIssueOptions{AssigneeIDs: &ids},
+	)
+
+	return err
+}
+
+// GetProjectLabels returns all the labels of the project.
+func (c *gitlabClient) GetProjectLabels(pid interface{}) ([]*gitlab.Label, error) {
+	var r []*gitlab.Label
+
+	opt := gitlab.ListLabelsOptions{ListOptions: gitlab.ListOptions{PerPage: 100, Page: 1}}
+
+	for {
+		v, resp, err := c.cli.Labels.ListLabels(pid, &opt)
+		if err != nil {
+			return nil, err
+		}
+
+		r = append(r, v...)
+
+		if resp.NextPage == 0 {
+			return r, nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
+
+// CreateProjectLabel creates the label with the default color if color is
+// not set.
+func (c *gitlabClient) CreateProjectLabel(pid interface{}, label, color string) error {
+	if color == "" {
+		color = defaultLabelColor
+	}
+
+	_, _, err := c.cli.Labels.CreateLabel(
+		pid, &gitlab.CreateLabelOptions{Name: &label, Color: &color},
+	)
+
+	return err
+}
+
+// UpdateProjectLabel updates the label named by opt, such as its name,
+// color and description.
+func (c *gitlabClient) UpdateProjectLabel(pid interface{}, opt *gitlab.UpdateLabelOptions) error {
+	_, _, err := c.cli.Labels.UpdateLabel(pid, opt)
+
+	return err
+}
+
+// GetDirectoryTree returns all the nodes of the tree.
+func (c *gitlabClient) GetDirectoryTree(pid interface{}, opt gitlab.ListTreeOptions) ([]*gitlab.TreeNode, error) {
+	var r []*gitlab.TreeNode
+
+	opt.PerPage = 100
+	opt.Page = 1
+
+	for {
+		v, resp, err := c.cli.Repositories.ListTree(pid, &opt)
+		if err != nil {
+			return nil, err
+		}
+
+		r = append(r, v...)
+
+		if resp.NextPage == 0 {
+			return r, nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
+
+// ListCollaborators returns all the members of the project, including the
+// inherited ones. The request is sent directly, because the custom roles
+// of members are not decoded by the library.
+func (c *gitlabClient) ListCollaborators(pid interface{}) ([]*projectMember, error) {
+	var r []*projectMember
+
+	path := fmt.Sprintf("projects/%s/members/all", url.PathEscape(fmt.Sprint(pid)))
+	opt := gitlab.ListProjectMembersOptions{ListOptions: gitlab.ListOptions{PerPage: 100, Page: 1}}
+
+	for {
+		req, err := c.cli.NewRequest(http.MethodGet, path, &opt, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var v []*projectMember
+
+		resp, err := c.cli.Do(req, &v)
+		if err != nil {
+			return nil, err
+		}
+
+		r = append(r, v...)
+
+		if resp.NextPage == 0 {
+			return r, nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
+
+// ListMemberRoles returns the custom roles of the group.
+func (c *gitlabClient) ListMemberRoles(gid int) ([]*memberRole, error) {
+	req, err := c.cli.NewRequest(http.MethodGet, fmt.Sprintf("groups/%d/member_roles", gid), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var v []*memberRole
+	_, err = c.cli.Do(req, &v)
+
+	return v, err
+}
+
+func (c *gitlabClient) GetGroup(gid interface{}) (*gitlab.Group, error) {
+	v, _, err := c.cli.Groups.GetGroup(gid, nil)
+
+	return v, err
+}
+
+func (c *gitlabClient) GetPathContent(pid interface{}, file, branch string) (*gitlab.File, error) {
+	v, _, err := c.cli.RepositoryFiles.GetFile(pid, file, &gitlab.GetFileOptions{Ref: &branch})
+
+	return v, err
+}
+
+// GetFileLastCommit returns the sha of the last commit changing the file on
+// the branch. It is empty if the file has no commit.
+func (c *gitlabClient) GetFileLastCommit(pid interface{}, file, branch string) (string, error) {
+	v, _, err := c.cli.Commits.ListCommits(pid, &gitlab.ListCommitsOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 1},
+		RefName:     &branch,
+		Path:        &file,
+	})
+	if err != nil || len(v) == 0 {
+		return "", err
+	}
+
+	return v[0].ID, nil
+}
+
+func (c *gitlabClient) GetIssue(pid interface{}, issueID int) (*gitlab.Issue, error) {
+	v, _, err := c.cli.Issues.GetIssue(pid, issueID)
+
+	return v, err
+}
+
+func (c *gitlabClient) SetCommitStatus(pid interface{}, sha string, opt *gitlab.SetCommitStatusOptions) error {
+	_, _, err := c.cli.Commits.SetCommitStatus(pid, sha, opt)
+
+	return err
+}
+
+func (c *gitlabClient) GetMergeRequest(pid interface{}, mrID int) (*gitlab.MergeRequest, error) {
+	v, _, err := c.cli.MergeRequests.GetMergeRequest(pid, mrID, nil)
+
+	return v, err
+}
+
+// GetMergeRequestChanges returns the paths of files changed by the merge
+// request.
+func (c *gitlabClient) GetMergeRequestChanges(pid interface{}, mrID int) ([]string, error) {
+	v, _, err := c.cli.MergeRequests.GetMergeRequestChanges(pid, mrID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	r := make([]string, 0, len(v.Changes))
+	for _, item := range v.Changes {
+		r = append(r, item.NewPath)
+	}
+
+	return r, nil
+}
+
+// CountGroupMergeRequests returns the number of merge requests in the group
+// which are authored by author and in the state.
+func (c *gitlabClient) CountGroupMergeRequests(gid interface{}, author, state string) (int, error) {
+	opt := gitlab.ListGroupMergeRequestsOptions{
+		ListOptions:    gitlab.ListOptions{PerPage: 1},
+		State:          &state,
+		AuthorUsername: &author,
+	}
+
+	v, resp, err := c.cli.MergeRequests.ListGroupMergeRequests(gid, &opt)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.TotalItems > 0 {
+		return resp.TotalItems, nil
+	}
+
+	return len(v), nil
+}
+
+// GetProjectID returns the id of project whose path is path, such as org/repo.
+func (c *gitlabClient) GetProjectID(path string) (int, error) {
+	v, _, err := c.cli.Projects.GetProject(path, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return v.ID, nil
+}
+
+// ListMergeRequestNotes returns all the notes of the merge request in the
+// order they are created.
+func (c *gitlabClient) ListMergeRequestNotes(pid interface{}, mrID int) ([]*gitlab.Note, error) {
+	var r []*gitlab.Note
+
+	opt := gitlab.ListMergeRequestNotesOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 100, Page: 1},
+		OrderBy:     gitlab.String("created_at"),
+		Sort:        gitlab.String("asc"),
+	}
+
+	for {
+		v, resp, err := c.cli.Notes.ListMergeRequestNotes(pid, mrID, &opt)
+		if err != nil {
+			return nil, err
+		}
+
+		r = append(r, v...)
+
+		if resp.NextPage == 0 {
+			return r, nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
+
+// ListIssueNotes returns all the notes of the issue in the order they are
+// created.
+func (c *gitlabClient) ListIssueNotes(pid interface{}, issueID int) ([]*gitlab.Note, error) {
+	var r []*gitlab.Note
+
+	opt := gitlab.ListIssueNotesOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 100, Page: 1},
+		OrderBy:     gitlab.String("created_at"),
+		Sort:        gitlab.String("asc"),
+	}
+
+	for {
+		v, resp, err := c.cli.Notes.ListIssueNotes(pid, issueID, &opt)
+		if err != nil {
+			return nil, err
+		}
+
+		r = append(r, v...)
+
+		if resp.NextPage == 0 {
+			return r, nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
+
+func (c *gitlabClient) UpdateMergeRequestNote(pid interface{}, mrID, noteID int, body string) error {
+	_, _, err := c.cli.Notes.UpdateMergeRequestNote(
+		pid, mrID, noteID, &gitlab.UpdateMergeRequestNoteOptions{Body: &body},
+	)
+
+	return err
+}
+
+func (c *gitlabClient) UpdateIssueNote(pid interface{}, issueID, noteID int, body string) error {
+	_, _, err := c.cli.Notes.UpdateIssueNote(
+		pid, issueID, noteID, &gitlab.UpdateIssueNoteOptions{Body: &body},
+	)
+
+	return err
+}
+
+func (c *gitlabClient) CreateEpicNote(gid interface{}, epicID int, body string) error {
+	_, _, err := c.cli.Notes.CreateEpicNote(
+		gid, epicID, &gitlab.CreateEpicNoteOptions{Body: &body},
+	)
+
+	return err
+}
+
+// CreateIssue opens the issue in the project. It is assigned to assignees
+// and labeled by labels if they are not empty.
+func (c *gitlabClient) CreateIssue(
+	pid interface{}, title, description string, assignees []int, labels []string,
+) (*gitlab.Issue, error) {
+	opt := &gitlab.CreateIssueOptions{Title: &title, Description: &description}
+
+	if len(assignees) > 0 {
+		opt.AssigneeIDs = &assignees
+	}
+
+	if len(labels) > 0 {
+		v := gitlab.Labels(labels)
+		opt.Labels = &v
+	}
+
+	v, _, err := c.cli.Issues.CreateIssue(pid, opt)
+
+	return v, err
+}
+
+// ListProjectHookURLs returns the URLs of the webhooks of the project.
+func (c *gitlabClient) ListProjectHookURLs(pid interface{}) ([]string, error) {
+	v, _, err := c.cli.Projects.ListProjectHooks(pid, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	r := make([]string, len(v))
+	for i := range v {
+		r[i] = v[i].URL
+	}
+
+	return r, nil
+}
+
+// AddProjectHook registers the webhook of the events the robot handles to
+// the project.
+func (c *gitlabClient) AddProjectHook(pid interface{}, url, token string) error {
+	opt := &gitlab.AddProjectHookOptions{
+		URL:                 &url,
+		IssuesEvents:        gitlab.Bool(true),
+		MergeRequestsEvents: gitlab.Bool(true),
+		NoteEvents:          gitlab.Bool(true),
+		PushEvents:          gitlab.Bool(true),
+	}
+
+	if token != "" {
+		opt.Token = &token
+	}
+
+	_, _, err := c.cli.Projects.AddProjectHook(pid, opt)
+
+	return err
+}
+
+// GetUserID returns the id of user whose username is username.
+func (c *gitlabClient) GetUserID(username string) (int, error) {
+	v, _, err := c.cli.Users.ListUsers(&gitlab.ListUsersOptions{Username: &username})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(v) == 0 {
+		return 0, fmt.Errorf("user %s is not found", username)
+	}
+
+	return v[0].ID, nil
+}
+
+func (c *gitlabClient) DeleteMergeRequestNote(pid interface{}, mrID, noteID int) error {
+	_, err := c.cli.Notes.DeleteMergeRequestNote(pid, mrID, noteID)
+
+	return err
+}
+
+func (c *gitlabClient) DeleteIssueNote(pid interface{}, issueID, noteID int) error {
+	_, err := c.cli.Notes.DeleteIssueNote(pid, issueID, noteID)
+
+	return err
+}
+
+// ListClosedMergeRequests returns all the closed merge requests with the
+// label, which are updated before the time.
+func (c *gitlabClient) ListClosedMergeRequests(pid interface{}, label string, updatedBefore time.Time) ([]*gitlab.MergeRequest, error) {
+	var r []*gitlab.MergeRequest
+
+	opt := gitlab.ListProjectMergeRequestsOptions{
+		ListOptions:   gitlab.ListOptions{PerPage: 100, Page: 1},
+		State:         gitlab.String("closed"),
+		Labels:        gitlab.Labels{label},
+		UpdatedBefore: &updatedBefore,
+	}
+
+	for {
+		v, resp, err := c.cli.MergeRequests.ListProjectMergeRequests(pid, &opt)
+		if err != nil {
+			return nil, err
+		}
+
+		r = append(r, v...)
+
+		if resp.NextPage == 0 {
+			return r, nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
+
+// ListClosedIssues returns all the closed issues with the label, which are
+// updated before the time.
+func (c *gitlabClient) ListClosedIssues(pid interface{}, label string, updatedBefore time.Time) ([]*gitlab.Issue, error) {
+	var r []*gitlab.Issue
+
+	labels := gitlab.Labels{label}
+	opt := gitlab.ListProjectIssuesOptions{
+		ListOptions:   gitlab.ListOptions{PerPage: 100, Page: 1},
+		State:         gitlab.String("closed"),
+		Labels:        &labels,
+		UpdatedBefore: &updatedBefore,
+	}
+
+	for {
+		v, resp, err := c.cli.Issues.ListProjectIssues(pid, &opt)
+		if err != nil {
+			return nil, err
+		}
+
+		r = append(r, v...)
+
+		if resp.NextPage == 0 {
+			return r, nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
+
+// ListOpenMergeRequests returns all the open merge requests created after
+// the time.
+func (c *gitlabClient) ListOpenMergeRequests(pid interface{}, createdAfter time.Time) ([]*gitlab.MergeRequest, error) {
+	var r []*gitlab.MergeRequest
+
+	opt := gitlab.ListProjectMergeRequestsOptions{
+		ListOptions:  gitlab.ListOptions{PerPage: 100, Page: 1},
+		State:        gitlab.String(stateOpened),
+		CreatedAfter: &createdAfter,
+	}
+
+	for {
+		v, resp, err := c.cli.MergeRequests.ListProjectMergeRequests(pid, &opt)
+		if err != nil {
+			return nil, err
+		}
+
+		r = append(r, v...)
+
+		if resp.NextPage == 0 {
+			return r, nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
+
+// ListOpenIssues returns all the open issues created after the time.
+func (c *gitlabClient) ListOpenIssues(pid interface{}, createdAfter time.Time) ([]*gitlab.Issue, error) {
+	var r []*gitlab.Issue
+
+	opt := gitlab.ListProjectIssuesOptions{
+		ListOptions:  gitlab.ListOptions{PerPage: 100, Page: 1},
+		State:        gitlab.String(stateOpened),
+		CreatedAfter: &createdAfter,
+	}
+
+	for {
+		v, resp, err := c.cli.Issues.ListProjectIssues(pid, &opt)
+		if err != nil {
+			return nil, err
+		}
+
+		r = append(r, v...)
+
+		if resp.NextPage == 0 {
+			return r, nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
+
+// ListOpenLabeledMergeRequests returns all the open merge requests with the
+// label.
+func (c *gitlabClient) ListOpenLabeledMergeRequests(pid interface{}, label string) ([]*gitlab.MergeRequest, error) {
+	var r []*gitlab.MergeRequest
+
+	opt := gitlab.ListProjectMergeRequestsOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 100, Page: 1},
+		State:       gitlab.String(stateOpened),
+		Labels:      gitlab.Labels{label},
+	}
+
+	for {
+		v, resp, err := c.cli.MergeRequests.ListProjectMergeRequests(pid, &opt)
+		if err != nil {
+			return nil, err
+		}
+
+		r = append(r, v...)
+
+		if resp.NextPage == 0 {
+			return r, nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
+
+// ListOpenLabeledIssues returns all the open issues with the label.
+func (c *gitlabClient) ListOpenLabeledIssues(pid interface{}, label string) ([]*gitlab.Issue, error) {
+	var r []*gitlab.Issue
+
+	labels := gitlab.Labels{label}
+	opt := gitlab.ListProjectIssuesOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 100, Page: 1},
+		State:       gitlab.String(stateOpened),
+		Labels:      &labels,
+	}
+
+	for {
+		v, resp, err := c.cli.Issues.ListProjectIssues(pid, &opt)
+		if err != nil {
+			return nil, err
+		}
+
+		r = append(r, v...)
+
+		if resp.NextPage == 0 {
+			return r, nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// orgTokenPaths is the flag of the token paths by org, such as
+// openeuler=/etc/openeuler-bot/token. It can be repeated.
+type orgTokenPaths map[string]string
+
+func (o *orgTokenPaths) String() string {
+	v := make([]string, 0, len(*o))
+	for org, path := range *o {
+		v = append(v, org+"="+path)
+	}
+
+	return strings.Join(v, ",")
+}
+
+func (o *orgTokenPaths) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 || i == len(s)-1 {
+		return fmt.Errorf("invalid org token path: %s, it should be like org=path", s)
+	}
+
+	if *o == nil {
+		*o = make(orgTokenPaths)
+	}
+
+	(*o)[s[:i]] = s[i+1:]
+
+	return nil
+}
+
+func (o orgTokenPaths) paths() []string {
+	r := make([]string, 0, len(o))
+	for _, p := range o {
+		r = append(r, p)
+	}
+
+	return r
+}
+
+// clientFor returns the client of the bot identity of org, by which the
+// comments show up under the bot account of the community. The org may be
+// a subgroup, whose top-level group is looked up too. The default client
+// is returned if the org has no identity of its own.
+func (bot *robot) clientFor(org string) iClient {
+	if c, ok := bot.orgClients[org]; ok {
+		return c
+	}
+
+	if i := strings.Index(org, "/"); i > 0 {
+		if c, ok := bot.orgClients[org[:i]]; ok {
+			return c
+		}
+	}
+
+	return bot.cli
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	cloudEventSpecVersion = "1.0"
+	cloudEventTypeWelcome = "org.opensourceways.robot.welcome"
+	cloudEventSource      = "robot-gitlab-welcome"
+
+	sinkHTTP  = "http"
+	sinkKafka = "kafka"
+
+	// cloudEventQueueSize is the most events waiting to be published.
+	cloudEventQueueSize = 1000
+)
+
+var cloudEventsDroppedTotal = newCounterVec(
+	"welcome_cloudevents_dropped_total",
+	"Number of the CloudEvents dropped as the queue to publish them is full.",
+)
+
+// cloudEvent is a CloudEvent of version 1.0 whose data is JSON.
+type cloudEvent struct {
+	ID      string
+	Type    string
+	Source  string
+	Subject string
+	Time    time.Time
+	Data    interface{}
+}
+
+func (e *cloudEvent) attributes() map[string]string {
+	return map[string]string{
+		"specversion": cloudEventSpecVersion,
+		"id":          e.ID,
+		"type":        e.Type,
+		"source":      e.Source,
+		"subject":     e.Subject,
+		"time":        e.Time.UTC().Format(time.RFC3339),
+	}
+}
+
+type welcomeEventData struct {
+	Community string `json:"community"`
+	Org       string `json:"org"`
+	Repo      string `json:"repo"`
+	Number    int    `json:"number,omitempty"`
+	Sig       string `json:"sig"`
+	Author    string `json:"author"`
+	Newcomer  bool   `json:"newcomer"`
+	Succeeded bool   `json:"succeeded"`
+}
+
+type eventPublisher interface {
+	publish(e *cloudEvent) error
+}
+
+// asyncPublisher publishes the events to the sink in background through a
+// bounded queue, so that a slow sink never holds up the welcomes. The
+// events are dropped when the queue is full.
+type asyncPublisher struct {
+	sink   eventPublisher
+	events chan *cloudEvent
+}
+
+func newAsyncPublisher(sink eventPublisher, size int) *asyncPublisher {
+	p := &asyncPublisher{sink: sink, events: make(chan *cloudEvent, size)}
+
+	go p.run()
+
+	return p
+}
+
+func (p *asyncPublisher) publish(e *cloudEvent) error {
+	select {
+	case p.events <- e:
+		return nil
+	default:
+		cloudEventsDroppedTotal.inc()
+
+		return fmt.Errorf("the queue of cloud events is full, drop the event %s", e.ID)
+	}
+}
+
+func (p *asyncPublisher) run() {
+	for e := range p.events {
+		if err := p.sink.publish(e); err != nil {
+			logrus.WithError(err).WithField("subject", e.Subject).Error("publish welcome cloud event")
+		}
+	}
+}
+
+func (bot *robot) publishWelcomeEvent(
+	org, repo, author string, number int,
+	cfg *botConfig, plan *ActionPlan, err error, log *logrus.Entry,
+) {
+	if bot.publisher == nil {
+		return
+	}
+
+	if bot.privacy {
+		author = redactUsername(author)
+	}
+
+	e := &cloudEvent{
+		ID:      newEventID(),
+		Type:    cloudEventTypeWelcome,
+		Source:  cloudEventSource,
+		Subject: fmt.Sprintf("%s/%s", org, repo),
+		Time:    time.Now(),
+		Data: welcomeEventData{
+			Community: cfg.CommunityName,
+			Org:       org,
+			Repo:      repo,
+			Number:    number,
+			Sig:       plan.SigName,
+			Author:    author,
+			Newcomer:  plan.Newcomer,
+			Succeeded: err == nil,
+		},
+	}
+
+	if err := bot.publisher.publish(e); err != nil {
+		log.WithError(err).Error("publish welcome cloud event")
+	}
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(b)
+}
+
+func postJSON(hc *http.Client, url string, header map[string]string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		v, _ := ioutil.ReadAll(resp.Body)
+
+		return fmt.Errorf("%s responds %d: %s", url, resp.StatusCode, string(v))
+	}
+
+	return nil
+}
+
+// httpSink delivers the event in the binary content mode of the HTTP
+// protocol binding.
+type httpSink struct {
+	url string
+	hc  *http.Client
+}
+
+func (s *httpSink) publish(e *cloudEvent) error {
+	body, err := json.Marshal(e.Data)
+	if err != nil {
+		return err
+	}
+
+	header := map[string]string{"Content-Type": "application/json"}
+	for k, v := range e.attributes() {
+		header["ce-"+k] = v
+	}
+
+	return postJSON(s.hc, s.url, header, body)
+}
+
+// kafkaSink delivers the event to a topic through a Kafka REST proxy in the
+// structured content mode.
+type kafkaSink struct {
+	url   string
+	topic string
+	hc    *http.Client
+}
+
+func (s *kafkaSink) publish(e *cloudEvent) error {
+	v := map[string]interface{}{
+		"datacontenttype": "application/json",
+		"data":            e.Data,
+	}
+	for k, attr := range e.attributes() {
+		v[k] = attr
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"records": []map[string]interface{}{{"value": v}},
+	})
+	if err != nil {
+		return err
+	}
+
+	return postJSON(
+		s.hc, fmt.Sprintf("%s/topics/%s", s.url, s.topic),
+		map[string]string{"Content-Type": "application/vnd.kafka.json.v2+json"},
+		body,
+	)
+}
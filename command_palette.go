@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	sectionCommands = "commands"
+
+	commandTargetMergeRequest = "merge_request"
+	commandTargetIssue        = "issue"
+
+	defaultCommandPaletteTitle = "You can interact with me by the commands below:"
+)
+
+type commandPaletteConfig struct {
+	// URL or File is the command registry shared by the robots of the
+	// community, so that the welcome lists the commands available now
+	// instead of only linking to the command_link. File is a local path,
+	// such as a mounted config map, and URL takes precedence if both set.
+	URL  string `json:"url,omitempty"`
+	File string `json:"file,omitempty"`
+
+	// Robots lists the commands of these robots only. All the commands are
+	// listed if it is empty.
+	Robots []string `json:"robots,omitempty"`
+
+	// Title is the heading of the commands.
+	Title string `json:"title,omitempty"`
+
+	// CacheTTL is the seconds to cache the registry. The default value is
+	// 600.
+	CacheTTL int `json:"cache_ttl,omitempty"`
+}
+
+func (c *commandPaletteConfig) setDefault() {
+	if c.Title == "" {
+		c.Title = defaultCommandPaletteTitle
+	}
+
+	if c.CacheTTL <= 0 {
+		c.CacheTTL = 600
+	}
+}
+
+func (c *commandPaletteConfig) validate() error {
+	if c.URL != "" && sanitizeURL(c.URL) == "" {
+		return fmt.Errorf("unsupported url of command_palette: %s", c.URL)
+	}
+
+	return nil
+}
+
+func (c *commandPaletteConfig) enabled() bool {
+	return c.URL != "" || c.File != ""
+}
+
+func (c *commandPaletteConfig) source() string {
+	if c.URL != "" {
+		return c.URL
+	}
+
+	return c.File
+}
+
+// registeredCommand is a command of the robots of the community.
+type registeredCommand struct {
+	// Usage is how to use the command, such as "/lgtm [cancel]".
+	Usage string `json:"usage" required:"true"`
+
+	Description string `json:"description,omitempty"`
+
+	// Robot is the robot serving the command.
+	Robot string `json:"robot,omitempty"`
+
+	// Targets are merge_request and issue, on which the command works.
+	// It works on both if empty.
+	Targets []string `json:"targets,omitempty"`
+
+	// Deprecated commands are not listed.
+	Deprecated bool `json:"deprecated,omitempty"`
+}
+
+func (c *registeredCommand) appliesTo(target string) bool {
+	return len(c.Targets) == 0 || containsString(c.Targets, target)
+}
+
+type commandRegistry struct {
+	Commands []registeredCommand `json:"commands,omitempty"`
+}
+
+func parseCommandRegistry(b []byte) (*commandRegistry, error) {
+	r := new(commandRegistry)
+	if err := yaml.Unmarshal(b, r); err != nil {
+		return nil, err
+	}
+
+	for i := range r.Commands {
+		if strings.TrimSpace(r.Commands[i].Usage) == "" {
+			return nil, fmt.Errorf("the usage of command %d can not be empty", i)
+		}
+	}
+
+	return r, nil
+}
+
+func fetchCommandRegistry(hc *http.Client, url string) ([]byte, error) {
+	resp, err := hc.Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch the command registry, status code: %d", resp.StatusCode)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// getCommandRegistry reads the registry of config, which is cached for a
+// while so that the changes of it are picked up.
+func (bot *robot) getCommandRegistry(cfg *commandPaletteConfig) (*commandRegistry, error) {
+	key := cfg.source()
+	if v, ok := bot.commands.get(key); ok {
+		return v.(*commandRegistry), nil
+	}
+
+	var b []byte
+	var err error
+
+	if cfg.URL != "" {
+		b, err = fetchCommandRegistry(bot.hc, cfg.URL)
+	} else {
+		b, err = ioutil.ReadFile(cfg.File)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := parseCommandRegistry(b)
+	if err != nil {
+		return nil, newConfigError(fmt.Errorf("invalid command registry %s: %v", key, err))
+	}
+
+	bot.commands.set(key, r, time.Duration(cfg.CacheTTL)*time.Second)
+
+	return r, nil
+}
+
+// genCommandPalette renders the commands working on the merge request or
+// issue as a markdown table, followed by the link to the command help.
+func genCommandPalette(r *commandRegistry, isMR bool, cfg *botConfig) string {
+	target := commandTargetIssue
+	if isMR {
+		target = commandTargetMergeRequest
+	}
+
+	c := &cfg.CommandPalette
+
+	var rows []string
+	for i := range r.Commands {
+		cmd := &r.Commands[i]
+		if cmd.Deprecated || !cmd.appliesTo(target) {
+			continue
+		}
+
+		if len(c.Robots) > 0 && !containsString(c.Robots, cmd.Robot) {
+			continue
+		}
+
+		// the backticks can't be escaped in the code span, so they are
+		// dropped.
+		usage := strings.Replace(strings.Join(strings.Fields(cmd.Usage), " "), "`", "", -1)
+
+		rows = append(rows, fmt.Sprintf(
+			"| `%s` | %s |", strings.Replace(usage, "|", "\\|", -1), sanitizeText(cmd.Description),
+		))
+	}
+
+	if len(rows) == 0 {
+		return ""
+	}
+
+	s := fmt.Sprintf("%s\n\n| Command | Description |\n| --- | --- |\n%s", c.Title, strings.Join(rows, "\n"))
+
+	if link := sanitizeURL(cfg.commandLink()); link != "" {
+		s += fmt.Sprintf("\n\nSee more at **[Here](%s)**.", link)
+	}
+
+	return s
+}
+
+func (bot *robot) genCommandsSection(ctx *sectionContext) (string, error) {
+	c := &ctx.cfg.CommandPalette
+	if !c.enabled() {
+		return "", nil
+	}
+
+	r, err := bot.getCommandRegistry(c)
+	if err != nil {
+		return "", err
+	}
+
+	return genCommandPalette(r, ctx.meta != nil && ctx.meta.IsMergeRequest, ctx.cfg), nil
+}
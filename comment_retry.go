@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// commentFooterPrefix is the beginning of the footer of welcome comment.
+const commentFooterPrefix = "<!-- " + botName + "-robot: id="
+
+// commentFooter returns the hidden metadata appended to the welcome
+// comment, by which the comment already posted can be found. The version
+// of robot tells which deployment posted it.
+func commentFooter(comment string) string {
+	h := sha1.Sum([]byte(comment))
+
+	return fmt.Sprintf("%s%s version=%s -->", commentFooterPrefix, hex.EncodeToString(h[:])[:12], version)
+}
+
+// commentPosted reports whether a note containing the footer exists.
+func commentPosted(editor noteEditor, footer string) (bool, error) {
+	notes, err := editor.listNotes()
+	if err != nil {
+		return false, err
+	}
+
+	for _, n := range notes {
+		if !n.System && strings.Contains(n.Body, footer) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// postCommentOnce posts the comment by post and retries it on failure. The
+// request which failed might have been applied by GitLab, so the notes are
+// checked for the footer of the comment before each retry to make sure it
+// is posted at most once. The comment is not retried if the notes can't be
+// read.
+func postCommentOnce(
+	comment string, acts actions, cfg *commentRetryConfig,
+	post func(string) error, log *logrus.Entry,
+) error {
+	footer := commentFooter(comment)
+	comment = comment + "\n\n" + footer
+
+	err := post(comment)
+	if err == nil || cfg.Retries <= 0 {
+		return err
+	}
+
+	editor, ok := acts.(noteEditor)
+	if !ok {
+		return err
+	}
+
+	for i := 1; i <= cfg.Retries; i++ {
+		log.WithError(err).Warnf("post comment failed, retry %d/%d", i, cfg.Retries)
+
+		time.Sleep(time.Duration(cfg.Interval) * time.Second)
+
+		posted, lerr := commentPosted(editor, footer)
+		if lerr != nil {
+			log.WithError(lerr).Error("list notes to check the comment before retrying")
+
+			return err
+		}
+
+		if posted {
+			log.Info("the comment had been posted by the failed request")
+
+			return nil
+		}
+
+		if err = post(comment); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	stepCommitStatus = "commit_status"
+
+	defaultCommitStatusName = "community/welcome"
+)
+
+// welcomeStatusDescription describes the outcome of welcome, such as
+// "ok, sig/storage".
+func welcomeStatusDescription(plan *ActionPlan, report *stepReport) string {
+	result := "ok"
+	if report.err() != nil {
+		result = "failed"
+	} else if report.failed > 0 {
+		result = "partial"
+	}
+
+	if plan.SigName == "" {
+		return result + ", no sig"
+	}
+
+	return fmt.Sprintf("%s, sig/%s", result, plan.SigName)
+}
+
+// setWelcomeStatus sets the outcome of welcome as the commit status of the
+// head of merge request, so that the sig routing is visible in the widget
+// of merge request and other automation can gate on it.
+func (bot *robot) setWelcomeStatus(pid, number int, plan *ActionPlan, report *stepReport, cfg *botConfig, log *logrus.Entry) {
+	c := &cfg.CommitStatus
+	if !c.Enabled || number <= 0 {
+		return
+	}
+
+	state := gitlab.Success
+	if report.err() != nil {
+		state = gitlab.Failed
+	}
+
+	desc := welcomeStatusDescription(plan, report)
+
+	report.run(stepCommitStatus, "", false, &cfg.StepRetry, func() error {
+		mr, err := bot.cli.GetMergeRequest(pid, number)
+		if err != nil {
+			return err
+		}
+
+		return bot.cli.SetCommitStatus(pid, mr.SHA, &gitlab.SetCommitStatusOptions{
+			State:       state,
+			Name:        gitlab.String(c.Name),
+			Description: gitlab.String(desc),
+		})
+	}, log)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// composeRequest is the input of composing a welcome plan.
+type composeRequest struct {
+	Org    string `json:"org"`
+	Repo   string `json:"repo"`
+	Author string `json:"author"`
+
+	// ProjectID is resolved from org/repo if not set.
+	ProjectID int `json:"project_id,omitempty"`
+
+	// Number is the number of merge request. The steps specific to the
+	// merge request, such as the newcomer check, are skipped if not set.
+	Number int `json:"number,omitempty"`
+
+	// Changes are the files changed by the merge request. They are fetched
+	// by Number if not set.
+	Changes []string `json:"changes,omitempty"`
+}
+
+func (req *composeRequest) validate() error {
+	if req.Org == "" || req.Repo == "" || req.Author == "" {
+		return fmt.Errorf("org, repo and author must be set")
+	}
+
+	return nil
+}
+
+// composeHandler returns the welcome plan the robot would apply for the
+// request, without applying it. It lets the other services, such as the
+// onboarding UI, reuse the composition of welcome.
+func (bot *robot) composeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	var req composeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	log := logrus.WithFields(logrus.Fields{
+		"compose": fmt.Sprintf("%s/%s", req.Org, req.Repo),
+		"author":  req.Author,
+	})
+
+	plan, err := bot.compose(&req, log)
+	if err != nil {
+		log.WithError(err).Error("compose welcome plan")
+
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	if plan == nil {
+		http.Error(w, "the repo is not configured", http.StatusNotFound)
+
+		return
+	}
+
+	writeJSON(w, plan)
+}
+
+func (bot *robot) compose(req *composeRequest, log *logrus.Entry) (*ActionPlan, error) {
+	cfg, err := bot.botConfigFor(req.Org, req.Repo, log)
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+
+	pid := req.ProjectID
+	if pid == 0 {
+		if pid, err = bot.cli.GetProjectID(fmt.Sprintf("%s/%s", req.Org, req.Repo)); err != nil {
+			return nil, err
+		}
+	}
+
+	var meta *itemMeta
+	if req.Number > 0 {
+		if meta, err = bot.getMRMeta(pid, req.Number); err != nil {
+			return nil, err
+		}
+	}
+
+	return bot.genPlan(req.Org, req.Repo, req.Author, req.Number, pid, req.Changes, meta, cfg, log)
+}
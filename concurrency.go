@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	projectQueueDepth = newGaugeVec(
+		"welcome_project_queue_depth",
+		"Number of the events of project waiting for a slot of the concurrency limit.",
+		"project",
+	)
+
+	projectInFlight = newGaugeVec(
+		"welcome_project_in_flight",
+		"Number of the events of project being processed.",
+		"project",
+	)
+)
+
+// projectConcurrencyLimits is the flag of the concurrency limits by project,
+// such as openeuler/kernel=8. It can be repeated.
+type projectConcurrencyLimits map[string]int
+
+func (p *projectConcurrencyLimits) String() string {
+	v := make([]string, 0, len(*p))
+	for project, n := range *p {
+		v = append(v, fmt.Sprintf("%s=%d", project, n))
+	}
+
+	return strings.Join(v, ",")
+}
+
+func (p *projectConcurrencyLimits) Set(s string) error {
+	i := strings.LastIndex(s, "=")
+	if i <= 0 {
+		return fmt.Errorf("invalid project concurrency limit: %s, it should be like org/repo=n", s)
+	}
+
+	n, err := strconv.Atoi(s[i+1:])
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid project concurrency limit: %s, it should be like org/repo=n", s)
+	}
+
+	if *p == nil {
+		*p = make(projectConcurrencyLimits)
+	}
+
+	(*p)[s[:i]] = n
+
+	return nil
+}
+
+// projectLimiter caps the events of each project processed at the same time,
+// so that a massively active project can't starve the others sharing the
+// robot. The events beyond the limit wait in the queue of project.
+type projectLimiter struct {
+	max    int
+	limits projectConcurrencyLimits
+
+	lock  sync.Mutex
+	slots map[string]chan struct{}
+}
+
+func newProjectLimiter(max int, limits projectConcurrencyLimits) *projectLimiter {
+	return &projectLimiter{
+		max:    max,
+		limits: limits,
+		slots:  make(map[string]chan struct{}),
+	}
+}
+
+// limitOf returns the limit of project, 0 means unlimited.
+func (l *projectLimiter) limitOf(project string) int {
+	if n, ok := l.limits[project]; ok {
+		return n
+	}
+
+	return l.max
+}
+
+func (l *projectLimiter) slotsOf(project string) chan struct{} {
+	n := l.limitOf(project)
+	if n <= 0 {
+		return nil
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	s, ok := l.slots[project]
+	if !ok {
+		s = make(chan struct{}, n)
+		l.slots[project] = s
+	}
+
+	return s
+}
+
+// acquire waits for a slot of project and returns the function to release
+// it, which must be called once the event is processed.
+func (l *projectLimiter) acquire(project string) func() {
+	slots := l.slotsOf(project)
+	if slots == nil {
+		return func() {}
+	}
+
+	projectQueueDepth.add(1, project)
+	slots <- struct{}{}
+	projectQueueDepth.add(-1, project)
+
+	projectInFlight.add(1, project)
+
+	return func() {
+		projectInFlight.add(-1, project)
+		<-slots
+	}
+}
@@ -2,16 +2,37 @@ package main
 
 import (
 	"fmt"
+	"path"
+	"strings"
+	"time"
+
 	"github.com/opensourceways/community-robot-lib/config"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 type configuration struct {
 	ConfigItems []botConfig `json:"config_items,omitempty"`
+
+	// Default is the config of the repos which match none of ConfigItems.
+	// Its repos and excluded_repos are ignored.
+	Default *botConfig `json:"default,omitempty"`
+
+	// secretsErr is the error of decrypting the encrypted secrets, which
+	// is reported by Validate.
+	secretsErr error
 }
 
 func (c *configuration) configFor(org, repo string) *botConfig {
+	cfg, _ := c.resolve(org, repo)
+
+	return cfg
+}
+
+// resolve returns the config of org/repo and where it comes from, which is
+// like config_items[0] or default.
+func (c *configuration) resolve(org, repo string) (*botConfig, string) {
 	if c == nil {
-		return nil
+		return nil, ""
 	}
 
 	items := c.ConfigItems
@@ -21,10 +42,14 @@ func (c *configuration) configFor(org, repo string) *botConfig {
 	}
 
 	if i := config.Find(org, repo, v); i >= 0 {
-		return &items[i]
+		return &items[i], fmt.Sprintf("config_items[%d]", i)
 	}
 
-	return nil
+	if c.Default != nil {
+		return c.Default, "default"
+	}
+
+	return nil, ""
 }
 
 func (c *configuration) Validate() error {
@@ -32,6 +57,10 @@ func (c *configuration) Validate() error {
 		return nil
 	}
 
+	if c.secretsErr != nil {
+		return c.secretsErr
+	}
+
 	items := c.ConfigItems
 	for i := range items {
 		if err := items[i].validate(); err != nil {
@@ -39,6 +68,10 @@ func (c *configuration) Validate() error {
 		}
 	}
 
+	if c.Default != nil {
+		return c.Default.validateSettings()
+	}
+
 	return nil
 }
 
@@ -47,10 +80,16 @@ func (c *configuration) SetDefault() {
 		return
 	}
 
+	c.secretsErr = c.decryptSecrets()
+
 	Items := c.ConfigItems
 	for i := range Items {
 		Items[i].setDefault()
 	}
+
+	if c.Default != nil {
+		c.Default.setDefault()
+	}
 }
 
 type botConfig struct {
@@ -59,7 +98,18 @@ type botConfig struct {
 	CommunityName string `json:"community_name" required:"true"`
 
 	// CommandLink is the link to command help document page.
-	CommandLink string `json:"command_link" required:"true"`
+	// Deprecated: use the commands of Links instead.
+	CommandLink string `json:"command_link,omitempty"`
+
+	// Links are the links of community available to the templates as
+	// .Links, such as the contribution guide and the code of conduct. The
+	// one named commands, which is the link to command help document page,
+	// is required.
+	Links map[string]string `json:"links,omitempty"`
+
+	// CheckLinks decides whether to check that the links are reachable by
+	// HTTP HEAD once an hour. The unreachable ones are logged as warnings.
+	CheckLinks bool `json:"check_links,omitempty"`
 
 	// CommunityRepo is used to read file path
 	CommunityRepo string `json:"community_repo" required:"true"`
@@ -73,29 +123,362 @@ type botConfig struct {
 	// FileBranch is used to located FilePath
 	FileBranch string `json:"file_branch,omitempty"`
 
+	// RelationSources are the path-owner-map files of the changed files
+	// under the directory prefixes, so that large monorepos can
+	// decentralize the routing. The one with the longest matching prefix
+	// takes precedence, and FilePath is used if none matches.
+	RelationSources []relationSource `json:"relation_sources,omitempty"`
+
+	// SigPaths map the directory prefixes of monorepos to the sigs owning
+	// them. The sigs of merge requests are inferred from the changed files
+	// instead of the repo, and all of them are labeled when the changes
+	// span several sigs, while the one owning the most files is welcomed
+	// by. The sig of repo is used if none matches.
+	SigPaths []sigPath `json:"sig_paths,omitempty"`
+
+	// AliasesFile is the repo-local file in the format of OWNERS_ALIASES,
+	// read from FileBranch. The owners in the path-owner-map files which
+	// are aliases are replaced with the members of them.
+	AliasesFile string `json:"aliases_file,omitempty"`
+
+	// OwnersAliasesFile is the file in the format of OWNERS_ALIASES, such as
+	// OWNERS_ALIASES, read along with the sig files. The aliases used in the
+	// OWNERS, sig-info and relation files are replaced with the members of
+	// them, so that the teams maintain their members in one place.
+	OwnersAliasesFile string `json:"owners_aliases_file,omitempty"`
+
+	// OwnershipOnly welcomes without the sigs, for the communities which
+	// are not organized by sig. The owners of the changed files in the
+	// path-owner-map files are mentioned, and the labels of the matched
+	// entries are added.
+	OwnershipOnly bool `json:"ownership_only,omitempty"`
+
+	// SpecialContact limits routing the merge requests to the owners in
+	// FilePath, which is slow and over-mentions for the giant ones.
+	SpecialContact specialContactConfig `json:"special_contact,omitempty"`
+
+	// TargetBranches are the patterns of target branches, such as main and
+	// release/*, of the merge requests to welcome. All the merge requests
+	// are welcomed if it is empty.
+	TargetBranches []string `json:"target_branches,omitempty"`
+
+	// SoleMaintainer configures the welcome of the author who is the only
+	// maintainer or committer found.
+	SoleMaintainer soleMaintainerConfig `json:"sole_maintainer,omitempty"`
+
+	// MaintainerRoles are the roles of the project members regarded as the
+	// maintainers when the sig files are not available, such as developer,
+	// maintainer and owner, which are the default value. The custom roles
+	// of GitLab 16 or later are matched by name.
+	MaintainerRoles []string `json:"maintainer_roles,omitempty"`
+
 	// NeedAssign decides assign maintainers to PR or not
 	NeedAssign bool `json:"need_assign,omitempty"`
 
+	// Handoff configures welcoming the human who takes over the issue
+	// opened by a bot.
+	Handoff handoffConfig `json:"handoff,omitempty"`
+
+	// Throttle caps the welcomes posted to a project in an hour.
+	Throttle throttleConfig `json:"throttle,omitempty"`
+
+	// SigFile configures the file of sig in the community repo, which can
+	// tune the welcomes of the repos of sig.
+	SigFile sigFileConfig `json:"sig_file,omitempty"`
+
+	// SigSource configures where the sig of repo and the members of sig
+	// are read from.
+	SigSource sigSourceConfig `json:"sig_source,omitempty"`
+
+	// Directory configures looking up the members of sig in the directory
+	// of the enterprise, such as their usernames and availability.
+	Directory directoryConfig `json:"directory,omitempty"`
+
+	// SigDirective configures setting the sig by the /sig directive.
+	SigDirective sigDirectiveConfig `json:"sig_directive,omitempty"`
+
+	// WelcomeInfo configures the /welcome-info command.
+	WelcomeInfo welcomeInfoConfig `json:"welcome_info,omitempty"`
+
+	// Reaction configures the award emoji added to the new merge requests
+	// and issues.
+	Reaction reactionConfig `json:"reaction,omitempty"`
+
+	// Assignment configures the policy of the assignee and reviewers of
+	// the merge request, which replaces need_assign.
+	Assignment assignmentConfig `json:"assignment,omitempty"`
+
+	// WelcomeTemplate is the go template of welcome comment. The template
+	// of catalog is used if it is empty. Besides the builtin functions, it
+	// can use join, truncateList, mention and pluralize. The metadata of the
+	// merge request or issue is available as .TargetBranch, .Labels,
+	// .Milestone, .ChangedFiles, .IssueType and .IsMergeRequest.
+	WelcomeTemplate string `json:"welcome_template,omitempty"`
+
+	// Templates are the go templates of welcome comment by event type,
+	// merge_request or issue, which take precedence over welcome_template.
+	Templates map[string]string `json:"templates,omitempty"`
+
+	// Variables are the variables by event type, which are available to
+	// the templates as .Vars, such as .Vars.thanks. They are merged with
+	// the builtin ones.
+	Variables map[string]map[string]string `json:"variables,omitempty"`
+
+	// Language is the language of the welcome template of catalog, such as
+	// en and zh. The default value is en.
+	Language string `json:"language,omitempty"`
+
+	// Tone is the tone of the welcome template of catalog, casual or
+	// formal. The default value is casual.
+	Tone string `json:"tone,omitempty"`
+
+	// Catalog are the welcome templates by language and tone, which take
+	// precedence over the builtin ones.
+	Catalog map[string]map[string]string `json:"catalog,omitempty"`
+
+	// Sections are the sections of welcome comment in order, which are the
+	// builtin welcome, ladder, mr_template, quick_links, commands,
+	// review_sla and faq by default. Custom sections can be added by their
+	// templates.
+	Sections []sectionConfig `json:"sections,omitempty"`
+
+	// QuickLinks means to append the links of sig, such as mailing list and
+	// meeting, to the welcome comment
+	QuickLinks bool `json:"quick_links,omitempty"`
+
+	// FAQ answers the questions of the new issues which match its rules in
+	// the welcome comment.
+	FAQ faqConfig `json:"faq,omitempty"`
+
+	// MemberAuthor decides how to welcome the author who is a maintainer or
+	// committer of the sig. It can be skip which means no comment at all,
+	// minimal which means a short comment without mentions, or empty which
+	// means to welcome as usual.
+	MemberAuthor string `json:"member_author,omitempty"`
+
+	// DryRun means to only log the action plan of an event without executing it
+	DryRun bool `json:"dry_run,omitempty"`
+
 	// WelcomeSimpler means to make the welcome message simpler when PR is opened
 	WelcomeSimpler bool `json:"welcome_simpler,omitempty"`
 
 	// Path is used to read file path
 	Path string `json:"path" required:"true"`
 
-	// reposSig is used to cache information
-	reposSig map[string]string
+	// Cooldown is the seconds after welcoming an author, during which the
+	// author is not welcomed again and only the labels are added. It is
+	// disabled if not set.
+	Cooldown int `json:"cooldown,omitempty"`
+
+	// MaintainersTimeout is the seconds to wait for each of the calls to
+	// resolve the maintainers of sig. The default value is 10.
+	MaintainersTimeout int `json:"maintainers_timeout,omitempty"`
+
+	// Mention configures how the users and groups are mentioned
+	Mention mentionConfig `json:"mention,omitempty"`
+
+	// Labels are the labels added besides the newcomer and sig ones.
+	Labels []string `json:"labels,omitempty"`
+
+	// RepoFile configures the file in the repo itself which can disable or
+	// tune the welcome of repo.
+	RepoFile repoFileConfig `json:"repo_file,omitempty"`
+
+	// CommitStatus configures setting the outcome of welcome as the commit
+	// status of the head of merge request.
+	CommitStatus commitStatusConfig `json:"commit_status,omitempty"`
+
+	// MRTemplate configures asking for the description of merge request
+	// which keeps the placeholders of template.
+	MRTemplate mrTemplateConfig `json:"mr_template,omitempty"`
+
+	// Moderation configures the handling of the merge requests and issues
+	// of the blocked or banned authors.
+	Moderation moderationConfig `json:"moderation,omitempty"`
+
+	// ExemptLabels are the labels, such as no-welcome, with which the merge
+	// requests and issues created are not welcomed, and ExemptSigLabels
+	// means to still add the sig labels to them.
+	ExemptLabels    []string `json:"exempt_labels,omitempty"`
+	ExemptSigLabels bool     `json:"exempt_sig_labels,omitempty"`
+
+	// Imported configures the handling of the merge requests and issues
+	// created by the migration tools, which are not welcomed as usual.
+	Imported importedConfig `json:"imported,omitempty"`
+
+	// Conversion configures checking whether the newcomers welcomed open
+	// further merge requests.
+	Conversion conversionConfig `json:"conversion,omitempty"`
+
+	// Bootstrap configures setting up the projects created, which are told
+	// by the system hooks of GitLab.
+	Bootstrap bootstrapConfig `json:"bootstrap,omitempty"`
+
+	// Mentorship configures opening the issue to follow up the onboarding
+	// of each newcomer.
+	Mentorship mentorshipConfig `json:"mentorship,omitempty"`
+
+	// ReviewSLA configures telling the contributors how soon the
+	// maintainers of sig usually respond.
+	ReviewSLA reviewSLAConfig `json:"review_sla,omitempty"`
+
+	// CommandPalette configures listing the commands of the robots of the
+	// community in the welcome.
+	CommandPalette commandPaletteConfig `json:"command_palette,omitempty"`
+
+	// Engagement configures tracking whether the contributors react to or
+	// reply to the welcomes.
+	Engagement engagementConfig `json:"engagement,omitempty"`
+
+	// Fairness configures spreading the mentions among the maintainers and
+	// committers.
+	Fairness fairnessConfig `json:"fairness,omitempty"`
+
+	// Roles configures the roles of sig-info besides maintainers and
+	// committers to show in the welcome comment
+	Roles rolesConfig `json:"roles,omitempty"`
+
+	// Placement configures where the welcome comment appears
+	Placement placementConfig `json:"placement,omitempty"`
+
+	// CommentRetry configures the retries of posting the welcome comment
+	CommentRetry commentRetryConfig `json:"comment_retry,omitempty"`
+
+	// StepRetry configures the retries of the steps other than the
+	// welcome comment, such as assigning and labeling.
+	StepRetry stepRetryConfig `json:"step_retry,omitempty"`
+
+	// SigLabel configures the label of sig
+	SigLabel sigLabelConfig `json:"sig_label,omitempty"`
+
+	// LabelTaxonomy declares the colors, descriptions and renames of the
+	// labels managed by the robot, which are applied by the labels sync
+	// command.
+	LabelTaxonomy labelTaxonomyConfig `json:"label_taxonomy,omitempty"`
+
+	// Triage configures assigning the issues to the triage rotation of sig
+	Triage triageConfig `json:"triage,omitempty"`
+
+	// StaleCleanup configures cleaning up the welcomes of the merge requests
+	// and issues closed without merge for a long time
+	StaleCleanup staleCleanupConfig `json:"stale_cleanup,omitempty"`
+
+	// PipelineHelp configures the help on the first failed pipeline of the
+	// merge requests of newcomers
+	PipelineHelp pipelineHelpConfig `json:"pipeline_help,omitempty"`
+
+	// Wiki configures the guidance for the wiki pages created
+	Wiki wikiConfig `json:"wiki,omitempty"`
+
+	// Epic configures the guidance for the epics opened in the group
+	Epic epicConfig `json:"epic,omitempty"`
+
+	// ErrorBudget configures the error budget of GitLab API calls per project
+	ErrorBudget errorBudgetConfig `json:"error_budget,omitempty"`
+
+	// Digest configures the periodic digest of the contributors welcomed
+	// by sig, so that the sig leads can follow up with them.
+	Digest digestConfig `json:"digest,omitempty"`
+
+	// LateMentions configures editing the welcome posted without the
+	// mentions once the sig and its maintainers can be resolved.
+	LateMentions lateMentionsConfig `json:"late_mentions,omitempty"`
+
+	// Alert configures alerting the operators when a project fails to
+	// welcome repeatedly.
+	Alert alertConfig `json:"alert,omitempty"`
+
+	// Ladder are the steps of contributor ladder. The message of the step
+	// whose threshold the contributions of author reach is appended to the
+	// welcome comment, such as the onboarding for the first-timers and the
+	// nudge about applying for committer for the regular contributors.
+	Ladder []ladderStep `json:"ladder,omitempty"`
+
+	// Newcomer configures how to detect whether the author is a newcomer
+	Newcomer newcomerConfig `json:"newcomer,omitempty"`
+
+	// secretPaths are the paths of the fields decrypted, such as
+	// alert.url, which are redacted when the config is shown.
+	secretPaths []string
 }
 
 func (c *botConfig) setDefault() {
+	if c.MaintainersTimeout <= 0 {
+		c.MaintainersTimeout = 10
+	}
+
+	if c.CommandLink != "" && c.commandLink() == "" {
+		if c.Links == nil {
+			c.Links = make(map[string]string)
+		}
+
+		c.Links[linkCommands] = c.CommandLink
+	}
+
+	if c.Language == "" {
+		c.Language = defaultLanguage
+	}
+
+	if c.Tone == "" {
+		c.Tone = toneCasual
+	}
+
+	c.Mention.setDefault()
+	c.Placement.setDefault()
+	c.CommentRetry.setDefault()
+	c.Fairness.setDefault()
+	c.Conversion.setDefault()
+	c.Engagement.setDefault()
+	c.Moderation.setDefault()
+	c.Imported.setDefault()
+	c.MRTemplate.setDefault()
+	c.CommitStatus.setDefault()
+	c.RepoFile.setDefault()
+	c.StepRetry.setDefault()
+	c.SigLabel.setDefault()
+	c.StaleCleanup.setDefault()
+	c.ErrorBudget.setDefault()
+	c.Alert.setDefault()
+	c.FAQ.setDefault()
+	c.Reaction.setDefault()
+	c.Handoff.setDefault()
+	c.Throttle.setDefault()
+	c.SigFile.setDefault()
+	c.SigSource.setDefault()
+	c.Directory.setDefault()
+	c.ReviewSLA.setDefault()
+	c.Mentorship.setDefault()
+	c.CommandPalette.setDefault()
+
+	// the defaults are copied, so that they can't be changed through the
+	// config.
+	if len(c.Sections) == 0 {
+		c.Sections = append([]sectionConfig(nil), defaultSections...)
+	}
+
+	if len(c.MaintainerRoles) == 0 {
+		c.MaintainerRoles = append([]string(nil), defaultMaintainerRoles...)
+	}
+	c.LateMentions.setDefault()
+	c.Digest.setDefault()
+	c.Newcomer.setDefault()
 }
 
 func (c *botConfig) validate() error {
+	if err := c.validateSettings(); err != nil {
+		return err
+	}
+
+	return c.RepoFilter.Validate()
+}
+
+func (c *botConfig) validateSettings() error {
 	if c.CommunityName == "" {
 		return fmt.Errorf("the community_name configuration can not be empty")
 	}
 
-	if c.CommandLink == "" {
-		return fmt.Errorf("the command_link configuration can not be empty")
+	if c.commandLink() == "" {
+		return fmt.Errorf("the commands of links configuration can not be empty")
 	}
 
 	if c.CommunityRepo == "" {
@@ -106,5 +489,1012 @@ func (c *botConfig) validate() error {
 		return fmt.Errorf("the branch configuration can not be empty")
 	}
 
-	return c.RepoFilter.Validate()
+	if c.WelcomeTemplate != "" {
+		if _, err := parseTemplate(c.WelcomeTemplate); err != nil {
+			return fmt.Errorf("invalid welcome_template: %v", err)
+		}
+	}
+
+	if err := c.validateCatalog(); err != nil {
+		return err
+	}
+
+	if c.OwnershipOnly && c.FilePath == "" && len(c.RelationSources) == 0 {
+		return fmt.Errorf("file_path or relation_sources must be set when ownership_only is set")
+	}
+
+	if c.Cooldown < 0 || time.Duration(c.Cooldown)*time.Second > welcomeHistoryTTL {
+		return fmt.Errorf("cooldown must be between 0 and %v", welcomeHistoryTTL)
+	}
+
+	for _, v := range c.TargetBranches {
+		if _, err := path.Match(v, ""); err != nil {
+			return fmt.Errorf("invalid target branch pattern: %s", v)
+		}
+	}
+
+	if v := c.MemberAuthor; v != "" && v != memberAuthorSkip && v != memberAuthorMinimal {
+		return fmt.Errorf("unsupported member_author: %s", v)
+	}
+
+	if err := c.Placement.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Mention.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Roles.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Moderation.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Imported.validate(); err != nil {
+		return err
+	}
+
+	if err := c.MRTemplate.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Fairness.validate(); err != nil {
+		return err
+	}
+
+	if err := c.FAQ.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Engagement.validate(); err != nil {
+		return err
+	}
+
+	for i := range c.SigPaths {
+		if err := c.SigPaths[i].validate(); err != nil {
+			return err
+		}
+	}
+
+	for i := range c.RelationSources {
+		if err := c.RelationSources[i].validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := c.SpecialContact.validate(); err != nil {
+		return err
+	}
+
+	if err := c.CommentRetry.validate(); err != nil {
+		return err
+	}
+
+	if err := c.StepRetry.validate(); err != nil {
+		return err
+	}
+
+	if err := c.SigLabel.validate(); err != nil {
+		return err
+	}
+
+	if err := c.LabelTaxonomy.validate(); err != nil {
+		return err
+	}
+
+	if err := c.StaleCleanup.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Alert.validate(); err != nil {
+		return err
+	}
+
+	if err := c.SoleMaintainer.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Handoff.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Bootstrap.validate(); err != nil {
+		return err
+	}
+
+	if err := c.SigSource.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Directory.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Mentorship.validate(); err != nil {
+		return err
+	}
+
+	if err := c.ReviewSLA.validate(); err != nil {
+		return err
+	}
+
+	if err := c.CommandPalette.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Throttle.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Reaction.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Assignment.validate(); err != nil {
+		return err
+	}
+
+	if c.NeedAssign && c.Assignment.Policy != "" {
+		return fmt.Errorf("need_assign and the policy of assignment can't be set at the same time")
+	}
+
+	if err := c.Digest.validate(); err != nil {
+		return err
+	}
+
+	for i := range c.Sections {
+		if err := c.Sections[i].validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := c.validateEventTemplates(); err != nil {
+		return err
+	}
+
+	templates := []string{
+		c.welcomeTemplate(objectKindMergeRequest), c.welcomeTemplate(objectKindIssue),
+	}
+	for i := range c.Ladder {
+		if err := c.Ladder[i].validate(); err != nil {
+			return err
+		}
+
+		templates = append(templates, c.Ladder[i].Message)
+	}
+
+	for i := range c.Sections {
+		if v := c.Sections[i].Template; v != "" {
+			templates = append(templates, v)
+		}
+	}
+
+	if err := checkLinkRefs(c.Links, templates...); err != nil {
+		return err
+	}
+
+	if c.Wiki.Enabled && c.Wiki.Issue <= 0 {
+		return fmt.Errorf("the wiki issue must be set when wiki is enabled")
+	}
+
+	return c.Newcomer.validate()
+}
+
+func (c *botConfig) isTargetBranch(branch string) bool {
+	if len(c.TargetBranches) == 0 {
+		return true
+	}
+
+	for _, v := range c.TargetBranches {
+		if ok, _ := path.Match(v, branch); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+type ladderStep struct {
+	// MinContributions is the threshold of the contributions of author
+	// before the merge request.
+	MinContributions int `json:"min_contributions"`
+
+	// Message is the go template of the message, which can use .Author,
+	// .Sig, .Contributions and .Links.
+	Message string `json:"message" required:"true"`
+}
+
+func (s *ladderStep) validate() error {
+	if s.MinContributions < 0 {
+		return fmt.Errorf("min_contributions of ladder can not be negative")
+	}
+
+	if _, err := parseTemplate(s.Message); err != nil {
+		return fmt.Errorf("invalid message of ladder: %v", err)
+	}
+
+	return nil
+}
+
+type newcomerConfig struct {
+	// Source is where to query the contributions of author, ipb or gitlab.
+	// The default value is ipb.
+	Source string `json:"source,omitempty"`
+
+	// Group is the GitLab group to search merge requests of author in
+	// when Source is gitlab. It is the org of repo if not set.
+	Group string `json:"group,omitempty"`
+
+	// CacheTTL is the seconds to cache the contributions of author.
+	// The default value is 3600.
+	CacheTTL int `json:"cache_ttl,omitempty"`
+
+	// MergeRequests decides whether to label the merge requests of
+	// newcomers. The default value is true.
+	MergeRequests *bool `json:"merge_requests,omitempty"`
+
+	// Issues decides whether to label the issues of newcomers.
+	Issues bool `json:"issues,omitempty"`
+
+	// LabelUntilMerged is the number of merged merge requests in Group,
+	// before which the author is still labeled as newcomer, so that the
+	// reviewers keep prioritizing them. Only the first contribution is
+	// labeled if it is 0.
+	LabelUntilMerged int `json:"label_until_merged,omitempty"`
+
+	// RemoveLabel means to remove the newcomer label from the open merge
+	// requests and issues of author in the project once a merge request
+	// makes the author cross LabelUntilMerged.
+	RemoveLabel bool `json:"remove_label,omitempty"`
+}
+
+func (c *newcomerConfig) setDefault() {
+
+	if c.Source == "" {
+		c.Source = newcomerSourceIPB
+	}
+
+	if c.CacheTTL <= 0 {
+		c.CacheTTL = 3600
+	}
+}
+
+func (c *newcomerConfig) validate() error {
+	if c.Source != "" && c.Source != newcomerSourceIPB && c.Source != newcomerSourceGitlab {
+		return fmt.Errorf("unsupported newcomer source: %s", c.Source)
+	}
+
+	if c.LabelUntilMerged < 0 {
+		return fmt.Errorf("label_until_merged of newcomer can't be negative")
+	}
+
+	return nil
+}
+
+type sigLabelConfig struct {
+	// Namespace is the namespace of sig label, such as sig of sig/storage.
+	// The default value is sig.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Scoped means to use scoped label such as sig::storage which are
+	// mutually exclusive. Set it only if the license of the GitLab instance
+	// has scoped labels, which can't be told by its version. Otherwise, the
+	// labels such as sig::storage are plain ones and not exclusive.
+	Scoped bool `json:"scoped,omitempty"`
+
+	// Prefix is the whole prefix of sig label, such as SIG- of SIG-storage
+	// or area/ of area/storage. It overrides Namespace and Scoped if set.
+	Prefix string `json:"prefix,omitempty"`
+
+	// LegacyPrefixes are the prefixes of sig label used before, such as
+	// sig/. The labels of them are still recognized as the sig labels, so
+	// that the merge requests and issues labeled before are not labeled
+	// again. They are renamed to the current prefix by the labels sync if
+	// MigrateLegacy is true.
+	LegacyPrefixes []string `json:"legacy_prefixes,omitempty"`
+	MigrateLegacy  bool     `json:"migrate_legacy,omitempty"`
+
+	// Mapping maps the sig name to the part of label after the namespace.
+	// The sig names which are not in it are normalized, such as the latin
+	// letters with diacritics being transliterated and the spaces and
+	// separators being replaced with -.
+	Mapping map[string]string `json:"mapping,omitempty"`
+
+	// MaxLength is the max length of sig label. The longer ones are cut
+	// with a hash appended. The default value is 255, which is the max
+	// length GitLab accepts.
+	MaxLength int `json:"max_length,omitempty"`
+}
+
+func (c *sigLabelConfig) setDefault() {
+	if c.Namespace == "" {
+		c.Namespace = defaultSigLabelNamespace
+	}
+
+	if c.MaxLength <= 0 || c.MaxLength > maxLabelLength {
+		c.MaxLength = maxLabelLength
+	}
+}
+
+func (c *sigLabelConfig) validate() error {
+	if normalizeLabelPart(c.Namespace) != c.Namespace {
+		return fmt.Errorf("invalid sig label namespace: %s", c.Namespace)
+	}
+
+	if c.MaxLength > 0 && c.MaxLength < 16 {
+		return fmt.Errorf("sig label max_length can not be less than 16")
+	}
+
+	for _, p := range append([]string{c.Prefix}, c.LegacyPrefixes...) {
+		if strings.TrimSpace(p) != p || strings.ContainsAny(p, ",\"\n") {
+			return fmt.Errorf("invalid sig label prefix: %q", p)
+		}
+	}
+
+	for _, p := range c.LegacyPrefixes {
+		if p == "" || containsString(c.prefixes(), p) {
+			return fmt.Errorf("invalid legacy sig label prefix: %q", p)
+		}
+	}
+
+	seen := make(map[string]string, len(c.Mapping))
+	for k, v := range c.Mapping {
+		if v == "" || normalizeLabelPart(v) != v {
+			return fmt.Errorf("invalid sig label mapping of %s: %s", k, v)
+		}
+
+		// the sig must be able to be looked up by the label.
+		if s, ok := seen[v]; ok {
+			return fmt.Errorf("sig %s and %s are mapped to the same label: %s", s, k, v)
+		}
+		seen[v] = k
+	}
+
+	return nil
+}
+
+type placementConfig struct {
+	// Mode is where the welcome comment appears. It can be note which is
+	// a new note, first which is a new note posted before any other action
+	// so that it precedes the system notes generated by them, summary
+	// which is appended to the summary note shared by robots, or card which
+	// is a section of the structured summary card shared by robots.
+	// The default value is note.
+	Mode string `json:"mode,omitempty"`
+
+	// SummaryMarker is the hidden text identifying the summary note.
+	// The default value is <!-- robot-summary -->.
+	SummaryMarker string `json:"summary_marker,omitempty"`
+
+	// Delay is the seconds to wait before commenting. It makes the welcome
+	// comment come after the ones of other robots reacting to the same event.
+	Delay int `json:"delay,omitempty"`
+}
+
+func (c *placementConfig) setDefault() {
+	if c.Mode == "" {
+		c.Mode = placementNote
+	}
+
+	if c.SummaryMarker == "" {
+		c.SummaryMarker = "<!-- robot-summary -->"
+	}
+}
+
+func (c *placementConfig) validate() error {
+	switch c.Mode {
+	case "", placementNote, placementFirst, placementSummary, placementCard:
+	default:
+		return fmt.Errorf("unsupported placement mode: %s", c.Mode)
+	}
+
+	if c.Delay < 0 {
+		return fmt.Errorf("placement delay can not be negative")
+	}
+
+	return nil
+}
+
+const (
+	overflowSkip  = "skip"
+	overflowGroup = "group"
+)
+
+type relationSource struct {
+	// Prefix is the directory prefix of the changed files, such as
+	// drivers/net/.
+	Prefix string `json:"prefix" required:"true"`
+
+	// FilePath is the path-owner-map file path
+	FilePath string `json:"file_path" required:"true"`
+
+	// Branch is used to located FilePath
+	Branch string `json:"branch" required:"true"`
+}
+
+func (s *relationSource) validate() error {
+	if s.Prefix == "" || s.FilePath == "" || s.Branch == "" {
+		return fmt.Errorf("prefix, file_path and branch of relation_sources must be set")
+	}
+
+	return nil
+}
+
+type specialContactConfig struct {
+	// MaxFiles is the max number of changed files to look up the owners
+	// for. It is unlimited if not set.
+	MaxFiles int `json:"max_files,omitempty"`
+
+	// MaxOwners is the max number of owners to mention. It is unlimited if
+	// not set.
+	MaxOwners int `json:"max_owners,omitempty"`
+
+	// Overflow is what to do when either limit is exceeded. It is skip,
+	// which routes the merge request as if there were no owners, or group,
+	// which mentions Group instead. The default value is skip.
+	Overflow string `json:"overflow,omitempty"`
+
+	// Group is the catch-all GitLab group to mention on overflow.
+	Group string `json:"group,omitempty"`
+}
+
+func (c *specialContactConfig) validate() error {
+	if c.MaxFiles < 0 || c.MaxOwners < 0 {
+		return fmt.Errorf("the limits of special_contact can not be negative")
+	}
+
+	switch c.Overflow {
+	case "", overflowSkip:
+	case overflowGroup:
+		if c.Group == "" {
+			return fmt.Errorf("special_contact group must be set for the overflow of group")
+		}
+	default:
+		return fmt.Errorf("unsupported overflow of special_contact: %s", c.Overflow)
+	}
+
+	return nil
+}
+
+// overflow returns the contacts when the limits are exceeded.
+func (c *specialContactConfig) overflow() sets.String {
+	if c.Overflow == overflowGroup {
+		return sets.NewString(c.Group)
+	}
+
+	return nil
+}
+
+type repoFileConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Path is the path of file in the repo. The default value is
+	// .welcome-bot.yaml.
+	Path string `json:"path,omitempty"`
+
+	// Branch is the branch to read the file from. The default value is
+	// master.
+	Branch string `json:"branch,omitempty"`
+}
+
+func (c *repoFileConfig) setDefault() {
+	if c.Path == "" {
+		c.Path = defaultRepoFilePath
+	}
+
+	if c.Branch == "" {
+		c.Branch = sigFileBranch
+	}
+}
+
+type commitStatusConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Name is the name of commit status. The default value is
+	// community/welcome.
+	Name string `json:"name,omitempty"`
+}
+
+func (c *commitStatusConfig) setDefault() {
+	if c.Name == "" {
+		c.Name = defaultCommitStatusName
+	}
+}
+
+type mrTemplateConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Markers are the placeholders of the merge request template, such as
+	// "<!-- describe your change -->". The description keeping any of
+	// them, or empty, is regarded as unfilled.
+	Markers []string `json:"markers,omitempty"`
+
+	// Label is added to the merge request whose description is unfilled,
+	// and removed once it is filled in. The default value is
+	// needs-description.
+	Label string `json:"label,omitempty"`
+
+	// Message is the polite ask appended to the welcome comment.
+	Message string `json:"message,omitempty"`
+}
+
+func (c *mrTemplateConfig) setDefault() {
+	if c.Label == "" {
+		c.Label = defaultNeedsDescriptionLabel
+	}
+
+	if c.Message == "" {
+		c.Message = defaultDescriptionAsk
+	}
+}
+
+func (c *mrTemplateConfig) validate() error {
+	for _, m := range c.Markers {
+		if strings.TrimSpace(m) == "" {
+			return fmt.Errorf("the markers of mr_template can not be blank")
+		}
+	}
+
+	return nil
+}
+
+type moderationConfig struct {
+	// BlockedUsers are the authors who are not welcomed.
+	BlockedUsers []string `json:"blocked_users,omitempty"`
+
+	// ServiceURL is the url of the moderation service which is asked
+	// whether the author is blocked by GET {service_url}?user={author}. It
+	// responds {"blocked": true} if so.
+	ServiceURL string `json:"service_url,omitempty"`
+
+	// Label is added to the merge requests and issues of the blocked
+	// authors. The default value is blocked-author.
+	Label string `json:"label,omitempty"`
+
+	// Close decides whether to close the merge requests and issues of the
+	// blocked authors.
+	Close bool `json:"close,omitempty"`
+
+	// CloseMessage is the go template of the comment posted before
+	// closing, which can use .Author, .Community and .Links.
+	CloseMessage string `json:"close_message,omitempty"`
+}
+
+func (c *moderationConfig) setDefault() {
+	if c.Label == "" {
+		c.Label = defaultBlockedAuthorLabel
+	}
+}
+
+func (c *moderationConfig) validate() error {
+	if c.CloseMessage != "" {
+		if _, err := parseTemplate(c.CloseMessage); err != nil {
+			return fmt.Errorf("invalid close_message of moderation: %v", err)
+		}
+	}
+
+	return nil
+}
+
+type conversionConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Days is the days after the welcome to check whether the newcomer has
+	// opened further merge requests. The default value is 30.
+	Days int `json:"days,omitempty"`
+}
+
+func (c *conversionConfig) setDefault() {
+	if c.Days <= 0 {
+		c.Days = 30
+	}
+}
+
+type engagementConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Days is the days after the welcome to check the reactions and
+	// replies of the contributor. The default value is 7.
+	Days int `json:"days,omitempty"`
+
+	// ThankMessage is the go template of the comment posted when the
+	// contributor replies for the first time, which can use .Author,
+	// .Community and .Links. Nothing is posted if it is empty.
+	ThankMessage string `json:"thank_message,omitempty"`
+}
+
+func (c *engagementConfig) setDefault() {
+	if c.Days <= 0 {
+		c.Days = 7
+	}
+}
+
+func (c *engagementConfig) validate() error {
+	if c.ThankMessage != "" {
+		if _, err := parseTemplate(c.ThankMessage); err != nil {
+			return fmt.Errorf("invalid thank_message of engagement: %v", err)
+		}
+	}
+
+	return nil
+}
+
+type fairnessConfig struct {
+	// MaxMentions is the max number of maintainers, and of committers
+	// likewise, to mention. The ones mentioned or assigned the least in the
+	// last Days are picked. All of them are mentioned if it is not set.
+	MaxMentions int `json:"max_mentions,omitempty"`
+
+	// Days is the days of mentions to count. The default value is 30.
+	Days int `json:"days,omitempty"`
+}
+
+func (c *fairnessConfig) setDefault() {
+	if c.Days <= 0 {
+		c.Days = 30
+	}
+}
+
+func (c *fairnessConfig) validate() error {
+	if c.MaxMentions < 0 {
+		return fmt.Errorf("fairness max_mentions can not be negative")
+	}
+
+	if max := int(mentionLedgerTTL / (24 * time.Hour)); c.Days > max {
+		return fmt.Errorf("fairness days can not exceed %d", max)
+	}
+
+	return nil
+}
+
+type mentionConfig struct {
+	// Format is how a user is mentioned on the GitLab instance, in which
+	// {username} is replaced with the username. The default value is
+	// @{username}.
+	Format string `json:"format,omitempty"`
+
+	// Groups maps the sig name to the full path of GitLab group, such as
+	// sig-storage-maintainers or group/subgroup, which is mentioned
+	// instead of listing the maintainers and committers of sig.
+	Groups map[string]string `json:"groups,omitempty"`
+
+	// MirrorGroupFormat is the full path of the GitLab group mirroring a
+	// sig, in which {sig} is replaced with the sig name, such as
+	// openeuler/sig-{sig}. The groups of Groups take precedence, and the
+	// sig has no mirror group if the path doesn't exist. The path is
+	// available to the templates as .SigGroup.
+	MirrorGroupFormat string `json:"mirror_group_format,omitempty"`
+
+	// GroupMention are the sigs whose mirror groups are mentioned instead
+	// of listing their maintainers and committers. * means all the sigs.
+	GroupMention []string `json:"group_mention,omitempty"`
+}
+
+func (c *mentionConfig) setDefault() {
+	if c.Format == "" {
+		c.Format = "@" + usernamePlaceholder
+	}
+}
+
+func (c *mentionConfig) validate() error {
+	if c.Format != "" && !strings.Contains(c.Format, usernamePlaceholder) {
+		return fmt.Errorf("mention format must contain %s", usernamePlaceholder)
+	}
+
+	if c.MirrorGroupFormat != "" && !strings.Contains(c.MirrorGroupFormat, sigPlaceholder) {
+		return fmt.Errorf("mirror_group_format must contain %s", sigPlaceholder)
+	}
+
+	return nil
+}
+
+type rolesConfig struct {
+	// Mention is the roles whose members are mentioned. The roles can be
+	// reviewers, triagers and security_contacts.
+	Mention []string `json:"mention,omitempty"`
+
+	// List is the roles whose members are only listed as links.
+	List []string `json:"list,omitempty"`
+}
+
+func (c *rolesConfig) enabled() bool {
+	return len(c.Mention) > 0 || len(c.List) > 0
+}
+
+func (c *rolesConfig) isMentioned(role string) bool {
+	return containsString(c.Mention, role)
+}
+
+func (c *rolesConfig) isListed(role string) bool {
+	return containsString(c.List, role)
+}
+
+func (c *rolesConfig) validate() error {
+	for _, v := range append(append([]string{}, c.Mention...), c.List...) {
+		if !containsString(extraRoles, v) {
+			return fmt.Errorf("unsupported role: %s", v)
+		}
+	}
+
+	for _, v := range c.Mention {
+		if c.isListed(v) {
+			return fmt.Errorf("role %s can not be both mentioned and listed", v)
+		}
+	}
+
+	return nil
+}
+
+func containsString(v []string, s string) bool {
+	for _, item := range v {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+type triageConfig struct {
+	// Enabled means to assign the new issue to the member of the triage
+	// rotation of sig in turn.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Rotations maps the sig name to its ordered triage rotation. The
+	// triagers of sig-info are used for the sigs which are not in it.
+	Rotations map[string][]string `json:"rotations,omitempty"`
+}
+
+type staleCleanupConfig struct {
+	// Enabled means to clean up the welcomes of the merge requests and
+	// issues labeled as newcomer which have been closed without merge for
+	// Days. Only the repos like org/repo are scanned.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Days is the days since the merge request or issue is closed.
+	// The default value is 30.
+	Days int `json:"days,omitempty"`
+
+	// Action is what to do besides removing the newcomer label. It can be
+	// remove which deletes the welcome comment, or followup which posts a
+	// follow-up comment. The default value is remove.
+	Action string `json:"action,omitempty"`
+}
+
+func (c *staleCleanupConfig) setDefault() {
+	if c.Days <= 0 {
+		c.Days = 30
+	}
+
+	if c.Action == "" {
+		c.Action = staleActionRemove
+	}
+}
+
+func (c *staleCleanupConfig) validate() error {
+	if v := c.Action; v != "" && v != staleActionRemove && v != staleActionFollowup {
+		return fmt.Errorf("unsupported stale_cleanup action: %s", v)
+	}
+
+	return nil
+}
+
+type pipelineHelpConfig struct {
+	// Enabled means to explain the common CI failures when the pipeline of
+	// merge request labeled as newcomer fails for the first time. It needs
+	// the pipeline events sent to /gitlab-extra-hook.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// DocsURL is the link to the documents of CI.
+	DocsURL string `json:"docs_url,omitempty"`
+}
+
+type wikiConfig struct {
+	// Enabled means to post the guidance when a wiki page is created.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Issue is the number of the issue to post the guidance on, because
+	// the wiki page can't be commented on.
+	Issue int `json:"issue,omitempty"`
+}
+
+type epicConfig struct {
+	// Enabled means to comment on the epic opened without a sig label. The
+	// config applies to the epics of group whose path is listed in repos.
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+type stepRetryConfig struct {
+	// Retries is the times to retry a step when it fails. It is disabled
+	// if not set.
+	Retries int `json:"retries,omitempty"`
+
+	// Interval is the seconds to wait before each retry.
+	// The default value is 2.
+	Interval int `json:"interval,omitempty"`
+}
+
+func (c *stepRetryConfig) setDefault() {
+	if c.Interval <= 0 {
+		c.Interval = 2
+	}
+}
+
+func (c *stepRetryConfig) validate() error {
+	if c.Retries < 0 {
+		return fmt.Errorf("step_retry retries can not be negative")
+	}
+
+	return nil
+}
+
+type commentRetryConfig struct {
+	// Retries is the times to retry posting the welcome comment when it
+	// fails. The comment is never posted twice, because the existing notes
+	// are checked before each retry. It is disabled if not set.
+	Retries int `json:"retries,omitempty"`
+
+	// Interval is the seconds to wait before each retry.
+	// The default value is 2.
+	Interval int `json:"interval,omitempty"`
+}
+
+func (c *commentRetryConfig) setDefault() {
+	if c.Interval <= 0 {
+		c.Interval = 2
+	}
+}
+
+func (c *commentRetryConfig) validate() error {
+	if c.Retries < 0 {
+		return fmt.Errorf("comment_retry retries can not be negative")
+	}
+
+	return nil
+}
+
+type errorBudgetConfig struct {
+	// Window is the seconds of the sliding window to count the failures.
+	// The default value is 600.
+	Window int `json:"window,omitempty"`
+
+	// MaxErrors is the failures allowed in the window. The labels and
+	// assignment are skipped once it is reached. It is disabled if not set.
+	MaxErrors int `json:"max_errors,omitempty"`
+}
+
+func (c *errorBudgetConfig) setDefault() {
+	if c.Window <= 0 {
+		c.Window = 600
+	}
+}
+
+func (c *errorBudgetConfig) window() time.Duration {
+	return time.Duration(c.Window) * time.Second
+}
+
+type alertConfig struct {
+	// URL is the url of the webhook to send the alerts to. It is disabled
+	// if not set.
+	URL string `json:"url,omitempty"`
+
+	// Kind is the kind of webhook, which decides the payload. It can be
+	// webhook, slack, wecom or dingtalk. The default value is webhook,
+	// whose payload is the alert in json.
+	Kind string `json:"kind,omitempty"`
+
+	// MaxFailures is the failed welcomes of a project allowed in the
+	// minutes. The alert is sent once it is exceeded. The default value is 3.
+	MaxFailures int `json:"max_failures,omitempty"`
+
+	// Minutes is the minutes of the sliding window to count the failures.
+	// The default value is 30.
+	Minutes int `json:"minutes,omitempty"`
+}
+
+func (c *alertConfig) setDefault() {
+	if c.Kind == "" {
+		c.Kind = alertKindWebhook
+	}
+
+	if c.MaxFailures <= 0 {
+		c.MaxFailures = 3
+	}
+
+	if c.Minutes <= 0 {
+		c.Minutes = 30
+	}
+}
+
+func (c *alertConfig) validate() error {
+	switch c.Kind {
+	case "", alertKindWebhook, alertKindSlack, alertKindWeCom, alertKindDingTalk:
+		return nil
+	default:
+		return fmt.Errorf("unsupported kind of alert: %s", c.Kind)
+	}
+}
+
+func (c *alertConfig) window() time.Duration {
+	return time.Duration(c.Minutes) * time.Minute
+}
+
+type lateMentionsConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Delay is the seconds to wait before the first recheck, which doubles
+	// on each one after up to 6 hours. The default value is 300.
+	Delay int `json:"delay,omitempty"`
+
+	// MaxAttempts is the max number of rechecks. The default value is 5.
+	MaxAttempts int `json:"max_attempts,omitempty"`
+}
+
+func (c *lateMentionsConfig) setDefault() {
+	if c.Delay <= 0 {
+		c.Delay = 300
+	}
+
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 5
+	}
+}
+
+type digestConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Period is how often to send the digest, daily or weekly. The default
+	// value is weekly.
+	Period string `json:"period,omitempty"`
+
+	// NewcomersOnly decides whether to list the newcomers only.
+	NewcomersOnly bool `json:"newcomers_only,omitempty"`
+
+	// URL is the url of the webhook to post the digest to as
+	// {"text": digest}. The digest is posted to the issues if it is not set.
+	URL string `json:"url,omitempty"`
+
+	// Project is the path of project, such as the community repo, whose
+	// issues the digest is posted to.
+	Project string `json:"project,omitempty"`
+
+	// Issue is the issue to post the digest of the sigs to.
+	Issue int `json:"issue,omitempty"`
+
+	// SigIssues are the issues by sig to post the digest of each sig to
+	// instead of Issue.
+	SigIssues map[string]int `json:"sig_issues,omitempty"`
+}
+
+func (c *digestConfig) setDefault() {
+	if c.Period == "" {
+		c.Period = digestWeekly
+	}
+}
+
+func (c *digestConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Period != digestDaily && c.Period != digestWeekly {
+		return fmt.Errorf("unsupported period of digest: %s", c.Period)
+	}
+
+	if c.URL == "" && (c.Project == "" || (c.Issue <= 0 && len(c.SigIssues) == 0)) {
+		return fmt.Errorf("url, or project and issue of digest must be set")
+	}
+
+	return nil
+}
+
+func (c *digestConfig) period() time.Duration {
+	if c.Period == digestDaily {
+		return 24 * time.Hour
+	}
+
+	return 7 * 24 * time.Hour
 }
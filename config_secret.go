@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const (
+	encryptCommand = "encrypt"
+
+	// configKeyEnv is the env of the data key to decrypt the secrets of the
+	// config, which is 32 bytes encoded in base64.
+	configKeyEnv = "WELCOME_CONFIG_KEY"
+
+	// configKeyFileEnv is the env of the file of the data key, such as the
+	// one mounted by the KMS provider of the secret store. It is used if
+	// configKeyEnv is not set.
+	configKeyFileEnv = "WELCOME_CONFIG_KEY_FILE"
+
+	encryptedValuePrefix = "ENC[AES256_GCM,"
+
+	// encryptedNonceSize is the size of the nonce used by SOPS.
+	encryptedNonceSize = 32
+)
+
+// encryptedValueRe matches the values encrypted in the format of SOPS, such
+// as ENC[AES256_GCM,data:...,iv:...,tag:...,type:str].
+var encryptedValueRe = regexp.MustCompile(
+	`^ENC\[AES256_GCM,data:([^,]*),iv:([^,]+),tag:([^,]+),type:str\]$`,
+)
+
+func isEncryptedValue(v string) bool {
+	return strings.HasPrefix(v, encryptedValuePrefix)
+}
+
+// loadConfigKey reads the data key from the env. It returns nil if the key
+// is not configured.
+func loadConfigKey() ([]byte, error) {
+	s := os.Getenv(configKeyEnv)
+
+	if s == "" {
+		f := os.Getenv(configKeyFileEnv)
+		if f == "" {
+			return nil, nil
+		}
+
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("read the config key file: %v", err)
+		}
+
+		s = string(b)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("the config key must be encoded in base64: %v", err)
+	}
+
+	if len(key) != 32 {
+		return nil, fmt.Errorf("the config key must be 32 bytes, but it is %d", len(key))
+	}
+
+	return key, nil
+}
+
+func newConfigCipher(key []byte, nonceSize int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCMWithNonceSize(block, nonceSize)
+}
+
+// decryptValue decrypts the value encrypted by encryptValue or SOPS. The
+// value is authenticated with its path, such as "default:alert:url:", so
+// that it can't be moved to another field.
+func decryptValue(v, path string, key []byte) (string, error) {
+	m := encryptedValueRe.FindStringSubmatch(v)
+	if m == nil {
+		return "", errors.New("the encrypted value is malformed")
+	}
+
+	var parts [3][]byte
+	for i := range parts {
+		b, err := base64.StdEncoding.DecodeString(m[i+1])
+		if err != nil {
+			return "", errors.New("the encrypted value is malformed")
+		}
+
+		parts[i] = b
+	}
+
+	data, iv, tag := parts[0], parts[1], parts[2]
+
+	aead, err := newConfigCipher(key, len(iv))
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext := append(data, tag...)
+
+	r, err := aead.Open(nil, iv, ciphertext, []byte(path))
+	if err != nil {
+		return "", errors.New("the encrypted value can't be decrypted by the config key")
+	}
+
+	return string(r), nil
+}
+
+// encryptValue encrypts the value of the field at path in the format of
+// SOPS.
+func encryptValue(v, path string, key []byte) (string, error) {
+	aead, err := newConfigCipher(key, encryptedNonceSize)
+	if err != nil {
+		return "", err
+	}
+
+	iv := make([]byte, encryptedNonceSize)
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+
+	r := aead.Seal(nil, iv, []byte(v), []byte(path))
+	n := len(r) - aead.Overhead()
+
+	enc := base64.StdEncoding.EncodeToString
+
+	return fmt.Sprintf(
+		"ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:str]", enc(r[:n]), enc(iv), enc(r[n:]),
+	), nil
+}
+
+// secretDecrypter replaces the encrypted strings of the config with the
+// plain ones in place, so that the secrets, such as the urls with tokens,
+// can be committed to the config repo.
+type secretDecrypter struct {
+	key    []byte
+	keyErr error
+	loaded bool
+	errs   *multiError
+
+	// decrypted are the paths of the fields decrypted.
+	decrypted [][]string
+}
+
+func (d *secretDecrypter) decrypt(v string, path []string) (string, bool) {
+	p := strings.Join(path, ":") + ":"
+
+	if !d.loaded {
+		d.loaded = true
+
+		d.key, d.keyErr = loadConfigKey()
+		if d.keyErr != nil {
+			d.errs.add(d.keyErr)
+		}
+	}
+
+	if d.keyErr != nil {
+		return "", false
+	}
+
+	if d.key == nil {
+		d.errs.add(fmt.Errorf(
+			"%s is encrypted, but neither %s nor %s is set", p, configKeyEnv, configKeyFileEnv,
+		))
+
+		return "", false
+	}
+
+	r, err := decryptValue(v, p, d.key)
+	if err != nil {
+		d.errs.add(fmt.Errorf("%s: %v", p, err))
+
+		return "", false
+	}
+
+	d.decrypted = append(d.decrypted, path)
+
+	return r, true
+}
+
+// walk decrypts the strings of v, which are named by the json tags of the
+// fields and the keys of the maps as the path. The encrypted strings which
+// can't be set in place are rejected rather than left encrypted.
+func (d *secretDecrypter) walk(v reflect.Value, path []string) {
+	switch v.Kind() {
+	case reflect.String:
+		s := v.String()
+		if !isEncryptedValue(s) {
+			return
+		}
+
+		if !v.CanSet() {
+			d.errs.add(fmt.Errorf(
+				"%s: the encrypted value is not supported in the field, which can't be set in place",
+				strings.Join(path, ":")+":",
+			))
+
+			return
+		}
+
+		if r, ok := d.decrypt(s, path); ok {
+			v.SetString(r)
+		}
+
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			d.walk(v.Elem(), path)
+		}
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+
+			p := path
+			if !f.Anonymous || name != "" {
+				if name == "" {
+					name = f.Name
+				}
+
+				p = append(append([]string{}, path...), name)
+			}
+
+			d.walk(v.Field(i), p)
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			d.walk(v.Index(i), path)
+		}
+
+	case reflect.Map:
+		t := v.Type()
+
+		iter := v.MapRange()
+		for iter.Next() {
+			p := append(append([]string{}, path...), fmt.Sprint(iter.Key().Interface()))
+
+			// the values of map can't be set in place, so only the strings
+			// are decrypted, and the encrypted ones in the other values are
+			// rejected by walking them.
+			if t.Key().Kind() != reflect.String || t.Elem().Kind() != reflect.String {
+				d.walk(iter.Value(), p)
+
+				continue
+			}
+
+			s := iter.Value().String()
+			if !isEncryptedValue(s) {
+				continue
+			}
+
+			if r, ok := d.decrypt(s, p); ok {
+				v.SetMapIndex(iter.Key(), reflect.ValueOf(r).Convert(v.Type().Elem()))
+			}
+		}
+	}
+}
+
+// decryptSecrets decrypts the encrypted strings of the config, and records
+// the paths of them in each item. It reads the key only when there is an
+// encrypted string, as most of the communities have none.
+func (c *configuration) decryptSecrets() error {
+	d := &secretDecrypter{errs: newMultiError()}
+
+	decrypt := func(cfg *botConfig, name string) {
+		d.decrypted = nil
+		d.walk(reflect.ValueOf(cfg).Elem(), []string{name})
+
+		cfg.secretPaths = nil
+		for _, p := range d.decrypted {
+			cfg.secretPaths = append(cfg.secretPaths, strings.Join(p[1:], "."))
+		}
+	}
+
+	for i := range c.ConfigItems {
+		decrypt(&c.ConfigItems[i], "config_items")
+	}
+
+	if c.Default != nil {
+		decrypt(c.Default, "default")
+	}
+
+	if err := d.errs.err(); err != nil {
+		return newConfigError(err)
+	}
+
+	return nil
+}
+
+// isSecret reports whether the field at path, such as alert.url, was
+// encrypted.
+func (c *botConfig) isSecret(path string) bool {
+	return containsString(c.secretPaths, path)
+}
+
+// redactSecrets replaces the value at path of the config decoded from JSON
+// with REDACTED. The path doesn't have the indexes of arrays, so the values
+// of all the elements are replaced.
+func redactSecrets(node interface{}, path []string) {
+	switch v := node.(type) {
+	case []interface{}:
+		for i := range v {
+			redactSecrets(v[i], path)
+		}
+
+	case map[string]interface{}:
+		if len(path) == 0 {
+			return
+		}
+
+		if len(path) == 1 {
+			if _, ok := v[path[0]].(string); ok {
+				v[path[0]] = redacted
+			}
+
+			return
+		}
+
+		redactSecrets(v[path[0]], path[1:])
+	}
+}
+
+// runEncrypt encrypts the value read from stdin by the config key, and
+// prints the encrypted one to put in the config. The value is bound to the
+// path of its field, such as default:alert:url, so it can be decrypted only
+// there.
+func runEncrypt(args []string) error {
+	fs := flag.NewFlagSet(encryptCommand, flag.ExitOnError)
+	path := fs.String("path", "", "The path of the field to put the value in, which is the keys joined by colons, such as default:alert:url.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *path == "" {
+		return fmt.Errorf("path must be set")
+	}
+
+	key, err := loadConfigKey()
+	if err != nil {
+		return err
+	}
+
+	if key == nil {
+		return fmt.Errorf("neither %s nor %s is set", configKeyEnv, configKeyFileEnv)
+	}
+
+	s, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && s == "" {
+		return fmt.Errorf("read the value from stdin: %v", err)
+	}
+
+	v, err := encryptValue(strings.TrimRight(s, "\r\n"), strings.TrimSuffix(*path, ":")+":", key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(v)
+
+	return nil
+}
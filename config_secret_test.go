@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testConfigKey(t *testing.T) []byte {
+	key := []byte(strings.Repeat("k", 32))
+
+	old, ok := os.LookupEnv(configKeyEnv)
+	os.Setenv(configKeyEnv, base64.StdEncoding.EncodeToString(key))
+
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(configKeyEnv, old)
+		} else {
+			os.Unsetenv(configKeyEnv)
+		}
+	})
+
+	return key
+}
+
+func mustEncrypt(t *testing.T, v, path string, key []byte) string {
+	t.Helper()
+
+	r, err := encryptValue(v, path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func TestSecretRoundTrip(t *testing.T) {
+	key := testConfigKey(t)
+
+	v := mustEncrypt(t, "https://hook?token=x", "default:alert:url:", key)
+	if !encryptedValueRe.MatchString(v) {
+		t.Fatalf("%s is not in the format of SOPS", v)
+	}
+
+	r, err := decryptValue(v, "default:alert:url:", key)
+	if err != nil || r != "https://hook?token=x" {
+		t.Errorf("decrypt = %q, %v", r, err)
+	}
+}
+
+func TestSecretTampered(t *testing.T) {
+	key := testConfigKey(t)
+
+	v := mustEncrypt(t, "secret", "default:alert:url:", key)
+	m := encryptedValueRe.FindStringSubmatch(v)
+
+	data, _ := base64.StdEncoding.DecodeString(m[1])
+	data[0] ^= 1
+	tampered := strings.Replace(v, m[1], base64.StdEncoding.EncodeToString(data), 1)
+
+	wrongKey := []byte(strings.Repeat("x", 32))
+
+	cases := map[string]string{
+		"data":      tampered,
+		"malformed": strings.Replace(v, "iv:", "iv:!", 1),
+		"truncated": strings.TrimSuffix(v, "]"),
+	}
+
+	for name, s := range cases {
+		if _, err := decryptValue(s, "default:alert:url:", key); err == nil {
+			t.Errorf("%s: the value is decrypted", name)
+		}
+	}
+
+	if _, err := decryptValue(v, "default:alert:url:", wrongKey); err == nil {
+		t.Error("the value is decrypted by the wrong key")
+	}
+}
+
+// TestSecretBoundToPath checks that the value encrypted for a field can't
+// be moved to another one.
+func TestSecretBoundToPath(t *testing.T) {
+	key := testConfigKey(t)
+
+	c := &configuration{Default: &botConfig{
+		Links: map[string]string{"guide": mustEncrypt(t, "https://guide", "default:alert:url:", key)},
+	}}
+
+	if err := c.decryptSecrets(); err == nil || !strings.Contains(err.Error(), "default:links:guide:") {
+		t.Errorf("err = %v", err)
+	}
+
+	if !isEncryptedValue(c.Default.Links["guide"]) {
+		t.Error("the value moved is decrypted")
+	}
+}
+
+func TestDecryptSecrets(t *testing.T) {
+	key := testConfigKey(t)
+
+	c := &configuration{
+		ConfigItems: []botConfig{{
+			Links: map[string]string{
+				"guide":    mustEncrypt(t, "https://guide?token=x", "config_items:links:guide:", key),
+				"commands": "https://commands",
+			},
+		}},
+		Default: &botConfig{},
+	}
+	c.Default.Alert.URL = mustEncrypt(t, "https://alert?token=y", "default:alert:url:", key)
+
+	if err := c.decryptSecrets(); err != nil {
+		t.Fatal(err)
+	}
+
+	item := &c.ConfigItems[0]
+	if item.Links["guide"] != "https://guide?token=x" || c.Default.Alert.URL != "https://alert?token=y" {
+		t.Errorf("links = %v, alert = %s", item.Links, c.Default.Alert.URL)
+	}
+
+	if !item.isSecret("links.guide") || item.isSecret("links.commands") || !c.Default.isSecret("alert.url") {
+		t.Errorf("secret paths = %v, %v", item.secretPaths, c.Default.secretPaths)
+	}
+}
+
+func TestDecryptSecretsUnsupportedType(t *testing.T) {
+	key := testConfigKey(t)
+
+	type item struct {
+		URL string `json:"url"`
+	}
+
+	v := struct {
+		Items map[string]item        `json:"items"`
+		Any   map[string]interface{} `json:"any"`
+	}{
+		Items: map[string]item{"a": {URL: mustEncrypt(t, "x", "items:a:url:", key)}},
+		Any:   map[string]interface{}{"b": mustEncrypt(t, "y", "any:b:", key)},
+	}
+
+	d := &secretDecrypter{errs: newMultiError()}
+	d.walk(reflect.ValueOf(&v).Elem(), nil)
+
+	err := d.errs.err()
+	if err == nil {
+		t.Fatal("the encrypted values in the unsupported types are accepted")
+	}
+
+	for _, p := range []string{"items:a:url:", "any:b:"} {
+		if !strings.Contains(err.Error(), p) {
+			t.Errorf("%s is not reported: %v", p, err)
+		}
+	}
+}
+
+func TestEffectiveConfigRedactsSecrets(t *testing.T) {
+	cfg := &botConfig{
+		Links:       map[string]string{"guide": "https://guide?token=x", "commands": "https://commands"},
+		secretPaths: []string{"links.guide", "alert.url"},
+	}
+	cfg.Alert.URL = "https://alert?token=y"
+
+	r, err := (&effectiveConfig{Config: cfg}).redacted()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := json.Marshal(r)
+	for _, s := range []string{"token=x", "token=y"} {
+		if strings.Contains(string(b), s) {
+			t.Errorf("the secret %s is served: %s", s, b)
+		}
+	}
+
+	if !strings.Contains(string(b), "https://commands") {
+		t.Errorf("the plain link is redacted: %s", b)
+	}
+}
+
+func TestSetDefaultCopiesDefaults(t *testing.T) {
+	a, b := &botConfig{}, &botConfig{}
+	a.setDefault()
+	b.setDefault()
+
+	a.Sections[0].Disabled = true
+	a.MaintainerRoles[0] = "guest"
+
+	if defaultSections[0].Disabled || b.Sections[0].Disabled || defaultMaintainerRoles[0] == "guest" {
+		t.Error("the defaults are shared by the configs")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// welcomedNewcomer is a newcomer welcomed whose conversion is to be
+// checked.
+type welcomedNewcomer struct {
+	Community  string    `json:"community"`
+	Org        string    `json:"org"`
+	Repo       string    `json:"repo"`
+	Author     string    `json:"author"`
+	WelcomedAt time.Time `json:"welcomed_at"`
+
+	// IsIssue tells the newcomer is welcomed by an issue.
+	IsIssue bool `json:"is_issue,omitempty"`
+}
+
+// conversionStats is the conversion of the newcomers welcomed by a
+// community.
+type conversionStats struct {
+	Community string `json:"community"`
+
+	// Checked is the number of newcomers checked after the days.
+	Checked   int `json:"checked"`
+	Converted int `json:"converted"`
+
+	// Pending is the number of newcomers to check.
+	Pending int     `json:"pending"`
+	Rate    float64 `json:"rate"`
+}
+
+type conversionState struct {
+	Pending []welcomedNewcomer          `json:"pending"`
+	Stats   map[string]*conversionStats `json:"stats"`
+}
+
+// conversionTracker tracks whether the newcomers welcomed open further
+// merge requests after the days, which is saved to the file if the path
+// is set.
+type conversionTracker struct {
+	lock  sync.Mutex
+	path  string
+	state conversionState
+}
+
+func newConversionTracker(path string) *conversionTracker {
+	t := &conversionTracker{path: path}
+
+	if path != "" {
+		if err := loadStateFile(path, &t.state); err != nil {
+			logrus.WithError(err).Errorf("load the conversion: %s", path)
+
+			t.state = conversionState{}
+		}
+	}
+
+	if t.state.Stats == nil {
+		t.state.Stats = make(map[string]*conversionStats)
+	}
+
+	return t
+}
+
+// save must be called with the lock held.
+func (t *conversionTracker) save() {
+	if t.path == "" {
+		return
+	}
+
+	if err := saveStateFile(t.path, &t.state); err != nil {
+		logrus.WithError(err).Errorf("save the conversion: %s", t.path)
+	}
+}
+
+// statsOf must be called with the lock held.
+func (t *conversionTracker) statsOf(community string) *conversionStats {
+	s, ok := t.state.Stats[community]
+	if !ok {
+		s = &conversionStats{Community: community}
+		t.state.Stats[community] = s
+	}
+
+	return s
+}
+
+func (t *conversionTracker) record(org, repo, author string, isIssue bool, cfg *botConfig) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, v := range t.state.Pending {
+		if v.Community == cfg.CommunityName && v.Author == author {
+			return
+		}
+	}
+
+	t.state.Pending = append(t.state.Pending, welcomedNewcomer{
+		Community:  cfg.CommunityName,
+		Org:        org,
+		Repo:       repo,
+		Author:     author,
+		WelcomedAt: time.Now(),
+		IsIssue:    isIssue,
+	})
+	t.statsOf(cfg.CommunityName).Pending++
+
+	t.save()
+}
+
+// due returns the newcomers welcomed before the days of their config.
+func (t *conversionTracker) due(configFor func(org, repo string) *botConfig) []welcomedNewcomer {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var r []welcomedNewcomer
+	for _, v := range t.state.Pending {
+		cfg := configFor(v.Org, v.Repo)
+		if cfg == nil || !cfg.Conversion.Enabled {
+			continue
+		}
+
+		if time.Since(v.WelcomedAt) >= time.Duration(cfg.Conversion.Days)*24*time.Hour {
+			r = append(r, v)
+		}
+	}
+
+	return r
+}
+
+func (t *conversionTracker) done(v welcomedNewcomer, converted bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for i := range t.state.Pending {
+		if p := &t.state.Pending[i]; p.Community == v.Community && p.Author == v.Author {
+			t.state.Pending = append(t.state.Pending[:i], t.state.Pending[i+1:]...)
+
+			break
+		}
+	}
+
+	s := t.statsOf(v.Community)
+	s.Pending--
+	s.Checked++
+	if converted {
+		s.Converted++
+	}
+	s.Rate = float64(s.Converted) / float64(s.Checked)
+
+	t.save()
+}
+
+func (t *conversionTracker) stats() []conversionStats {
+	t.lock.Lock()
+	r := make([]conversionStats, 0, len(t.state.Stats))
+	for _, s := range t.state.Stats {
+		r = append(r, *s)
+	}
+	t.lock.Unlock()
+
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].Community < r[j].Community
+	})
+
+	return r
+}
+
+// checkConversions checks every interval whether the newcomers welcomed
+// before the days have opened further merge requests. Only the leader
+// runs it if the leader election is enabled.
+func (bot *robot) checkConversions(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for range t.C {
+		if bot.elector != nil && !bot.elector.isLeader() {
+			continue
+		}
+
+		c, err := bot.getConfig()
+		if err != nil {
+			logrus.WithError(err).Error("get config to check the conversions")
+
+			continue
+		}
+
+		for _, v := range bot.conversions.due(c.configFor) {
+			cfg := c.configFor(v.Org, v.Repo)
+
+			// the contributions are counted before the merge request
+			// which is welcomed, so any one means a further merge request.
+			// The cached count may be fetched for another item.
+			bot.forgetContributions(v.Org, v.Author, &cfg.Newcomer)
+
+			n, err := bot.countContributions(v.Org, v.Author, !v.IsIssue, cfg)
+			if err != nil {
+				logrus.WithError(err).WithField("author", v.Author).Error("check the conversion")
+
+				continue
+			}
+
+			bot.conversions.done(v, n > 0)
+		}
+	}
+}
+
+// statsHandler serves the stats of welcomes, such as the conversion of
+// newcomers and the engagement with the welcomes.
+func (bot *robot) statsHandler(w http.ResponseWriter, r *http.Request) {
+	conversion := bot.conversions.stats()
+	engagement := bot.engagements.stats()
+
+	if c := r.URL.Query().Get("community"); c != "" {
+		var cs []conversionStats
+		for _, s := range conversion {
+			if s.Community == c {
+				cs = append(cs, s)
+			}
+		}
+
+		var es []engagementStats
+		for _, s := range engagement {
+			if s.Community == c {
+				es = append(es, s)
+			}
+		}
+
+		if len(cs) == 0 && len(es) == 0 {
+			http.Error(w, fmt.Sprintf("no stats of community %s", c), http.StatusNotFound)
+
+			return
+		}
+
+		conversion, engagement = cs, es
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"conversion": conversion,
+		"engagement": engagement,
+	})
+}
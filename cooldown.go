@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// welcomeHistoryTTL is how long a welcome is kept in the history. It is
+// the upper limit of cooldown.
+const welcomeHistoryTTL = 30 * 24 * time.Hour
+
+// welcomeHistory records when each author was welcomed last time. It is
+// saved to the file if the path is set, so that it survives restarts.
+type welcomeHistory struct {
+	lock  sync.Mutex
+	path  string
+	items map[string]time.Time
+}
+
+func newWelcomeHistory(path string) *welcomeHistory {
+	h := &welcomeHistory{path: path, items: make(map[string]time.Time)}
+
+	if path == "" {
+		return h
+	}
+
+	if err := loadStateFile(path, &h.items); err != nil || h.items == nil {
+		logrus.WithError(err).Errorf("load the welcome history: %s", path)
+
+		h.items = make(map[string]time.Time)
+	}
+
+	return h
+}
+
+func welcomeHistoryKey(author string, cfg *botConfig) string {
+	return cfg.CommunityName + "/" + author
+}
+
+// inCooldown reports whether the author was welcomed within the cooldown.
+func (h *welcomeHistory) inCooldown(author string, cfg *botConfig) bool {
+	if cfg.Cooldown <= 0 {
+		return false
+	}
+
+	h.lock.Lock()
+	t, ok := h.items[welcomeHistoryKey(author, cfg)]
+	h.lock.Unlock()
+
+	return ok && time.Since(t) < time.Duration(cfg.Cooldown)*time.Second
+}
+
+func (h *welcomeHistory) record(author string, cfg *botConfig) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	now := time.Now()
+	h.items[welcomeHistoryKey(author, cfg)] = now
+
+	for k, t := range h.items {
+		if now.Sub(t) > welcomeHistoryTTL {
+			delete(h.items, k)
+		}
+	}
+
+	if h.path != "" {
+		if err := saveStateFile(h.path, h.items); err != nil {
+			logrus.WithError(err).Errorf("save the welcome history: %s", h.path)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dependencyGitlab        = "gitlab"
+	dependencyCommunityRepo = "community_repo"
+	dependencyNewcomer      = "newcomer"
+
+	// the rate is halved on each back-off, and recovers by a tenth of the
+	// maximum on each success, but never drops below a tenth of it.
+	limiterBackoffFactor   = 0.5
+	limiterRecoverFraction = 0.1
+	limiterMinFraction     = 0.1
+)
+
+var (
+	dependencyRate = newGaugeVec(
+		"welcome_dependency_rate",
+		"Requests per second currently allowed to the dependency.",
+		"dependency",
+	)
+	dependencyWaiting = newGaugeVec(
+		"welcome_dependency_waiting",
+		"Number of the requests waiting for the rate limit of the dependency.",
+		"dependency",
+	)
+	dependencySaturatedTotal = newCounterVec(
+		"welcome_dependency_saturated_total",
+		"Number of the requests failed at once because the dependency is saturated.",
+		"dependency",
+	)
+	dependencyBackoffsTotal = newCounterVec(
+		"welcome_dependency_backoffs_total",
+		"Number of the back-offs of the rate of the dependency by reason.",
+		"dependency", "reason",
+	)
+)
+
+// errDependencySaturated fails the request which would wait too long for
+// the rate limit, so that a slow dependency doesn't hold the workers. It is
+// retryable as the network error.
+var errDependencySaturated = errors.New("the dependency is saturated")
+
+// adaptiveLimiter is the token bucket of a dependency. Its rate backs off
+// when the dependency throttles or fails, and recovers gradually when the
+// dependency succeeds again.
+type adaptiveLimiter struct {
+	name    string
+	maxRate float64
+	maxWait time.Duration
+
+	lock    sync.Mutex
+	rate    float64
+	tokens  float64
+	last    time.Time
+	paused  time.Time
+	waiting int
+}
+
+func newAdaptiveLimiter(name string, rate float64, maxWait time.Duration) *adaptiveLimiter {
+	l := &adaptiveLimiter{
+		name:    name,
+		maxRate: rate,
+		maxWait: maxWait,
+		rate:    rate,
+		tokens:  rate,
+		last:    time.Now(),
+	}
+
+	dependencyRate.set(rate, name)
+
+	return l
+}
+
+// reserve takes a token and returns how long to wait for it. It fails if
+// the wait is longer than maxWait.
+func (l *adaptiveLimiter) reserve() (time.Duration, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+
+	// the burst is the tokens of a second, but at least one.
+	burst := l.rate
+	if burst < 1 {
+		burst = 1
+	}
+
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > burst {
+		l.tokens = burst
+	}
+	l.last = now
+
+	var d time.Duration
+	if now.Before(l.paused) {
+		d = l.paused.Sub(now)
+	}
+
+	if l.tokens < 1 {
+		d += time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	}
+
+	if d > l.maxWait {
+		dependencySaturatedTotal.inc(l.name)
+
+		return 0, errDependencySaturated
+	}
+
+	l.tokens--
+
+	return d, nil
+}
+
+// wait blocks until the request can be sent.
+func (l *adaptiveLimiter) wait(req *http.Request) error {
+	d, err := l.reserve()
+	if err != nil || d <= 0 {
+		return err
+	}
+
+	l.lock.Lock()
+	l.waiting++
+	dependencyWaiting.set(float64(l.waiting), l.name)
+	l.lock.Unlock()
+
+	defer func() {
+		l.lock.Lock()
+		l.waiting--
+		dependencyWaiting.set(float64(l.waiting), l.name)
+		l.lock.Unlock()
+	}()
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// observe adjusts the rate by the result of request.
+func (l *adaptiveLimiter) observe(resp *http.Response, err error) {
+	reason := ""
+	var pause time.Duration
+
+	switch {
+	case err != nil:
+		reason = "error"
+	case resp.StatusCode == http.StatusTooManyRequests:
+		reason = "throttled"
+		pause = retryAfterOf(resp)
+	case resp.StatusCode >= 500:
+		reason = "server_error"
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if reason == "" {
+		if l.rate < l.maxRate {
+			l.rate += l.maxRate * limiterRecoverFraction
+			if l.rate > l.maxRate {
+				l.rate = l.maxRate
+			}
+
+			dependencyRate.set(l.rate, l.name)
+		}
+
+		return
+	}
+
+	dependencyBackoffsTotal.inc(l.name, reason)
+
+	l.rate *= limiterBackoffFactor
+	if min := l.maxRate * limiterMinFraction; l.rate < min {
+		l.rate = min
+	}
+	dependencyRate.set(l.rate, l.name)
+
+	if t := time.Now().Add(pause); t.After(l.paused) {
+		l.paused = t
+	}
+}
+
+// retryAfterOf returns the wait told by the Retry-After header in seconds.
+func retryAfterOf(resp *http.Response) time.Duration {
+	n, err := strconv.Atoi(strings.TrimSpace(resp.Header.Get("Retry-After")))
+	if err != nil || n <= 0 {
+		return 0
+	}
+
+	return time.Duration(n) * time.Second
+}
+
+// limitedTransport sends the requests at the rate of the limiter of their
+// dependency. The requests of no dependency are sent as is.
+type limitedTransport struct {
+	base    http.RoundTripper
+	limiter func(*http.Request) *adaptiveLimiter
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	l := t.limiter(req)
+	if l == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	if err := l.wait(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+
+	// the requests canceled by the caller say nothing about the dependency.
+	if req.Context().Err() == nil {
+		l.observe(resp, err)
+	}
+
+	return resp, err
+}
+
+// withLimiter returns a copy of hc sending the requests by limiter.
+func withLimiter(hc *http.Client, limiter func(*http.Request) *adaptiveLimiter) *http.Client {
+	base := hc.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	c := *hc
+	c.Transport = &limitedTransport{base: base, limiter: limiter}
+
+	return &c
+}
+
+// isCommunityRepoRead reports whether the request of GitLab API reads the
+// files of repo, such as sig-info and OWNERS.
+func isCommunityRepoRead(req *http.Request) bool {
+	p := req.URL.EscapedPath()
+
+	return req.Method == http.MethodGet &&
+		(strings.Contains(p, "/repository/files/") || strings.HasSuffix(p, "/repository/tree"))
+}
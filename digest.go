@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	digestDaily  = "daily"
+	digestWeekly = "weekly"
+
+	digestCheckInterval = time.Hour
+
+	// digestNoSig is the section of the welcomes whose sig is unknown.
+	digestNoSig = "unknown"
+)
+
+// digestEntry is a welcome recorded for the digest.
+type digestEntry struct {
+	Org        string    `json:"org"`
+	Repo       string    `json:"repo"`
+	Number     int       `json:"number"`
+	IsIssue    bool      `json:"is_issue,omitempty"`
+	Author     string    `json:"author"`
+	Sig        string    `json:"sig,omitempty"`
+	Newcomer   bool      `json:"newcomer,omitempty"`
+	WelcomedAt time.Time `json:"welcomed_at"`
+}
+
+// reference returns the GitLab reference of the merge request or issue,
+// which is rendered as a link.
+func (e *digestEntry) reference() string {
+	if e.IsIssue {
+		return fmt.Sprintf("%s/%s#%d", e.Org, e.Repo, e.Number)
+	}
+
+	return fmt.Sprintf("%s/%s!%d", e.Org, e.Repo, e.Number)
+}
+
+type digestState struct {
+	// Entries are the welcomes to digest by community.
+	Entries map[string][]digestEntry `json:"entries"`
+
+	// SentAt is when the last digest of community was sent.
+	SentAt map[string]time.Time `json:"sent_at"`
+}
+
+// digestLog records the welcomes until they are sent in the digest of their
+// community, which is saved to the file if the path is set.
+type digestLog struct {
+	lock  sync.Mutex
+	path  string
+	state digestState
+}
+
+func newDigestLog(path string) *digestLog {
+	d := &digestLog{path: path}
+
+	if path != "" {
+		if err := loadStateFile(path, &d.state); err != nil {
+			logrus.WithError(err).Errorf("load the digest: %s", path)
+
+			d.state = digestState{}
+		}
+	}
+
+	if d.state.Entries == nil {
+		d.state.Entries = make(map[string][]digestEntry)
+	}
+
+	if d.state.SentAt == nil {
+		d.state.SentAt = make(map[string]time.Time)
+	}
+
+	return d
+}
+
+// save must be called with the lock held.
+func (d *digestLog) save() {
+	if d.path == "" {
+		return
+	}
+
+	if err := saveStateFile(d.path, &d.state); err != nil {
+		logrus.WithError(err).Errorf("save the digest: %s", d.path)
+	}
+}
+
+func (d *digestLog) record(community string, e digestEntry) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.state.Entries[community] = append(d.state.Entries[community], e)
+
+	// the first digest covers the period since the first welcome.
+	if _, ok := d.state.SentAt[community]; !ok {
+		d.state.SentAt[community] = e.WelcomedAt
+	}
+
+	d.save()
+}
+
+// due returns the entries of community if its digest is due.
+func (d *digestLog) due(community string, period time.Duration) ([]digestEntry, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	t, ok := d.state.SentAt[community]
+	if !ok || time.Since(t) < period {
+		return nil, false
+	}
+
+	return d.state.Entries[community], true
+}
+
+// sent drops the n entries sent in the digest of community.
+func (d *digestLog) sent(community string, n int) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.state.Entries[community] = d.state.Entries[community][n:]
+	if len(d.state.Entries[community]) == 0 {
+		delete(d.state.Entries, community)
+	}
+	d.state.SentAt[community] = time.Now()
+
+	d.save()
+}
+
+// recordDigest records the welcome for the digest of community. Nothing is
+// recorded in the privacy mode, as the digest lists the usernames.
+func (bot *robot) recordDigest(org, repo, author string, acts actions, plan *ActionPlan, cfg *botConfig) {
+	c := &cfg.Digest
+	if !c.Enabled || (c.NewcomersOnly && !plan.Newcomer) || bot.privacy {
+		return
+	}
+
+	v, ok := welcomedItemOf(org, repo, author, acts, cfg)
+	if !ok {
+		return
+	}
+
+	bot.digests.record(cfg.CommunityName, digestEntry{
+		Org:        org,
+		Repo:       repo,
+		Number:     v.Number,
+		IsIssue:    v.IsIssue,
+		Author:     author,
+		Sig:        plan.SigName,
+		Newcomer:   plan.Newcomer,
+		WelcomedAt: v.WelcomedAt,
+	})
+}
+
+// genDigests returns the digest of each sig.
+func genDigests(community string, entries []digestEntry) map[string]string {
+	bySig := make(map[string][]digestEntry)
+	for _, e := range entries {
+		sig := e.Sig
+		if sig == "" {
+			sig = digestNoSig
+		}
+
+		bySig[sig] = append(bySig[sig], e)
+	}
+
+	r := make(map[string]string, len(bySig))
+	for sig, v := range bySig {
+		sort.Slice(v, func(i, j int) bool {
+			return v[i].WelcomedAt.Before(v[j].WelcomedAt)
+		})
+
+		b := new(strings.Builder)
+		fmt.Fprintf(b, "### Contributors welcomed to the %s Community, SIG %s\n\n", community, sig)
+
+		for _, e := range v {
+			newcomer := ""
+			if e.Newcomer {
+				newcomer = " (newcomer)"
+			}
+
+			fmt.Fprintf(b, "- @%s%s: %s\n", e.Author, newcomer, e.reference())
+		}
+
+		r[sig] = b.String()
+	}
+
+	return r
+}
+
+// sendDigests checks every hour whether the digests of communities are due
+// and sends them. Only the leader runs it if the leader election is
+// enabled.
+func (bot *robot) sendDigests() {
+	t := time.NewTicker(digestCheckInterval)
+	defer t.Stop()
+
+	for range t.C {
+		if bot.elector != nil && !bot.elector.isLeader() {
+			continue
+		}
+
+		c, err := bot.getConfig()
+		if err != nil {
+			logrus.WithError(err).Error("get config to send the digests")
+
+			continue
+		}
+
+		for _, cfg := range digestConfigs(c) {
+			log := logrus.WithField("community", cfg.CommunityName)
+
+			entries, ok := bot.digests.due(cfg.CommunityName, cfg.Digest.period())
+			if !ok {
+				continue
+			}
+
+			if err := bot.sendDigest(entries, cfg); err != nil {
+				log.WithError(err).Error("send the digest")
+
+				continue
+			}
+
+			bot.digests.sent(cfg.CommunityName, len(entries))
+		}
+	}
+}
+
+// digestConfigs returns the first config of each community enabling the
+// digest.
+func digestConfigs(c *configuration) []*botConfig {
+	seen := make(map[string]bool)
+
+	var r []*botConfig
+	add := func(cfg *botConfig) {
+		if cfg != nil && cfg.Digest.Enabled && !seen[cfg.CommunityName] {
+			seen[cfg.CommunityName] = true
+			r = append(r, cfg)
+		}
+	}
+
+	for i := range c.ConfigItems {
+		add(&c.ConfigItems[i])
+	}
+	add(c.Default)
+
+	return r
+}
+
+func (bot *robot) sendDigest(entries []digestEntry, cfg *botConfig) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	c := &cfg.Digest
+	digests := genDigests(cfg.CommunityName, entries)
+
+	sigs := make([]string, 0, len(digests))
+	for sig := range digests {
+		sigs = append(sigs, sig)
+	}
+	sort.Strings(sigs)
+
+	if c.URL != "" {
+		v := make([]string, len(sigs))
+		for i, sig := range sigs {
+			v[i] = digests[sig]
+		}
+
+		body, err := json.Marshal(map[string]string{"text": strings.Join(v, "\n")})
+		if err != nil {
+			return err
+		}
+
+		return postJSON(bot.hc, c.URL, map[string]string{"Content-Type": "application/json"}, body)
+	}
+
+	cli := bot.clientFor(strings.Split(c.Project, "/")[0])
+
+	// the digests of the sigs without their own issues are posted together.
+	var rest []string
+	for _, sig := range sigs {
+		if issue, ok := c.SigIssues[sig]; ok {
+			if err := cli.CreateIssueComment(c.Project, issue, digests[sig]); err != nil {
+				return err
+			}
+		} else {
+			rest = append(rest, digests[sig])
+		}
+	}
+
+	if len(rest) == 0 || c.Issue <= 0 {
+		return nil
+	}
+
+	return cli.CreateIssueComment(c.Project, c.Issue, strings.Join(rest, "\n"))
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var directoryLookupsTotal = newCounterVec(
+	"welcome_directory_lookups_total",
+	"Number of the lookups of the members in the directory by result.",
+	"result",
+)
+
+type directoryConfig struct {
+	// URL is the LDAP server of the enterprise directory, such as
+	// ldaps://ldap.example.com. The members of sig are looked up in it,
+	// so that they are mentioned by their usernames of GitLab and the ones
+	// out of office are not. It is disabled if not set.
+	URL string `json:"url,omitempty"`
+
+	// BindDN and the password in BindPasswordFile are used to bind. It
+	// binds anonymously if BindDN is not set.
+	BindDN           string `json:"bind_dn,omitempty"`
+	BindPasswordFile string `json:"bind_password_file,omitempty"`
+
+	// BaseDN is where the users are searched, such as
+	// ou=people,dc=example,dc=com.
+	BaseDN string `json:"base_dn,omitempty"`
+
+	// LookupAttribute is the attribute of the user which equals the name
+	// in the sig files. The default value is uid.
+	LookupAttribute string `json:"lookup_attribute,omitempty"`
+
+	// UsernameAttribute is the attribute of the username of GitLab. The
+	// name in the sig files is the username if it is not set.
+	UsernameAttribute string `json:"username_attribute,omitempty"`
+
+	// AwayAttribute is the boolean attribute which is TRUE if the user is
+	// out of office, and AwayUntilAttribute is the generalized time until
+	// which the user is out of office. The availability is not checked if
+	// neither is set.
+	AwayAttribute      string `json:"away_attribute,omitempty"`
+	AwayUntilAttribute string `json:"away_until_attribute,omitempty"`
+
+	// CacheTTL is the seconds to cache the users looked up. The default
+	// value is 600.
+	CacheTTL int `json:"cache_ttl,omitempty"`
+
+	// Timeout is the seconds to look up the members of a welcome. The
+	// default value is 5.
+	Timeout int `json:"timeout,omitempty"`
+}
+
+func (c *directoryConfig) setDefault() {
+	if c.LookupAttribute == "" {
+		c.LookupAttribute = "uid"
+	}
+
+	if c.CacheTTL <= 0 {
+		c.CacheTTL = 600
+	}
+
+	if c.Timeout <= 0 {
+		c.Timeout = 5
+	}
+}
+
+func (c *directoryConfig) validate() error {
+	if !c.enabled() {
+		return nil
+	}
+
+	if !strings.HasPrefix(c.URL, "ldap://") && !strings.HasPrefix(c.URL, "ldaps://") {
+		return fmt.Errorf("url of directory must be an ldap or ldaps url")
+	}
+
+	if c.BaseDN == "" {
+		return fmt.Errorf("base_dn of directory must be set")
+	}
+
+	if c.BindDN != "" && c.BindPasswordFile == "" {
+		return fmt.Errorf("bind_password_file of directory must be set with bind_dn")
+	}
+
+	return nil
+}
+
+func (c *directoryConfig) enabled() bool {
+	return c.URL != ""
+}
+
+// attributes are the attributes of user to read. 1.1 asks for none of them
+// when the user is only checked to exist.
+func (c *directoryConfig) attributes() []string {
+	if v := nonEmpty(c.UsernameAttribute, c.AwayAttribute, c.AwayUntilAttribute); len(v) > 0 {
+		return v
+	}
+
+	return []string{"1.1"}
+}
+
+// directoryUser is the user found in the directory.
+type directoryUser struct {
+	username string
+	away     bool
+}
+
+// ldapGeneralizedTimeLayouts are the layouts of the generalized time of
+// LDAP, which has the fraction of second or not.
+var ldapGeneralizedTimeLayouts = []string{"20060102150405Z0700", "20060102150405.999999999Z0700"}
+
+func parseGeneralizedTime(s string) (time.Time, bool) {
+	for _, l := range ldapGeneralizedTimeLayouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func (c *directoryConfig) userOf(name string, entry map[string][]string) *directoryUser {
+	first := func(attr string) string {
+		if v := entry[strings.ToLower(attr)]; attr != "" && len(v) > 0 {
+			return strings.TrimSpace(v[0])
+		}
+
+		return ""
+	}
+
+	u := &directoryUser{username: name}
+
+	if c.UsernameAttribute != "" {
+		if v := first(c.UsernameAttribute); v != "" {
+			u.username = v
+		}
+	}
+
+	if b, err := strconv.ParseBool(first(c.AwayAttribute)); err == nil && b {
+		u.away = true
+	}
+
+	if t, ok := parseGeneralizedTime(first(c.AwayUntilAttribute)); ok && time.Now().Before(t) {
+		u.away = true
+	}
+
+	return u
+}
+
+func (c *directoryConfig) cacheKey(name string) string {
+	return fmt.Sprintf("%s|%s|%s=%s", c.URL, c.BaseDN, c.LookupAttribute, name)
+}
+
+// lookupDirectory finds the users of names in the directory. The names not
+// found are absent from the result. The users are cached, and the ones
+// not cached are looked up on one connection.
+func (bot *robot) lookupDirectory(names []string, c *directoryConfig) (map[string]*directoryUser, error) {
+	r := make(map[string]*directoryUser, len(names))
+
+	var missing []string
+	for _, name := range names {
+		if v, ok := bot.directory.get(c.cacheKey(name)); ok {
+			if u := v.(*directoryUser); u != nil {
+				r[name] = u
+			}
+		} else {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) == 0 {
+		return r, nil
+	}
+
+	conn, err := dialLDAP(c.URL, time.Duration(c.Timeout)*time.Second)
+	if err != nil {
+		directoryLookupsTotal.add(float64(len(missing)), "error")
+
+		return r, err
+	}
+
+	defer conn.close()
+
+	if c.BindDN != "" {
+		password, err := ioutil.ReadFile(c.BindPasswordFile)
+		if err != nil {
+			directoryLookupsTotal.add(float64(len(missing)), "error")
+
+			return r, err
+		}
+
+		if err := conn.bind(c.BindDN, strings.TrimSpace(string(password))); err != nil {
+			directoryLookupsTotal.add(float64(len(missing)), "error")
+
+			return r, err
+		}
+	}
+
+	ttl := time.Duration(c.CacheTTL) * time.Second
+
+	for i, name := range missing {
+		// 2 entries are enough to tell the ambiguous ones.
+		entries, err := conn.search(c.BaseDN, c.LookupAttribute, name, c.attributes(), 2)
+		if err != nil {
+			directoryLookupsTotal.add(float64(len(missing)-i), "error")
+
+			return r, err
+		}
+
+		var u *directoryUser
+		if len(entries) == 1 {
+			u = c.userOf(name, entries[0])
+			r[name] = u
+
+			directoryLookupsTotal.inc("found")
+		} else {
+			directoryLookupsTotal.inc("not_found")
+		}
+
+		bot.directory.set(c.cacheKey(name), u, ttl)
+	}
+
+	return r, nil
+}
+
+// applyDirectory replaces the members with their usernames in the
+// directory, and leaves out the ones out of office, unless all of them are,
+// so that somebody is still mentioned. It returns the members out of
+// office. The members are kept as they are if the directory fails.
+func (bot *robot) applyDirectory(
+	maintainers, committers []string, cfg *botConfig, log *logrus.Entry,
+) ([]string, []string, []string) {
+	c := &cfg.Directory
+	if !c.enabled() {
+		return maintainers, committers, nil
+	}
+
+	users, err := bot.lookupDirectory(append(append([]string{}, maintainers...), committers...), c)
+	if err != nil {
+		log.WithError(err).Error("look up the members in the directory")
+	}
+
+	var away []string
+
+	resolve := func(names []string) (all, available []string) {
+		for _, name := range names {
+			u, ok := users[name]
+			if !ok {
+				all = append(all, name)
+				available = append(available, name)
+
+				continue
+			}
+
+			all = append(all, u.username)
+
+			if u.away {
+				away = append(away, u.username)
+			} else {
+				available = append(available, u.username)
+			}
+		}
+
+		return
+	}
+
+	allMaintainers, availableMaintainers := resolve(maintainers)
+	allCommitters, availableCommitters := resolve(committers)
+
+	if len(away) > 0 && len(availableMaintainers)+len(availableCommitters) == 0 {
+		log.Infof("all the members are out of office, mention them anyway")
+
+		return allMaintainers, allCommitters, away
+	}
+
+	return availableMaintainers, availableCommitters, away
+}
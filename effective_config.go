@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// effectiveConfig is the config which the robot applies to a repo.
+type effectiveConfig struct {
+	// Source is the item of central config matched, such as
+	// config_items[0] or default.
+	Source string `json:"source"`
+
+	// RepoFile is the path of the repo file merged, which is empty if
+	// there is none.
+	RepoFile string `json:"repo_file,omitempty"`
+
+	// SkipMergeRequests and SkipIssues report whether the welcome is
+	// turned off by the repo file.
+	SkipMergeRequests bool `json:"skip_merge_requests,omitempty"`
+	SkipIssues        bool `json:"skip_issues,omitempty"`
+
+	Config *botConfig `json:"config"`
+}
+
+// effectiveConfigHandler returns the config resolved for org/repo, after
+// matching the config items, falling back to the default and merging the
+// repo file. It helps the operators to find out why a repo behaves
+// differently.
+func (bot *robot) effectiveConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	org, repo := r.URL.Query().Get("org"), r.URL.Query().Get("repo")
+	if org == "" || repo == "" {
+		http.Error(w, "org and repo must be set", http.StatusBadRequest)
+
+		return
+	}
+
+	c, err := bot.getConfig()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	cfg, source := c.resolve(org, repo)
+	if cfg == nil {
+		http.Error(w, "the repo is not configured", http.StatusNotFound)
+
+		return
+	}
+
+	v, err := bot.effectiveConfig(org, repo, cfg)
+	if err != nil {
+		logrus.WithError(err).Errorf("get the effective config of %s/%s", org, repo)
+
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	v.Source = source
+
+	out, err := v.redacted()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	writeJSON(w, out)
+}
+
+// redacted returns the effective config whose secrets decrypted are
+// replaced, so that they are not served in plain text.
+func (v *effectiveConfig) redacted() (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var r map[string]interface{}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+
+	for _, p := range v.Config.secretPaths {
+		redactSecrets(r["config"], strings.Split(p, "."))
+	}
+
+	return r, nil
+}
+
+func (bot *robot) effectiveConfig(org, repo string, cfg *botConfig) (*effectiveConfig, error) {
+	r := &effectiveConfig{Config: cfg}
+
+	if !cfg.RepoFile.Enabled {
+		return r, nil
+	}
+
+	pid, err := bot.cli.GetProjectID(fmt.Sprintf("%s/%s", org, repo))
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := bot.getRepoFile(pid, &cfg.RepoFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if f == nil {
+		return r, nil
+	}
+
+	r.RepoFile = cfg.RepoFile.Path
+	r.SkipMergeRequests = f.skip(false)
+	r.SkipIssues = f.skip(true)
+	r.Config = f.merge(cfg)
+
+	return r, nil
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	engagementReaction = "reaction"
+	engagementReply    = "reply"
+)
+
+var engagementTotal = newCounterVec(
+	"welcome_engagement_total",
+	"Number of the welcomes the contributors engage with, by reaction or reply.",
+	"repo", "kind",
+)
+
+// welcomedItem is a merge request or issue welcomed whose engagement is to
+// be checked.
+type welcomedItem struct {
+	Community string `json:"community"`
+	Org       string `json:"org"`
+	Repo      string `json:"repo"`
+	ProjectID int    `json:"project_id"`
+	Number    int    `json:"number"`
+	IsIssue   bool   `json:"is_issue,omitempty"`
+	Author    string `json:"author"`
+
+	// NoteID is the id of welcome comment, which is 0 until it is found.
+	NoteID     int       `json:"note_id,omitempty"`
+	WelcomedAt time.Time `json:"welcomed_at"`
+
+	Reacted bool `json:"reacted,omitempty"`
+	Replied bool `json:"replied,omitempty"`
+}
+
+func (v *welcomedItem) is(o *welcomedItem) bool {
+	return v.ProjectID == o.ProjectID && v.Number == o.Number && v.IsIssue == o.IsIssue
+}
+
+func (v *welcomedItem) actions(cli iClient) actions {
+	if v.IsIssue {
+		return &issueActions{cli: cli, projectID: v.ProjectID, number: v.Number}
+	}
+
+	return &mrActions{cli: cli, projectID: v.ProjectID, number: v.Number}
+}
+
+// welcomedItemOf returns the item the actions apply to. Only the ones of
+// GitLab are tracked.
+func welcomedItemOf(org, repo, author string, acts actions, cfg *botConfig) (welcomedItem, bool) {
+	v := welcomedItem{
+		Community:  cfg.CommunityName,
+		Org:        org,
+		Repo:       repo,
+		Author:     author,
+		WelcomedAt: time.Now(),
+	}
+
+	switch a := acts.(type) {
+	case *mrActions:
+		v.ProjectID, v.Number = a.projectID, a.number
+	case *issueActions:
+		v.ProjectID, v.Number, v.IsIssue = a.projectID, a.number, true
+	default:
+		return v, false
+	}
+
+	return v, true
+}
+
+// engagementStats is the engagement of the contributors with the welcomes
+// of a repo.
+type engagementStats struct {
+	Community string `json:"community"`
+	Repo      string `json:"repo"`
+
+	Welcomed int     `json:"welcomed"`
+	Reacted  int     `json:"reacted"`
+	Replied  int     `json:"replied"`
+	Rate     float64 `json:"rate"`
+}
+
+type engagementState struct {
+	Pending []welcomedItem              `json:"pending"`
+	Stats   map[string]*engagementStats `json:"stats"`
+}
+
+// engagementTracker tracks whether the contributors react to or reply to
+// the welcomes in the days after, which is saved to the file if the path
+// is set.
+type engagementTracker struct {
+	lock  sync.Mutex
+	path  string
+	state engagementState
+}
+
+func newEngagementTracker(path string) *engagementTracker {
+	t := &engagementTracker{path: path}
+
+	if path != "" {
+		if err := loadStateFile(path, &t.state); err != nil {
+			logrus.WithError(err).Errorf("load the engagement: %s", path)
+
+			t.state = engagementState{}
+		}
+	}
+
+	if t.state.Stats == nil {
+		t.state.Stats = make(map[string]*engagementStats)
+	}
+
+	return t
+}
+
+// save must be called with the lock held.
+func (t *engagementTracker) save() {
+	if t.path == "" {
+		return
+	}
+
+	if err := saveStateFile(t.path, &t.state); err != nil {
+		logrus.WithError(err).Errorf("save the engagement: %s", t.path)
+	}
+}
+
+// statsOf must be called with the lock held.
+func (t *engagementTracker) statsOf(v *welcomedItem) *engagementStats {
+	repo := v.Org + "/" + v.Repo
+
+	s, ok := t.state.Stats[repo]
+	if !ok {
+		s = &engagementStats{Community: v.Community, Repo: repo}
+		t.state.Stats[repo] = s
+	}
+
+	return s
+}
+
+func (t *engagementTracker) record(v welcomedItem) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for i := range t.state.Pending {
+		if t.state.Pending[i].is(&v) {
+			return
+		}
+	}
+
+	t.state.Pending = append(t.state.Pending, v)
+	t.statsOf(&v).Welcomed++
+
+	t.save()
+}
+
+func (t *engagementTracker) pending() []welcomedItem {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	r := make([]welcomedItem, len(t.state.Pending))
+	copy(r, t.state.Pending)
+
+	return r
+}
+
+// update saves the item checked, and removes it if done is true.
+func (t *engagementTracker) update(v welcomedItem, done bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for i := range t.state.Pending {
+		p := &t.state.Pending[i]
+		if !p.is(&v) {
+			continue
+		}
+
+		s := t.statsOf(&v)
+		if v.Reacted && !p.Reacted {
+			s.Reacted++
+			engagementTotal.inc(s.Repo, engagementReaction)
+		}
+
+		if v.Replied && !p.Replied {
+			s.Replied++
+			engagementTotal.inc(s.Repo, engagementReply)
+		}
+
+		if done {
+			t.state.Pending = append(t.state.Pending[:i], t.state.Pending[i+1:]...)
+		} else {
+			*p = v
+		}
+
+		break
+	}
+
+	t.save()
+}
+
+func (t *engagementTracker) stats() []engagementStats {
+	t.lock.Lock()
+	r := make([]engagementStats, 0, len(t.state.Stats))
+	for _, s := range t.state.Stats {
+		v := *s
+		if v.Welcomed > 0 {
+			v.Rate = float64(v.Replied) / float64(v.Welcomed)
+		}
+		r = append(r, v)
+	}
+	t.lock.Unlock()
+
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].Repo < r[j].Repo
+	})
+
+	return r
+}
+
+// checkEngagements checks every interval whether the contributors react to
+// or reply to the welcomes of the last days, and thanks them for the first
+// reply if configured. Only the leader runs it if the leader election is
+// enabled.
+func (bot *robot) checkEngagements(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for range t.C {
+		if bot.elector != nil && !bot.elector.isLeader() {
+			continue
+		}
+
+		c, err := bot.getConfig()
+		if err != nil {
+			logrus.WithError(err).Error("get config to check the engagements")
+
+			continue
+		}
+
+		for _, v := range bot.engagements.pending() {
+			cfg := c.configFor(v.Org, v.Repo)
+			if cfg == nil || !cfg.Engagement.Enabled {
+				bot.engagements.update(v, true)
+
+				continue
+			}
+
+			log := logrus.WithFields(logrus.Fields{
+				"org":    v.Org,
+				"repo":   v.Repo,
+				"number": v.Number,
+			})
+
+			r, err := bot.checkEngagement(v, cfg, log)
+			if err != nil {
+				log.WithError(err).Error("check the engagement")
+
+				continue
+			}
+
+			expired := time.Since(v.WelcomedAt) >= time.Duration(cfg.Engagement.Days)*24*time.Hour
+			bot.engagements.update(r, expired || (r.Reacted && r.Replied))
+		}
+	}
+}
+
+func (bot *robot) checkEngagement(v welcomedItem, cfg *botConfig, log *logrus.Entry) (welcomedItem, error) {
+	acts := v.actions(bot.clientFor(v.Org))
+
+	notes, err := acts.(noteEditor).listNotes()
+	if err != nil {
+		return v, err
+	}
+
+	if v.NoteID == 0 {
+		if v.NoteID = findWelcomeNote(notes); v.NoteID == 0 {
+			return v, nil
+		}
+	}
+
+	if !v.Replied && repliedAfter(notes, v.Author, v.NoteID) {
+		v.Replied = true
+
+		if err := bot.thankForReply(&v, acts, cfg); err != nil {
+			log.WithError(err).Error("thank for the reply")
+		}
+	}
+
+	if !v.Reacted {
+		emojis, err := bot.listWelcomeAwardEmoji(&v)
+		if err != nil {
+			return v, err
+		}
+
+		for _, e := range emojis {
+			if e.User.Username == v.Author {
+				v.Reacted = true
+
+				break
+			}
+		}
+	}
+
+	return v, nil
+}
+
+func (bot *robot) listWelcomeAwardEmoji(v *welcomedItem) ([]*gitlab.AwardEmoji, error) {
+	if v.IsIssue {
+		return bot.cli.ListIssueNoteAwardEmoji(v.ProjectID, v.Number, v.NoteID)
+	}
+
+	return bot.cli.ListMergeRequestNoteAwardEmoji(v.ProjectID, v.Number, v.NoteID)
+}
+
+func (bot *robot) thankForReply(v *welcomedItem, acts actions, cfg *botConfig) error {
+	if cfg.Engagement.ThankMessage == "" {
+		return nil
+	}
+
+	comment, err := renderTemplate(cfg.Engagement.ThankMessage, map[string]interface{}{
+		"Author":    escapeUsername(v.Author),
+		"Community": cfg.CommunityName,
+		"Links":     cfg.Links,
+	})
+	if err != nil {
+		return err
+	}
+
+	return acts.addComment(comment)
+}
+
+// findWelcomeNote returns the id of the first welcome comment, or 0 if
+// there is none.
+func findWelcomeNote(notes []*gitlab.Note) int {
+	for _, n := range notes {
+		if !n.System && strings.Contains(n.Body, commentFooterPrefix) {
+			return n.ID
+		}
+	}
+
+	return 0
+}
+
+// repliedAfter reports whether author commented after the note.
+func repliedAfter(notes []*gitlab.Note, author string, noteID int) bool {
+	for _, n := range notes {
+		if !n.System && n.ID > noteID && n.Author.Username == author {
+			return true
+		}
+	}
+
+	return false
+}
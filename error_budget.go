@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	apiErrorsTotal = newCounterVec(
+		"welcome_gitlab_api_errors_total",
+		"Number of failed GitLab API calls.",
+		"project",
+	)
+
+	apiErrorsInWindow = newGaugeVec(
+		"welcome_gitlab_api_errors_in_window",
+		"Number of failed GitLab API calls in the sliding window of error budget.",
+		"project",
+	)
+)
+
+type projectErrorBudget struct {
+	Project   int  `json:"project"`
+	Errors    int  `json:"errors"`
+	Exhausted bool `json:"exhausted"`
+}
+
+// errorBudget counts the failures of GitLab API calls per project in a
+// sliding window.
+type errorBudget struct {
+	lock     sync.Mutex
+	failures map[int][]time.Time
+}
+
+func newErrorBudget() *errorBudget {
+	return &errorBudget{failures: make(map[int][]time.Time)}
+}
+
+func (b *errorBudget) record(pid int) {
+	b.lock.Lock()
+	b.failures[pid] = append(b.failures[pid], time.Now())
+	b.lock.Unlock()
+
+	apiErrorsTotal.inc(strconv.Itoa(pid))
+}
+
+// count returns the failures of the project in the window and drops the
+// ones out of it.
+func (b *errorBudget) count(pid int, window time.Duration) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.countLocked(pid, window)
+}
+
+func (b *errorBudget) countLocked(pid int, window time.Duration) int {
+	start := time.Now().Add(-window)
+
+	v := b.failures[pid]
+	i := 0
+	for i < len(v) && v[i].Before(start) {
+		i++
+	}
+
+	if i == len(v) {
+		delete(b.failures, pid)
+	} else {
+		b.failures[pid] = v[i:]
+	}
+
+	n := len(v) - i
+	apiErrorsInWindow.set(float64(n), strconv.Itoa(pid))
+
+	return n
+}
+
+// exhausted reports whether the project has used up its error budget.
+func (b *errorBudget) exhausted(pid int, cfg *errorBudgetConfig) bool {
+	if cfg.MaxErrors <= 0 {
+		return false
+	}
+
+	return b.count(pid, cfg.window()) >= cfg.MaxErrors
+}
+
+func (b *errorBudget) list(window time.Duration, maxErrors int) []projectErrorBudget {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	pids := make([]int, 0, len(b.failures))
+	for pid := range b.failures {
+		pids = append(pids, pid)
+	}
+
+	r := make([]projectErrorBudget, 0, len(pids))
+	for _, pid := range pids {
+		n := b.countLocked(pid, window)
+		if n == 0 {
+			continue
+		}
+
+		r = append(r, projectErrorBudget{
+			Project:   pid,
+			Errors:    n,
+			Exhausted: maxErrors > 0 && n >= maxErrors,
+		})
+	}
+
+	return r
+}
+
+// errorBudgetHandler lists the projects which have failures in the window.
+// The window and the budget are passed as the query parameters of
+// window(seconds) and max_errors.
+func (bot *robot) errorBudgetHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := errorBudgetConfig{}
+	cfg.setDefault()
+
+	if v, err := strconv.Atoi(r.URL.Query().Get("window")); err == nil && v > 0 {
+		cfg.Window = v
+	}
+
+	if v, err := strconv.Atoi(r.URL.Query().Get("max_errors")); err == nil {
+		cfg.MaxErrors = v
+	}
+
+	writeJSON(w, bot.errorBudget.list(cfg.window(), cfg.MaxErrors))
+}
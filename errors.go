@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	errorKindConfig     = "config"
+	errorKindGitlab4xx  = "gitlab_4xx"
+	errorKindGitlab5xx  = "gitlab_5xx"
+	errorKindNetwork    = "network"
+	errorKindDataFormat = "data_format"
+	errorKindOther      = "other"
+)
+
+var errorsTotal = newCounterVec(
+	"welcome_errors_total",
+	"Number of the errors of handling the events by kind.",
+	"kind", "retryable",
+)
+
+// configError is the error caused by the config of the robot or of the
+// repo, which is fixed only by the maintainers of the config.
+type configError struct {
+	err error
+}
+
+func (e *configError) Error() string {
+	return "invalid config: " + e.err.Error()
+}
+
+func (e *configError) Unwrap() error {
+	return e.err
+}
+
+func newConfigError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &configError{err: err}
+}
+
+// dataFormatError is the error caused by the data which can't be parsed,
+// such as a file too large or encoded unexpectedly.
+type dataFormatError struct {
+	err error
+}
+
+func (e *dataFormatError) Error() string {
+	return e.err.Error()
+}
+
+func (e *dataFormatError) Unwrap() error {
+	return e.err
+}
+
+func newDataFormatError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &dataFormatError{err: err}
+}
+
+// errorKind returns the kind of error. It is coarser than the class of
+// classifyError and tells who can fix the error and whether retrying it
+// may succeed.
+func errorKind(err error) string {
+	var ce *configError
+	if errors.As(err, &ce) {
+		return errorKindConfig
+	}
+
+	var re *gitlab.ErrorResponse
+	if errors.As(err, &re) && re.Response != nil {
+		if re.Response.StatusCode >= 500 {
+			return errorKindGitlab5xx
+		}
+
+		return errorKindGitlab4xx
+	}
+
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return errorKindNetwork
+	}
+
+	var de *dataFormatError
+	var se *json.SyntaxError
+	var te *json.UnmarshalTypeError
+	var be base64.CorruptInputError
+	if errors.As(err, &de) || errors.As(err, &se) || errors.As(err, &te) || errors.As(err, &be) {
+		return errorKindDataFormat
+	}
+
+	// sigs.k8s.io/yaml doesn't export the types of its errors.
+	if s := err.Error(); strings.Contains(s, "error converting YAML to JSON") ||
+		strings.Contains(s, "error unmarshaling JSON") {
+		return errorKindDataFormat
+	}
+
+	return errorKindOther
+}
+
+// isRetryable reports whether retrying the event may succeed. The errors
+// of GitLab 5xx, rate limit and network are transient, while the others
+// fail again until somebody fixes the cause.
+func isRetryable(err error) bool {
+	if m, ok := err.(*multiError); ok {
+		for _, e := range m.errs {
+			if isRetryable(e) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	switch errorKind(err) {
+	case errorKindGitlab5xx, errorKindNetwork:
+		return true
+	case errorKindGitlab4xx:
+		return classifyError(err) == errorClassRateLimited
+	default:
+		return false
+	}
+}
+
+// errorKinds returns the sorted kinds of the errors in err.
+func errorKinds(err error) []string {
+	if m, ok := err.(*multiError); ok {
+		v := sets.NewString()
+		for _, e := range m.errs {
+			v.Insert(errorKinds(e)...)
+		}
+
+		return v.List()
+	}
+
+	return []string{errorKind(err)}
+}
+
+// multiError collects the errors of the independent steps of handling an
+// event, keeping each of them to be classified.
+type multiError struct {
+	errs []error
+}
+
+func newMultiError() *multiError {
+	return new(multiError)
+}
+
+func (m *multiError) add(err error) {
+	if err == nil {
+		return
+	}
+
+	if v, ok := err.(*multiError); ok {
+		m.errs = append(m.errs, v.errs...)
+	} else {
+		m.errs = append(m.errs, err)
+	}
+}
+
+// err returns nil if there is no error, or the error itself.
+func (m *multiError) err() error {
+	if len(m.errs) == 0 {
+		return nil
+	}
+
+	return m
+}
+
+func (m *multiError) Error() string {
+	v := make([]string, len(m.errs))
+	for i, e := range m.errs {
+		v[i] = e.Error()
+	}
+
+	return strings.Join(v, ". ")
+}
+
+// httpStatusOf returns the status code of the webhook response to the
+// event which failed with err. GitLab treats the non-2xx responses as
+// failures, so only the retryable errors are reported, and the others are
+// accepted so that the webhook isn't disabled for what retrying can't fix.
+// The events dispatched by the framework are responded by it regardless,
+// unless they are served at /gitlab-sync-hook.
+func httpStatusOf(err error) int {
+	if err == nil || !isRetryable(err) {
+		return http.StatusOK
+	}
+
+	var re *gitlab.ErrorResponse
+	if errors.As(err, &re) && re.Response != nil && re.Response.StatusCode == http.StatusTooManyRequests {
+		return http.StatusTooManyRequests
+	}
+
+	return http.StatusServiceUnavailable
+}
+
+// observeError counts err by its kinds and logs it with them.
+func observeError(err error, msg string, log *logrus.Entry) {
+	if err == nil {
+		return
+	}
+
+	kinds := errorKinds(err)
+	retryable := isRetryable(err)
+
+	for _, k := range kinds {
+		errorsTotal.inc(k, strconv.FormatBool(retryable))
+	}
+
+	log.WithError(err).WithFields(logrus.Fields{
+		"error_kind": strings.Join(kinds, ","),
+		"retryable":  retryable,
+	}).Error(msg)
+}
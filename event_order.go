@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+var orderedQueueDepth = newGaugeVec(
+	"welcome_ordered_queue_depth",
+	"Number of the events waiting to be processed in order after the ones of the same target.",
+)
+
+// orderedEvent is an event waiting in the queue of its target.
+type orderedEvent struct {
+	handle func() error
+	done   chan error
+}
+
+// eventOrdering processes the events of the same merge request or issue one
+// by one in the order they arrive, so that an update or a close following
+// the open immediately can't interleave with it. Each target has a queue of
+// its own, and the queues are drained by a pool of workers, so that a
+// handler waiting for the limiter or sleeping before a retry only holds up
+// the events of its own target.
+type eventOrdering struct {
+	lock sync.Mutex
+
+	// queues are the events waiting of the targets which are ready or
+	// being drained.
+	queues map[string][]*orderedEvent
+	ready  chan string
+}
+
+// newEventOrdering returns nil if there is no worker, which disables the
+// ordering.
+func newEventOrdering(workers int) *eventOrdering {
+	if workers <= 0 {
+		return nil
+	}
+
+	o := &eventOrdering{
+		queues: make(map[string][]*orderedEvent),
+		ready:  make(chan string, workers),
+	}
+
+	for i := 0; i < workers; i++ {
+		go o.serve()
+	}
+
+	return o
+}
+
+func (o *eventOrdering) serve() {
+	for target := range o.ready {
+		o.drain(target)
+	}
+}
+
+// drain runs the events of target until its queue is empty, and then drops
+// the queue, so that the next event makes the target ready again.
+func (o *eventOrdering) drain(target string) {
+	for {
+		o.lock.Lock()
+
+		q := o.queues[target]
+		if len(q) == 0 {
+			delete(o.queues, target)
+			o.lock.Unlock()
+
+			return
+		}
+
+		e := q[0]
+		o.queues[target] = q[1:]
+
+		o.lock.Unlock()
+
+		orderedQueueDepth.add(-1)
+
+		e.done <- e.handle()
+	}
+}
+
+// run runs handle after the events of the target which arrived earlier,
+// and waits for it. It runs handle at once if o is nil.
+func (o *eventOrdering) run(target string, handle func() error) error {
+	if o == nil {
+		return handle()
+	}
+
+	e := &orderedEvent{handle: handle, done: make(chan error, 1)}
+	o.enqueue(target, e)
+
+	return <-e.done
+}
+
+// post runs handle after the events of the target which arrived earlier
+// without waiting for it, so that the reader of a message broker keeps
+// reading. It runs handle in a goroutine if o is nil.
+func (o *eventOrdering) post(target string, handle func()) {
+	if o == nil {
+		go handle()
+
+		return
+	}
+
+	o.enqueue(target, &orderedEvent{
+		handle: func() error {
+			handle()
+
+			return nil
+		},
+		done: make(chan error, 1),
+	})
+}
+
+func (o *eventOrdering) enqueue(target string, e *orderedEvent) {
+	orderedQueueDepth.add(1)
+
+	o.lock.Lock()
+	q, ok := o.queues[target]
+	o.queues[target] = append(q, e)
+	o.lock.Unlock()
+
+	// the target is drained already if it has a queue. The order of the
+	// targets doesn't matter, so the caller doesn't wait for a worker.
+	if !ok {
+		select {
+		case o.ready <- target:
+		default:
+			go func() { o.ready <- target }()
+		}
+	}
+}
+
+// actionsTarget returns the target of the item which acts act on. The items
+// on other platforms are told by the author.
+func actionsTarget(pid int, author string, acts actions) string {
+	switch a := acts.(type) {
+	case *mrActions:
+		return mrTarget(a.projectID, a.number)
+	case *issueActions:
+		return issueTarget(a.projectID, a.number)
+	default:
+		return fmt.Sprintf("%d@%s", pid, author)
+	}
+}
+
+func mrTarget(pid, number int) string {
+	return fmt.Sprintf("%d!%d", pid, number)
+}
+
+func issueTarget(pid, number int) string {
+	return fmt.Sprintf("%d#%d", pid, number)
+}
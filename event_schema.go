@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	objectKindWorkItem = "work_item"
+
+	schemaFixCoerced = "coerced"
+	schemaFixDropped = "dropped"
+
+	// maxToleratedFields is the most fields of a payload to fix before
+	// giving up, as the payload is not of the event decoded then.
+	maxToleratedFields = 16
+)
+
+var (
+	eventSchemaAdaptedTotal = newCounterVec(
+		"welcome_event_schema_adapted_total",
+		"Number of the event payloads converted from the schema of other GitLab versions.",
+		"adapter", "versions",
+	)
+
+	eventSchemaToleratedTotal = newCounterVec(
+		"welcome_event_schema_tolerated_total",
+		"Number of the fields of the event payloads which are coerced or dropped as they are not of the type expected.",
+		"field", "fix",
+	)
+)
+
+// gitlabVersionRe matches the user agent of the GitLab webhooks, such as
+// GitLab/16.0.1.
+var gitlabVersionRe = regexp.MustCompile(`^GitLab/(\S+)`)
+
+func gitlabVersionOf(userAgent string) string {
+	if m := gitlabVersionRe.FindStringSubmatch(userAgent); m != nil {
+		return m[1]
+	}
+
+	return ""
+}
+
+// payloadAdapter converts the payload in the schema of some GitLab versions
+// to the one the handlers expect. The schema is detected by the shape of the
+// payload instead of the version of GitLab, because the events consumed
+// from the message broker don't tell the version.
+type payloadAdapter struct {
+	name string
+
+	// versions are the GitLab versions sending the payloads adapted.
+	versions string
+
+	applies func(p map[string]interface{}) bool
+	adapt   func(p map[string]interface{})
+}
+
+var payloadAdapters = []payloadAdapter{
+	{
+		name:     "project-from-repository",
+		versions: "8.x",
+		applies: func(p map[string]interface{}) bool {
+			_, ok := p["project"]
+			_, repo := p["repository"].(map[string]interface{})
+
+			return !ok && repo
+		},
+		adapt: adaptProjectFromRepository,
+	},
+	{
+		name:     "work-item-as-issue",
+		versions: "17.x",
+		applies: func(p map[string]interface{}) bool {
+			if p["object_kind"] != objectKindWorkItem {
+				return false
+			}
+
+			attrs, _ := p["object_attributes"].(map[string]interface{})
+			t, _ := attrs["type"].(string)
+
+			return t == "Issue" || t == "Incident"
+		},
+		adapt: func(p map[string]interface{}) {
+			p["object_kind"] = objectKindIssue
+			p["event_type"] = objectKindIssue
+		},
+	},
+}
+
+// adaptProjectFromRepository fills the project which the payloads of GitLab
+// 8 don't have by the repository.
+func adaptProjectFromRepository(p map[string]interface{}) {
+	repo := p["repository"].(map[string]interface{})
+	attrs, _ := p["object_attributes"].(map[string]interface{})
+
+	project := map[string]interface{}{}
+
+	for _, k := range []string{"name", "description", "homepage", "git_ssh_url", "git_http_url"} {
+		if v, ok := repo[k]; ok {
+			project[k] = v
+		}
+	}
+
+	if home, _ := repo["homepage"].(string); home != "" {
+		project["web_url"] = home
+
+		if u, err := url.Parse(home); err == nil {
+			path := strings.Trim(u.Path, "/")
+			project["path_with_namespace"] = path
+
+			if i := strings.LastIndex(path, "/"); i > 0 {
+				project["namespace"] = path[:i]
+			}
+		}
+	}
+
+	for _, v := range []interface{}{p["project_id"], attrs["target_project_id"], attrs["project_id"]} {
+		if v != nil {
+			project["id"] = v
+
+			break
+		}
+	}
+
+	p["project"] = project
+}
+
+func decodePayload(payload []byte) (map[string]interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(payload))
+	d.UseNumber()
+
+	var p map[string]interface{}
+	if err := d.Decode(&p); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// adaptPayload converts the payload of the webhook to the schema the
+// handlers expect, and returns the adapters applied. The payload is
+// returned as it is if none applies, or if it is not a JSON object, which
+// fails when it is decoded.
+func adaptPayload(payload []byte) ([]byte, []string) {
+	p, err := decodePayload(payload)
+	if err != nil || p == nil {
+		return payload, nil
+	}
+
+	var applied []string
+	for i := range payloadAdapters {
+		if a := &payloadAdapters[i]; a.applies(p) {
+			a.adapt(p)
+			applied = append(applied, a.name)
+
+			eventSchemaAdaptedTotal.inc(a.name, a.versions)
+		}
+	}
+
+	if len(applied) == 0 {
+		return payload, nil
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return payload, nil
+	}
+
+	return b, applied
+}
+
+// decodeEvent decodes the payload to the event v tolerantly. The fields of
+// the payload which are not of the type expected, such as the ids in
+// strings, are coerced to the type if possible, or dropped, so that the
+// fields newer GitLab changes don't fail the whole event.
+func decodeEvent(payload []byte, v interface{}) error {
+	err := json.Unmarshal(payload, v)
+
+	te, ok := err.(*json.UnmarshalTypeError)
+	if !ok {
+		return err
+	}
+
+	p, err := decodePayload(payload)
+	if err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(v).Elem()
+
+	for i := 0; i < maxToleratedFields; i++ {
+		path := strings.Split(te.Field, ".")
+
+		fix := fixPayloadField(p, path, te.Type)
+		if fix == "" {
+			return newDataFormatError(te)
+		}
+
+		eventSchemaToleratedTotal.inc(fieldPattern(path), fix)
+
+		b, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+
+		rv.Set(reflect.Zero(rv.Type()))
+
+		err = json.Unmarshal(b, v)
+		if te, ok = err.(*json.UnmarshalTypeError); !ok {
+			return err
+		}
+	}
+
+	return newDataFormatError(te)
+}
+
+// fieldPattern drops the indexes of the arrays from the path of the field,
+// so that the fields of the elements count as one.
+func fieldPattern(path []string) string {
+	r := make([]string, 0, len(path))
+	for _, s := range path {
+		if _, err := strconv.Atoi(s); err != nil {
+			r = append(r, s)
+		}
+	}
+
+	return strings.Join(r, ".")
+}
+
+// fixPayloadField coerces the field at path of node to the type t, or drops
+// it. The path has the indexes of the arrays or not, depending on the
+// version of Go, and the fields of all the elements are fixed if not.
+func fixPayloadField(node interface{}, path []string, t reflect.Type) string {
+	switch v := node.(type) {
+	case []interface{}:
+		if len(path) > 0 {
+			if i, err := strconv.Atoi(path[0]); err == nil {
+				if i < 0 || i >= len(v) {
+					return ""
+				}
+
+				if len(path) == 1 {
+					return fixArrayElement(v, i, t)
+				}
+
+				return fixPayloadField(v[i], path[1:], t)
+			}
+		}
+
+		fix := ""
+		for i := range v {
+			r := ""
+			if len(path) == 0 {
+				r = fixArrayElement(v, i, t)
+			} else {
+				r = fixPayloadField(v[i], path, t)
+			}
+
+			if r != "" {
+				fix = r
+			}
+		}
+
+		return fix
+
+	case map[string]interface{}:
+		if len(path) == 0 {
+			return ""
+		}
+
+		k, ok := payloadKey(v, path[0])
+		if !ok {
+			return ""
+		}
+
+		if len(path) > 1 {
+			return fixPayloadField(v[k], path[1:], t)
+		}
+
+		// the path of the elements of array ends with the array in the
+		// older versions of Go.
+		if a, ok := v[k].([]interface{}); ok && t.Kind() != reflect.Slice {
+			return fixPayloadField(a, nil, t)
+		}
+
+		if r, ok := coercePayloadValue(v[k], t); ok {
+			v[k] = r
+
+			return schemaFixCoerced
+		}
+
+		delete(v, k)
+
+		return schemaFixDropped
+	}
+
+	return ""
+}
+
+// fixArrayElement fixes the element of array which is not of the type t.
+func fixArrayElement(a []interface{}, i int, t reflect.Type) string {
+	if b, err := json.Marshal(a[i]); err == nil && json.Unmarshal(b, reflect.New(t).Interface()) == nil {
+		return ""
+	}
+
+	if r, ok := coercePayloadValue(a[i], t); ok {
+		a[i] = r
+
+		return schemaFixCoerced
+	}
+
+	a[i] = nil
+
+	return schemaFixDropped
+}
+
+// payloadKey finds the key of the field, which is matched case-insensitively
+// as encoding/json does.
+func payloadKey(m map[string]interface{}, name string) (string, bool) {
+	if _, ok := m[name]; ok {
+		return name, true
+	}
+
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			return k, true
+		}
+	}
+
+	return "", false
+}
+
+// coercePayloadValue converts the scalar value to the scalar type t, such as
+// "12" to 12 and 12 to "12".
+func coercePayloadValue(v interface{}, t reflect.Type) (interface{}, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+
+		if s, ok := v.(string); ok {
+			if _, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				return json.Number(strings.TrimSpace(s)), true
+			}
+		}
+
+	case reflect.String:
+		switch s := v.(type) {
+		case json.Number:
+			return s.String(), true
+		case bool:
+			return strconv.FormatBool(s), true
+		}
+
+	case reflect.Bool:
+		if s, ok := v.(string); ok {
+			if b, err := strconv.ParseBool(s); err == nil {
+				return b, true
+			}
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// The fixtures under testdata/events are the payloads of the same events
+// sent by each GitLab version, one directory per version. The drift
+// directory has the synthetic payloads of the changes newer versions may
+// make, such as the ids in strings. To cover a new version, add the
+// payloads of the events below it, naming them as eventSummaries.
+
+// eventSummary is what the handlers read from the event.
+type eventSummary struct {
+	Kind      string
+	ProjectID int
+	Project   string
+	IID       int
+	Action    string
+	User      string
+	Note      string
+}
+
+var eventSummaries = map[string]eventSummary{
+	"merge_request.json": {
+		Kind:      objectKindMergeRequest,
+		ProjectID: 1,
+		Project:   "openeuler/community",
+		IID:       12,
+		Action:    "open",
+		User:      "newbie",
+	},
+	"issue.json": {
+		Kind:      objectKindIssue,
+		ProjectID: 1,
+		Project:   "openeuler/community",
+		IID:       34,
+		Action:    "open",
+		User:      "newbie",
+	},
+	"note_merge_request.json": {
+		Kind:      "MergeRequest",
+		ProjectID: 1,
+		Project:   "openeuler/community",
+		IID:       12,
+		User:      "maintainer",
+		Note:      "/welcome-info",
+	},
+	"note_issue.json": {
+		Kind:      "Issue",
+		ProjectID: 1,
+		Project:   "openeuler/community",
+		IID:       34,
+		User:      "maintainer",
+		Note:      "/welcome-info",
+	},
+}
+
+func username(u *gitlab.EventUser) string {
+	if u == nil {
+		return ""
+	}
+
+	return u.Username
+}
+
+// summarizeEvent decodes the payload as the handlers of payloadHandler do.
+func summarizeEvent(payload []byte) (eventSummary, error) {
+	payload, _ = adaptPayload(payload)
+
+	kind, err := objectKindOf(payload)
+	if err != nil {
+		return eventSummary{}, err
+	}
+
+	switch kind {
+	case objectKindMergeRequest:
+		e := new(gitlab.MergeEvent)
+		if err := decodeEvent(payload, e); err != nil {
+			return eventSummary{}, err
+		}
+
+		return eventSummary{
+			Kind:      kind,
+			ProjectID: e.Project.ID,
+			Project:   e.Project.PathWithNamespace,
+			IID:       e.ObjectAttributes.IID,
+			Action:    e.ObjectAttributes.Action,
+			User:      username(e.User),
+		}, nil
+
+	case objectKindIssue:
+		e := new(gitlab.IssueEvent)
+		if err := decodeEvent(payload, e); err != nil {
+			return eventSummary{}, err
+		}
+
+		return eventSummary{
+			Kind:      kind,
+			ProjectID: e.Project.ID,
+			Project:   e.Project.PathWithNamespace,
+			IID:       e.ObjectAttributes.IID,
+			Action:    e.ObjectAttributes.Action,
+			User:      username(e.User),
+		}, nil
+
+	case objectKindNote:
+		var note struct {
+			ObjectAttributes struct {
+				NoteableType string `json:"noteable_type"`
+			} `json:"object_attributes"`
+		}
+		if err := json.Unmarshal(payload, &note); err != nil {
+			return eventSummary{}, err
+		}
+
+		t := note.ObjectAttributes.NoteableType
+
+		if t == "MergeRequest" {
+			e := new(gitlab.MergeCommentEvent)
+			if err := decodeEvent(payload, e); err != nil {
+				return eventSummary{}, err
+			}
+
+			return eventSummary{
+				Kind:      t,
+				ProjectID: e.Project.ID,
+				Project:   e.Project.PathWithNamespace,
+				IID:       e.MergeRequest.IID,
+				User:      username(e.User),
+				Note:      e.ObjectAttributes.Note,
+			}, nil
+		}
+
+		e := new(gitlab.IssueCommentEvent)
+		if err := decodeEvent(payload, e); err != nil {
+			return eventSummary{}, err
+		}
+
+		return eventSummary{
+			Kind:      t,
+			ProjectID: e.Project.ID,
+			Project:   e.Project.PathWithNamespace,
+			IID:       e.Issue.IID,
+			User:      username(e.User),
+			Note:      e.ObjectAttributes.Note,
+		}, nil
+	}
+
+	return eventSummary{Kind: kind}, nil
+}
+
+func TestEventFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "events", "*", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) == 0 {
+		t.Fatal("no event fixtures")
+	}
+
+	for _, f := range files {
+		f := f
+		name := filepath.Base(f)
+
+		t.Run(strings.TrimPrefix(filepath.ToSlash(f), "testdata/events/"), func(t *testing.T) {
+			want, ok := eventSummaries[name]
+			if !ok {
+				t.Fatalf("unknown event fixture %s", name)
+			}
+
+			b, err := ioutil.ReadFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := summarizeEvent(b)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+
+			if got != want {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestAdaptPayloadUnchanged(t *testing.T) {
+	payload := []byte(`{"object_kind":"issue","project":{"id":1}}`)
+
+	got, applied := adaptPayload(payload)
+	if string(got) != string(payload) || len(applied) != 0 {
+		t.Errorf("adaptPayload changed the payload to %s by %v", got, applied)
+	}
+
+	for _, v := range []string{``, `null`, `[1]`, `{"object_kind":`} {
+		if got, _ := adaptPayload([]byte(v)); string(got) != v {
+			t.Errorf("adaptPayload(%q) = %q, want it as it is", v, got)
+		}
+	}
+}
+
+func TestDecodeEvent(t *testing.T) {
+	var v struct {
+		IDs   []int `json:"ids"`
+		Items []struct {
+			Name string `json:"name"`
+		} `json:"items"`
+		Draft bool   `json:"draft"`
+		Title string `json:"title"`
+	}
+
+	payload := `{"ids":[1,"2",{"x":3}],"items":[{"name":4},{"name":"b"}],"draft":"true","title":{"text":"t"}}`
+	if err := decodeEvent([]byte(payload), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(v.IDs) != 3 || v.IDs[0] != 1 || v.IDs[1] != 2 || v.IDs[2] != 0 {
+		t.Errorf("ids = %v, want [1 2 0]", v.IDs)
+	}
+
+	if len(v.Items) != 2 || v.Items[0].Name != "4" || v.Items[1].Name != "b" {
+		t.Errorf("items = %+v", v.Items)
+	}
+
+	if !v.Draft || v.Title != "" {
+		t.Errorf("draft = %v, title = %q", v.Draft, v.Title)
+	}
+
+	if err := decodeEvent([]byte(`{"ids":`), &v); err == nil {
+		t.Error("decodeEvent accepted the malformed payload")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import "github.com/sirupsen/logrus"
+
+var exemptItemsTotal = newCounterVec(
+	"welcome_exempt_items_total",
+	"Number of the merge requests and issues not welcomed for their exempt labels by label.",
+	"label",
+)
+
+// exemptLabel returns the exempt label which the merge request or issue
+// carries when it is created, such as the one set by the templates or the
+// release automation. It is empty if there is none.
+func (c *botConfig) exemptLabel(meta *itemMeta) string {
+	if meta == nil {
+		return ""
+	}
+
+	for _, l := range c.ExemptLabels {
+		if containsString(meta.Labels, l) {
+			return l
+		}
+	}
+
+	return ""
+}
+
+// handleExempt handles the merge request or issue with an exempt label,
+// which is neither commented nor mentioned. Only the sig labels are added
+// if configured.
+func (bot *robot) handleExempt(
+	org, repo, author, label string, pid, number int, meta *itemMeta,
+	acts actions, cfg *botConfig, log *logrus.Entry,
+) error {
+	exemptItemsTotal.inc(label)
+
+	log = log.WithField("exempt_label", label)
+	if !cfg.ExemptSigLabels || cfg.DryRun {
+		log.Info("skip the welcome of exempt item")
+
+		return nil
+	}
+
+	plan, err := bot.genPlan(org, repo, author, number, pid, nil, meta, cfg, log)
+	if err != nil {
+		return err
+	}
+
+	mErr := newMultiError()
+
+	for _, l := range plan.Labels {
+		if sig, ok := bot.sigOfLabel(l, cfg); !ok || sig == "" || containsString(meta.Labels, l) {
+			continue
+		}
+
+		if err := bot.createLabelIfNeed(acts, pid, l, log); err != nil {
+			log.Errorf("create repo label:%s, err:%s", l, err.Error())
+		}
+
+		mErr.add(acts.addLabel(l))
+	}
+
+	return mErr.err()
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	objectKindWikiPage = "wiki_page"
+	objectKindEpic     = "epic"
+
+	actionCreate = "create"
+
+	wikiGuidanceTemplate = `
+Hi ***{{ .Author }}***, thanks for creating the wiki page [{{ .Title }}]({{ .URL }}) of {{ .Repo }}.
+{{- if .Sig }}
+The repo belongs to the SIG: [{{ .Sig }}](https://gitee.com/openeuler/community/tree/master/sig/{{ .SigPath }}), please keep the page consistent with its documents.
+{{- end }}
+{{- if .Maintainers }} You can ask any of the maintainers for review: {{ mention .Maintainers | join " , " }}{{ end }}`
+
+	epicGuidanceTemplate = `
+Hi ***{{ .Author }}***, welcome to the {{ .Community }} Community.
+Please add the label of the SIG this epic belongs to, such as {{ .LabelExample }}, so that its maintainers can follow it.
+You can find the instructions on how to interact with me at **[Here]({{ .CommandLink }})**.`
+)
+
+// wikiPageEvent is the payload of the wiki page event of GitLab.
+type wikiPageEvent struct {
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Project struct {
+		ID                int    `json:"id"`
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+	ObjectAttributes struct {
+		Title  string `json:"title"`
+		URL    string `json:"url"`
+		Action string `json:"action"`
+	} `json:"object_attributes"`
+}
+
+// epicEvent is the payload of the epic event of a group webhook.
+type epicEvent struct {
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Group struct {
+		ID       int    `json:"id"`
+		FullPath string `json:"full_path"`
+	} `json:"group"`
+	ObjectAttributes struct {
+		ID     int    `json:"id"`
+		Action string `json:"action"`
+	} `json:"object_attributes"`
+	Labels []struct {
+		Title string `json:"title"`
+	} `json:"labels"`
+}
+
+// extraEventsHandler serves the GitLab webhook of the events which are not
+// dispatched by the framework, namely the wiki page and pipeline events of
+// projects, the epic events of groups and the system hooks.
+func (bot *robot) extraEventsHandler(getSecret func() []byte) http.HandlerFunc {
+	return webhookHandler(getSecret, bot.extraEventHandler)
+}
+
+// syncEventsHandler serves the GitLab webhook of all the events, including
+// the ones dispatched by the framework, which are handled before
+// responding, so that GitLab retries the retryable failures.
+func (bot *robot) syncEventsHandler(getSecret func() []byte) http.HandlerFunc {
+	return webhookHandler(getSecret, bot.payloadHandler)
+}
+
+func objectKindOf(payload []byte) (string, error) {
+	var kind struct {
+		ObjectKind string `json:"object_kind"`
+	}
+	if err := json.Unmarshal(payload, &kind); err != nil {
+		return "", err
+	}
+
+	return kind.ObjectKind, nil
+}
+
+// extraEventHandler returns the handler of the event which is not
+// dispatched by the framework. It is nil if the event is not supported.
+func (bot *robot) extraEventHandler(payload []byte, log *logrus.Entry) (func() error, error) {
+	kind, err := objectKindOf(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case objectKindWikiPage:
+		e := new(wikiPageEvent)
+		if err := decodeEvent(payload, e); err != nil {
+			return nil, err
+		}
+
+		return func() error { return bot.handleWikiPageEvent(e, log) }, nil
+
+	case objectKindEpic:
+		e := new(epicEvent)
+		if err := decodeEvent(payload, e); err != nil {
+			return nil, err
+		}
+
+		return func() error { return bot.handleEpicEvent(e, log) }, nil
+
+	case objectKindPipeline:
+		e := new(gitlab.PipelineEvent)
+		if err := decodeEvent(payload, e); err != nil {
+			return nil, err
+		}
+
+		return func() error { return bot.handlePipelineEvent(e, log) }, nil
+
+	// the system hooks have no object_kind.
+	case "":
+		return bot.systemHookHandler(payload, log)
+
+	default:
+		return nil, nil
+	}
+}
+
+// handleWikiPageEvent posts the guidance to the tracking issue configured,
+// because the wiki page can't be commented on.
+func (bot *robot) handleWikiPageEvent(e *wikiPageEvent, log *logrus.Entry) error {
+	if e.ObjectAttributes.Action != actionCreate {
+		return nil
+	}
+
+	v := strings.Split(e.Project.PathWithNamespace, "/")
+	if len(v) < 2 {
+		return fmt.Errorf("invalid project path: %s", e.Project.PathWithNamespace)
+	}
+
+	org, repo := strings.Join(v[:len(v)-1], "/"), v[len(v)-1]
+
+	cfg, err := bot.botConfigFor(org, repo, log)
+	if err != nil || cfg == nil {
+		return err
+	}
+
+	if !cfg.Wiki.Enabled || cfg.Wiki.Issue <= 0 {
+		return nil
+	}
+
+	pid := e.Project.ID
+
+	sigName, err := bot.getSigOfRepo(org, repo, pid, cfg)
+	if err != nil {
+		return err
+	}
+
+	var maintainers []string
+	if sigName != "" {
+		if maintainers, _, err = bot.getMaintainers(org, repo, sigName, 0, pid, nil, cfg, log); err != nil {
+			log.WithError(err).Error("get maintainers of sig")
+		}
+	}
+
+	comment, err := renderTemplate(wikiGuidanceTemplate, map[string]interface{}{
+		"Author":      escapeUsername(e.User.Username),
+		"Title":       sanitizeText(e.ObjectAttributes.Title),
+		"URL":         sanitizeURL(e.ObjectAttributes.URL),
+		"Repo":        e.Project.PathWithNamespace,
+		"Sig":         sanitizeText(sigName),
+		"SigPath":     sanitizePathSegment(sigName),
+		"Maintainers": escapeUsernames(maintainers),
+	})
+	if err != nil {
+		return err
+	}
+
+	if cfg.DryRun {
+		log.WithField("comment", comment).Info("wiki page guidance")
+
+		return nil
+	}
+
+	return bot.clientFor(org).CreateIssueComment(pid, cfg.Wiki.Issue, comment)
+}
+
+// handleEpicEvent comments on the epic opened without a sig label. The
+// config of the group is the one applied to it as an org.
+func (bot *robot) handleEpicEvent(e *epicEvent, log *logrus.Entry) error {
+	if e.ObjectAttributes.Action != actionOpen {
+		return nil
+	}
+
+	group := e.Group.FullPath
+
+	cfg, err := bot.botConfigFor(group, "", log)
+	if err != nil || cfg == nil {
+		return err
+	}
+
+	if !cfg.Epic.Enabled {
+		return nil
+	}
+
+	for _, l := range e.Labels {
+		if sig, ok := bot.sigOfLabel(l.Title, cfg); ok && sig != "" {
+			return nil
+		}
+	}
+
+	prefix := bot.sigLabel("", cfg)
+
+	comment, err := renderTemplate(epicGuidanceTemplate, map[string]interface{}{
+		"Author":       escapeUsername(e.User.Username),
+		"Community":    cfg.CommunityName,
+		"CommandLink":  cfg.commandLink(),
+		"LabelExample": "`" + prefix + "storage`",
+	})
+	if err != nil {
+		return err
+	}
+
+	if cfg.DryRun {
+		log.WithField("comment", comment).Info("epic guidance")
+
+		return nil
+	}
+
+	return bot.clientFor(group).CreateEpicNote(e.Group.ID, e.ObjectAttributes.ID, comment)
+}
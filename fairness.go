@@ -0,0 +1,177 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// mentionLedgerTTL is how long the mentions are kept, which bounds the
+// days of fairness.
+const mentionLedgerTTL = 90 * 24 * time.Hour
+
+// mentionLedger records when each member is mentioned or assigned, which
+// is saved to the file if the path is set.
+type mentionLedger struct {
+	lock  sync.Mutex
+	path  string
+	items map[string][]time.Time
+}
+
+func newMentionLedger(path string) *mentionLedger {
+	l := &mentionLedger{path: path, items: make(map[string][]time.Time)}
+
+	if path == "" {
+		return l
+	}
+
+	if err := loadStateFile(path, &l.items); err != nil || l.items == nil {
+		logrus.WithError(err).Errorf("load the mention ledger: %s", path)
+
+		l.items = make(map[string][]time.Time)
+	}
+
+	return l
+}
+
+func (l *mentionLedger) record(members []string) {
+	if len(members) == 0 {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	for _, m := range members {
+		l.items[m] = append(l.items[m], now)
+	}
+
+	for k, v := range l.items {
+		i := 0
+		for i < len(v) && now.Sub(v[i]) > mentionLedgerTTL {
+			i++
+		}
+
+		if i == len(v) {
+			delete(l.items, k)
+		} else {
+			l.items[k] = v[i:]
+		}
+	}
+
+	if l.path != "" {
+		if err := saveStateFile(l.path, l.items); err != nil {
+			logrus.WithError(err).Errorf("save the mention ledger: %s", l.path)
+		}
+	}
+}
+
+type mentionStat struct {
+	Member   string    `json:"member"`
+	Mentions int       `json:"mentions"`
+	Last     time.Time `json:"last,omitempty"`
+}
+
+// statOf must be called with the lock held.
+func (l *mentionLedger) statOf(member string, since time.Time) mentionStat {
+	s := mentionStat{Member: member}
+
+	for _, t := range l.items[member] {
+		if t.After(since) {
+			s.Mentions++
+			s.Last = t
+		}
+	}
+
+	return s
+}
+
+// pick returns at most max members which are mentioned the least in the
+// last days, in their original order. The less recently mentioned one is
+// preferred if they are mentioned equally.
+func (l *mentionLedger) pick(members []string, max, days int) []string {
+	if max <= 0 || len(members) <= max {
+		return members
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+
+	l.lock.Lock()
+	stats := make([]mentionStat, len(members))
+	for i, m := range members {
+		stats[i] = l.statOf(m, since)
+	}
+	l.lock.Unlock()
+
+	index := make([]int, len(members))
+	for i := range index {
+		index[i] = i
+	}
+
+	sort.SliceStable(index, func(i, j int) bool {
+		a, b := stats[index[i]], stats[index[j]]
+		if a.Mentions != b.Mentions {
+			return a.Mentions < b.Mentions
+		}
+
+		return a.Last.Before(b.Last)
+	})
+
+	index = index[:max]
+	sort.Ints(index)
+
+	r := make([]string, len(index))
+	for i, k := range index {
+		r[i] = members[k]
+	}
+
+	return r
+}
+
+// stats returns the mentions of each member in the last days, the most
+// mentioned first.
+func (l *mentionLedger) stats(days int) []mentionStat {
+	since := time.Now().AddDate(0, 0, -days)
+
+	l.lock.Lock()
+	r := make([]mentionStat, 0, len(l.items))
+	for m := range l.items {
+		if s := l.statOf(m, since); s.Mentions > 0 {
+			r = append(r, s)
+		}
+	}
+	l.lock.Unlock()
+
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].Mentions != r[j].Mentions {
+			return r[i].Mentions > r[j].Mentions
+		}
+
+		return r[i].Member < r[j].Member
+	})
+
+	return r
+}
+
+// mentionFairnessHandler serves the mentions of members in the last days,
+// 30 by default, so that the sig leads can verify the load distribution.
+func (bot *robot) mentionFairnessHandler(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid days", http.StatusBadRequest)
+
+			return
+		}
+
+		days = n
+	}
+
+	writeJSON(w, bot.mentions.stats(days))
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var faqMatchesTotal = newCounterVec(
+	"welcome_faq_matches_total",
+	"Number of the issues checked against the FAQ rules by rule and result.",
+	"rule", "result",
+)
+
+const (
+	faqResultMatched = "matched"
+	faqResultWeak    = "weak"
+)
+
+type faqConfig struct {
+	// Rules are the FAQ knowledge base. The answers of the rules matching
+	// the text of a new issue are appended to the welcome comment.
+	Rules []faqRule `json:"rules,omitempty"`
+
+	// Title is the heading of the answers. The default value is
+	// "This might answer your question:".
+	Title string `json:"title,omitempty"`
+
+	// MaxAnswers is the maximum number of answers appended. The default
+	// value is 3.
+	MaxAnswers int `json:"max_answers,omitempty"`
+}
+
+func (c *faqConfig) setDefault() {
+	if c.Title == "" {
+		c.Title = "This might answer your question:"
+	}
+
+	if c.MaxAnswers <= 0 {
+		c.MaxAnswers = 3
+	}
+
+	for i := range c.Rules {
+		c.Rules[i].setDefault()
+	}
+}
+
+func (c *faqConfig) validate() error {
+	for i := range c.Rules {
+		if err := c.Rules[i].validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type faqRule struct {
+	// Name identifies the rule in the metrics.
+	Name string `json:"name" required:"true"`
+
+	// Patterns are the regular expressions of the title and description of
+	// issue. Any of them matching is confident.
+	Patterns []string `json:"patterns,omitempty"`
+
+	// Keywords are matched case-insensitively. It is confident if at least
+	// MinKeywords of them are found.
+	Keywords []string `json:"keywords,omitempty"`
+
+	// MinKeywords is the number of keywords to be found. The default value
+	// is 2, or the number of Keywords if it is less.
+	MinKeywords int `json:"min_keywords,omitempty"`
+
+	// Answer is the text of answer, and Link is the link to the details.
+	Answer string `json:"answer" required:"true"`
+	Link   string `json:"link,omitempty"`
+}
+
+func (r *faqRule) setDefault() {
+	if r.MinKeywords <= 0 {
+		r.MinKeywords = 2
+	}
+
+	if n := len(r.Keywords); n > 0 && r.MinKeywords > n {
+		r.MinKeywords = n
+	}
+}
+
+func (r *faqRule) validate() error {
+	if r.Name == "" || r.Answer == "" {
+		return fmt.Errorf("the name and answer of faq rule must be set")
+	}
+
+	if len(r.Patterns) == 0 && len(r.Keywords) == 0 {
+		return fmt.Errorf("the faq rule %s has neither patterns nor keywords", r.Name)
+	}
+
+	for _, p := range r.Patterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("invalid pattern of faq rule %s: %v", r.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// match reports whether the rule matches the text confidently. weak means
+// some keywords are found but too few, which helps tune the rule.
+func (r *faqRule) match(text string) (matched, weak bool) {
+	for _, p := range r.Patterns {
+		// the patterns are validated.
+		if regexp.MustCompile(p).MatchString(text) {
+			return true, false
+		}
+	}
+
+	if len(r.Keywords) == 0 {
+		return false, false
+	}
+
+	lower := strings.ToLower(text)
+
+	n := 0
+	for _, k := range r.Keywords {
+		if strings.Contains(lower, strings.ToLower(k)) {
+			n++
+		}
+	}
+
+	return n >= r.MinKeywords, n > 0 && n < r.MinKeywords
+}
+
+// genFAQAnswers returns the answers of the rules matching the issue, which
+// is empty if none matches.
+func genFAQAnswers(meta *itemMeta, cfg *faqConfig) string {
+	if meta == nil || meta.IsMergeRequest || len(cfg.Rules) == 0 {
+		return ""
+	}
+
+	text := meta.Title + "\n" + meta.Description
+
+	var answers []string
+	for i := range cfg.Rules {
+		r := &cfg.Rules[i]
+
+		matched, weak := r.match(text)
+		switch {
+		case matched:
+			faqMatchesTotal.inc(r.Name, faqResultMatched)
+		case weak:
+			faqMatchesTotal.inc(r.Name, faqResultWeak)
+		}
+
+		if !matched || len(answers) >= cfg.MaxAnswers {
+			continue
+		}
+
+		if r.Link != "" {
+			answers = append(answers, fmt.Sprintf("- [%s](%s)", r.Answer, r.Link))
+		} else {
+			answers = append(answers, "- "+r.Answer)
+		}
+	}
+
+	if len(answers) == 0 {
+		return ""
+	}
+
+	return cfg.Title + "\n" + strings.Join(answers, "\n")
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	sigFileBranch = "master"
+	fileCacheTTL  = 10 * time.Minute
+
+	// the file of a commit never changes, so it is kept long, and swept
+	// by the cache once it expires, so that the files of the commits not
+	// read any more don't hold the memory.
+	fileCommitCacheTTL = 24 * time.Hour
+
+	defaultMaxFileSize = 1 << 20
+
+	fileEncodingAuto   = "auto"
+	fileEncodingBase64 = "base64"
+	fileEncodingText   = "text"
+)
+
+var fileEncodings = []string{fileEncodingAuto, fileEncodingBase64, fileEncodingText}
+
+// contentDecoder decodes the content of the files returned by
+// GetPathContent. GitLab encodes it in base64 normally, but some versions and
+// proxies return it as is, so the encoding is taken from the response unless
+// it is forced.
+type contentDecoder struct {
+	// maxSize is the maximum size in bytes of the file decoded. The larger
+	// files are refused before being decoded or cached. 0 means unlimited.
+	maxSize int
+
+	// encoding is auto, base64 or text. auto follows the encoding of the
+	// response, and tries base64 first if the response has none.
+	encoding string
+}
+
+// checkSize refuses the file larger than maxSize. The size reported by
+// GitLab is checked first, and the length of the content as well in case
+// it is not reported.
+func (d contentDecoder) checkSize(f *gitlab.File) error {
+	if d.maxSize <= 0 {
+		return nil
+	}
+
+	n := len(f.Content)
+	if f.Encoding != fileEncodingText {
+		n = base64.StdEncoding.DecodedLen(n)
+	}
+
+	if f.Size > n {
+		n = f.Size
+	}
+
+	if n > d.maxSize {
+		return newDataFormatError(fmt.Errorf(
+			"the file %s is %d bytes, larger than the limit of %d bytes", f.FilePath, n, d.maxSize,
+		))
+	}
+
+	return nil
+}
+
+// decode returns the content of f.
+func (d contentDecoder) decode(f *gitlab.File) ([]byte, error) {
+	if err := d.checkSize(f); err != nil {
+		return nil, err
+	}
+
+	encoding := d.encoding
+	if encoding == "" || encoding == fileEncodingAuto {
+		encoding = strings.ToLower(f.Encoding)
+	}
+
+	switch encoding {
+	case fileEncodingBase64:
+		return decodeBase64(f.Content)
+
+	case fileEncodingText:
+		return []byte(f.Content), nil
+
+	case "":
+		if b, err := decodeBase64(f.Content); err == nil {
+			return b, nil
+		}
+
+		return []byte(f.Content), nil
+
+	default:
+		return nil, newDataFormatError(fmt.Errorf(
+			"the encoding %s of file %s is not supported", f.Encoding, f.FilePath,
+		))
+	}
+}
+
+// decodeBase64 decodes s, which may be wrapped into lines and may have no
+// padding.
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == ' ' {
+			return -1
+		}
+
+		return r
+	}, s)
+
+	if strings.HasSuffix(s, "=") || len(s)%4 == 0 {
+		return base64.StdEncoding.DecodeString(s)
+	}
+
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+func sigOwnersFile(sig string) string {
+	return fmt.Sprintf("sig/%s/OWNERS", sig)
+}
+
+func sigInfoFile(sig string) string {
+	return fmt.Sprintf("sig/%s/sig-info.yaml", sig)
+}
+
+func fileCacheKey(pid interface{}, file, branch string) string {
+	return fmt.Sprintf("%v/%s/%s", pid, branch, file)
+}
+
+func fileCommitCacheKey(pid interface{}, file, sha string) string {
+	return fmt.Sprintf("%v/%s@%s", pid, file, sha)
+}
+
+// getPathContent is the cached version of GetPathContent. If the cache is
+// keyed by commit, the last commit of the file is checked on each read, so
+// that the update of file takes effect at once without the short TTL.
+func (bot *robot) getPathContent(pid interface{}, file, branch string) (*gitlab.File, error) {
+	key := fileCacheKey(pid, file, branch)
+
+	if bot.fileCacheByCommit {
+		sha, err := bot.cli.GetFileLastCommit(pid, file, branch)
+		if err != nil {
+			logrus.WithError(err).Warnf("get the last commit of %s, use the cache by TTL", file)
+		} else if sha != "" {
+			key = fileCommitCacheKey(pid, file, sha)
+		}
+	}
+
+	if v, ok := bot.files.get(key); ok {
+		return v.(*gitlab.File), nil
+	}
+
+	f, err := bot.cli.GetPathContent(pid, file, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	// the large file is not cached, so that it doesn't take the memory.
+	if err := bot.content.checkSize(f); err != nil {
+		return nil, err
+	}
+
+	ttl := fileCacheTTL
+	if key != fileCacheKey(pid, file, branch) {
+		ttl = fileCommitCacheTTL
+	}
+
+	bot.files.set(key, f, ttl)
+
+	return f, nil
+}
+
+// fileContent returns the decoded content of f.
+func (bot *robot) fileContent(f *gitlab.File) ([]byte, error) {
+	return bot.content.decode(f)
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	giteeAPI = "https://gitee.com/api/v5"
+
+	// giteeSignatureMaxAge is how far the timestamp of the signed webhook
+	// may be from now, which allows for the clock skew.
+	giteeSignatureMaxAge = 5 * time.Minute
+)
+
+// giteeClient is a minimal client of Gitee API v5 which is enough to apply
+// the action plan to the issues and pull requests of Gitee. The requests
+// are sent by hc, so that the proxy and CA of deployment are applied.
+type giteeClient struct {
+	getToken func() []byte
+	hc       *http.Client
+}
+
+func (c *giteeClient) do(method, path string, body interface{}, result interface{}) error {
+	var payload []byte
+	if body != nil {
+		v, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		payload = v
+	}
+
+	req, err := http.NewRequest(
+		method,
+		fmt.Sprintf("%s%s?access_token=%s", giteeAPI, path, url.QueryEscape(string(c.getToken()))),
+		bytes.NewReader(payload),
+	)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	v, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("gitee responds %d: %s", resp.StatusCode, string(v))
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(v, result)
+}
+
+func (c *giteeClient) createComment(org, repo, kind, number, comment string) error {
+	return c.do(
+		http.MethodPost,
+		fmt.Sprintf("/repos/%s/%s/%s/%s/comments", org, repo, kind, number),
+		map[string]string{"body": comment}, nil,
+	)
+}
+
+func (c *giteeClient) addLabels(org, repo, kind, number string, labels []string) error {
+	return c.do(
+		http.MethodPost,
+		fmt.Sprintf("/repos/%s/%s/%s/%s/labels", org, repo, kind, number),
+		labels, nil,
+	)
+}
+
+func (c *giteeClient) createLabelIfNeed(org, repo, label string) error {
+	var v []struct {
+		Name string `json:"name"`
+	}
+
+	if err := c.do(http.MethodGet, fmt.Sprintf("/repos/%s/%s/labels", org, repo), nil, &v); err != nil {
+		return err
+	}
+
+	for i := range v {
+		if v[i].Name == label {
+			return nil
+		}
+	}
+
+	return c.do(
+		http.MethodPost,
+		fmt.Sprintf("/repos/%s/%s/labels", org, repo),
+		map[string]string{"name": label, "color": "0052cc"}, nil,
+	)
+}
+
+const (
+	giteeKindPR    = "pulls"
+	giteeKindIssue = "issues"
+)
+
+// giteeActions applies the plan to an issue or a pull request of Gitee.
+// The number of Gitee issue is a string like I4ABCD.
+type giteeActions struct {
+	cli    *giteeClient
+	org    string
+	repo   string
+	kind   string
+	author string
+	number string
+}
+
+func (a *giteeActions) addComment(comment string) error {
+	return a.cli.createComment(a.org, a.repo, a.kind, a.number, mirrorComment(comment, a.author))
+}
+
+func (a *giteeActions) addLabel(label string) error {
+	return a.cli.addLabels(a.org, a.repo, a.kind, a.number, []string{label})
+}
+
+func (a *giteeActions) createLabelIfNeed(label string) error {
+	return a.cli.createLabelIfNeed(a.org, a.repo, label)
+}
+
+func (a *giteeActions) assign(ids []int) error {
+	return nil
+}
+
+type giteeItem struct {
+	Number json.Number `json:"number"`
+	User   struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+type giteeEvent struct {
+	Action      string     `json:"action"`
+	Issue       *giteeItem `json:"issue,omitempty"`
+	PullRequest *giteeItem `json:"pull_request,omitempty"`
+	Repository  struct {
+		Namespace string `json:"namespace"`
+		Path      string `json:"path"`
+	} `json:"repository"`
+}
+
+// giteeHandler serves the webhook of Gitee. Like GitHub, the repositories on
+// Gitee are the mirrors of the ones on GitLab which have the same path.
+func (bot *robot) giteeHandler(cli *giteeClient, getSecret func() []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !validateGiteeToken(r.Header.Get("X-Gitee-Token"), r.Header.Get("X-Gitee-Timestamp"), getSecret()) {
+			http.Error(w, "invalid token", http.StatusForbidden)
+
+			return
+		}
+
+		eventType := r.Header.Get("X-Gitee-Event")
+		if eventType != "Issue Hook" && eventType != "Merge Request Hook" {
+			w.WriteHeader(http.StatusOK)
+
+			return
+		}
+
+		var e giteeEvent
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		log := logrus.WithFields(logrus.Fields{
+			"event-type": eventType,
+			"event-id":   r.Header.Get("X-Gitee-Delivery"),
+		})
+
+		go func() {
+			observeError(bot.handleGiteeEvent(&e, cli, log), "handle gitee event", log)
+		}()
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (bot *robot) handleGiteeEvent(e *giteeEvent, cli *giteeClient, log *logrus.Entry) error {
+	if e.Action != actionOpen {
+		return nil
+	}
+
+	item, kind := e.Issue, giteeKindIssue
+	if e.PullRequest != nil {
+		item, kind = e.PullRequest, giteeKindPR
+	}
+
+	if item == nil {
+		return nil
+	}
+
+	org, repo := e.Repository.Namespace, e.Repository.Path
+
+	return bot.handleMirrorEvent(
+		org, repo, item.User.Login,
+		&giteeActions{cli: cli, org: org, repo: repo, kind: kind, author: item.User.Login, number: item.Number.String()},
+		&itemMeta{IsMergeRequest: kind == giteeKindPR},
+		log,
+	)
+}
+
+// validateGiteeToken supports both the password and the signature mode of
+// the webhook of Gitee. It rejects the token if the secret is not
+// configured, so that the webhook can't be forged, and the signature whose
+// timestamp is not within giteeSignatureMaxAge, so that the captured
+// requests can't be replayed.
+func validateGiteeToken(token, timestamp string, secret []byte) bool {
+	if len(secret) == 0 {
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), secret) == 1 {
+		return true
+	}
+
+	// the timestamp is in milliseconds.
+	ms, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	if d := time.Since(time.Unix(0, ms*int64(time.Millisecond))); d > giteeSignatureMaxAge || d < -giteeSignatureMaxAge {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "\n" + string(secret)))
+
+	return hmac.Equal([]byte(token), []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil))))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func giteeSignature(timestamp string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "\n" + string(secret)))
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidateGiteeToken(t *testing.T) {
+	secret := []byte("secret")
+
+	at := func(d time.Duration) string {
+		return strconv.FormatInt(time.Now().Add(d).UnixNano()/int64(time.Millisecond), 10)
+	}
+
+	now, old, future := at(0), at(-10*time.Minute), at(10*time.Minute)
+
+	cases := []struct {
+		name      string
+		token     string
+		timestamp string
+		secret    []byte
+		want      bool
+	}{
+		{"password", "secret", "", secret, true},
+		{"wrong password", "secrets", "", secret, false},
+		{"signature", giteeSignature(now, secret), now, secret, true},
+		{"wrong signature", giteeSignature(now, []byte("other")), now, secret, false},
+		{"replayed signature", giteeSignature(old, secret), old, secret, false},
+		{"future signature", giteeSignature(future, secret), future, secret, false},
+		{"malformed timestamp", giteeSignature("now", secret), "now", secret, false},
+		{"no secret", "", "", nil, false},
+	}
+
+	for _, c := range cases {
+		if got := validateGiteeToken(c.token, c.timestamp, c.secret); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestMirrorComment(t *testing.T) {
+	got := mirrorComment("Hi @newbie, @alice and @bob. will review it. Mail a@b.com.", "newbie")
+	want := "Hi @newbie, alice and bob. will review it. Mail a@b.com."
+
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
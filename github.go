@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const githubAPI = "https://api.github.com"
+
+// githubClient is a minimal client of GitHub REST API which is enough to
+// apply the action plan to the issues and pull requests of GitHub. The
+// requests are sent by hc, so that the proxy and CA of deployment are
+// applied.
+type githubClient struct {
+	getToken func() []byte
+	hc       *http.Client
+}
+
+func (c *githubClient) do(method, path string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, githubAPI+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "token "+string(c.getToken()))
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		v, _ := ioutil.ReadAll(resp.Body)
+
+		return fmt.Errorf("github responds %d: %s", resp.StatusCode, string(v))
+	}
+
+	return nil
+}
+
+func (c *githubClient) createComment(org, repo string, number int, comment string) error {
+	return c.do(
+		http.MethodPost,
+		fmt.Sprintf("/repos/%s/%s/issues/%d/comments", org, repo, number),
+		map[string]string{"body": comment},
+	)
+}
+
+func (c *githubClient) addLabels(org, repo string, number int, labels []string) error {
+	return c.do(
+		http.MethodPost,
+		fmt.Sprintf("/repos/%s/%s/issues/%d/labels", org, repo, number),
+		map[string][]string{"labels": labels},
+	)
+}
+
+// githubActions applies the plan to an issue or a pull request of GitHub.
+// GitHub creates the label automatically when it is added and does not
+// exist, so createLabelIfNeed does nothing.
+type githubActions struct {
+	cli    *githubClient
+	org    string
+	repo   string
+	author string
+	number int
+}
+
+func (a *githubActions) addComment(comment string) error {
+	return a.cli.createComment(a.org, a.repo, a.number, mirrorComment(comment, a.author))
+}
+
+func (a *githubActions) addLabel(label string) error {
+	return a.cli.addLabels(a.org, a.repo, a.number, []string{label})
+}
+
+func (a *githubActions) createLabelIfNeed(label string) error {
+	return nil
+}
+
+func (a *githubActions) assign(ids []int) error {
+	return nil
+}
+
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+type githubItem struct {
+	Number int        `json:"number"`
+	User   githubUser `json:"user"`
+}
+
+type githubEvent struct {
+	Action      string      `json:"action"`
+	Issue       *githubItem `json:"issue,omitempty"`
+	PullRequest *githubItem `json:"pull_request,omitempty"`
+	Repository  struct {
+		Name  string     `json:"name"`
+		Owner githubUser `json:"owner"`
+	} `json:"repository"`
+}
+
+// githubHandler serves the webhook of GitHub. The repositories on GitHub are
+// the mirrors of the ones on GitLab which have the same path, so the sig
+// and maintainers are still resolved from GitLab.
+func (bot *robot) githubHandler(cli *githubClient, getSecret func() []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		if !validateGithubSignature(r.Header.Get("X-Hub-Signature-256"), payload, getSecret()) {
+			http.Error(w, "invalid signature", http.StatusForbidden)
+
+			return
+		}
+
+		eventType := r.Header.Get("X-GitHub-Event")
+		if eventType != "issues" && eventType != "pull_request" {
+			w.WriteHeader(http.StatusOK)
+
+			return
+		}
+
+		var e githubEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		log := logrus.WithFields(logrus.Fields{
+			"event-type": eventType,
+			"event-id":   r.Header.Get("X-GitHub-Delivery"),
+		})
+
+		go func() {
+			observeError(bot.handleGithubEvent(&e, cli, log), "handle github event", log)
+		}()
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (bot *robot) handleGithubEvent(e *githubEvent, cli *githubClient, log *logrus.Entry) error {
+	if e.Action != "opened" {
+		return nil
+	}
+
+	item := e.Issue
+	if e.PullRequest != nil {
+		item = e.PullRequest
+	}
+
+	if item == nil {
+		return nil
+	}
+
+	org, repo := e.Repository.Owner.Login, e.Repository.Name
+
+	return bot.handleMirrorEvent(
+		org, repo, item.User.Login,
+		&githubActions{cli: cli, org: org, repo: repo, author: item.User.Login, number: item.Number},
+		&itemMeta{IsMergeRequest: e.PullRequest != nil},
+		log,
+	)
+}
+
+// validateGithubSignature rejects the payload if the secret is not
+// configured, so that the webhook can't be forged.
+func validateGithubSignature(sig string, payload, secret []byte) bool {
+	if len(secret) == 0 {
+		return false
+	}
+
+	sig = strings.TrimPrefix(sig, "sha256=")
+
+	v, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+
+	return hmac.Equal(v, mac.Sum(nil))
+}
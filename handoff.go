@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	// handoffMarker identifies the comment of handoff, so that only the
+	// first human taking over the issue is welcomed.
+	handoffMarker = "<!-- " + botName + "-robot: handoff -->"
+
+	handoffCacheTTL = 30 * 24 * time.Hour
+
+	defaultHandoffTemplate = `Hi ***{{ .Author }}***, thanks for taking up this issue reported by ***{{ .Bot }}***. ` +
+		`The maintainers of the repo can help if you have any question.`
+)
+
+// gitlabBotNameRe matches the users of the project and group access tokens
+// of GitLab, which are bots.
+var gitlabBotNameRe = regexp.MustCompile(`^(project|group)_\d+_bot(_[0-9a-f]+)?\d*$`)
+
+type handoffConfig struct {
+	// Enabled means to welcome the first human commenting on the issue
+	// opened by a bot, such as the reporter of CI failures, who takes it
+	// over from the bot.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Bots are the usernames of the bots whose issues are handed off. The
+	// users of the access tokens of GitLab are always regarded as bots.
+	Bots []string `json:"bots,omitempty"`
+
+	// Label is added to the issue handed off. It is not added if not set.
+	Label string `json:"label,omitempty"`
+
+	// LabelOnly means to add the label without the comment.
+	LabelOnly bool `json:"label_only,omitempty"`
+
+	// Template is the go template of the comment, which can use .Author,
+	// the human, and .Bot. The default one thanks the human briefly.
+	Template string `json:"template,omitempty"`
+}
+
+func (c *handoffConfig) setDefault() {
+	if c.Template == "" {
+		c.Template = defaultHandoffTemplate
+	}
+}
+
+func (c *handoffConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.LabelOnly && c.Label == "" {
+		return fmt.Errorf("label of handoff must be set for label_only")
+	}
+
+	if _, err := parseTemplate(c.Template); err != nil {
+		return fmt.Errorf("invalid template of handoff: %v", err)
+	}
+
+	return nil
+}
+
+func (c *handoffConfig) isBot(user string) bool {
+	return containsString(c.Bots, user) || gitlabBotNameRe.MatchString(user)
+}
+
+func handoffKey(pid, number int) string {
+	return fmt.Sprintf("handoff/%d/%d", pid, number)
+}
+
+// handleHandoff welcomes the human commenting on the issue opened by a bot,
+// if nobody has taken it over before. The issue handed off is remembered,
+// and the marker of comment tells it after restart.
+func (bot *robot) handleHandoff(e *gitlab.IssueCommentEvent, log *logrus.Entry) error {
+	user := e.User.Username
+	pid, number := e.ProjectID, e.Issue.IID
+
+	i := strings.LastIndex(e.Project.PathWithNamespace, "/")
+	if i < 0 {
+		return nil
+	}
+
+	org, repo := e.Project.PathWithNamespace[:i], e.Project.PathWithNamespace[i+1:]
+
+	cfg, err := bot.botConfigFor(org, repo, log)
+	if err != nil || cfg == nil {
+		return err
+	}
+
+	c := &cfg.Handoff
+	if !c.Enabled || c.isBot(user) || strings.Contains(e.ObjectAttributes.Note, "<!-- "+botName+"-robot:") {
+		return nil
+	}
+
+	if _, ok := bot.handoffs.get(handoffKey(pid, number)); ok {
+		return nil
+	}
+
+	if bot.deferIfStandby(func() error { return bot.handleHandoff(e, log) }, log) {
+		return nil
+	}
+
+	cli := bot.clientFor(org)
+
+	issue, err := cli.GetIssue(pid, number)
+	if err != nil {
+		return err
+	}
+
+	if issue.Author == nil || !c.isBot(issue.Author.Username) {
+		return nil
+	}
+
+	// the label tells the issue handed off in label_only.
+	if c.Label != "" && containsString(issue.Labels, c.Label) {
+		bot.handoffs.set(handoffKey(pid, number), true, handoffCacheTTL)
+
+		return nil
+	}
+
+	notes, err := cli.ListIssueNotes(pid, number)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range notes {
+		if !n.System && strings.Contains(n.Body, handoffMarker) {
+			bot.handoffs.set(handoffKey(pid, number), true, handoffCacheTTL)
+
+			return nil
+		}
+	}
+
+	log = log.WithField("handoff", issue.Author.Username)
+	log.Info("the issue of bot is taken up by a human")
+
+	if cfg.DryRun {
+		return nil
+	}
+
+	acts := &issueActions{cli: cli, projectID: pid, number: number}
+	mErr := newMultiError()
+
+	if c.Label != "" {
+		if err := bot.createLabelIfNeed(acts, pid, c.Label, log); err != nil {
+			log.WithError(err).Errorf("create label %s", c.Label)
+		}
+		mErr.add(acts.addLabel(c.Label))
+	}
+
+	if !c.LabelOnly {
+		comment, err := renderTemplate(c.Template, map[string]interface{}{
+			"Author": escapeUsername(user),
+			"Bot":    escapeUsername(issue.Author.Username),
+		})
+		if err == nil {
+			err = acts.addComment(handoffMarker + "\n" + comment)
+		}
+		mErr.add(err)
+	}
+
+	if err := mErr.err(); err != nil {
+		return err
+	}
+
+	bot.handoffs.set(handoffKey(pid, number), true, handoffCacheTTL)
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	importedActionSkip  = "skip"
+	importedActionLabel = "label"
+	importedActionQuiet = "quiet"
+
+	defaultImportedLabel = "imported"
+)
+
+var importedItemsTotal = newCounterVec(
+	"welcome_imported_items_total",
+	"Number of the merge requests and issues created by the migration tools by action.",
+	"action",
+)
+
+type importedConfig struct {
+	// Authors are the service accounts of the migration tools, whose merge
+	// requests and issues are imported ones.
+	Authors []string `json:"authors,omitempty"`
+
+	// Markers are the text in the description which tells the item is
+	// imported, such as "*Created by:" added by the GitLab importers.
+	Markers []string `json:"markers,omitempty"`
+
+	// Action is what to do with the imported items instead of welcoming.
+	// It can be skip which does nothing, label which only adds Label, or
+	// quiet which adds Label and posts the welcome without mentions. The
+	// default value is quiet.
+	Action string `json:"action,omitempty"`
+
+	// Label is the archive label of the imported items. The default value
+	// is imported.
+	Label string `json:"label,omitempty"`
+}
+
+func (c *importedConfig) setDefault() {
+	if c.Action == "" {
+		c.Action = importedActionQuiet
+	}
+
+	if c.Label == "" {
+		c.Label = defaultImportedLabel
+	}
+}
+
+func (c *importedConfig) validate() error {
+	switch c.Action {
+	case "", importedActionSkip, importedActionLabel, importedActionQuiet:
+		return nil
+	default:
+		return fmt.Errorf("unsupported action of imported: %s", c.Action)
+	}
+}
+
+// isImported reports whether the merge request or issue is created by a
+// migration tool.
+func (c *importedConfig) isImported(author string, meta *itemMeta) bool {
+	if containsString(c.Authors, author) {
+		return true
+	}
+
+	if meta == nil {
+		return false
+	}
+
+	for _, m := range c.Markers {
+		if m != "" && strings.Contains(meta.Description, m) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleImported handles the imported merge request or issue, which is not
+// welcomed as usual, so that a bulk import doesn't cause a mention storm.
+func (bot *robot) handleImported(author string, pid int, acts actions, cfg *botConfig, log *logrus.Entry) error {
+	c := &cfg.Imported
+
+	importedItemsTotal.inc(c.Action)
+
+	log = log.WithField("imported_action", c.Action)
+	if c.Action == importedActionSkip || cfg.DryRun {
+		log.Info("skip the welcome of imported item")
+
+		return nil
+	}
+
+	if err := bot.createLabelIfNeed(acts, pid, c.Label, log); err != nil {
+		log.Errorf("create repo label:%s, err:%s", c.Label, err.Error())
+	}
+
+	if err := acts.addLabel(c.Label); err != nil {
+		return err
+	}
+
+	if c.Action != importedActionQuiet {
+		return nil
+	}
+
+	return bot.postComment(genSafeComment(author, cfg), acts, cfg, log)
+}
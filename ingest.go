@@ -0,0 +1,486 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	ingestNATS  = "nats"
+	ingestKafka = "kafka"
+
+	objectKindMergeRequest = "merge_request"
+	objectKindIssue        = "issue"
+	objectKindPush         = "push"
+	objectKindNote         = "note"
+
+	ingestRetryMaxInterval = time.Minute
+)
+
+// eventConsumer reads the payloads of GitLab webhook from a message broker
+// and passes each of them to handle until it fails to read or stop is
+// closed.
+type eventConsumer interface {
+	consume(handle func(payload []byte), stop <-chan struct{}) error
+}
+
+// handlePayload handles the payload of GitLab webhook read from the message
+// broker. The kind of event is decided by its object_kind. The event is
+// handled after the ones of the same target consumed earlier, but not
+// before the next one is read, so that a slow handler doesn't keep the
+// consumer from answering the broker.
+func (bot *robot) handlePayload(payload []byte) {
+	payload, adapted := adaptPayload(payload)
+
+	log := logrus.WithField("ingest", true)
+	if len(adapted) > 0 {
+		log = log.WithField("adapted", adapted)
+	}
+
+	handle, err := bot.payloadHandler(payload, log)
+	if err != nil {
+		observeError(err, "decode the event", log)
+
+		return
+	}
+
+	if handle == nil {
+		return
+	}
+
+	bot.ingestOrdering.post(payloadTarget(payload), func() {
+		observeError(handle(), "handle gitlab event", log)
+	})
+}
+
+// payloadTarget returns the merge request or issue the payload is about,
+// which is keyed as mrTarget and issueTarget do, or the kind and project
+// for the other events.
+func payloadTarget(payload []byte) string {
+	p, err := decodePayload(payload)
+	if err != nil {
+		return ""
+	}
+
+	field := func(v interface{}, keys ...string) interface{} {
+		for _, k := range keys {
+			m, _ := v.(map[string]interface{})
+			v = m[k]
+		}
+
+		return v
+	}
+
+	pid := field(p, "project", "id")
+
+	switch kind, _ := p["object_kind"].(string); kind {
+	case objectKindMergeRequest:
+		return fmt.Sprintf("%v!%v", pid, field(p, "object_attributes", "iid"))
+
+	case objectKindIssue:
+		return fmt.Sprintf("%v#%v", pid, field(p, "object_attributes", "iid"))
+
+	case objectKindNote:
+		switch field(p, "object_attributes", "noteable_type") {
+		case "MergeRequest":
+			return fmt.Sprintf("%v!%v", pid, field(p, "merge_request", "iid"))
+		case "Issue":
+			return fmt.Sprintf("%v#%v", pid, field(p, "issue", "iid"))
+		}
+
+		return fmt.Sprintf("%s/%v", kind, pid)
+
+	default:
+		return fmt.Sprintf("%s/%v", kind, pid)
+	}
+}
+
+// gitlabEventHandler handles the GitLab events, which is the robot itself or
+// the router of tenants.
+type gitlabEventHandler interface {
+	HandleMergeEvent(e *gitlab.MergeEvent, log *logrus.Entry) error
+	HandleIssueEvent(e *gitlab.IssueEvent, log *logrus.Entry) error
+	HandleMergeCommentEvent(e *gitlab.MergeCommentEvent, log *logrus.Entry) error
+	HandleIssueCommentEvent(e *gitlab.IssueCommentEvent, log *logrus.Entry) error
+	HandlePushEvent(e *gitlab.PushEvent, log *logrus.Entry) error
+}
+
+// gitlabEvents returns the handler of the events consumed, so that they are
+// dispatched to the tenants as the webhooks.
+func (bot *robot) gitlabEvents() gitlabEventHandler {
+	if bot.router != nil {
+		return bot.router
+	}
+
+	return bot
+}
+
+func (bot *robot) payloadHandler(payload []byte, log *logrus.Entry) (func() error, error) {
+	kind, err := objectKindOf(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case objectKindMergeRequest:
+		e := new(gitlab.MergeEvent)
+		if err := decodeEvent(payload, e); err != nil {
+			return nil, err
+		}
+
+		return func() error { return bot.gitlabEvents().HandleMergeEvent(e, log) }, nil
+
+	case objectKindIssue:
+		e := new(gitlab.IssueEvent)
+		if err := decodeEvent(payload, e); err != nil {
+			return nil, err
+		}
+
+		return func() error { return bot.gitlabEvents().HandleIssueEvent(e, log) }, nil
+
+	case objectKindNote:
+		return bot.noteEventHandler(payload, log)
+
+	case objectKindPush:
+		e := new(gitlab.PushEvent)
+		if err := decodeEvent(payload, e); err != nil {
+			return nil, err
+		}
+
+		return func() error { return bot.gitlabEvents().HandlePushEvent(e, log) }, nil
+
+	default:
+		return bot.extraEventHandler(payload, log)
+	}
+}
+
+// runConsumer keeps consuming the events until stop is closed, and
+// reconnects with exponential back-off when the connection is lost.
+func (bot *robot) runConsumer(c eventConsumer, stop <-chan struct{}) {
+	interval := time.Second
+
+	for {
+		start := time.Now()
+
+		err := c.consume(bot.handlePayload, stop)
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		logrus.WithError(err).Error("consume the events, reconnect later")
+
+		if time.Since(start) > ingestRetryMaxInterval {
+			interval = time.Second
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+
+		if interval *= 2; interval > ingestRetryMaxInterval {
+			interval = ingestRetryMaxInterval
+		}
+	}
+}
+
+// consumerRunner consumes the events only while the replica is the leader,
+// so that the standby doesn't take a share of the events from the group,
+// which it couldn't handle until it takes over.
+type consumerRunner struct {
+	lock     sync.Mutex
+	bot      *robot
+	consumer eventConsumer
+
+	// stop is nil if the consumer is not running.
+	stop chan struct{}
+}
+
+// sync starts or stops the consumer by the leadership of the replica. It
+// is called each time the leadership changes.
+func (r *consumerRunner) sync() {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	leader := r.bot.elector == nil || r.bot.elector.isLeader()
+
+	switch {
+	case leader && r.stop == nil:
+		logrus.Info("start consuming the events")
+
+		r.stop = make(chan struct{})
+		go r.bot.runConsumer(r.consumer, r.stop)
+
+	case !leader && r.stop != nil:
+		logrus.Info("stop consuming the events, as the replica is not the leader")
+
+		close(r.stop)
+		r.stop = nil
+	}
+}
+
+// natsConsumer subscribes to the subject of NATS in a queue group, so that
+// the messages are distributed among the replicas. It speaks the core
+// protocol of NATS, which is enough for subscribing.
+type natsConsumer struct {
+	url     string
+	subject string
+	queue   string
+}
+
+func (c *natsConsumer) consume(handle func([]byte), stop <-chan struct{}) error {
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", u.Host, 10*time.Second)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	// closing the connection unsubscribes and ends the reading below.
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-stop:
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	connect := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "robot-gitlab-" + botName,
+	}
+	if u.User != nil {
+		if p, ok := u.User.Password(); ok {
+			connect["user"], connect["pass"] = u.User.Username(), p
+		} else {
+			connect["auth_token"] = u.User.Username()
+		}
+	}
+
+	v, err := json.Marshal(connect)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nSUB %s %s 1\r\n", v, c.subject, c.queue); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case strings.HasPrefix(line, "MSG "):
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			fields := strings.Fields(line)
+
+			n, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil {
+				return fmt.Errorf("invalid message: %s", line)
+			}
+
+			payload := make([]byte, n+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return err
+			}
+
+			handle(payload[:n])
+
+		case line == "PING":
+			if _, err := io.WriteString(conn, "PONG\r\n"); err != nil {
+				return err
+			}
+
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", line)
+		}
+	}
+}
+
+// kafkaConsumer reads the topic in a consumer group through the consumer
+// API of a Kafka REST proxy. The offsets are committed automatically, so
+// the events can be replayed by resetting the offsets of the group.
+type kafkaConsumer struct {
+	url   string
+	topic string
+	group string
+	hc    *http.Client
+}
+
+const (
+	kafkaContentType = "application/vnd.kafka.v2+json"
+	kafkaJSONType    = "application/vnd.kafka.json.v2+json"
+)
+
+func (c *kafkaConsumer) do(method, url, accept string, body interface{}, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		v, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		payload = v
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", kafkaContentType)
+	req.Header.Set("Accept", accept)
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	v, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s responds %d: %s", url, resp.StatusCode, string(v))
+	}
+
+	if out == nil || len(v) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(v, out)
+}
+
+func (c *kafkaConsumer) consume(handle func([]byte), stop <-chan struct{}) error {
+	host, _ := os.Hostname()
+
+	var instance struct {
+		BaseURI string `json:"base_uri"`
+	}
+
+	err := c.do(
+		http.MethodPost, fmt.Sprintf("%s/consumers/%s", c.url, c.group), kafkaContentType,
+		map[string]string{
+			"name":               fmt.Sprintf("%s-%d", host, time.Now().UnixNano()),
+			"format":             "json",
+			"auto.offset.reset":  "earliest",
+			"auto.commit.enable": "true",
+		},
+		&instance,
+	)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = c.do(http.MethodDelete, instance.BaseURI, kafkaContentType, nil, nil)
+	}()
+
+	err = c.do(
+		http.MethodPost, instance.BaseURI+"/subscription", kafkaContentType,
+		map[string][]string{"topics": {c.topic}}, nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+
+		var records []struct {
+			Value json.RawMessage `json:"value"`
+		}
+
+		if err := c.do(http.MethodGet, instance.BaseURI+"/records", kafkaJSONType, nil, &records); err != nil {
+			return err
+		}
+
+		for _, r := range records {
+			handle(r.Value)
+		}
+
+		if len(records) == 0 {
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+// noteEventHandler returns the handler of the note event by the type of
+// the item commented. The comments of commits and snippets are ignored.
+func (bot *robot) noteEventHandler(payload []byte, log *logrus.Entry) (func() error, error) {
+	var note struct {
+		ObjectAttributes struct {
+			NoteableType string `json:"noteable_type"`
+		} `json:"object_attributes"`
+	}
+	if err := json.Unmarshal(payload, &note); err != nil {
+		return nil, err
+	}
+
+	switch note.ObjectAttributes.NoteableType {
+	case "MergeRequest":
+		e := new(gitlab.MergeCommentEvent)
+		if err := decodeEvent(payload, e); err != nil {
+			return nil, err
+		}
+
+		return func() error { return bot.gitlabEvents().HandleMergeCommentEvent(e, log) }, nil
+
+	case "Issue":
+		e := new(gitlab.IssueCommentEvent)
+		if err := decodeEvent(payload, e); err != nil {
+			return nil, err
+		}
+
+		return func() error { return bot.gitlabEvents().HandleIssueCommentEvent(e, log) }, nil
+
+	default:
+		return nil, nil
+	}
+}
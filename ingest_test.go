@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeNATS accepts one client, and records the lines of protocol it sends.
+type fakeNATS struct {
+	ln    net.Listener
+	conn  chan net.Conn
+	lines chan string
+}
+
+func newFakeNATS(t *testing.T) *fakeNATS {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeNATS{ln: ln, conn: make(chan net.Conn, 1), lines: make(chan string, 16)}
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.conn <- conn
+
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(s.lines)
+
+				return
+			}
+
+			s.lines <- strings.TrimRight(line, "\r\n")
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeNATS) expect(t *testing.T, prefix string) string {
+	t.Helper()
+
+	select {
+	case line, ok := <-s.lines:
+		if !ok {
+			t.Fatalf("the connection is closed, want %q", prefix)
+		}
+
+		if !strings.HasPrefix(line, prefix) {
+			t.Fatalf("got %q, want %q", line, prefix)
+		}
+
+		return line
+
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for %q", prefix)
+	}
+
+	return ""
+}
+
+func TestNATSConsumer(t *testing.T) {
+	s := newFakeNATS(t)
+	defer s.ln.Close()
+
+	got := make(chan string, 4)
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+
+	c := &natsConsumer{url: "nats://token@" + s.ln.Addr().String(), subject: "events", queue: "welcome"}
+	go func() {
+		done <- c.consume(func(p []byte) { got <- string(p) }, stop)
+	}()
+
+	line := s.expect(t, "CONNECT ")
+
+	var connect map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "CONNECT ")), &connect); err != nil {
+		t.Fatal(err)
+	}
+
+	if connect["auth_token"] != "token" {
+		t.Errorf("auth_token = %v, want token", connect["auth_token"])
+	}
+
+	if line := s.expect(t, "SUB "); line != "SUB events welcome 1" {
+		t.Errorf("got %q", line)
+	}
+
+	conn := <-s.conn
+	payload := `{"object_kind":"issue"}`
+	fmt.Fprintf(conn, "MSG events 1 %d\r\n%s\r\nPING\r\nMSG events 1 reply %d\r\n%s\r\n", len(payload), payload, 2, "{}")
+
+	for _, want := range []string{payload, "{}"} {
+		select {
+		case v := <-got:
+			if v != want {
+				t.Errorf("got payload %q, want %q", v, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for the message")
+		}
+	}
+
+	s.expect(t, "PONG")
+
+	close(stop)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the consumer doesn't stop")
+	}
+}
+
+func TestNATSConsumerError(t *testing.T) {
+	s := newFakeNATS(t)
+	defer s.ln.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- (&natsConsumer{url: "nats://" + s.ln.Addr().String(), subject: "events", queue: "welcome"}).consume(
+			func([]byte) {}, make(chan struct{}),
+		)
+	}()
+
+	conn := <-s.conn
+
+	fmt.Fprint(conn, "-ERR 'Authorization Violation'\r\n")
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "Authorization Violation") {
+			t.Errorf("err = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the consumer doesn't fail")
+	}
+}
+
+func TestNATSConsumerMalformedMessage(t *testing.T) {
+	s := newFakeNATS(t)
+	defer s.ln.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- (&natsConsumer{url: "nats://" + s.ln.Addr().String(), subject: "events", queue: "welcome"}).consume(
+			func([]byte) {}, make(chan struct{}),
+		)
+	}()
+
+	conn := <-s.conn
+	fmt.Fprint(conn, "MSG events 1 x\r\n")
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "invalid message") {
+			t.Errorf("err = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the consumer doesn't fail")
+	}
+}
+
+func TestKafkaConsumer(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		requests []string
+		served   bool
+	)
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		requests = append(requests, r.Method+" "+r.URL.Path)
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/consumers/welcome":
+			fmt.Fprintf(w, `{"base_uri":"%s/consumers/welcome/instances/a"}`, srv.URL)
+
+		case r.URL.Path == "/consumers/welcome/instances/a/records":
+			if r.Header.Get("Accept") != kafkaJSONType {
+				t.Errorf("accept = %s", r.Header.Get("Accept"))
+			}
+
+			if served {
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprint(w, `{"message":"gone"}`)
+
+				return
+			}
+
+			served = true
+			fmt.Fprint(w, `[{"value":{"object_kind":"issue"}},{"value":{"object_kind":"push"}}]`)
+
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	var got []string
+
+	c := &kafkaConsumer{url: srv.URL, topic: "events", group: "welcome", hc: srv.Client()}
+
+	err := c.consume(func(p []byte) { got = append(got, string(p)) }, make(chan struct{}))
+	if err == nil || !strings.Contains(err.Error(), "responds 500") {
+		t.Errorf("err = %v", err)
+	}
+
+	if len(got) != 2 || got[0] != `{"object_kind":"issue"}` || got[1] != `{"object_kind":"push"}` {
+		t.Errorf("got %v", got)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	want := []string{
+		"POST /consumers/welcome",
+		"POST /consumers/welcome/instances/a/subscription",
+		"GET /consumers/welcome/instances/a/records",
+		"GET /consumers/welcome/instances/a/records",
+		"DELETE /consumers/welcome/instances/a",
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+}
+
+func TestKafkaConsumerStop(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/consumers/welcome" {
+			fmt.Fprint(w, `{"base_uri":"http://`+r.Host+`/consumers/welcome/instances/a"}`)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	stop := make(chan struct{})
+	close(stop)
+
+	c := &kafkaConsumer{url: srv.URL, topic: "events", group: "welcome", hc: srv.Client()}
+	if err := c.consume(func([]byte) { t.Error("no record is expected") }, stop); err != nil {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestPayloadTarget(t *testing.T) {
+	cases := map[string]string{
+		`{"object_kind":"merge_request","project":{"id":1},"object_attributes":{"iid":2}}`:                                         "1!2",
+		`{"object_kind":"issue","project":{"id":"1"},"object_attributes":{"iid":3}}`:                                               "1#3",
+		`{"object_kind":"note","project":{"id":1},"object_attributes":{"noteable_type":"MergeRequest"},"merge_request":{"iid":2}}`: "1!2",
+		`{"object_kind":"note","project":{"id":1},"object_attributes":{"noteable_type":"Issue"},"issue":{"iid":3}}`:                "1#3",
+		`{"object_kind":"push","project":{"id":1}}`:                                                                                "push/1",
+	}
+
+	for payload, want := range cases {
+		if got := payloadTarget([]byte(payload)); got != want {
+			t.Errorf("payloadTarget(%s) = %q, want %q", payload, got, want)
+		}
+	}
+
+}
+
+func TestEventOrderingPost(t *testing.T) {
+	o := newEventOrdering(2)
+
+	block := make(chan struct{})
+	o.post("a", func() { <-block })
+
+	var (
+		lock sync.Mutex
+		got  []int
+	)
+
+	done := make(chan struct{})
+	for i := 0; i < 20; i++ {
+		i := i
+		o.post("b", func() {
+			lock.Lock()
+			got = append(got, i)
+			n := len(got)
+			lock.Unlock()
+
+			if n == 20 {
+				close(done)
+			}
+		})
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the events of b are held up by a")
+	}
+
+	for i := range got {
+		if got[i] != i {
+			t.Fatalf("the events are out of order: %v", got)
+		}
+	}
+
+	close(block)
+}
+
+// blockingConsumer consumes until stop is closed, and counts the running
+// ones.
+type blockingConsumer struct {
+	running int32
+}
+
+func (c *blockingConsumer) consume(handle func([]byte), stop <-chan struct{}) error {
+	atomic.AddInt32(&c.running, 1)
+	defer atomic.AddInt32(&c.running, -1)
+
+	<-stop
+
+	return nil
+}
+
+func TestConsumerRunnerFollowsLeadership(t *testing.T) {
+	bot := newRobot(nil, nil)
+	bot.elector = &leaderElector{identity: "test"}
+
+	c := new(blockingConsumer)
+	r := &consumerRunner{bot: bot, consumer: c}
+
+	running := func(want int32) {
+		t.Helper()
+
+		for i := 0; i < 100 && atomic.LoadInt32(&c.running) != want; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if v := atomic.LoadInt32(&c.running); v != want {
+			t.Fatalf("%d consumers are running, want %d", v, want)
+		}
+	}
+
+	r.sync()
+	running(0)
+
+	atomic.StoreInt32(&bot.elector.leader, 1)
+	r.sync()
+	r.sync()
+	running(1)
+
+	atomic.StoreInt32(&bot.elector.leader, 0)
+	r.sync()
+	running(0)
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const redacted = "REDACTED"
+
+var (
+	apiRequestsTotal = newCounterVec(
+		"welcome_gitlab_api_requests_total",
+		"Number of GitLab API calls by method and status.",
+		"method", "status",
+	)
+
+	apiRequestSeconds = newCounterVec(
+		"welcome_gitlab_api_request_seconds_total",
+		"Total seconds spent in GitLab API calls by method.",
+		"method",
+	)
+
+	// the tokens passed as the query parameters, such as private_token=xxx.
+	tokenParamRe = regexp.MustCompile(`((?:private|access|job)_token=)[^&\s"]+`)
+)
+
+// instrumentedClient wraps the client to log every call at debug level and
+// count the calls and their durations by method, which tells the endpoints
+// slowing down the welcomes. The token is redacted from the errors logged.
+type instrumentedClient struct {
+	cli      iClient
+	getToken func() []byte
+}
+
+func newInstrumentedClient(cli iClient, getToken func() []byte) *instrumentedClient {
+	return &instrumentedClient{cli: cli, getToken: getToken}
+}
+
+func (c *instrumentedClient) observe(method string, project interface{}, start time.Time, err *error) {
+	d := time.Since(start)
+
+	status := "ok"
+	if *err != nil {
+		status = apiErrorStatus(*err)
+	}
+
+	apiRequestsTotal.inc(method, status)
+	apiRequestSeconds.add(d.Seconds(), method)
+
+	if !logrus.IsLevelEnabled(logrus.DebugLevel) {
+		return
+	}
+
+	log := logrus.WithFields(logrus.Fields{
+		"method":   method,
+		"duration": d.String(),
+		"status":   status,
+	})
+
+	if project != nil {
+		log = log.WithField("project", project)
+	}
+
+	if *err != nil {
+		log = log.WithFields(logrus.Fields{
+			"error_class": classifyError(*err),
+			"error":       c.redact((*err).Error()),
+		})
+	}
+
+	log.Debug("gitlab api call")
+}
+
+// redact removes the token from s.
+func (c *instrumentedClient) redact(s string) string {
+	s = tokenParamRe.ReplaceAllString(s, "${1}"+redacted)
+
+	if c.getToken != nil {
+		if t := strings.TrimSpace(string(c.getToken())); t != "" {
+			s = strings.Replace(s, t, redacted, -1)
+		}
+	}
+
+	return s
+}
+
+// apiErrorStatus returns the http status code of error, or its class if it
+// is not an error response.
+func apiErrorStatus(err error) string {
+	var e *gitlab.ErrorResponse
+	if errors.As(err, &e) && e.Response != nil {
+		return strconv.Itoa(e.Response.StatusCode)
+	}
+
+	return classifyError(err)
+}
+
+func (c *instrumentedClient) CreateMergeRequestComment(projectID interface{}, mrID int, comment string) (err error) {
+	defer c.observe("CreateMergeRequestComment", projectID, time.Now(), &err)
+
+	return c.cli.CreateMergeRequestComment(projectID, mrID, comment)
+}
+
+func (c *instrumentedClient) AddMergeRequestLabel(projectID interface{}, mrID int, labels gitlab.Labels) (err error) {
+	defer c.observe("AddMergeRequestLabel", projectID, time.Now(), &err)
+
+	return c.cli.AddMergeRequestLabel(projectID, mrID, labels)
+}
+
+func (c *instrumentedClient) GetProjectLabels(projectID interface{}) (v []*gitlab.Label, err error) {
+	defer c.observe("GetProjectLabels", projectID, time.Now(), &err)
+
+	return c.cli.GetProjectLabels(projectID)
+}
+
+func (c *instrumentedClient) CreateProjectLabel(pid interface{}, label, color string) (err error) {
+	defer c.observe("CreateProjectLabel", pid, time.Now(), &err)
+
+	return c.cli.CreateProjectLabel(pid, label, color)
+}
+
+func (c *instrumentedClient) UpdateProjectLabel(pid interface{}, opt *gitlab.UpdateLabelOptions) (err error) {
+	defer c.observe("UpdateProjectLabel", pid, time.Now(), &err)
+
+	return c.cli.UpdateProjectLabel(pid, opt)
+}
+
+func (c *instrumentedClient) GetDirectoryTree(projectID interface{}, opts gitlab.ListTreeOptions) (v []*gitlab.TreeNode, err error) {
+	defer c.observe("GetDirectoryTree", projectID, time.Now(), &err)
+
+	return c.cli.GetDirectoryTree(projectID, opts)
+}
+
+func (c *instrumentedClient) ListCollaborators(projectID interface{}) (v []*projectMember, err error) {
+	defer c.observe("ListCollaborators", projectID, time.Now(), &err)
+
+	return c.cli.ListCollaborators(projectID)
+}
+
+func (c *instrumentedClient) ListMemberRoles(gid int) (v []*memberRole, err error) {
+	defer c.observe("ListMemberRoles", gid, time.Now(), &err)
+
+	return c.cli.ListMemberRoles(gid)
+}
+
+func (c *instrumentedClient) GetGroup(gid interface{}) (v *gitlab.Group, err error) {
+	defer c.observe("GetGroup", gid, time.Now(), &err)
+
+	return c.cli.GetGroup(gid)
+}
+
+func (c *instrumentedClient) CreateIssueComment(projectID interface{}, issueID int, comment string) (err error) {
+	defer c.observe("CreateIssueComment", projectID, time.Now(), &err)
+
+	return c.cli.CreateIssueComment(projectID, issueID, comment)
+}
+
+func (c *instrumentedClient) AddIssueLabels(projectID interface{}, issueID int, labels gitlab.Labels) (err error) {
+	defer c.observe("AddIssueLabels", projectID, time.Now(), &err)
+
+	return c.cli.AddIssueLabels(projectID, issueID, labels)
+}
+
+func (c *instrumentedClient) GetPathContent(projectID interface{}, file, branch string) (v *gitlab.File, err error) {
+	defer c.observe("GetPathContent", projectID, time.Now(), &err)
+
+	return c.cli.GetPathContent(projectID, file, branch)
+}
+
+func (c *instrumentedClient) GetFileLastCommit(projectID interface{}, file, branch string) (v string, err error) {
+	defer c.observe("GetFileLastCommit", projectID, time.Now(), &err)
+
+	return c.cli.GetFileLastCommit(projectID, file, branch)
+}
+
+func (c *instrumentedClient) GetMergeRequestChanges(projectID interface{}, mrID int) (v []string, err error) {
+	defer c.observe("GetMergeRequestChanges", projectID, time.Now(), &err)
+
+	return c.cli.GetMergeRequestChanges(projectID, mrID)
+}
+
+func (c *instrumentedClient) AssignMergeRequest(projectID interface{}, mrID int, ids []int) (err error) {
+	defer c.observe("AssignMergeRequest", projectID, time.Now(), &err)
+
+	return c.cli.AssignMergeRequest(projectID, mrID, ids)
+}
+
+func (c *instrumentedClient) SetReviewers(projectID interface{}, mrID int, ids []int) (err error) {
+	defer c.observe("SetReviewers", projectID, time.Now(), &err)
+
+	return c.cli.SetReviewers(projectID, mrID, ids)
+}
+
+func (c *instrumentedClient) CountGroupMergeRequests(gid interface{}, author, state string) (v int, err error) {
+	defer c.observe("CountGroupMergeRequests", gid, time.Now(), &err)
+
+	return c.cli.CountGroupMergeRequests(gid, author, state)
+}
+
+func (c *instrumentedClient) GetProjectID(path string) (v int, err error) {
+	defer c.observe("GetProjectID", path, time.Now(), &err)
+
+	return c.cli.GetProjectID(path)
+}
+
+func (c *instrumentedClient) ListMergeRequestNotes(pid interface{}, mrID int) (v []*gitlab.Note, err error) {
+	defer c.observe("ListMergeRequestNotes", pid, time.Now(), &err)
+
+	return c.cli.ListMergeRequestNotes(pid, mrID)
+}
+
+func (c *instrumentedClient) ListIssueNotes(pid interface{}, issueID int) (v []*gitlab.Note, err error) {
+	defer c.observe("ListIssueNotes", pid, time.Now(), &err)
+
+	return c.cli.ListIssueNotes(pid, issueID)
+}
+
+func (c *instrumentedClient) UpdateMergeRequestNote(pid interface{}, mrID, noteID int, body string) (err error) {
+	defer c.observe("UpdateMergeRequestNote", pid, time.Now(), &err)
+
+	return c.cli.UpdateMergeRequestNote(pid, mrID, noteID, body)
+}
+
+func (c *instrumentedClient) UpdateIssueNote(pid interface{}, issueID, noteID int, body string) (err error) {
+	defer c.observe("UpdateIssueNote", pid, time.Now(), &err)
+
+	return c.cli.UpdateIssueNote(pid, issueID, noteID, body)
+}
+
+func (c *instrumentedClient) CreateEpicNote(gid interface{}, epicID int, body string) (err error) {
+	defer c.observe("CreateEpicNote", gid, time.Now(), &err)
+
+	return c.cli.CreateEpicNote(gid, epicID, body)
+}
+
+func (c *instrumentedClient) AssignIssue(projectID interface{}, issueID int, ids []int) (err error) {
+	defer c.observe("AssignIssue", projectID, time.Now(), &err)
+
+	return c.cli.AssignIssue(projectID, issueID, ids)
+}
+
+func (c *instrumentedClient) GetUserID(username string) (v int, err error) {
+	defer c.observe("GetUserID", nil, time.Now(), &err)
+
+	return c.cli.GetUserID(username)
+}
+
+func (c *instrumentedClient) ListProjectHookURLs(pid interface{}) (v []string, err error) {
+	defer c.observe("ListProjectHookURLs", pid, time.Now(), &err)
+
+	return c.cli.ListProjectHookURLs(pid)
+}
+
+func (c *instrumentedClient) AddProjectHook(pid interface{}, url, token string) (err error) {
+	defer c.observe("AddProjectHook", pid, time.Now(), &err)
+
+	return c.cli.AddProjectHook(pid, url, token)
+}
+
+func (c *instrumentedClient) CreateIssue(
+	pid interface{}, title, description string, assignees []int, labels []string,
+) (v *gitlab.Issue, err error) {
+	defer c.observe("CreateIssue", pid, time.Now(), &err)
+
+	return c.cli.CreateIssue(pid, title, description, assignees, labels)
+}
+
+func (c *instrumentedClient) ListClosedMergeRequests(pid interface{}, label string, updatedBefore time.Time) (v []*gitlab.MergeRequest, err error) {
+	defer c.observe("ListClosedMergeRequests", pid, time.Now(), &err)
+
+	return c.cli.ListClosedMergeRequests(pid, label, updatedBefore)
+}
+
+func (c *instrumentedClient) ListClosedIssues(pid interface{}, label string, updatedBefore time.Time) (v []*gitlab.Issue, err error) {
+	defer c.observe("ListClosedIssues", pid, time.Now(), &err)
+
+	return c.cli.ListClosedIssues(pid, label, updatedBefore)
+}
+
+func (c *instrumentedClient) RemoveMergeRequestLabel(projectID interface{}, mrID int, labels gitlab.Labels) (err error) {
+	defer c.observe("RemoveMergeRequestLabel", projectID, time.Now(), &err)
+
+	return c.cli.RemoveMergeRequestLabel(projectID, mrID, labels)
+}
+
+func (c *instrumentedClient) RemoveIssueLabels(projectID interface{}, issueID int, labels gitlab.Labels) (err error) {
+	defer c.observe("RemoveIssueLabels", projectID, time.Now(), &err)
+
+	return c.cli.RemoveIssueLabels(projectID, issueID, labels)
+}
+
+func (c *instrumentedClient) DeleteMergeRequestNote(pid interface{}, mrID, noteID int) (err error) {
+	defer c.observe("DeleteMergeRequestNote", pid, time.Now(), &err)
+
+	return c.cli.DeleteMergeRequestNote(pid, mrID, noteID)
+}
+
+func (c *instrumentedClient) DeleteIssueNote(pid interface{}, issueID, noteID int) (err error) {
+	defer c.observe("DeleteIssueNote", pid, time.Now(), &err)
+
+	return c.cli.DeleteIssueNote(pid, issueID, noteID)
+}
+
+func (c *instrumentedClient) GetMergeRequest(pid interface{}, mrID int) (v *gitlab.MergeRequest, err error) {
+	defer c.observe("GetMergeRequest", pid, time.Now(), &err)
+
+	return c.cli.GetMergeRequest(pid, mrID)
+}
+
+func (c *instrumentedClient) GetIssue(pid interface{}, issueID int) (v *gitlab.Issue, err error) {
+	defer c.observe("GetIssue", pid, time.Now(), &err)
+
+	return c.cli.GetIssue(pid, issueID)
+}
+
+func (c *instrumentedClient) ListOpenMergeRequests(pid interface{}, createdAfter time.Time) (v []*gitlab.MergeRequest, err error) {
+	defer c.observe("ListOpenMergeRequests", pid, time.Now(), &err)
+
+	return c.cli.ListOpenMergeRequests(pid, createdAfter)
+}
+
+func (c *instrumentedClient) ListOpenIssues(pid interface{}, createdAfter time.Time) (v []*gitlab.Issue, err error) {
+	defer c.observe("ListOpenIssues", pid, time.Now(), &err)
+
+	return c.cli.ListOpenIssues(pid, createdAfter)
+}
+
+func (c *instrumentedClient) ListOpenLabeledMergeRequests(pid interface{}, label string) (v []*gitlab.MergeRequest, err error) {
+	defer c.observe("ListOpenLabeledMergeRequests", pid, time.Now(), &err)
+
+	return c.cli.ListOpenLabeledMergeRequests(pid, label)
+}
+
+func (c *instrumentedClient) ListOpenLabeledIssues(pid interface{}, label string) (v []*gitlab.Issue, err error) {
+	defer c.observe("ListOpenLabeledIssues", pid, time.Now(), &err)
+
+	return c.cli.ListOpenLabeledIssues(pid, label)
+}
+
+func (c *instrumentedClient) SetCommitStatus(pid interface{}, sha string, opt *gitlab.SetCommitStatusOptions) (err error) {
+	defer c.observe("SetCommitStatus", pid, time.Now(), &err)
+
+	return c.cli.SetCommitStatus(pid, sha, opt)
+}
+
+func (c *instrumentedClient) CloseMergeRequest(pid interface{}, mrID int) (err error) {
+	defer c.observe("CloseMergeRequest", pid, time.Now(), &err)
+
+	return c.cli.CloseMergeRequest(pid, mrID)
+}
+
+func (c *instrumentedClient) CloseIssue(pid interface{}, issueID int) (err error) {
+	defer c.observe("CloseIssue", pid, time.Now(), &err)
+
+	return c.cli.CloseIssue(pid, issueID)
+}
+
+func (c *instrumentedClient) ListMergeRequestNoteAwardEmoji(pid interface{}, mrID, noteID int) (v []*gitlab.AwardEmoji, err error) {
+	defer c.observe("ListMergeRequestNoteAwardEmoji", pid, time.Now(), &err)
+
+	return c.cli.ListMergeRequestNoteAwardEmoji(pid, mrID, noteID)
+}
+
+func (c *instrumentedClient) ListIssueNoteAwardEmoji(pid interface{}, issueID, noteID int) (v []*gitlab.AwardEmoji, err error) {
+	defer c.observe("ListIssueNoteAwardEmoji", pid, time.Now(), &err)
+
+	return c.cli.ListIssueNoteAwardEmoji(pid, issueID, noteID)
+}
+
+func (c *instrumentedClient) CreateMergeRequestAwardEmoji(pid interface{}, mrID int, name string) (err error) {
+	defer c.observe("CreateMergeRequestAwardEmoji", pid, time.Now(), &err)
+
+	return c.cli.CreateMergeRequestAwardEmoji(pid, mrID, name)
+}
+
+func (c *instrumentedClient) CreateIssueAwardEmoji(pid interface{}, issueID int, name string) (err error) {
+	defer c.observe("CreateIssueAwardEmoji", pid, time.Now(), &err)
+
+	return c.cli.CreateIssueAwardEmoji(pid, issueID, name)
+}
@@ -0,0 +1,392 @@
+//go:build integration
+// +build integration
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+	"sigs.k8s.io/yaml"
+)
+
+// The harness runs the whole pipeline, from the payload of webhook to the
+// calls of GitLab API, against the fake GitLab below by default:
+//
+//	go test -tags integration -run Integration ./...
+//
+// It runs against a real GitLab instance, such as the gitlab-ce container,
+// instead if GITLAB_IT_URL and GITLAB_IT_TOKEN are set. The project named by
+// GITLAB_IT_PROJECT must have the files of sig infra, which are listed by
+// itFiles, on its master branch, and GITLAB_IT_MR is the merge request to
+// welcome.
+
+const (
+	itProjectID = 1
+	itOrg       = "openeuler"
+	itRepo      = "community"
+	itSig       = "infra"
+	itAuthor    = "newbie"
+	itMR        = 7
+)
+
+// itFiles are the files of the project read by the pipeline.
+var itFiles = map[string]string{
+	sigOwnersFile(itSig): "maintainers:\n- alice\n",
+	sigInfoFile(itSig):   "name: infra\nmaintainers:\n- gitee_id: alice\n- gitee_id: bob\n",
+}
+
+const itConfig = `
+default:
+  community_name: openEuler
+  community_repo: openeuler/community
+  branch: master
+  path: sig
+  links:
+    commands: https://example.com/commands
+  newcomer:
+    source: gitlab
+`
+
+// fakeGitlab serves the subset of GitLab API v4 used by the pipeline and
+// records the notes and labels written.
+type fakeGitlab struct {
+	lock   sync.Mutex
+	notes  []string
+	labels map[string]bool
+	added  []string
+
+	// failNotes makes creating notes fail with the status code.
+	failNotes int
+}
+
+func newFakeGitlab() *fakeGitlab {
+	return &fakeGitlab{labels: make(map[string]bool)}
+}
+
+func (f *fakeGitlab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	p := strings.TrimPrefix(r.URL.Path, "/api/v4/")
+	project := fmt.Sprintf("projects/%d/", itProjectID)
+	mr := fmt.Sprintf("%smerge_requests/%d", project, itMR)
+
+	switch {
+	case strings.HasPrefix(p, project+"repository/files/"):
+		content, ok := itFiles[strings.TrimPrefix(p, project+"repository/files/")]
+		if !ok {
+			f.notFound(w)
+
+			return
+		}
+
+		f.json(w, map[string]string{
+			"content":  base64.StdEncoding.EncodeToString([]byte(content)),
+			"encoding": "base64",
+		})
+
+	case p == project+"repository/tree":
+		f.json(w, []map[string]string{
+			{"path": fmt.Sprintf("sig/%s/%s/c/%s.yaml", itSig, itOrg, itRepo), "type": "blob"},
+		})
+
+	case p == project+"members/all":
+		f.json(w, []map[string]interface{}{
+			{"username": "alice", "access_level": 40},
+			{"username": "bob", "access_level": 30},
+		})
+
+	case p == fmt.Sprintf("groups/%s/merge_requests", itOrg):
+		// the merge request being welcomed is the only one of the author.
+		w.Header().Set("X-Total", "1")
+		f.json(w, []interface{}{})
+
+	case p == mr && r.Method == http.MethodGet:
+		f.json(w, map[string]interface{}{
+			"iid": itMR, "project_id": itProjectID, "target_branch": "master",
+			"title": "fix typo", "changes_count": "1",
+		})
+
+	case p == mr && r.Method == http.MethodPut:
+		var opt struct {
+			AddLabels string `json:"add_labels"`
+		}
+		f.decode(r, &opt)
+
+		if opt.AddLabels != "" {
+			f.added = append(f.added, strings.Split(opt.AddLabels, ",")...)
+		}
+
+		f.json(w, map[string]interface{}{"iid": itMR})
+
+	case p == mr+"/notes" && r.Method == http.MethodPost:
+		if f.failNotes > 0 {
+			w.WriteHeader(f.failNotes)
+
+			return
+		}
+
+		var opt struct {
+			Body string `json:"body"`
+		}
+		f.decode(r, &opt)
+
+		f.notes = append(f.notes, opt.Body)
+		f.json(w, map[string]interface{}{"id": len(f.notes), "body": opt.Body})
+
+	case p == mr+"/notes":
+		v := make([]map[string]interface{}, len(f.notes))
+		for i, n := range f.notes {
+			v[i] = map[string]interface{}{"id": i + 1, "body": n}
+		}
+		f.json(w, v)
+
+	case p == project+"labels" && r.Method == http.MethodPost:
+		var opt struct {
+			Name string `json:"name"`
+		}
+		f.decode(r, &opt)
+
+		f.labels[opt.Name] = true
+		f.json(w, map[string]interface{}{"name": opt.Name})
+
+	case p == project+"labels":
+		var v []map[string]interface{}
+		for name := range f.labels {
+			v = append(v, map[string]interface{}{"name": name})
+		}
+		f.json(w, v)
+
+	default:
+		f.notFound(w)
+	}
+}
+
+func (f *fakeGitlab) json(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeGitlab) decode(r *http.Request, v interface{}) {
+	b, _ := ioutil.ReadAll(r.Body)
+	_ = json.Unmarshal(b, v)
+}
+
+func (f *fakeGitlab) notFound(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNotFound)
+	_, _ = w.Write([]byte(`{"message":"404 Not Found"}`))
+}
+
+// itTarget is the GitLab instance the pipeline runs against.
+type itTarget struct {
+	url   string
+	token string
+	pid   int
+	path  string
+	mr    int
+	fake  *fakeGitlab
+}
+
+func newITTarget(t *testing.T) *itTarget {
+	if u := os.Getenv("GITLAB_IT_URL"); u != "" {
+		mr, err := strconv.Atoi(os.Getenv("GITLAB_IT_MR"))
+		if err != nil {
+			t.Fatalf("invalid GITLAB_IT_MR: %v", err)
+		}
+
+		it := &itTarget{
+			url:   strings.TrimSuffix(u, "/") + "/api/v4",
+			token: os.Getenv("GITLAB_IT_TOKEN"),
+			path:  os.Getenv("GITLAB_IT_PROJECT"),
+			mr:    mr,
+		}
+
+		cli, err := newGitlabClient(func() []byte { return []byte(it.token) }, it.url, http.DefaultClient)
+		if err != nil {
+			t.Fatalf("new gitlab client: %v", err)
+		}
+
+		if it.pid, err = cli.GetProjectID(it.path); err != nil {
+			t.Fatalf("get the id of project %s: %v", it.path, err)
+		}
+
+		return it
+	}
+
+	fake := newFakeGitlab()
+	s := httptest.NewServer(fake)
+	t.Cleanup(s.Close)
+
+	return &itTarget{
+		url:  s.URL + "/api/v4",
+		pid:  itProjectID,
+		path: itOrg + "/" + itRepo,
+		mr:   itMR,
+		fake: fake,
+	}
+}
+
+func (it *itTarget) robot(t *testing.T) *robot {
+	cfg := new(configuration)
+	if err := yaml.Unmarshal([]byte(itConfig), cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+
+	cfg.SetDefault()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+
+	// the client doesn't retry, so that the failures are seen at once.
+	cli, err := gitlab.NewClient(it.token, gitlab.WithBaseURL(it.url), gitlab.WithCustomRetryMax(0))
+	if err != nil {
+		t.Fatalf("new gitlab client: %v", err)
+	}
+
+	return newRobot(&gitlabClient{cli: cli}, func() (*configuration, error) { return cfg, nil })
+}
+
+// payload returns the webhook payload of opening the merge request.
+func (it *itTarget) payload() []byte {
+	b, _ := json.Marshal(map[string]interface{}{
+		"object_kind": objectKindMergeRequest,
+		"user":        map[string]interface{}{"username": itAuthor},
+		"project":     map[string]interface{}{"id": it.pid, "path_with_namespace": it.path},
+		"object_attributes": map[string]interface{}{
+			"iid":           it.mr,
+			"action":        actionOpen,
+			"target_branch": "master",
+			"author_id":     1,
+		},
+	})
+
+	return b
+}
+
+func (it *itTarget) handle(t *testing.T, bot *robot) error {
+	handle, err := bot.payloadHandler(it.payload(), logrus.WithField("test", t.Name()))
+	if err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+
+	if handle == nil {
+		t.Fatal("the merge request event is not handled")
+	}
+
+	return handle()
+}
+
+// notes returns the notes of the merge request.
+func (it *itTarget) notes(t *testing.T) []string {
+	if it.fake != nil {
+		it.fake.lock.Lock()
+		defer it.fake.lock.Unlock()
+
+		return append([]string(nil), it.fake.notes...)
+	}
+
+	cli, err := gitlab.NewClient(it.token, gitlab.WithBaseURL(it.url))
+	if err != nil {
+		t.Fatalf("new gitlab client: %v", err)
+	}
+
+	v, _, err := cli.Notes.ListMergeRequestNotes(it.pid, it.mr, nil)
+	if err != nil {
+		t.Fatalf("list notes: %v", err)
+	}
+
+	r := make([]string, 0, len(v))
+	for _, n := range v {
+		r = append(r, n.Body)
+	}
+
+	return r
+}
+
+func TestIntegrationWelcomeMergeRequest(t *testing.T) {
+	it := newITTarget(t)
+	bot := it.robot(t)
+
+	if err := it.handle(t, bot); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+
+	var welcome string
+	for _, n := range it.notes(t) {
+		if strings.Contains(n, commentFooterPrefix) {
+			welcome = n
+		}
+	}
+
+	if welcome == "" {
+		t.Fatal("the welcome comment is not posted")
+	}
+
+	for _, want := range []string{itAuthor, "@alice", "@bob", itSig} {
+		if !strings.Contains(welcome, want) {
+			t.Errorf("the welcome comment doesn't contain %q:\n%s", want, welcome)
+		}
+	}
+
+	if it.fake == nil {
+		return
+	}
+
+	for _, label := range []string{newcomerLabel, defaultSigLabelNamespace + "/" + itSig} {
+		if !it.fake.labels[label] {
+			t.Errorf("the label %s is not created", label)
+		}
+
+		if !containsString(it.fake.added, label) {
+			t.Errorf("the label %s is not added, added: %v", label, it.fake.added)
+		}
+	}
+}
+
+func TestIntegrationCommentFailureIsRetryable(t *testing.T) {
+	it := newITTarget(t)
+	if it.fake == nil {
+		t.Skip("the failure is injected into the fake GitLab only")
+	}
+
+	it.fake.failNotes = http.StatusBadGateway
+
+	err := it.handle(t, it.robot(t))
+	if err == nil {
+		t.Fatal("the failure of posting comment is not reported")
+	}
+
+	if !isRetryable(err) || httpStatusOf(err) != http.StatusServiceUnavailable {
+		t.Errorf("the failure of GitLab 5xx should be retryable, got kinds %v", errorKinds(err))
+	}
+}
+
+func TestIntegrationCommentRejectedIsNotRetryable(t *testing.T) {
+	it := newITTarget(t)
+	if it.fake == nil {
+		t.Skip("the failure is injected into the fake GitLab only")
+	}
+
+	it.fake.failNotes = http.StatusForbidden
+
+	err := it.handle(t, it.robot(t))
+	if err == nil {
+		t.Fatal("the failure of posting comment is not reported")
+	}
+
+	if isRetryable(err) || httpStatusOf(err) != http.StatusOK {
+		t.Errorf("the failure of GitLab 4xx should not be retryable, got kinds %v", errorKinds(err))
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// HandlePushEvent invalidates the cached community repo data, such as the
+// sig mapping, OWNERS, sig-info and welcome files of sigs, which are changed by the push.
+// So the updates of maintainers take effect at once.
+func (bot *robot) HandlePushEvent(e *gitlab.PushEvent, log *logrus.Entry) error {
+	branch := strings.TrimPrefix(e.Ref, "refs/heads/")
+
+	changed := sets.NewString()
+	for _, c := range e.Commits {
+		if c == nil {
+			continue
+		}
+
+		changed.Insert(c.Added...)
+		changed.Insert(c.Modified...)
+		changed.Insert(c.Removed...)
+	}
+
+	// the payload includes at most 20 commits, so drop all the cached files
+	// of the project if some commits are missing.
+	if e.TotalCommitsCount > len(e.Commits) {
+		prefix := fmt.Sprintf("%d/%s/", e.ProjectID, branch)
+		bot.files.deleteIf(func(key string) bool {
+			return strings.HasPrefix(key, prefix)
+		})
+		bot.repoFiles.deleteIf(func(key string) bool {
+			return strings.HasPrefix(key, prefix)
+		})
+		bot.sigFiles.deleteIf(func(key string) bool {
+			return strings.HasPrefix(key, prefix)
+		})
+	} else {
+		for f := range changed {
+			bot.files.delete(fileCacheKey(e.ProjectID, f, branch))
+			bot.repoFiles.delete(fileCacheKey(e.ProjectID, f, branch))
+			bot.sigFiles.delete(fileCacheKey(e.ProjectID, f, branch))
+		}
+	}
+
+	c, err := bot.getConfig()
+	if err != nil {
+		return err
+	}
+
+	invalidate := func(cfg *botConfig) {
+		// only the community repo has the sig mapping.
+		if cfg == nil || cfg.Branch != branch || !strings.EqualFold(cfg.CommunityRepo, e.Project.PathWithNamespace) {
+			return
+		}
+
+		if e.TotalCommitsCount > len(e.Commits) || hasPathUnder(changed, cfg.Path) {
+			// the mapping may be listed through the projects of events,
+			// so it is dropped for all of them.
+			suffix := strings.TrimPrefix(sigTreeKey(0, cfg), "0")
+			bot.sigTrees.deleteIf(func(key string) bool {
+				return strings.HasSuffix(key, suffix)
+			})
+
+			log.Infof("sig mapping of %s is invalidated", cfg.CommunityRepo)
+		}
+	}
+
+	for i := range c.ConfigItems {
+		invalidate(&c.ConfigItems[i])
+	}
+
+	invalidate(c.Default)
+
+	return nil
+}
+
+func hasPathUnder(files sets.String, dir string) bool {
+	for f := range files {
+		if strings.HasPrefix(f, dir) {
+			return true
+		}
+	}
+
+	return false
+}
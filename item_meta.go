@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// itemMeta is the metadata of the merge request or issue exposed to the
+// welcome template, so that the communities can craft smarter greetings,
+// such as thanks for the docs update.
+type itemMeta struct {
+	IsMergeRequest bool
+
+	// TargetBranch is the target branch of merge request.
+	TargetBranch string
+
+	// Labels are the labels already present.
+	Labels []string
+
+	// Milestone is the title of milestone.
+	Milestone string
+
+	// ChangedFiles is the number of files changed by merge request.
+	ChangedFiles int
+
+	// IssueType is the type of issue, such as issue and incident.
+	IssueType string
+
+	// Title and Description are the text of the merge request or issue.
+	Title       string
+	Description string
+}
+
+func mrMetaOf(mr *gitlab.MergeRequest) *itemMeta {
+	m := &itemMeta{
+		IsMergeRequest: true,
+		TargetBranch:   mr.TargetBranch,
+		Labels:         mr.Labels,
+		Title:          mr.Title,
+		Description:    mr.Description,
+	}
+
+	if mr.Milestone != nil {
+		m.Milestone = mr.Milestone.Title
+	}
+
+	// it is like 1000+ if there are too many changes.
+	if n, err := strconv.Atoi(mr.ChangesCount); err == nil {
+		m.ChangedFiles = n
+	} else if len(mr.ChangesCount) > 1 {
+		m.ChangedFiles, _ = strconv.Atoi(mr.ChangesCount[:len(mr.ChangesCount)-1])
+	}
+
+	return m
+}
+
+func issueMetaOf(issue *gitlab.Issue) *itemMeta {
+	m := &itemMeta{
+		Labels:      issue.Labels,
+		Title:       issue.Title,
+		Description: issue.Description,
+	}
+
+	if issue.Milestone != nil {
+		m.Milestone = issue.Milestone.Title
+	}
+
+	if issue.IssueType != nil {
+		m.IssueType = *issue.IssueType
+	}
+
+	return m
+}
+
+// eventType returns the type of event welcomed, which is merge_request if
+// the metadata is unknown.
+func (m *itemMeta) eventType() string {
+	if m != nil && !m.IsMergeRequest {
+		return objectKindIssue
+	}
+
+	return objectKindMergeRequest
+}
+
+// getMRMeta returns the metadata of merge request. It is nil if the merge
+// request can't be got.
+func (bot *robot) getMRMeta(pid, number int) (*itemMeta, error) {
+	mr, err := bot.cli.GetMergeRequest(pid, number)
+	if err != nil {
+		return nil, err
+	}
+
+	return mrMetaOf(mr), nil
+}
+
+// getIssueMeta returns the metadata of issue. It is nil if the issue can't
+// be got.
+func (bot *robot) getIssueMeta(pid, number int) (*itemMeta, error) {
+	issue, err := bot.cli.GetIssue(pid, number)
+	if err != nil {
+		return nil, err
+	}
+
+	return issueMetaOf(issue), nil
+}
@@ -0,0 +1,48 @@
+package main
+
+const (
+	defaultSigLabelNamespace = "sig"
+	plainLabelSeparator      = "/"
+	scopedLabelSeparator     = "::"
+)
+
+// prefixes returns the current prefixes of sig label, which are the plain
+// and the scoped ones of namespace if the prefix is not set.
+func (c *sigLabelConfig) prefixes() []string {
+	if c.Prefix != "" {
+		return []string{c.Prefix}
+	}
+
+	return []string{c.Namespace + scopedLabelSeparator, c.Namespace + plainLabelSeparator}
+}
+
+// allPrefixes returns the current and the legacy prefixes of sig label.
+func (c *sigLabelConfig) allPrefixes() []string {
+	return append(c.prefixes(), c.LegacyPrefixes...)
+}
+
+func (bot *robot) sigLabelPrefix(cfg *botConfig) string {
+	if cfg.SigLabel.Prefix != "" {
+		return cfg.SigLabel.Prefix
+	}
+
+	if cfg.SigLabel.Scoped {
+		return cfg.SigLabel.Namespace + scopedLabelSeparator
+	}
+
+	return cfg.SigLabel.Namespace + plainLabelSeparator
+}
+
+func (bot *robot) sigLabel(sigName string, cfg *botConfig) string {
+	prefix := bot.sigLabelPrefix(cfg)
+
+	if sigName == "" {
+		return prefix
+	}
+
+	label := truncateLabel(prefix+sigLabelPart(sigName, &cfg.SigLabel), cfg.SigLabel.MaxLength)
+
+	bot.sigLabels.add(label, sigName)
+
+	return label
+}
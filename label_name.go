@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// maxLabelLength is the max length in characters of the label title
+// accepted by GitLab.
+const maxLabelLength = 255
+
+// transliterations maps the latin letters with diacritics to ASCII. The
+// other non-ASCII characters, such as CJK and emoji, are accepted by GitLab
+// and are kept as they are.
+var transliterations = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a",
+	'ç': "c", 'č': "c", 'ć': "c",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ě': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i",
+	'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u",
+	'ý': "y", 'ÿ': "y",
+	'š': "s", 'ś': "s", 'ž': "z", 'ź': "z", 'ż': "z", 'ř': "r", 'ł': "l",
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'þ': "th", 'ð': "d",
+}
+
+// normalizeLabelPart makes the sig name a valid part of label. The
+// latin letters with diacritics are transliterated, the spaces and the
+// characters which have special meaning in label, such as the comma which
+// separates labels in the API and the colon and slash which separate the
+// namespace, are replaced with -, and the control characters are dropped.
+func normalizeLabelPart(name string) string {
+	var b strings.Builder
+
+	lastDash := false
+	write := func(s string) {
+		if s == "-" {
+			if lastDash || b.Len() == 0 {
+				return
+			}
+			lastDash = true
+		} else {
+			lastDash = false
+		}
+
+		b.WriteString(s)
+	}
+
+	for _, r := range strings.TrimSpace(name) {
+		switch {
+		case r == utf8.RuneError || unicode.IsControl(r):
+		case unicode.IsSpace(r) || strings.ContainsRune(",:/\\\"'`", r):
+			write("-")
+		default:
+			lower := unicode.ToLower(r)
+			if v, ok := transliterations[lower]; ok {
+				if unicode.IsUpper(r) {
+					v = strings.ToUpper(v[:1]) + v[1:]
+				}
+
+				write(v)
+			} else {
+				write(string(r))
+			}
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// truncateLabel cuts the label to the max length. The hash of the whole
+// label is appended, so that the labels which share a long prefix are still
+// different.
+func truncateLabel(label string, max int) string {
+	if utf8.RuneCountInString(label) <= max {
+		return label
+	}
+
+	h := sha1.Sum([]byte(label))
+	suffix := "-" + hex.EncodeToString(h[:])[:8]
+
+	v := []rune(label)
+
+	return string(v[:max-len(suffix)]) + suffix
+}
+
+// sigLabelNames records the sig each sig label is generated from, so that
+// the sig can be looked up by the label.
+type sigLabelNames struct {
+	lock  sync.RWMutex
+	names map[string]string
+}
+
+func (s *sigLabelNames) add(label, sigName string) {
+	s.lock.Lock()
+	if s.names == nil {
+		s.names = make(map[string]string)
+	}
+	s.names[label] = sigName
+	s.lock.Unlock()
+}
+
+func (s *sigLabelNames) get(label string) (string, bool) {
+	s.lock.RLock()
+	v, ok := s.names[label]
+	s.lock.RUnlock()
+
+	return v, ok
+}
+
+// sigLabelPart returns the part of sig label for the sig. The mapping of
+// config takes precedence over the normalization.
+func sigLabelPart(sigName string, cfg *sigLabelConfig) string {
+	if v, ok := cfg.Mapping[sigName]; ok {
+		return v
+	}
+
+	return normalizeLabelPart(sigName)
+}
+
+// sigOfLabel returns the sig which the sig label is generated from.
+func (bot *robot) sigOfLabel(label string, cfg *botConfig) (string, bool) {
+	if v, ok := bot.sigLabels.get(label); ok {
+		return v, true
+	}
+
+	part := ""
+	for _, p := range cfg.SigLabel.allPrefixes() {
+		if strings.HasPrefix(label, p) {
+			part = strings.TrimPrefix(label, p)
+
+			break
+		}
+	}
+
+	if part == "" {
+		return "", false
+	}
+
+	for k, v := range cfg.SigLabel.Mapping {
+		if v == part {
+			return k, true
+		}
+	}
+
+	return part, true
+}
+
+// existingSigLabel returns the label of the sig among the labels of the
+// merge request or issue, which may be of a legacy prefix, so that the one
+// labeled before is not labeled again. It is the current sig label if there
+// is none.
+func (bot *robot) existingSigLabel(sigName string, labels []string, cfg *botConfig) string {
+	for _, l := range labels {
+		if v, ok := bot.sigOfLabel(l, cfg); ok && v == sigName {
+			return l
+		}
+	}
+
+	return bot.sigLabel(sigName, cfg)
+}
+
+// legacySigLabelRenames returns the renames of the sig labels of legacy
+// prefixes in the project to the current prefix.
+func (bot *robot) legacySigLabelRenames(labels []*gitlab.Label, cfg *botConfig) []labelRename {
+	var r []labelRename
+
+	for _, l := range labels {
+		for _, p := range cfg.SigLabel.LegacyPrefixes {
+			if !strings.HasPrefix(l.Name, p) {
+				continue
+			}
+
+			if sig, ok := bot.sigOfLabel(l.Name, cfg); ok && sig != "" {
+				r = append(r, labelRename{From: l.Name, To: bot.sigLabel(sig, cfg)})
+			}
+
+			break
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// labelDeniedTTL is how long the project is known to deny creating labels,
+// after which it is tried again in case the permission is granted.
+const labelDeniedTTL = 6 * time.Hour
+
+// errLabelCreationDenied is the reason of the labels not created because
+// the robot lacks the permission in the project.
+var errLabelCreationDenied = errors.New("the robot can't create labels in the project")
+
+var labelCreationDeniedTotal = newCounterVec(
+	"welcome_label_creation_denied_total",
+	"Number of the labels not created because the robot lacks the permission.",
+	"reason",
+)
+
+func labelDeniedKey(pid int) string {
+	return fmt.Sprintf("label-denied/%d", pid)
+}
+
+// labelCreationDenied reports whether the project is known to deny the
+// robot creating labels.
+func (bot *robot) labelCreationDenied(pid int) bool {
+	_, ok := bot.labelDenied.get(labelDeniedKey(pid))
+
+	return ok
+}
+
+// createLabelIfNeed creates the label by acts unless the project denies it.
+// The denial is remembered, so that the project isn't asked again and again
+// and the error isn't logged on each event. The existing labels can still be
+// added after it fails.
+func (bot *robot) createLabelIfNeed(acts actions, pid int, label string, log *logrus.Entry) error {
+	if bot.labelCreationDenied(pid) {
+		labelCreationDeniedTotal.inc("cached")
+
+		return nil
+	}
+
+	err := acts.createLabelIfNeed(label)
+	if err == nil || classifyError(err) != errorClassForbidden {
+		return err
+	}
+
+	log.WithError(err).Warnf("%v, skip creating labels for %s", errLabelCreationDenied, labelDeniedTTL)
+
+	bot.labelDenied.set(labelDeniedKey(pid), true, labelDeniedTTL)
+	labelCreationDeniedTotal.inc("forbidden")
+
+	return nil
+}
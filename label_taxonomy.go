@@ -0,0 +1,362 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	labelsCommand = "labels"
+	labelsExport  = "export"
+	labelsSync    = "sync"
+)
+
+// defaultManagedLabels are the patterns of the labels managed by the robot
+// besides the sig ones.
+var defaultManagedLabels = []string{newcomerLabel, "kind/*", "size/*"}
+
+type labelTaxonomyConfig struct {
+	// Labels declare the colors and descriptions of the labels matching the
+	// patterns, such as sig/* and newcomer. The first matching one is used.
+	Labels []labelSpec `json:"labels,omitempty"`
+
+	// Renames are the labels to migrate. The old label is renamed if the
+	// new one doesn't exist, otherwise the new one is applied to the open
+	// merge requests and issues with the old one instead.
+	Renames []labelRename `json:"renames,omitempty"`
+}
+
+func (c *labelTaxonomyConfig) validate() error {
+	for i := range c.Labels {
+		if err := c.Labels[i].validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range c.Renames {
+		if r.From == "" || r.To == "" || r.From == r.To {
+			return fmt.Errorf("the from and to of label rename must be set and be different")
+		}
+	}
+
+	return nil
+}
+
+// spec returns the declaration of the label. It is nil if there is none.
+func (c *labelTaxonomyConfig) spec(label string) *labelSpec {
+	for i := range c.Labels {
+		if ok, _ := path.Match(c.Labels[i].Pattern, label); ok {
+			return &c.Labels[i]
+		}
+	}
+
+	return nil
+}
+
+type labelSpec struct {
+	// Pattern is the name or the glob pattern of labels.
+	Pattern string `json:"pattern" required:"true"`
+
+	// Color is like #428BCA. The color is left as it is if empty.
+	Color string `json:"color,omitempty"`
+
+	// Description is left as it is if empty.
+	Description string `json:"description,omitempty"`
+}
+
+func (s *labelSpec) validate() error {
+	if s.Pattern == "" {
+		return fmt.Errorf("the pattern of label can not be empty")
+	}
+
+	if _, err := path.Match(s.Pattern, ""); err != nil {
+		return fmt.Errorf("invalid label pattern %s: %v", s.Pattern, err)
+	}
+
+	if s.Color != "" && (len(s.Color) != 7 || s.Color[0] != '#') {
+		return fmt.Errorf("the color of label %s must be like #428BCA", s.Pattern)
+	}
+
+	return nil
+}
+
+func (s *labelSpec) isPattern() bool {
+	return strings.ContainsAny(s.Pattern, "*?[")
+}
+
+type labelRename struct {
+	From string `json:"from" required:"true"`
+	To   string `json:"to" required:"true"`
+}
+
+type labelsOptions struct {
+	action  string
+	project string
+	dryRun  bool
+}
+
+func (o *labelsOptions) addFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.project, "project", "", "Path of the project whose labels are exported or synced, such as org/repo.")
+	fs.BoolVar(&o.dryRun, "labels-dry-run", false, "Only log the changes of labels sync without applying them.")
+}
+
+func (o *labelsOptions) validate() error {
+	if o.action != labelsExport && o.action != labelsSync {
+		return fmt.Errorf("the labels command must be %s or %s", labelsExport, labelsSync)
+	}
+
+	if strings.Count(o.project, "/") < 1 {
+		return errors.New("project must be like org/repo")
+	}
+
+	return nil
+}
+
+// exportedLabel is a label managed by the robot with its declaration.
+type exportedLabel struct {
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description,omitempty"`
+
+	// Drift means the color or description differs from the declared one.
+	Drift bool `json:"drift,omitempty"`
+
+	OpenMergeRequests int `json:"open_merge_requests"`
+	OpenIssues        int `json:"open_issues"`
+}
+
+// isManagedLabel reports whether the label is managed by the robot.
+func isManagedLabel(label string, cfg *botConfig) bool {
+	for _, p := range cfg.SigLabel.allPrefixes() {
+		if strings.HasPrefix(label, p) {
+			return true
+		}
+	}
+
+	for _, p := range defaultManagedLabels {
+		if ok, _ := path.Match(p, label); ok {
+			return true
+		}
+	}
+
+	if cfg.LabelTaxonomy.spec(label) != nil {
+		return true
+	}
+
+	return containsString(cfg.Labels, label)
+}
+
+func isLabelDrifted(l *gitlab.Label, s *labelSpec) bool {
+	if s == nil {
+		return false
+	}
+
+	return (s.Color != "" && !strings.EqualFold(s.Color, l.Color)) ||
+		(s.Description != "" && s.Description != l.Description)
+}
+
+// runLabels runs the labels command against the project.
+func (bot *robot) runLabels(o *labelsOptions) error {
+	i := strings.LastIndex(o.project, "/")
+	org, repo := o.project[:i], o.project[i+1:]
+
+	log := logrus.WithFields(logrus.Fields{"labels": o.action, "project": o.project})
+
+	cfg, err := bot.botConfigFor(org, repo, log)
+	if err != nil {
+		return err
+	}
+
+	if cfg == nil {
+		return fmt.Errorf("%s is not configured", o.project)
+	}
+
+	pid, err := bot.cli.GetProjectID(o.project)
+	if err != nil {
+		return err
+	}
+
+	if o.action == labelsExport {
+		v, err := bot.exportLabels(pid, cfg)
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(v)
+	}
+
+	return bot.syncLabels(org, pid, o.dryRun, cfg, log)
+}
+
+func (bot *robot) exportLabels(pid int, cfg *botConfig) ([]exportedLabel, error) {
+	labels, err := bot.cli.GetProjectLabels(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	var r []exportedLabel
+	for _, l := range labels {
+		if !isManagedLabel(l.Name, cfg) {
+			continue
+		}
+
+		r = append(r, exportedLabel{
+			Name:              l.Name,
+			Color:             l.Color,
+			Description:       l.Description,
+			Drift:             isLabelDrifted(l, cfg.LabelTaxonomy.spec(l.Name)),
+			OpenMergeRequests: l.OpenMergeRequestsCount,
+			OpenIssues:        l.OpenIssuesCount,
+		})
+	}
+
+	return r, nil
+}
+
+// syncLabels reconciles the labels of the project with the taxonomy. The
+// renames are done first, then the drifted labels are updated and the
+// declared ones which are missing are created. It goes on after a failure
+// and returns all the errors.
+func (bot *robot) syncLabels(org string, pid int, dryRun bool, cfg *botConfig, log *logrus.Entry) error {
+	labels, err := bot.cli.GetProjectLabels(pid)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]*gitlab.Label, len(labels))
+	for _, l := range labels {
+		existing[l.Name] = l
+	}
+
+	cli := bot.clientFor(org)
+	mErr := newMultiError()
+
+	apply := func(msg string, f func() error) {
+		log.Info(msg)
+
+		if !dryRun {
+			mErr.add(f())
+		}
+	}
+
+	renames := cfg.LabelTaxonomy.Renames
+	if cfg.SigLabel.MigrateLegacy {
+		renames = append(renames, bot.legacySigLabelRenames(labels, cfg)...)
+	}
+
+	for _, r := range renames {
+		r := r
+
+		old, ok := existing[r.From]
+		if !ok {
+			continue
+		}
+
+		if _, ok := existing[r.To]; !ok {
+			apply(fmt.Sprintf("rename the label %s to %s", r.From, r.To), func() error {
+				return cli.UpdateProjectLabel(pid, &gitlab.UpdateLabelOptions{
+					Name: gitlab.String(r.From), NewName: gitlab.String(r.To),
+				})
+			})
+
+			old.Name = r.To
+			existing[r.To] = old
+			delete(existing, r.From)
+
+			continue
+		}
+
+		apply(fmt.Sprintf("relabel the open items from %s to %s", r.From, r.To), func() error {
+			return relabelOpenItems(cli, pid, r.From, r.To)
+		})
+	}
+
+	for name, l := range existing {
+		s := cfg.LabelTaxonomy.spec(name)
+		if !isLabelDrifted(l, s) {
+			continue
+		}
+
+		opt := &gitlab.UpdateLabelOptions{Name: gitlab.String(name)}
+		if s.Color != "" {
+			opt.Color = gitlab.String(s.Color)
+		}
+		if s.Description != "" {
+			opt.Description = gitlab.String(s.Description)
+		}
+
+		apply(fmt.Sprintf("update the color and description of label %s", name), func() error {
+			return cli.UpdateProjectLabel(pid, opt)
+		})
+	}
+
+	for i := range cfg.LabelTaxonomy.Labels {
+		s := &cfg.LabelTaxonomy.Labels[i]
+		if _, ok := existing[s.Pattern]; ok || s.isPattern() {
+			continue
+		}
+
+		apply(fmt.Sprintf("create the label %s", s.Pattern), func() error {
+			if err := cli.CreateProjectLabel(pid, s.Pattern, s.Color); err != nil || s.Description == "" {
+				return err
+			}
+
+			return cli.UpdateProjectLabel(pid, &gitlab.UpdateLabelOptions{
+				Name: gitlab.String(s.Pattern), Description: gitlab.String(s.Description),
+			})
+		})
+	}
+
+	return mErr.err()
+}
+
+// relabelOpenItems replaces the label from with to on the open merge
+// requests and issues.
+func relabelOpenItems(cli iClient, pid int, from, to string) error {
+	mrs, err := cli.ListOpenLabeledMergeRequests(pid, from)
+	if err != nil {
+		return err
+	}
+
+	mErr := newMultiError()
+
+	for _, mr := range mrs {
+		if err := cli.AddMergeRequestLabel(pid, mr.IID, gitlab.Labels{to}); err != nil {
+			mErr.add(err)
+
+			continue
+		}
+
+		mErr.add(cli.RemoveMergeRequestLabel(pid, mr.IID, gitlab.Labels{from}))
+	}
+
+	issues, err := cli.ListOpenLabeledIssues(pid, from)
+	if err != nil {
+		mErr.add(err)
+
+		return mErr.err()
+	}
+
+	for _, issue := range issues {
+		if err := cli.AddIssueLabels(pid, issue.IID, gitlab.Labels{to}); err != nil {
+			mErr.add(err)
+
+			continue
+		}
+
+		mErr.add(cli.RemoveIssueLabels(pid, issue.IID, gitlab.Labels{from}))
+	}
+
+	return mErr.err()
+}
@@ -0,0 +1,45 @@
+package main
+
+// ladderData is the data to render the message of contributor ladder.
+type ladderData struct {
+	Author        string
+	Sig           string
+	Contributions int
+	Links         map[string]string
+}
+
+// pickLadderStep returns the step with the largest threshold which the
+// contributions reach. It is nil if there is none.
+func pickLadderStep(contributions int, steps []ladderStep) *ladderStep {
+	var r *ladderStep
+
+	for i := range steps {
+		s := &steps[i]
+		if contributions >= s.MinContributions && (r == nil || s.MinContributions > r.MinContributions) {
+			r = s
+		}
+	}
+
+	return r
+}
+
+// genLadderHint returns the message tailored to the contributions of
+// author, such as the onboarding for the first-timers or the nudge about
+// applying for committer. It is empty if the contributions are unknown.
+func genLadderHint(author, sigName string, contributions int, cfg *botConfig) (string, error) {
+	if contributions < 0 {
+		return "", nil
+	}
+
+	step := pickLadderStep(contributions, cfg.Ladder)
+	if step == nil {
+		return "", nil
+	}
+
+	return renderTemplate(step.Message, &ladderData{
+		Author:        escapeUsername(author),
+		Sig:           sigName,
+		Contributions: contributions,
+		Links:         cfg.Links,
+	})
+}
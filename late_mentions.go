@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var delayedJobsPending = newGaugeVec(
+	"welcome_delayed_jobs_pending",
+	"Number of the delayed jobs waiting to run, by kind.",
+	"kind",
+)
+
+const (
+	delayedJobMentions = "mentions"
+
+	// mentionRecheckMaxDelay caps the interval of the rechecks, which
+	// doubles on each attempt.
+	mentionRecheckMaxDelay = 6 * time.Hour
+)
+
+// scheduleJob runs the job of kind after the delay. The jobs are kept in
+// memory only, and are lost on restart.
+func scheduleJob(kind string, delay time.Duration, job func()) {
+	delayedJobsPending.add(1, kind)
+
+	time.AfterFunc(delay, func() {
+		delayedJobsPending.add(-1, kind)
+
+		job()
+	})
+}
+
+// mentionRecheck is the welcome posted without the mentions, because the
+// sig or its maintainers could not be resolved, which is to be edited once
+// they are available.
+type mentionRecheck struct {
+	org     string
+	repo    string
+	author  string
+	pid     int
+	number  int
+	meta    *itemMeta
+	acts    actions
+	cfg     *botConfig
+	attempt int
+
+	// labels are the ones added with the welcome posted, which are not
+	// added again.
+	labels []string
+}
+
+// needMentionRecheck reports whether the welcome of plan lacks the mentions
+// which may be added later.
+func needMentionRecheck(plan *ActionPlan) bool {
+	if plan.Comment == "" {
+		return false
+	}
+
+	for _, v := range plan.Degraded {
+		if v.Step == stepResolveSig || v.Step == stepGetMaintainers {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (bot *robot) scheduleMentionRecheck(job *mentionRecheck) {
+	c := &job.cfg.LateMentions
+	if job.attempt >= c.MaxAttempts {
+		return
+	}
+
+	if _, ok := job.acts.(noteEditor); !ok {
+		return
+	}
+
+	// the interval doubles on each attempt.
+	delay := time.Duration(c.Delay) * time.Second
+	for i := 0; i < job.attempt && delay < mentionRecheckMaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > mentionRecheckMaxDelay {
+		delay = mentionRecheckMaxDelay
+	}
+
+	job.attempt++
+
+	// it runs after the events of the item, which may edit the welcome.
+	target := actionsTarget(job.pid, job.author, job.acts)
+
+	scheduleJob(delayedJobMentions, delay, func() {
+		_ = bot.ordering.run(target, func() error {
+			bot.recheckMentions(job)
+
+			return nil
+		})
+	})
+}
+
+// recheckMentions regenerates the welcome by the current config and edits
+// the one posted without mentions. It is rescheduled if the data is still
+// not available.
+func (bot *robot) recheckMentions(job *mentionRecheck) {
+	log := logrus.WithFields(logrus.Fields{
+		"org":     job.org,
+		"repo":    job.repo,
+		"author":  job.author,
+		"attempt": job.attempt,
+	})
+
+	if bot.elector != nil && !bot.elector.isLeader() {
+		log.Info("not the leader, drop the recheck of mentions")
+
+		return
+	}
+
+	c, err := bot.botConfigFor(job.org, job.repo, log)
+	if err != nil {
+		log.WithError(err).Error("get the config, drop the recheck of mentions")
+
+		return
+	}
+
+	if c == nil {
+		return
+	}
+
+	_, isIssue := job.acts.(*issueActions)
+
+	c, skip := bot.applyRepoFile(job.pid, isIssue, c, log)
+	if skip || !c.LateMentions.Enabled || c.DryRun {
+		return
+	}
+
+	job.cfg = c
+
+	// the author has just been welcomed, so the cooldown is ignored.
+	cfg := *c
+	cfg.Cooldown = 0
+
+	plan, err := bot.genPlan(job.org, job.repo, job.author, job.number, job.pid, nil, job.meta, &cfg, log)
+	if err == nil && needMentionRecheck(plan) {
+		err = errors.New(plan.Degraded[0].Error)
+	}
+
+	if err != nil {
+		log.WithError(err).Warn("the mentions are still not available")
+
+		bot.scheduleMentionRecheck(job)
+
+		return
+	}
+
+	if plan.Comment == "" {
+		return
+	}
+
+	if err := bot.updateWelcome(plan.Comment, job.acts, &cfg, log); err != nil {
+		log.WithError(err).Error("edit the welcome with the mentions")
+
+		return
+	}
+
+	for _, l := range plan.Labels {
+		if containsString(job.labels, l) {
+			continue
+		}
+
+		if err := bot.createLabelIfNeed(job.acts, job.pid, l, log); err != nil {
+			log.WithError(err).Errorf("create label %s", l)
+
+			continue
+		}
+
+		if err := job.acts.addLabel(l); err != nil {
+			log.WithError(err).Errorf("add label %s", l)
+		}
+	}
+
+	log.Info("the welcome is edited with the mentions")
+}
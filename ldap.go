@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The tags of BER and the protocol operations of LDAPv3 (RFC 4511) used by
+// ldapConn.
+const (
+	berBoolean     = 0x01
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berEnumerated  = 0x0a
+	berSequence    = 0x30
+
+	ldapBindRequest       = 0x60
+	ldapBindResponse      = 0x61
+	ldapUnbindRequest     = 0x42
+	ldapSearchRequest     = 0x63
+	ldapSearchResultEntry = 0x64
+	ldapSearchResultDone  = 0x65
+	ldapSearchResultRef   = 0x73
+	ldapAuthSimple        = 0x80
+	ldapFilterEquality    = 0xa3
+
+	ldapScopeWholeSubtree = 2
+
+	ldapSizeLimitExceeded = 4
+
+	// ldapMaxMessageSize caps the size of the message read, so that a
+	// broken server can't exhaust the memory.
+	ldapMaxMessageSize = 1 << 20
+)
+
+type berElement struct {
+	tag     byte
+	content []byte
+}
+
+func berLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+func berTLV(tag byte, content ...[]byte) []byte {
+	n := 0
+	for _, c := range content {
+		n += len(c)
+	}
+
+	b := append([]byte{tag}, berLength(n)...)
+	for _, c := range content {
+		b = append(b, c...)
+	}
+
+	return b
+}
+
+func berInt(tag byte, v int) []byte {
+	b := []byte{byte(v)}
+	for v >>= 8; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+
+	// the integers are in two's complement.
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+
+	return berTLV(tag, b)
+}
+
+func berString(tag byte, s string) []byte {
+	return berTLV(tag, []byte(s))
+}
+
+func berBool(v bool) []byte {
+	if v {
+		return berTLV(berBoolean, []byte{0xff})
+	}
+
+	return berTLV(berBoolean, []byte{0})
+}
+
+func (e berElement) int() int {
+	v := 0
+	for i, b := range e.content {
+		if i == 0 && b&0x80 != 0 {
+			v = -1
+		}
+
+		v = v<<8 | int(b)
+	}
+
+	return v
+}
+
+// children parses the content of the constructed element.
+func (e berElement) children() ([]berElement, error) {
+	var r []berElement
+
+	for b := e.content; len(b) > 0; {
+		v, rest, err := parseBER(b)
+		if err != nil {
+			return nil, err
+		}
+
+		r = append(r, v)
+		b = rest
+	}
+
+	return r, nil
+}
+
+func parseBER(b []byte) (berElement, []byte, error) {
+	errMalformed := errors.New("malformed ldap message")
+
+	if len(b) < 2 {
+		return berElement{}, nil, errMalformed
+	}
+
+	tag, n, i := b[0], int(b[1]), 2
+
+	if n&0x80 != 0 {
+		size := n & 0x7f
+		if size == 0 || size > 4 || len(b) < 2+size {
+			return berElement{}, nil, errMalformed
+		}
+
+		n = 0
+		for _, c := range b[2 : 2+size] {
+			n = n<<8 | int(c)
+		}
+
+		i += size
+	}
+
+	if n < 0 || len(b)-i < n {
+		return berElement{}, nil, errMalformed
+	}
+
+	return berElement{tag: tag, content: b[i : i+n]}, b[i+n:], nil
+}
+
+// readBER reads an element from the stream.
+func readBER(r *bufio.Reader) (berElement, error) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return berElement{}, err
+	}
+
+	n := int(head[1])
+
+	if n&0x80 != 0 {
+		size := n & 0x7f
+		if size == 0 || size > 4 {
+			return berElement{}, errors.New("malformed ldap message")
+		}
+
+		b := make([]byte, size)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return berElement{}, err
+		}
+
+		n = 0
+		for _, c := range b {
+			n = n<<8 | int(c)
+		}
+	}
+
+	if n < 0 || n > ldapMaxMessageSize {
+		return berElement{}, fmt.Errorf("the ldap message of %d bytes is too large", n)
+	}
+
+	content := make([]byte, n)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return berElement{}, err
+	}
+
+	return berElement{tag: head[0], content: content}, nil
+}
+
+// ldapConn is the minimal client of LDAPv3, which binds simply and searches
+// by the equality of an attribute. That is all the directory needs, so
+// that no library of LDAP is depended on.
+type ldapConn struct {
+	conn  net.Conn
+	r     *bufio.Reader
+	msgID int
+}
+
+// dialLDAP connects to the server of url, which is ldap://host[:port] or
+// ldaps://host[:port]. All the requests on the connection must finish in
+// timeout.
+func dialLDAP(rawURL string, timeout time.Duration) (*ldapConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &net.Dialer{Timeout: timeout}
+	host := u.Host
+
+	var conn net.Conn
+
+	switch u.Scheme {
+	case "ldap":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "389")
+		}
+
+		conn, err = d.Dial("tcp", host)
+
+	case "ldaps":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "636")
+		}
+
+		conn, err = tls.DialWithDialer(d, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+
+	default:
+		return nil, fmt.Errorf("unsupported scheme of ldap url: %s", u.Scheme)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+
+		return nil, err
+	}
+
+	return &ldapConn{conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+func (c *ldapConn) send(op []byte) error {
+	c.msgID++
+
+	_, err := c.conn.Write(berTLV(berSequence, berInt(berInteger, c.msgID), op))
+
+	return err
+}
+
+// recv reads the protocol operation of the next response.
+func (c *ldapConn) recv() (berElement, error) {
+	msg, err := readBER(c.r)
+	if err != nil {
+		return berElement{}, err
+	}
+
+	v, err := msg.children()
+	if err != nil {
+		return berElement{}, err
+	}
+
+	if msg.tag != berSequence || len(v) < 2 {
+		return berElement{}, errors.New("malformed ldap message")
+	}
+
+	return v[1], nil
+}
+
+type ldapError struct {
+	code    int
+	message string
+}
+
+func (e *ldapError) Error() string {
+	return fmt.Sprintf("ldap result code %d: %s", e.code, e.message)
+}
+
+// ldapResult returns the error of the LDAPResult, which is the content of
+// the responses.
+func ldapResult(op berElement) error {
+	v, err := op.children()
+	if err != nil {
+		return err
+	}
+
+	if len(v) < 3 {
+		return errors.New("malformed ldap result")
+	}
+
+	if code := v[0].int(); code != 0 {
+		return &ldapError{code: code, message: string(v[2].content)}
+	}
+
+	return nil
+}
+
+func (c *ldapConn) bind(dn, password string) error {
+	err := c.send(berTLV(
+		ldapBindRequest,
+		berInt(berInteger, 3),
+		berString(berOctetString, dn),
+		berString(ldapAuthSimple, password),
+	))
+	if err != nil {
+		return err
+	}
+
+	op, err := c.recv()
+	if err != nil {
+		return err
+	}
+
+	if op.tag != ldapBindResponse {
+		return fmt.Errorf("unexpected ldap response 0x%x to bind", op.tag)
+	}
+
+	return ldapResult(op)
+}
+
+// search finds the entries under base whose attr equals value, and returns
+// the attributes of them, whose names are in lower case.
+func (c *ldapConn) search(base, attr, value string, attrs []string, limit int) ([]map[string][]string, error) {
+	names := make([][]byte, len(attrs))
+	for i := range attrs {
+		names[i] = berString(berOctetString, attrs[i])
+	}
+
+	err := c.send(berTLV(
+		ldapSearchRequest,
+		berString(berOctetString, base),
+		berInt(berEnumerated, ldapScopeWholeSubtree),
+		berInt(berEnumerated, 0),
+		berInt(berInteger, limit),
+		berInt(berInteger, 0),
+		berBool(false),
+		berTLV(ldapFilterEquality, berString(berOctetString, attr), berString(berOctetString, value)),
+		berTLV(berSequence, names...),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var r []map[string][]string
+
+	for {
+		op, err := c.recv()
+		if err != nil {
+			return nil, err
+		}
+
+		switch op.tag {
+		case ldapSearchResultEntry:
+			entry, err := parseLDAPEntry(op)
+			if err != nil {
+				return nil, err
+			}
+
+			r = append(r, entry)
+
+		case ldapSearchResultDone:
+			// the entries beyond the limit are not wanted.
+			err := ldapResult(op)
+			if e, ok := err.(*ldapError); ok && e.code == ldapSizeLimitExceeded {
+				err = nil
+			}
+
+			return r, err
+
+		case ldapSearchResultRef:
+			// the referrals to other servers are not followed.
+
+		default:
+			return nil, fmt.Errorf("unexpected ldap response 0x%x to search", op.tag)
+		}
+	}
+}
+
+func parseLDAPEntry(op berElement) (map[string][]string, error) {
+	v, err := op.children()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(v) < 2 {
+		return nil, errors.New("malformed ldap entry")
+	}
+
+	attrs, err := v[1].children()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := make(map[string][]string, len(attrs))
+
+	for _, a := range attrs {
+		p, err := a.children()
+		if err != nil || len(p) < 2 {
+			return nil, errors.New("malformed ldap attribute")
+		}
+
+		vals, err := p[1].children()
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.ToLower(string(p[0].content))
+		for _, s := range vals {
+			entry[name] = append(entry[name], string(s.content))
+		}
+	}
+
+	return entry, nil
+}
+
+func (c *ldapConn) close() {
+	_ = c.send(berTLV(ldapUnbindRequest))
+	_ = c.conn.Close()
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBEREncoding(t *testing.T) {
+	cases := []struct {
+		got  []byte
+		want []byte
+	}{
+		{berLength(5), []byte{5}},
+		{berLength(200), []byte{0x81, 200}},
+		{berLength(300), []byte{0x82, 1, 44}},
+		{berInt(berInteger, 3), []byte{berInteger, 1, 3}},
+		{berInt(berInteger, 128), []byte{berInteger, 2, 0, 0x80}},
+		{berInt(berEnumerated, 0), []byte{berEnumerated, 1, 0}},
+		{berString(berOctetString, "cn"), []byte{berOctetString, 2, 'c', 'n'}},
+		{berBool(true), []byte{berBoolean, 1, 0xff}},
+		{berBool(false), []byte{berBoolean, 1, 0}},
+	}
+
+	for i, c := range cases {
+		if !bytes.Equal(c.got, c.want) {
+			t.Errorf("case %d: got % x, want % x", i, c.got, c.want)
+		}
+	}
+}
+
+func TestBERDecoding(t *testing.T) {
+	for _, v := range []int{0, 1, 127, 128, 255, 256, 1 << 20} {
+		e, rest, err := parseBER(berInt(berInteger, v))
+		if err != nil || len(rest) != 0 || e.int() != v {
+			t.Errorf("parse %d: %d, % x, %v", v, e.int(), rest, err)
+		}
+	}
+
+	if v := (berElement{content: []byte{0xff}}).int(); v != -1 {
+		t.Errorf("0xff = %d, want -1", v)
+	}
+
+	long := berString(berOctetString, strings.Repeat("a", 300))
+	e, _, err := parseBER(long)
+	if err != nil || len(e.content) != 300 {
+		t.Errorf("parse the long form: %d, %v", len(e.content), err)
+	}
+
+	malformed := map[string][]byte{
+		"short":          {berSequence},
+		"truncated":      {berOctetString, 3, 'a'},
+		"size of length": {berOctetString, 0x85, 1, 1, 1, 1, 1},
+		"indefinite":     {berOctetString, 0x80},
+		"missing length": {berOctetString, 0x82, 1},
+	}
+
+	for name, b := range malformed {
+		if _, _, err := parseBER(b); err == nil {
+			t.Errorf("%s: the malformed element is parsed", name)
+		}
+	}
+}
+
+func TestReadBER(t *testing.T) {
+	msg := berTLV(berSequence, berInt(berInteger, 1), berString(berOctetString, "x"))
+
+	e, err := readBER(bufio.NewReader(bytes.NewReader(msg)))
+	if err != nil || e.tag != berSequence || !bytes.Equal(e.content, msg[2:]) {
+		t.Errorf("read %+v, %v", e, err)
+	}
+
+	if _, err := readBER(bufio.NewReader(bytes.NewReader([]byte{berSequence, 0x84, 0x7f, 0, 0, 0}))); err == nil ||
+		!strings.Contains(err.Error(), "too large") {
+		t.Errorf("the large message: err = %v", err)
+	}
+
+	if _, err := readBER(bufio.NewReader(bytes.NewReader(msg[:len(msg)-1]))); err == nil {
+		t.Error("the truncated message is read")
+	}
+}
+
+// fakeLDAP serves one connection until it is unbound, and answers each
+// request with the responses returned by handle.
+type fakeLDAP struct {
+	ln       net.Listener
+	requests chan berElement
+}
+
+func newFakeLDAP(t *testing.T, handle func(op berElement) [][]byte) *fakeLDAP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeLDAP{ln: ln, requests: make(chan berElement, 8)}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		for {
+			msg, err := readBER(r)
+			if err != nil {
+				return
+			}
+
+			v, err := msg.children()
+			if err != nil || len(v) < 2 || v[1].tag == ldapUnbindRequest {
+				return
+			}
+
+			s.requests <- v[1]
+
+			for _, op := range handle(v[1]) {
+				if _, err := conn.Write(berTLV(berSequence, berInt(berInteger, v[0].int()), op)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeLDAP) dial(t *testing.T) *ldapConn {
+	c, err := dialLDAP("ldap://"+s.ln.Addr().String(), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(c.close)
+
+	return c
+}
+
+func ldapResultOp(tag byte, code int, message string) []byte {
+	return berTLV(
+		tag,
+		berInt(berEnumerated, code),
+		berString(berOctetString, ""),
+		berString(berOctetString, message),
+	)
+}
+
+func ldapEntryOp(dn string, attrs map[string][]string) []byte {
+	var list [][]byte
+	for k, vals := range attrs {
+		var v [][]byte
+		for _, s := range vals {
+			v = append(v, berString(berOctetString, s))
+		}
+
+		list = append(list, berTLV(berSequence, berString(berOctetString, k), berTLV(0x31, v...)))
+	}
+
+	return berTLV(ldapSearchResultEntry, berString(berOctetString, dn), berTLV(berSequence, list...))
+}
+
+func TestLDAPBind(t *testing.T) {
+	s := newFakeLDAP(t, func(op berElement) [][]byte {
+		v, _ := op.children()
+		if string(v[2].content) != "secret" {
+			return [][]byte{ldapResultOp(ldapBindResponse, 49, "invalid credentials")}
+		}
+
+		return [][]byte{ldapResultOp(ldapBindResponse, 0, "")}
+	})
+
+	c := s.dial(t)
+
+	if err := c.bind("cn=bot,dc=example", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-s.requests
+	v, err := req.children()
+	if err != nil || req.tag != ldapBindRequest || len(v) != 3 {
+		t.Fatalf("bind request: %+v, %v", req, err)
+	}
+
+	if v[0].int() != 3 || string(v[1].content) != "cn=bot,dc=example" || v[2].tag != ldapAuthSimple {
+		t.Errorf("bind request: version %d, dn %q, auth 0x%x", v[0].int(), v[1].content, v[2].tag)
+	}
+
+	err = c.bind("cn=bot,dc=example", "wrong")
+
+	var e *ldapError
+	if !errors.As(err, &e) || e.code != 49 || e.message != "invalid credentials" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestLDAPSearch(t *testing.T) {
+	s := newFakeLDAP(t, func(op berElement) [][]byte {
+		return [][]byte{
+			ldapEntryOp("uid=a,dc=example", map[string][]string{"UID": {"a"}, "mail": {"a@example.com"}}),
+			berTLV(ldapSearchResultRef, berString(berOctetString, "ldap://other")),
+			ldapEntryOp("uid=b,dc=example", map[string][]string{"uid": {"b"}}),
+			ldapResultOp(ldapSearchResultDone, ldapSizeLimitExceeded, "size limit exceeded"),
+		}
+	})
+
+	c := s.dial(t)
+
+	r, err := c.search("dc=example", "uid", "a", []string{"uid", "mail"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r) != 2 || r[0]["uid"][0] != "a" || r[0]["mail"][0] != "a@example.com" || r[1]["uid"][0] != "b" {
+		t.Errorf("entries = %v", r)
+	}
+
+	req := <-s.requests
+	v, err := req.children()
+	if err != nil || req.tag != ldapSearchRequest || len(v) != 8 {
+		t.Fatalf("search request: %+v, %v", req, err)
+	}
+
+	if string(v[0].content) != "dc=example" || v[1].int() != ldapScopeWholeSubtree || v[3].int() != 2 {
+		t.Errorf("search request: base %q, scope %d, limit %d", v[0].content, v[1].int(), v[3].int())
+	}
+
+	filter, _ := v[6].children()
+	if v[6].tag != ldapFilterEquality || len(filter) != 2 ||
+		string(filter[0].content) != "uid" || string(filter[1].content) != "a" {
+		t.Errorf("filter: %+v", v[6])
+	}
+
+	attrs, _ := v[7].children()
+	if len(attrs) != 2 || string(attrs[0].content) != "uid" || string(attrs[1].content) != "mail" {
+		t.Errorf("attributes: %+v", attrs)
+	}
+}
+
+func TestLDAPSearchFailures(t *testing.T) {
+	cases := map[string]struct {
+		ops  [][]byte
+		want string
+	}{
+		"result": {
+			ops:  [][]byte{ldapResultOp(ldapSearchResultDone, 32, "no such object")},
+			want: "ldap result code 32: no such object",
+		},
+		"unexpected": {
+			ops:  [][]byte{ldapResultOp(ldapBindResponse, 0, "")},
+			want: "unexpected ldap response 0x61 to search",
+		},
+		"malformed entry": {
+			ops:  [][]byte{berTLV(ldapSearchResultEntry, berString(berOctetString, "uid=a"))},
+			want: "malformed ldap entry",
+		},
+		"malformed attribute": {
+			ops: [][]byte{berTLV(
+				ldapSearchResultEntry,
+				berString(berOctetString, "uid=a"),
+				berTLV(berSequence, berTLV(berSequence, berString(berOctetString, "uid"))),
+			)},
+			want: "malformed ldap attribute",
+		},
+	}
+
+	for name, c := range cases {
+		ops := c.ops
+		s := newFakeLDAP(t, func(berElement) [][]byte { return ops })
+
+		_, err := s.dial(t).search("dc=example", "uid", "a", nil, 1)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: err = %v, want %q", name, err, c.want)
+		}
+	}
+}
+
+func TestLDAPConnectionClosed(t *testing.T) {
+	s := newFakeLDAP(t, func(berElement) [][]byte { return nil })
+
+	c := s.dial(t)
+	s.ln.Close()
+
+	// the server closes the connection without a response.
+	go func() {
+		<-s.requests
+		c.conn.Close()
+	}()
+
+	if err := c.bind("cn=bot", "secret"); err == nil {
+		t.Error("bind succeeds without a response")
+	}
+}
+
+func TestDialLDAPUnsupportedScheme(t *testing.T) {
+	if _, err := dialLDAP("http://127.0.0.1", time.Second); err == nil ||
+		!strings.Contains(err.Error(), "unsupported scheme") {
+		t.Errorf("err = %v", err)
+	}
+}
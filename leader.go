@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	leaseTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+	standbyBufferSize   = 500
+	standbyBufferMaxAge = 10 * time.Minute
+)
+
+var isLeaderGauge = newGaugeVec(
+	"welcome_leader",
+	"Whether the replica is the leader which processes the events.",
+	"identity",
+)
+
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions"`
+}
+
+func (l *lease) expired(now time.Time) bool {
+	t, err := time.Parse(leaseTimeFormat, l.Spec.RenewTime)
+	if err != nil {
+		return true
+	}
+
+	return now.After(t.Add(time.Duration(l.Spec.LeaseDurationSeconds) * time.Second))
+}
+
+// leaseClient reads and writes the Lease object by the REST API of
+// Kubernetes with the service account of pod.
+type leaseClient struct {
+	hc    *http.Client
+	url   string
+	token func() string
+}
+
+func newInClusterLeaseClient(namespace, name string) (*leaseClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a kubernetes cluster")
+	}
+
+	ca, err := ioutil.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("invalid ca of service account")
+	}
+
+	return &leaseClient{
+		hc: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+		url: fmt.Sprintf(
+			"https://%s:%s/apis/coordination.k8s.io/v1/namespaces/%s/leases",
+			host, port, namespace,
+		),
+		// the token is rotated by kubelet, so it is read on each request.
+		token: func() string {
+			v, _ := ioutil.ReadFile(serviceAccountDir + "/token")
+
+			return strings.TrimSpace(string(v))
+		},
+	}, nil
+}
+
+// do sends the request and decodes the response into l. It returns
+// http.StatusNotFound or http.StatusConflict as the code when the lease
+// does not exist or has been changed by others.
+func (c *leaseClient) do(method, url string, body, l *lease) (int, error) {
+	var payload []byte
+	if body != nil {
+		v, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+
+		payload = v
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token())
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	v, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("%s %s, status: %s, body: %s", method, url, resp.Status, v)
+	}
+
+	return resp.StatusCode, json.Unmarshal(v, l)
+}
+
+func (c *leaseClient) get(name string) (*lease, int, error) {
+	l := new(lease)
+	code, err := c.do(http.MethodGet, c.url+"/"+name, nil, l)
+
+	return l, code, err
+}
+
+func (c *leaseClient) create(l *lease) (int, error) {
+	return c.do(http.MethodPost, c.url, l, l)
+}
+
+func (c *leaseClient) update(l *lease) (int, error) {
+	return c.do(http.MethodPut, c.url+"/"+l.Metadata.Name, l, l)
+}
+
+// leaderElector elects the leader among the replicas by a Lease object.
+// The leader renews the lease every retry period, and the others take it
+// over once it is not renewed within the lease duration.
+type leaderElector struct {
+	cli       *leaseClient
+	name      string
+	namespace string
+	identity  string
+
+	leaseDuration time.Duration
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+
+	leader    int32
+	lastRenew time.Time
+
+	// onStartedLeading is called each time the replica becomes the leader,
+	// and onStoppedLeading each time it loses the leadership.
+	onStartedLeading func()
+	onStoppedLeading func()
+}
+
+func (le *leaderElector) isLeader() bool {
+	return atomic.LoadInt32(&le.leader) == 1
+}
+
+func (le *leaderElector) setLeader(b bool) {
+	v := int32(0)
+	if b {
+		v = 1
+	}
+
+	if atomic.SwapInt32(&le.leader, v) == v {
+		return
+	}
+
+	isLeaderGauge.set(float64(v), le.identity)
+
+	if b {
+		logrus.Infof("%s became the leader", le.identity)
+
+		if le.onStartedLeading != nil {
+			go le.onStartedLeading()
+		}
+	} else {
+		logrus.Warnf("%s lost the leadership", le.identity)
+
+		if le.onStoppedLeading != nil {
+			go le.onStoppedLeading()
+		}
+	}
+}
+
+// run tries to acquire or renew the lease every retry period until stop is
+// closed, when the lease is released if it is held.
+func (le *leaderElector) run(stop <-chan struct{}) {
+	isLeaderGauge.set(0, le.identity)
+
+	t := time.NewTicker(le.retryPeriod)
+	defer t.Stop()
+
+	for {
+		if err := le.tryAcquireOrRenew(); err != nil {
+			logrus.WithError(err).Debug("acquire or renew the lease")
+		}
+
+		// step down if the lease can't be renewed in time, so that two
+		// replicas never act as the leader at the same time.
+		if le.isLeader() && time.Since(le.lastRenew) > le.renewDeadline {
+			le.setLeader(false)
+		}
+
+		select {
+		case <-stop:
+			le.release()
+
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (le *leaderElector) tryAcquireOrRenew() error {
+	now := time.Now()
+	nowStr := now.UTC().Format(leaseTimeFormat)
+
+	l, code, err := le.cli.get(le.name)
+	if code == http.StatusNotFound {
+		l = &lease{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+			Metadata:   leaseMetadata{Name: le.name, Namespace: le.namespace},
+			Spec: leaseSpec{
+				HolderIdentity:       le.identity,
+				LeaseDurationSeconds: int(le.leaseDuration / time.Second),
+				AcquireTime:          nowStr,
+				RenewTime:            nowStr,
+			},
+		}
+
+		if _, err := le.cli.create(l); err != nil {
+			return err
+		}
+
+		le.renewed(now)
+
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if l.Spec.HolderIdentity != le.identity {
+		if l.Spec.HolderIdentity != "" && !l.expired(now) {
+			le.setLeader(false)
+
+			return nil
+		}
+
+		l.Spec.HolderIdentity = le.identity
+		l.Spec.AcquireTime = nowStr
+		l.Spec.LeaseTransitions++
+	}
+
+	l.Spec.RenewTime = nowStr
+	l.Spec.LeaseDurationSeconds = int(le.leaseDuration / time.Second)
+
+	// the update fails with conflict if another replica has changed the
+	// lease after it is read.
+	if _, err := le.cli.update(l); err != nil {
+		return err
+	}
+
+	le.renewed(now)
+
+	return nil
+}
+
+func (le *leaderElector) renewed(t time.Time) {
+	le.lastRenew = t
+	le.setLeader(true)
+}
+
+// release gives up the lease, so that the standby takes over without
+// waiting for the lease to expire.
+func (le *leaderElector) release() {
+	if !le.isLeader() {
+		return
+	}
+
+	le.setLeader(false)
+
+	l, _, err := le.cli.get(le.name)
+	if err != nil || l.Spec.HolderIdentity != le.identity {
+		return
+	}
+
+	l.Spec.HolderIdentity = ""
+	l.Spec.RenewTime = ""
+
+	if _, err := le.cli.update(l); err != nil {
+		logrus.WithError(err).Error("release the lease")
+	}
+}
+
+// readyHandler reports ready only on the leader, so that the webhooks are
+// routed to it when it is used as the readiness probe.
+func (le *leaderElector) readyHandler(w http.ResponseWriter, r *http.Request) {
+	if le.isLeader() {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "leader")
+
+		return
+	}
+
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprintln(w, "standby")
+}
+
+type bufferedEvent struct {
+	at     time.Time
+	handle func() error
+	log    *logrus.Entry
+
+	// handled reports whether the event has been handled by the previous
+	// leader. The event is not replayed if it is nil, as it can't be told.
+	handled func() (bool, error)
+}
+
+// standbyBuffer keeps the events received by the standby replica, which
+// are processed once it becomes the leader.
+type standbyBuffer struct {
+	lock   sync.Mutex
+	events []bufferedEvent
+}
+
+func (b *standbyBuffer) push(handle func() error, handled func() (bool, error), log *logrus.Entry) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if len(b.events) >= standbyBufferSize {
+		log.Warn("the standby buffer is full, drop the oldest event")
+
+		b.events = b.events[1:]
+	}
+
+	b.events = append(b.events, bufferedEvent{at: time.Now(), handle: handle, handled: handled, log: log})
+}
+
+func (b *standbyBuffer) drain() []bufferedEvent {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	v := b.events
+	b.events = nil
+
+	return v
+}
+
+// deferIfStandby buffers the event when the replica is the standby and
+// reports whether it is buffered. The event is dropped when the replica
+// takes over, as the previous leader might have handled it.
+func (bot *robot) deferIfStandby(handle func() error, log *logrus.Entry) bool {
+	return bot.deferIfStandbyUnless(handle, nil, log)
+}
+
+// deferIfStandbyUnless is deferIfStandby whose event is replayed when the
+// replica takes over, unless handled reports the previous leader has done
+// it.
+func (bot *robot) deferIfStandbyUnless(
+	handle func() error, handled func() (bool, error), log *logrus.Entry,
+) bool {
+	if bot.elector == nil || bot.elector.isLeader() {
+		return false
+	}
+
+	log.Info("the replica is the standby, buffer the event")
+	bot.standby.push(handle, handled, log)
+
+	return true
+}
+
+// replayStandbyEvents processes the events buffered when the replica was
+// the standby which the previous leader has not handled. The ones too old
+// and the ones which can't be checked are dropped.
+func (bot *robot) replayStandbyEvents() {
+	for _, e := range bot.standby.drain() {
+		if time.Since(e.at) > standbyBufferMaxAge {
+			e.log.Warn("drop the stale event buffered by the standby")
+
+			continue
+		}
+
+		if e.handled == nil {
+			e.log.Info("drop the event buffered by the standby, which the previous leader might have handled")
+
+			continue
+		}
+
+		if done, err := e.handled(); err != nil || done {
+			if err != nil {
+				e.log.WithError(err).Error("check the event buffered by the standby, drop it")
+			}
+
+			continue
+		}
+
+		if err := e.handle(); err != nil {
+			e.log.WithError(err).Error("replay the event buffered by the standby")
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fakeLeases serves the Lease objects by the REST API of Kubernetes, and
+// rejects the updates of stale resource versions with conflict.
+type fakeLeases struct {
+	lock    sync.Mutex
+	leases  map[string]*lease
+	version int
+	token   string
+}
+
+func (s *fakeLeases) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer "+s.token {
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/leases/")
+
+	switch r.Method {
+	case http.MethodGet:
+		l, ok := s.leases[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"kind":"Status","code":404}`))
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(l)
+
+	case http.MethodPost, http.MethodPut:
+		l := new(lease)
+		b, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(b, l); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		old, ok := s.leases[l.Metadata.Name]
+		if r.Method == http.MethodPost && ok {
+			w.WriteHeader(http.StatusConflict)
+
+			return
+		}
+
+		if r.Method == http.MethodPut && (!ok || old.Metadata.ResourceVersion != l.Metadata.ResourceVersion) {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"kind":"Status","code":409}`))
+
+			return
+		}
+
+		s.version++
+		l.Metadata.ResourceVersion = strings.Repeat("v", s.version)
+		s.leases[l.Metadata.Name] = l
+
+		_ = json.NewEncoder(w).Encode(l)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestElector(t *testing.T, identity string) (*leaderElector, *fakeLeases) {
+	s := &fakeLeases{leases: make(map[string]*lease), token: "t"}
+
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	return &leaderElector{
+		cli: &leaseClient{
+			hc:    srv.Client(),
+			url:   srv.URL + "/leases",
+			token: func() string { return "t" },
+		},
+		name:          "welcome",
+		namespace:     "default",
+		identity:      identity,
+		leaseDuration: 15 * time.Second,
+		renewDeadline: 10 * time.Second,
+		retryPeriod:   2 * time.Second,
+	}, s
+}
+
+func TestLeaderElectorAcquire(t *testing.T) {
+	le, s := newTestElector(t, "a")
+
+	if err := le.tryAcquireOrRenew(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !le.isLeader() {
+		t.Fatal("a doesn't acquire the lease which doesn't exist")
+	}
+
+	l := s.leases["welcome"]
+	if l.Spec.HolderIdentity != "a" || l.Spec.LeaseDurationSeconds != 15 {
+		t.Errorf("lease = %+v", l.Spec)
+	}
+
+	if _, err := time.Parse(leaseTimeFormat, l.Spec.RenewTime); err != nil {
+		t.Errorf("renew time %q: %v", l.Spec.RenewTime, err)
+	}
+
+	// renew
+	v := l.Metadata.ResourceVersion
+	if err := le.tryAcquireOrRenew(); err != nil || !le.isLeader() {
+		t.Fatalf("renew: %v", err)
+	}
+
+	if s.leases["welcome"].Metadata.ResourceVersion == v {
+		t.Error("the lease is not renewed")
+	}
+}
+
+func TestLeaderElectorStandby(t *testing.T) {
+	le, s := newTestElector(t, "b")
+
+	now := time.Now().UTC().Format(leaseTimeFormat)
+	s.leases["welcome"] = &lease{
+		Metadata: leaseMetadata{Name: "welcome", ResourceVersion: "x"},
+		Spec:     leaseSpec{HolderIdentity: "a", LeaseDurationSeconds: 15, RenewTime: now},
+	}
+
+	if err := le.tryAcquireOrRenew(); err != nil {
+		t.Fatal(err)
+	}
+
+	if le.isLeader() {
+		t.Fatal("b takes the lease held by a")
+	}
+
+	// the lease expires.
+	s.leases["welcome"].Spec.RenewTime = time.Now().Add(-time.Minute).UTC().Format(leaseTimeFormat)
+
+	if err := le.tryAcquireOrRenew(); err != nil {
+		t.Fatal(err)
+	}
+
+	l := s.leases["welcome"]
+	if !le.isLeader() || l.Spec.HolderIdentity != "b" || l.Spec.LeaseTransitions != 1 {
+		t.Errorf("leader = %v, lease = %+v", le.isLeader(), l.Spec)
+	}
+}
+
+func TestLeaderElectorConflict(t *testing.T) {
+	le, s := newTestElector(t, "b")
+
+	s.leases["welcome"] = &lease{
+		Metadata: leaseMetadata{Name: "welcome", ResourceVersion: "x"},
+		Spec:     leaseSpec{HolderIdentity: "", LeaseDurationSeconds: 15},
+	}
+
+	// another replica updates the lease between the read and the write.
+	le.cli.hc.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method == http.MethodPut {
+			s.lock.Lock()
+			s.leases["welcome"].Metadata.ResourceVersion = "y"
+			s.lock.Unlock()
+		}
+
+		return http.DefaultTransport.RoundTrip(r)
+	})
+
+	if err := le.tryAcquireOrRenew(); err == nil {
+		t.Fatal("the conflict is not reported")
+	}
+
+	if le.isLeader() {
+		t.Error("b is the leader after the conflict")
+	}
+}
+
+func TestLeaderElectorUnauthorized(t *testing.T) {
+	le, _ := newTestElector(t, "a")
+	le.cli.token = func() string { return "wrong" }
+
+	if err := le.tryAcquireOrRenew(); err == nil || le.isLeader() {
+		t.Errorf("err = %v, leader = %v", err, le.isLeader())
+	}
+}
+
+func TestLeaderElectorRelease(t *testing.T) {
+	le, s := newTestElector(t, "a")
+
+	stopped := make(chan struct{})
+	le.onStoppedLeading = func() { close(stopped) }
+
+	if err := le.tryAcquireOrRenew(); err != nil {
+		t.Fatal(err)
+	}
+
+	le.release()
+
+	if le.isLeader() {
+		t.Error("a is still the leader")
+	}
+
+	if l := s.leases["welcome"]; l.Spec.HolderIdentity != "" || !l.expired(time.Now()) {
+		t.Errorf("the lease is not released: %+v", l.Spec)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Error("onStoppedLeading is not called")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestReplayStandbyEvents(t *testing.T) {
+	bot := newRobot(nil, nil)
+	log := logrus.NewEntry(logrus.New())
+
+	var replayed []string
+	handle := func(name string) func() error {
+		return func() error {
+			replayed = append(replayed, name)
+
+			return nil
+		}
+	}
+
+	handled := func(done bool, err error) func() (bool, error) {
+		return func() (bool, error) { return done, err }
+	}
+
+	bot.standby.push(handle("unknown"), nil, log)
+	bot.standby.push(handle("handled"), handled(true, nil), log)
+	bot.standby.push(handle("failed"), handled(false, errors.New("500")), log)
+	bot.standby.push(handle("pending"), handled(false, nil), log)
+	bot.standby.push(handle("stale"), handled(false, nil), log)
+	bot.standby.events[len(bot.standby.events)-1].at = time.Now().Add(-2 * standbyBufferMaxAge)
+
+	bot.replayStandbyEvents()
+
+	if len(replayed) != 1 || replayed[0] != "pending" {
+		t.Errorf("replayed %v, want [pending]", replayed)
+	}
+
+	if len(bot.standby.drain()) != 0 {
+		t.Error("the buffer is not drained")
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// linkCommands is the link to the help of bot commands, which is
+// required.
+const linkCommands = "commands"
+
+const (
+	linkCheckTimeout  = 10 * time.Second
+	linkCheckInterval = time.Hour
+)
+
+// linkRefRe matches the references of links in a template, such as
+// {{ .Links.guide }} and {{ index .Links "code-of-conduct" }}.
+var linkRefRe = regexp.MustCompile(`\.Links\.([A-Za-z0-9_]+)|index\s+\.Links\s+"([^"]+)"`)
+
+func (c *botConfig) commandLink() string {
+	return c.Links[linkCommands]
+}
+
+// referencedLinks returns the names of links referenced by the template.
+func referencedLinks(text string) []string {
+	var r []string
+	for _, m := range linkRefRe.FindAllStringSubmatch(text, -1) {
+		if m[1] != "" {
+			r = append(r, m[1])
+		} else {
+			r = append(r, m[2])
+		}
+	}
+
+	return r
+}
+
+// checkLinkRefs makes sure all the links referenced by the templates are
+// configured.
+func checkLinkRefs(links map[string]string, templates ...string) error {
+	for _, t := range templates {
+		for _, name := range referencedLinks(t) {
+			if links[name] == "" {
+				return fmt.Errorf("the link %s referenced by the template is not configured", name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkLinks checks the links of the configs which set check_links once an
+// hour, and logs the unreachable ones. The config is not rejected by them,
+// since a site may be down for a while.
+func (bot *robot) checkLinks() {
+	t := time.NewTicker(linkCheckInterval)
+	defer t.Stop()
+
+	for ; ; <-t.C {
+		c, err := bot.getConfig()
+		if err != nil {
+			logrus.WithError(err).Error("get config to check the links")
+
+			continue
+		}
+
+		checked := make(map[string]bool)
+		for _, cfg := range linkCheckConfigs(c) {
+			for _, name := range sortedLinkNames(cfg.Links) {
+				url := cfg.Links[name]
+				if checked[url] {
+					continue
+				}
+				checked[url] = true
+
+				if err := checkLinkReachable(bot.hc, url); err != nil {
+					logrus.WithError(err).WithFields(logrus.Fields{
+						"community": cfg.CommunityName,
+						"link":      name,
+					}).Warn("the link is not reachable")
+				}
+			}
+		}
+	}
+}
+
+func linkCheckConfigs(c *configuration) []*botConfig {
+	var r []*botConfig
+	for i := range c.ConfigItems {
+		if c.ConfigItems[i].CheckLinks {
+			r = append(r, &c.ConfigItems[i])
+		}
+	}
+
+	if c.Default != nil && c.Default.CheckLinks {
+		r = append(r, c.Default)
+	}
+
+	return r
+}
+
+func sortedLinkNames(links map[string]string) []string {
+	names := make([]string, 0, len(links))
+	for k := range links {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// checkLinkReachable sends a HEAD request to the link, and a GET one if
+// HEAD is not allowed, to make sure it is reachable.
+func checkLinkReachable(hc *http.Client, url string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), linkCheckTimeout)
+	defer cancel()
+
+	do := func(method string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return hc.Do(req)
+	}
+
+	resp, err := do(http.MethodHead)
+	if err == nil && resp.StatusCode == http.StatusMethodNotAllowed {
+		resp.Body.Close()
+		resp, err = do(http.MethodGet)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
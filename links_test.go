@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckLinkReachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/get-only" && r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	for path, ok := range map[string]bool{"/": true, "/get-only": true, "/missing": false} {
+		if err := checkLinkReachable(srv.Client(), srv.URL+path); (err == nil) != ok {
+			t.Errorf("check %s: %v", path, err)
+		}
+	}
+}
@@ -3,75 +3,380 @@ package main
 import (
 	"errors"
 	"flag"
-	"github.com/opensourceways/community-robot-lib/gitlabclient"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/opensourceways/community-robot-lib/config"
 	"github.com/opensourceways/community-robot-lib/logrusutil"
-	liboptions "github.com/opensourceways/community-robot-lib/options"
 	framework "github.com/opensourceways/community-robot-lib/robot-gitlab-framework"
 	"github.com/opensourceways/community-robot-lib/secret"
 	"github.com/sirupsen/logrus"
 )
 
-type options struct {
-	service liboptions.ServiceOptions
-	gitlab  liboptions.GitLabOptions
-}
+func main() {
+	logrusutil.ComponentInit(botName)
 
-func (o *options) Validate() error {
-	if err := o.service.Validate(); err != nil {
-		return err
-	}
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	args := os.Args[1:]
 
-	return o.gitlab.Validate()
-}
-
-func gatherOptions(fs *flag.FlagSet, args ...string) options {
-	var o options
+	// encrypt is the subcommand to encrypt a secret to put in the config.
+	if len(args) > 0 && args[0] == encryptCommand {
+		if err := runEncrypt(args[1:]); err != nil {
+			logrus.WithError(err).Fatal("Error encrypt the value.")
+		}
 
-	o.gitlab.AddFlags(fs)
-	o.service.AddFlags(fs)
+		return
+	}
 
-	_ = fs.Parse(args)
+	// backfill is the subcommand to welcome the existing merge requests
+	// and issues of a project.
+	var backfill *backfillOptions
+	if len(args) > 0 && args[0] == backfillCommand {
+		backfill = new(backfillOptions)
+		backfill.addFlags(fs)
+		args = args[1:]
+	}
 
-	return o
-}
+	// labels is the subcommand to export or sync the labels managed by the
+	// robot of a project.
+	var labels *labelsOptions
+	if len(args) > 0 && args[0] == labelsCommand {
+		labels = new(labelsOptions)
+		labels.addFlags(fs)
+		args = args[1:]
 
-func main() {
-	logrusutil.ComponentInit(botName)
+		if len(args) > 0 {
+			labels.action = args[0]
+			args = args[1:]
+		}
+	}
 
-	o := gatherOptions(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:]...)
+	o := gatherOptions(fs, args...)
 	if err := o.Validate(); err != nil {
 		logrus.WithError(err).Fatal("Invalid options")
 	}
 
+	if backfill != nil {
+		if err := backfill.validate(); err != nil {
+			logrus.WithError(err).Fatal("Invalid options")
+		}
+	}
+
+	if labels != nil {
+		if err := labels.validate(); err != nil {
+			logrus.WithError(err).Fatal("Invalid options")
+		}
+	}
+
+	tenants := new(tenantsFile)
+	if o.tenantsFile != "" {
+		v, err := loadTenantsFile(o.tenantsFile)
+		if err != nil {
+			logrus.WithError(err).Fatal("Error load tenants file.")
+		}
+
+		tenants = v
+	}
+
 	secretAgent := new(secret.Agent)
-	if err := secretAgent.Start([]string{o.gitlab.TokenPath}); err != nil {
+	secrets := append([]string{o.gitlab.TokenPath}, o.github.secretPaths()...)
+	secrets = append(secrets, o.gitee.secretPaths()...)
+	secrets = append(secrets, nonEmpty(o.adminTokenPath, o.extraHookSecretFile, o.syncHookSecretFile, o.bootstrapWebhookSecretFile)...)
+	secrets = append(secrets, o.orgTokenPaths.paths()...)
+	secrets = append(secrets, tenants.secretPaths()...)
+
+	if err := secretAgent.Start(secrets); err != nil {
 		logrus.WithError(err).Fatal("Error starting secret agent.")
 	}
 
 	defer secretAgent.Stop()
 
-	agent := config.NewConfigAgent(func() config.Config {
-		return &configuration{}
-	})
-	if err := agent.Start(o.service.ConfigFile); err != nil {
-		logrus.WithError(err).Errorf("start config: %s", o.service.ConfigFile)
+	hc, err := o.http.client()
+	if err != nil {
+		logrus.WithError(err).Fatal("Error init http client.")
+	}
+
+	if o.http.insecureSkipVerify {
+		logrus.Warn("the certificates of servers are not verified")
+	}
+
+	var getConfig func() (*configuration, error)
+
+	if o.remote.url != "" {
+		rc, err := o.remote.config(hc)
+		if err != nil {
+			logrus.WithError(err).Fatal("Error init remote config.")
+		}
+
+		if err := rc.start(); err != nil {
+			logrus.WithError(err).Errorf("start config: %s", o.remote.url)
+			return
+		}
+
+		go rc.run(o.remote.interval)
+
+		getConfig = rc.get
+	} else {
+		gc, err := startConfigAgent(o.service.ConfigFile)
+		if err != nil {
+			logrus.WithError(err).Errorf("start config: %s", o.service.ConfigFile)
+			return
+		}
+
+		getConfig = gc
+	}
+
+	getToken := secretAgent.GetTokenGenerator(o.gitlab.TokenPath)
+
+	// the faults are injected beneath the limiters, so that they back off
+	// as the dependencies do fail.
+	chaos, err := chaosScenarioFromEnv()
+	if err != nil {
+		logrus.WithError(err).Fatal("Error init chaos scenario.")
+	}
+
+	// the clients of GitLab share the limiters, as they call the same
+	// instance.
+	gitlabHC := o.limits.gitlabClient(withChaos(hc, chaos, gitlabDependency))
+
+	c, err := newGitlabClient(getToken, gitlabAPIURL, gitlabHC)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error init gitlab client.")
+	}
+
+	r := newRobot(newInstrumentedClient(c, getToken), getConfig)
+
+	// the clients of orgs are pooled by org.
+	r.orgClients = make(map[string]iClient, len(o.orgTokenPaths))
+	for org, path := range o.orgTokenPaths {
+		getToken := secretAgent.GetTokenGenerator(path)
+
+		oc, err := newGitlabClient(getToken, gitlabAPIURL, gitlabHC)
+		if err != nil {
+			logrus.WithError(err).Fatalf("Error init gitlab client of org %s.", org)
+		}
+
+		r.orgClients[org] = newInstrumentedClient(oc, getToken)
+	}
+	r.publisher = o.events.publisher(hc)
+	r.hc = hc
+	r.newcomerHC = o.limits.newcomerClient(withChaos(hc, chaos, func(*http.Request) string {
+		return dependencyNewcomer
+	}))
+	r.welcomes = newWelcomeHistory(o.welcomeHistoryFile)
+	r.triage = newTriageRotation(o.triageStateFile)
+	r.mentions = newMentionLedger(o.mentionLedgerFile)
+	r.conversions = newConversionTracker(o.conversionFile)
+	r.engagements = newEngagementTracker(o.engagementFile)
+	r.responses = newResponseTracker(o.responseFile)
+	r.digests = newDigestLog(o.digestFile)
+	r.limiter = newProjectLimiter(o.projectConcurrency, o.projectConcurrencyLimits)
+	r.ordering = newEventOrdering(o.eventPartitions)
+	r.ingestOrdering = newEventOrdering(o.eventPartitions)
+	r.content = contentDecoder{maxSize: o.maxFileSize, encoding: o.fileEncoding}
+	r.fileCacheByCommit = o.fileCacheByCommit
+
+	if c := o.ingest.consumer(hc); c != nil {
+		r.consumer = &consumerRunner{bot: r, consumer: c}
+	}
+
+	if o.bootstrapWebhookSecretFile != "" {
+		r.webhookSecret = func() []byte {
+			return secretAgent.GetSecret(o.bootstrapWebhookSecretFile)
+		}
+	}
+
+	if o.privacyMode {
+		r.privacy = true
+		logrus.AddHook(privacyHook{})
+	}
+
+	if backfill != nil {
+		if err := r.backfill(backfill); err != nil {
+			logrus.WithError(err).Error("backfill")
+		}
+
 		return
 	}
 
-	defer agent.Stop()
+	if labels != nil {
+		if err := r.runLabels(labels); err != nil {
+			logrus.WithError(err).Error("labels")
+		}
 
-	c := gitlabclient.NewGitlabClient(secretAgent.GetTokenGenerator(o.gitlab.TokenPath), "https://source.openeuler.sh/api/v4")
+		return
+	}
+
+	if o.leader.enabled {
+		le, err := o.leader.elector()
+		if err != nil {
+			logrus.WithError(err).Fatal("Error init leader election.")
+		}
+
+		le.onStartedLeading = func() {
+			r.consumer.sync()
+			r.replayStandbyEvents()
+		}
+		le.onStoppedLeading = r.consumer.sync
+		r.elector = le
+
+		stop := make(chan struct{})
+		go le.run(stop)
+
+		// release the lease on shutdown, so that the standby takes over
+		// without waiting for the lease to expire.
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sig
+			close(stop)
+		}()
+
+		http.HandleFunc("/leader", le.readyHandler)
+	}
+
+	if cfg, err := r.getConfig(); err == nil {
+		go r.warmCache(cfg, o.warmup.workers, o.warmup.interval)
+	}
+
+	go r.checkLinks()
+
+	if o.bestEffortQueueSize > 0 {
+		r.bestEffort = newBestEffortQueue(o.bestEffortQueueSize, o.bestEffortInterval)
+
+		go r.bestEffort.run(r)
+	}
+
+	// the consumer is started once the replica is the leader if the
+	// leader election is enabled.
+	r.consumer.sync()
+
+	if o.staleCleanupInterval > 0 {
+		go r.cleanupStaleWelcomes(o.staleCleanupInterval)
+	}
+
+	if o.conversionCheckInterval > 0 {
+		go r.checkConversions(o.conversionCheckInterval)
+	}
+
+	if o.engagementCheckInterval > 0 {
+		go r.checkEngagements(o.engagementCheckInterval)
+	}
+
+	if o.responseCheckInterval > 0 {
+		go r.checkResponses(o.responseCheckInterval)
+	}
+
+	go r.sendDigests()
+
+	if o.github.tokenPath != "" {
+		gc := &githubClient{getToken: secretAgent.GetTokenGenerator(o.github.tokenPath), hc: hc}
+
+		http.Handle("/github-hook", r.githubHandler(gc, func() []byte {
+			return secretAgent.GetSecret(o.github.hmacSecretFile)
+		}))
+	}
+
+	if o.gitee.tokenPath != "" {
+		gc := &giteeClient{getToken: secretAgent.GetTokenGenerator(o.gitee.tokenPath), hc: hc}
+
+		http.Handle("/gitee-hook", r.giteeHandler(gc, func() []byte {
+			return secretAgent.GetSecret(o.gitee.secretFile)
+		}))
+	}
 
-	r := newRobot(c, func() (*configuration, error) {
+	// the extra events can bootstrap the projects, so they are served only
+	// if the secret is configured.
+	if o.extraHookSecretFile != "" {
+		http.Handle("/gitlab-extra-hook", r.extraEventsHandler(func() []byte {
+			return secretAgent.GetSecret(o.extraHookSecretFile)
+		}))
+	} else {
+		logrus.Warn("gitlab-extra-hook-secret-file is not set, /gitlab-extra-hook is not served")
+	}
+
+	// the sync hook takes all the events, so it is served only if the
+	// secret is configured.
+	if o.syncHookSecretFile != "" {
+		http.Handle("/gitlab-sync-hook", r.syncEventsHandler(func() []byte {
+			return secretAgent.GetSecret(o.syncHookSecretFile)
+		}))
+	}
+
+	admin := &adminServer{}
+	if o.adminTokenPath != "" {
+		admin.getToken = secretAgent.GetTokenGenerator(o.adminTokenPath)
+	} else {
+		logrus.Warn("admin-token-path is not set, the admin endpoints are not served")
+	}
+
+	http.HandleFunc("/metrics", metricsHandler)
+	http.HandleFunc("/version", versionHandler)
+	admin.handleRobot("", r)
+
+	// the tenants are served by their own robots, and the events of the
+	// other orgs by the default one.
+	router := newTenantRouter(r)
+	for i := range tenants.Tenants {
+		spec := &tenants.Tenants[i]
+
+		tr, err := newTenantRobot(spec, &o, hc, chaos, secretAgent.GetTokenGenerator(spec.TokenPath))
+		if err != nil {
+			logrus.WithError(err).Fatalf("Error init tenant %s.", spec.Name)
+		}
+
+		tadmin := &adminServer{}
+		if spec.AdminTokenPath != "" {
+			tadmin.getToken = secretAgent.GetTokenGenerator(spec.AdminTokenPath)
+		} else {
+			logrus.Warnf("admin_token_path of tenant %s is not set, its admin endpoints are not served", spec.Name)
+		}
+		tadmin.handleRobot("/tenants/"+spec.Name, tr)
+
+		if cfg, err := tr.getConfig(); err == nil {
+			go tr.warmCache(cfg, o.warmup.workers, o.warmup.interval)
+		}
+
+		go tr.sendDigests()
+		go tr.checkLinks()
+
+		router.add(spec, tr)
+	}
+
+	if len(tenants.Tenants) > 0 {
+		r.router = router
+		admin.handle("/admin/tenants", router.tenantsHandler)
+	}
+
+	if o.selftest.project != "" {
+		r.selftest(o.selftest.project, o.selftest.issue).log()
+
+		admin.handle("/selftest", r.selftestHandler(o.selftest.project, o.selftest.issue))
+	}
+
+	if r.router != nil {
+		framework.Run(router, o.service.Port, o.service.GracePeriod)
+	} else {
+		framework.Run(r, o.service.Port, o.service.GracePeriod)
+	}
+}
+
+// startConfigAgent starts watching the config file.
+func startConfigAgent(file string) (func() (*configuration, error), error) {
+	agent := config.NewConfigAgent(func() config.Config {
+		return &configuration{}
+	})
+	if err := agent.Start(file); err != nil {
+		return nil, err
+	}
+
+	return func() (*configuration, error) {
 		_, cfg := agent.GetConfig()
 		if c, ok := cfg.(*configuration); ok {
 			return c, nil
 		}
 		return nil, errors.New("can't convert to configuration")
-	})
-
-	framework.Run(r, o.service.Port, o.service.GracePeriod)
+	}, nil
 }
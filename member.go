@@ -0,0 +1,29 @@
+package main
+
+import "fmt"
+
+const (
+	memberAuthorSkip    = "skip"
+	memberAuthorMinimal = "minimal"
+
+	minimalWelcomeMessage = `
+Hi ***%s***, thanks for your contribution to the SIG: [%s](https://gitee.com/openeuler/community/tree/master/sig/%s).`
+)
+
+func isSigMember(author string, members ...[]string) bool {
+	for _, v := range members {
+		for _, m := range v {
+			if m == author {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func genMinimalComment(author, sigName string) string {
+	return fmt.Sprintf(
+		minimalWelcomeMessage, escapeUsername(author), sanitizeText(sigName), sanitizePathSegment(sigName),
+	)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// usernamePlaceholder is replaced with the username in the mention
+	// format.
+	usernamePlaceholder = "{username}"
+
+	// sigPlaceholder is replaced with the sig name in the mirror group
+	// format.
+	sigPlaceholder = "{sig}"
+
+	mirrorGroupCacheTTL = time.Hour
+)
+
+// mentionFuncs returns the template functions overriding mention with the
+// format of the GitLab instance. The group is always mentioned by its full
+// path.
+func mentionFuncs(cfg *mentionConfig, group string) template.FuncMap {
+	return template.FuncMap{
+		"mention": func(v []string) []string {
+			r := make([]string, len(v))
+			for i := range v {
+				if v[i] == group {
+					r[i] = "@" + group
+				} else {
+					r[i] = strings.Replace(cfg.Format, usernamePlaceholder, v[i], -1)
+				}
+			}
+
+			return r
+		},
+	}
+}
+
+// sigGroup returns the GitLab group to mention instead of the maintainers
+// and committers of sig. It is empty if not configured.
+func (bot *robot) sigGroup(sigName string, cfg *mentionConfig) string {
+	if v := cfg.Groups[sigName]; v != "" {
+		return strings.TrimPrefix(v, "@")
+	}
+
+	if containsString(cfg.GroupMention, sigName) || containsString(cfg.GroupMention, "*") {
+		return bot.mirrorGroup(sigName, cfg)
+	}
+
+	return ""
+}
+
+// mirrorGroup returns the full path of the GitLab group mirroring the sig.
+// It is empty if the sig has none.
+func (bot *robot) mirrorGroup(sigName string, cfg *mentionConfig) string {
+	if v := cfg.Groups[sigName]; v != "" {
+		return strings.TrimPrefix(v, "@")
+	}
+
+	if cfg.MirrorGroupFormat == "" || sigName == "" {
+		return ""
+	}
+
+	path := strings.Replace(cfg.MirrorGroupFormat, sigPlaceholder, sigName, -1)
+
+	// the groups which don't exist are cached too.
+	key := "mirror-groups/" + path
+	if v, ok := bot.files.get(key); ok {
+		return v.(string)
+	}
+
+	if _, err := bot.cli.GetGroup(path); err != nil {
+		if !isNotFound(err) {
+			return ""
+		}
+
+		path = ""
+	}
+
+	bot.files.set(key, path, mirrorGroupCacheTTL)
+
+	return path
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	mentorshipCacheTTL = 30 * 24 * time.Hour
+
+	defaultMentorshipTitle = "Mentor {{ .Author }} on {{ .Item }}"
+
+	defaultMentorshipDescription = `A newcomer ***{{ .Author }}*** opened {{ .Item }} in sig ***{{ .Sig }}***.
+
+{{ if .Mentor }}{{ .Mentor }}, it's your turn to help them get started, such as reviewing it and answering their questions. {{ end }}` +
+		`Please close this issue once they are on board.`
+)
+
+type mentorshipConfig struct {
+	// Enabled means to open an issue in Project when a newcomer is
+	// welcomed, which is assigned to the mentor of sig in turn, so that
+	// the onboarding is followed up.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Project is the path of the project of the mentorship issues, such
+	// as openeuler/mentorship.
+	Project string `json:"project,omitempty"`
+
+	// Rotations maps the sig name to its ordered mentor rotation. The
+	// mentors of sig-info are used for the sigs which are not in it.
+	Rotations map[string][]string `json:"rotations,omitempty"`
+
+	// Labels are added to the issues.
+	Labels []string `json:"labels,omitempty"`
+
+	// Title and Description are the go templates of the issue, which can
+	// use .Author, .Item, the reference of the merge request or issue,
+	// .Sig and .Mentor, which is empty if the sig has no mentor. The mentor
+	// is mentioned by mention.format in the description.
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+func (c *mentorshipConfig) setDefault() {
+	if c.Title == "" {
+		c.Title = defaultMentorshipTitle
+	}
+
+	if c.Description == "" {
+		c.Description = defaultMentorshipDescription
+	}
+}
+
+func (c *mentorshipConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Project == "" {
+		return fmt.Errorf("project of mentorship must be set")
+	}
+
+	if _, err := parseTemplate(c.Title); err != nil {
+		return fmt.Errorf("invalid title of mentorship: %v", err)
+	}
+
+	if _, err := parseTemplate(c.Description); err != nil {
+		return fmt.Errorf("invalid description of mentorship: %v", err)
+	}
+
+	return nil
+}
+
+// mentorMembers returns the mentor rotation of the sig. The one of config
+// takes precedence over the mentors of sig-info.
+func (bot *robot) mentorMembers(pid int, sigName string, cfg *mentorshipConfig) ([]string, error) {
+	if v := cfg.Rotations[sigName]; len(v) > 0 {
+		return v, nil
+	}
+
+	info, err := bot.getSigInfo(pid, sigName)
+	if err != nil {
+		return nil, err
+	}
+
+	var r []string
+	for _, m := range info.Mentors {
+		if m.GiteeID != "" {
+			r = append(r, m.GiteeID)
+		}
+	}
+
+	return r, nil
+}
+
+// itemReference returns the reference of the merge request or issue, such
+// as org/repo!12, which GitLab links in any project.
+func itemReference(org, repo string, acts actions) (string, string, bool) {
+	switch a := acts.(type) {
+	case *mrActions:
+		return fmt.Sprintf("%s/%s!%d", org, repo, a.number), fmt.Sprintf("mr/%d/%d", a.projectID, a.number), true
+	case *issueActions:
+		return fmt.Sprintf("%s/%s#%d", org, repo, a.number), fmt.Sprintf("issue/%d/%d", a.projectID, a.number), true
+	default:
+		return "", "", false
+	}
+}
+
+// openMentorship opens the issue to follow up the onboarding of the
+// newcomer, which is assigned to the mentor of sig whose turn it is. It is
+// opened once for each merge request or issue.
+func (bot *robot) openMentorship(
+	org, repo, author string, pid int, acts actions, plan *ActionPlan, cfg *botConfig, log *logrus.Entry,
+) error {
+	c := &cfg.Mentorship
+
+	ref, key, ok := itemReference(org, repo, acts)
+	if !ok {
+		return nil
+	}
+
+	if _, ok := bot.mentorships.get(key); ok {
+		return nil
+	}
+
+	mentor := ""
+	if plan.SigName != "" {
+		members, err := bot.mentorMembers(pid, plan.SigName, c)
+		if err != nil {
+			log.WithError(err).Errorf("get the mentors of sig %s", plan.SigName)
+		} else if len(members) > 0 {
+			mentor = bot.triage.pick(fmt.Sprintf("mentorship/%s/%s", cfg.CommunityName, plan.SigName), members)
+		}
+	}
+
+	// the title is plain text, so only the description is escaped.
+	title, err := renderTemplate(c.Title, map[string]interface{}{
+		"Author": author,
+		"Item":   ref,
+		"Sig":    plan.SigName,
+		"Mentor": mentor,
+	})
+	if err != nil {
+		return err
+	}
+
+	mention := ""
+	if mentor != "" {
+		mention = strings.Replace(cfg.Mention.Format, usernamePlaceholder, mentor, -1)
+	}
+
+	desc, err := renderTemplate(c.Description, map[string]interface{}{
+		"Author": escapeUsername(author),
+		"Item":   ref,
+		"Sig":    sanitizeText(plan.SigName),
+		"Mentor": mention,
+	})
+	if err != nil {
+		return err
+	}
+
+	cli := bot.clientFor(strings.SplitN(c.Project, "/", 2)[0])
+
+	var assignees []int
+	if mentor != "" {
+		id, err := cli.GetUserID(mentor)
+		if err != nil {
+			log.WithError(err).WithField("mentor", mentor).Error("get the id of mentor")
+		} else {
+			assignees = []int{id}
+		}
+	}
+
+	issue, err := cli.CreateIssue(c.Project, title, desc, assignees, c.Labels)
+	if err != nil {
+		return err
+	}
+
+	bot.mentorships.set(key, true, mentorshipCacheTTL)
+
+	if issue != nil {
+		log.WithField("mentor", mentor).Infof("open the mentorship issue %s#%d", c.Project, issue.IID)
+	}
+
+	return nil
+}
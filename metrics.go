@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	metricTypeCounter = "counter"
+	metricTypeGauge   = "gauge"
+)
+
+// metricVec is a group of metrics with the same name which are
+// distinguished by the values of labels. It is exposed in the text format
+// of Prometheus at /metrics.
+type metricVec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	lock   sync.Mutex
+	values map[string]float64
+}
+
+var metricsRegistry struct {
+	lock sync.Mutex
+	vecs []*metricVec
+}
+
+func newMetricVec(kind, name, help string, labels ...string) *metricVec {
+	v := &metricVec{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		values: make(map[string]float64),
+	}
+
+	metricsRegistry.lock.Lock()
+	metricsRegistry.vecs = append(metricsRegistry.vecs, v)
+	metricsRegistry.lock.Unlock()
+
+	return v
+}
+
+func newCounterVec(name, help string, labels ...string) *metricVec {
+	return newMetricVec(metricTypeCounter, name, help, labels...)
+}
+
+func newGaugeVec(name, help string, labels ...string) *metricVec {
+	return newMetricVec(metricTypeGauge, name, help, labels...)
+}
+
+func (m *metricVec) key(values []string) string {
+	if len(values) != len(m.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values", m.name, len(m.labels)))
+	}
+
+	items := make([]string, len(values))
+	for i, v := range values {
+		v = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+		items[i] = fmt.Sprintf(`%s="%s"`, m.labels[i], v)
+	}
+
+	return strings.Join(items, ",")
+}
+
+func (m *metricVec) add(delta float64, values ...string) {
+	k := m.key(values)
+
+	m.lock.Lock()
+	m.values[k] += delta
+	m.lock.Unlock()
+}
+
+func (m *metricVec) inc(values ...string) {
+	m.add(1, values...)
+}
+
+func (m *metricVec) set(v float64, values ...string) {
+	k := m.key(values)
+
+	m.lock.Lock()
+	m.values[k] = v
+	m.lock.Unlock()
+}
+
+func (m *metricVec) write(b *strings.Builder) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+
+	keys := make([]string, 0, len(m.values))
+	for k := range m.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if k == "" {
+			fmt.Fprintf(b, "%s %v\n", m.name, m.values[k])
+		} else {
+			fmt.Fprintf(b, "%s{%s} %v\n", m.name, k, m.values[k])
+		}
+	}
+}
+
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	b := new(strings.Builder)
+
+	metricsRegistry.lock.Lock()
+	for _, v := range metricsRegistry.vecs {
+		v.write(b)
+	}
+	metricsRegistry.lock.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write([]byte(b.String()))
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultBlockedAuthorLabel = "blocked-author"
+
+	moderationCacheTTL = 10 * time.Minute
+)
+
+// closer is implemented by the actions which can close the merge request
+// or issue.
+type closer interface {
+	close() error
+}
+
+func (a *mrActions) close() error {
+	return a.cli.CloseMergeRequest(a.projectID, a.number)
+}
+
+func (a *issueActions) close() error {
+	return a.cli.CloseIssue(a.projectID, a.number)
+}
+
+// isBlocked reports whether the author is blocked or banned by the
+// community, which is looked up in the list of config and then asked to
+// the moderation service. The service is not asked in the privacy mode, as
+// the username would be sent out.
+func (bot *robot) isBlocked(author string, cfg *botConfig) (bool, error) {
+	c := &cfg.Moderation
+	if containsString(c.BlockedUsers, author) {
+		return true, nil
+	}
+
+	if c.ServiceURL == "" || bot.privacy {
+		return false, nil
+	}
+
+	key := c.ServiceURL + "/" + author
+	if v, ok := bot.moderation.get(key); ok {
+		return v.(bool), nil
+	}
+
+	blocked, err := askModerationService(bot.hc, c.ServiceURL, author)
+	if err != nil {
+		return false, err
+	}
+
+	bot.moderation.set(key, blocked, moderationCacheTTL)
+
+	return blocked, nil
+}
+
+// askModerationService sends GET {service}?user={author}, and the service
+// responds {"blocked": true} if the author is blocked.
+func askModerationService(hc *http.Client, service, author string) (bool, error) {
+	u, err := url.Parse(service)
+	if err != nil {
+		return false, err
+	}
+
+	q := u.Query()
+	q.Set("user", author)
+	u.RawQuery = q.Encode()
+
+	resp, err := hc.Get(u.String())
+	if err != nil {
+		return false, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("ask the moderation service, status code: %d", resp.StatusCode)
+	}
+
+	var v struct {
+		Blocked bool `json:"blocked"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return false, err
+	}
+
+	return v.Blocked, nil
+}
+
+// handleBlockedAuthor labels the merge request or issue of the blocked
+// author instead of welcoming, and closes it with the message if
+// configured.
+func (bot *robot) handleBlockedAuthor(author string, pid int, acts actions, cfg *botConfig, log *logrus.Entry) error {
+	c := &cfg.Moderation
+
+	log = log.WithField("blocked_author", author)
+	if cfg.DryRun {
+		log.Info("skip the welcome of blocked author")
+
+		return nil
+	}
+
+	if err := bot.createLabelIfNeed(acts, pid, c.Label, log); err != nil {
+		log.Errorf("create repo label:%s, err:%s", c.Label, err.Error())
+	}
+
+	if err := acts.addLabel(c.Label); err != nil {
+		return err
+	}
+
+	if !c.Close {
+		return nil
+	}
+
+	cl, ok := acts.(closer)
+	if !ok {
+		log.Warn("closing is not supported")
+
+		return nil
+	}
+
+	if c.CloseMessage != "" {
+		comment, err := renderTemplate(c.CloseMessage, map[string]interface{}{
+			"Author":    escapeUsername(author),
+			"Community": cfg.CommunityName,
+			"Links":     cfg.Links,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := acts.addComment(comment); err != nil {
+			return err
+		}
+	}
+
+	return cl.close()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/opensourceways/community-robot-lib/gitlabclient"
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	actionUpdate = "update"
+
+	defaultNeedsDescriptionLabel = "needs-description"
+
+	defaultDescriptionAsk = `It seems some parts of the merge request template are not filled in yet. ` +
+		`Would you mind completing the description, so that the reviewers can understand your change quickly? Thanks!`
+)
+
+// unfilled reports whether the description keeps any placeholder of the
+// merge request template.
+func (c *mrTemplateConfig) unfilled(description string) bool {
+	if strings.TrimSpace(description) == "" {
+		return true
+	}
+
+	for _, m := range c.Markers {
+		if strings.Contains(description, m) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// descriptionUnfilled reports whether the description of merge request
+// keeps the placeholders of template.
+func (bot *robot) descriptionUnfilled(pid, number int, cfg *botConfig) (bool, error) {
+	mr, err := bot.cli.GetMergeRequest(pid, number)
+	if err != nil {
+		return false, err
+	}
+
+	return cfg.MRTemplate.unfilled(mr.Description), nil
+}
+
+// handleDescriptionUpdate removes the label of needing description once
+// the placeholders of template are filled in.
+func (bot *robot) handleDescriptionUpdate(e *gitlab.MergeEvent, cfg *botConfig, log *logrus.Entry) error {
+	c := &cfg.MRTemplate
+	if !c.Enabled {
+		return nil
+	}
+
+	d := &e.Changes.Description
+	if d.Previous == d.Current || c.unfilled(d.Current) {
+		return nil
+	}
+
+	found := false
+	for _, l := range e.Labels {
+		if l != nil && l.Title == c.Label {
+			found = true
+
+			break
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	if bot.deferIfStandby(func() error {
+		return bot.handleDescriptionUpdate(e, cfg, log)
+	}, log) {
+		return nil
+	}
+
+	log.Infof("the description is filled in, remove the label %s", c.Label)
+
+	if cfg.DryRun {
+		return nil
+	}
+
+	org, _ := gitlabclient.GetMROrgAndRepo(e)
+
+	return bot.clientFor(org).RemoveMergeRequestLabel(
+		e.Project.ID, e.ObjectAttributes.IID, gitlab.Labels{c.Label},
+	)
+}
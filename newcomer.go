@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	newcomerLabel        = "newcomer"
+	newcomerSourceIPB    = "ipb"
+	newcomerSourceGitlab = "gitlab"
+
+	stateMerged = "merged"
+	stateOpened = "opened"
+
+	actionMerge = "merge"
+)
+
+var newcomerGraduationsTotal = newCounterVec(
+	"welcome_newcomer_graduations_total",
+	"Number of the authors who crossed the merged merge requests threshold of the newcomer label by org.",
+	"org",
+)
+
+// checkNewcomer reports whether to check if the author of the merge
+// request or issue is a newcomer. The number is 0 for the issues, which are
+// told by the metadata.
+func checkNewcomer(number int, meta *itemMeta, cfg *newcomerConfig) bool {
+	if number > 0 {
+		return cfg.MergeRequests == nil || *cfg.MergeRequests
+	}
+
+	return meta != nil && !meta.IsMergeRequest && cfg.Issues
+}
+
+// countContributions returns the number of contributions of author to the
+// community before the merge request or issue which triggers the check. The
+// author is a newcomer if it is 0. byMR tells whether the check is triggered
+// by a merge request, which is excluded from the contributions.
+func (bot *robot) countContributions(org, author string, byMR bool, cfg *botConfig) (int, error) {
+	if bot.contributionSource(&cfg.Newcomer) == newcomerSourceGitlab {
+		n, fresh, err := bot.countGitlabContributions(org, author, cfg)
+		if err != nil {
+			return 0, err
+		}
+
+		// the merge request which is being handled is counted too, but
+		// only by the count fetched for it. The cached one was fetched
+		// before it was opened.
+		if byMR && fresh && n > 0 {
+			n--
+		}
+
+		return n, nil
+	}
+
+	return countIPBContributions(bot.newcomerHC, author)
+}
+
+// countGitlabContributions returns the number of merge requests of author
+// in the group, and whether it is fetched rather than cached.
+func (bot *robot) countGitlabContributions(org, author string, cfg *botConfig) (int, bool, error) {
+	key := contributionsKey(org, author, &cfg.Newcomer)
+	if v, ok := bot.contributions.get(key); ok {
+		return v.(int), false, nil
+	}
+
+	group := newcomerGroup(org, &cfg.Newcomer)
+
+	n := 0
+	for _, state := range []string{stateMerged, stateOpened} {
+		v, err := bot.cli.CountGroupMergeRequests(group, author, state)
+		if err != nil {
+			return 0, false, err
+		}
+
+		n += v
+	}
+
+	bot.contributions.set(key, n, time.Duration(cfg.Newcomer.CacheTTL)*time.Second)
+
+	return n, true, nil
+}
+
+func contributionsKey(org, author string, cfg *newcomerConfig) string {
+	return fmt.Sprintf("%s/%s", newcomerGroup(org, cfg), author)
+}
+
+// forgetContributions drops the cached contributions of author, once the
+// author is welcomed as a newcomer, so that the next merge request is
+// counted with the one welcomed.
+func (bot *robot) forgetContributions(org, author string, cfg *newcomerConfig) {
+	bot.contributions.delete(contributionsKey(org, author, cfg))
+}
+
+func countIPBContributions(hc *http.Client, author string) (int, error) {
+	resp, err := hc.Get(fmt.Sprintf("https://ipb.osinfra.cn/pulls?author=%s", author))
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	var t struct {
+		Total int `json:"total,omitempty"`
+	}
+
+	if err = json.Unmarshal(body, &t); err != nil {
+		return 0, err
+	}
+
+	return t.Total, nil
+}
+
+// newcomerGroup is the group where the merge requests of author are counted.
+func newcomerGroup(org string, cfg *newcomerConfig) string {
+	if cfg.Group != "" {
+		return cfg.Group
+	}
+
+	return org
+}
+
+// countMergedMRs returns the number of merge requests of author merged in
+// the group, which is cached as the contributions.
+func (bot *robot) countMergedMRs(org, author string, cfg *newcomerConfig) (int, error) {
+	group := newcomerGroup(org, cfg)
+
+	key := fmt.Sprintf("%s/%s/%s", stateMerged, group, author)
+	if v, ok := bot.contributions.get(key); ok {
+		return v.(int), nil
+	}
+
+	n, err := bot.cli.CountGroupMergeRequests(group, author, stateMerged)
+	if err != nil {
+		return 0, err
+	}
+
+	bot.contributions.set(key, n, time.Duration(cfg.CacheTTL)*time.Second)
+
+	return n, nil
+}
+
+// labelNewcomer reports whether to label the author as newcomer. It is the
+// newcomer welcomed only, unless label_until_merged is set, in which case
+// it is the author who has merged less merge requests than it.
+func (bot *robot) labelNewcomer(org, author string, newcomer bool, cfg *newcomerConfig, log *logrus.Entry) bool {
+	if cfg.LabelUntilMerged <= 0 {
+		return newcomer
+	}
+
+	n, err := bot.countMergedMRs(org, author, cfg)
+	if err != nil {
+		log.WithError(err).Error("count the merged merge requests of author")
+
+		return newcomer
+	}
+
+	return n < cfg.LabelUntilMerged
+}
+
+// handleNewcomerMerged removes the newcomer label from the open merge
+// requests and issues of author in the project, once the author crosses
+// the merged merge requests threshold by the one merged.
+func (bot *robot) handleNewcomerMerged(e *gitlab.MergeEvent, org string, cfg *botConfig, log *logrus.Entry) error {
+	c := &cfg.Newcomer
+	if c.LabelUntilMerged <= 0 || !c.RemoveLabel {
+		return nil
+	}
+
+	cli, pid := bot.clientFor(org), e.Project.ID
+
+	// the user of event is who merged it rather than the author.
+	mr, err := cli.GetMergeRequest(pid, e.ObjectAttributes.IID)
+	if err != nil || mr.Author == nil || !containsString(mr.Labels, newcomerLabel) {
+		return err
+	}
+
+	author := mr.Author.Username
+
+	// the count may be cached before the merge.
+	bot.contributions.delete(fmt.Sprintf("%s/%s/%s", stateMerged, newcomerGroup(org, c), author))
+
+	n, err := bot.countMergedMRs(org, author, c)
+	if err != nil || n < c.LabelUntilMerged {
+		return err
+	}
+
+	newcomerGraduationsTotal.inc(org)
+
+	log = log.WithField("merged", n)
+	log.Info("the author is no longer a newcomer, remove the newcomer label")
+
+	if cfg.DryRun {
+		return nil
+	}
+
+	labels := gitlab.Labels{newcomerLabel}
+	mErr := newMultiError()
+
+	mrs, err := cli.ListOpenLabeledMergeRequests(pid, newcomerLabel)
+	mErr.add(err)
+
+	for _, mr := range mrs {
+		if mr.Author != nil && mr.Author.Username == author {
+			mErr.add(cli.RemoveMergeRequestLabel(pid, mr.IID, labels))
+		}
+	}
+
+	issues, err := cli.ListOpenLabeledIssues(pid, newcomerLabel)
+	mErr.add(err)
+
+	for _, issue := range issues {
+		if issue.Author != nil && issue.Author.Username == author {
+			mErr.add(cli.RemoveIssueLabels(pid, issue.IID, labels))
+		}
+	}
+
+	return mErr.err()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+const ownershipWelcomeTemplate = `
+Hi ***{{ .Author }}***, welcome to the {{ .Community }} Community.
+I'm the Bot here serving you. You can find the instructions on how to interact with me at **[Here]({{ .CommandLink }})**.
+{{- if .Maintainers }}
+If you have any questions, please contact any of the owners of the changed files: {{ mention .Maintainers | join " , " }}{{ end }}`
+
+// getOwnershipOwners returns the owners and labels of the files changed by
+// the merge request, which are all the robot welcomes by when the sigs are
+// not used. There are none for the issues.
+func (bot *robot) getOwnershipOwners(pid, number int, changes []string, cfg *botConfig, log *logrus.Entry) ([]string, []string, error) {
+	if changes == nil {
+		if number == 0 {
+			return nil, nil, nil
+		}
+
+		v, err := bot.cli.GetMergeRequestChanges(pid, number)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		changes = v
+	}
+
+	owners, labels, err := bot.findRelationMatches(pid, changes, cfg, log)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return owners.List(), labels.List(), nil
+}
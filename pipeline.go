@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	objectKindPipeline = "pipeline"
+
+	pipelineStatusFailed = "failed"
+
+	// ciHelpMarker identifies the comment of CI help, so that it is posted
+	// only on the first failure.
+	ciHelpMarker = "<!-- " + botName + "-robot: ci-help -->"
+
+	ciHelpTemplate = ciHelpMarker + `
+Hi ***{{ .Author }}***, the pipeline of this merge request failed. Don't worry, it happens to everyone, and here are the common causes:
+- the code does not pass the lint or format check, please run them locally before pushing.
+- the unit tests fail, please check the log of the failed job for the details.
+- the commits are not signed off or the commit messages do not follow the convention.
+- a flaky job fails occasionally, you can retry it on the pipeline page.
+{{- if .DocsURL }}
+
+You can find more about the CI at **[Here]({{ .DocsURL }})**.
+{{- end }}`
+)
+
+// handlePipelineEvent posts the CI help to the merge request of newcomer
+// on its first failed pipeline.
+func (bot *robot) handlePipelineEvent(e *gitlab.PipelineEvent, log *logrus.Entry) error {
+	// only the pipelines of merge request carry the merge request.
+	if e.ObjectAttributes.Status != pipelineStatusFailed || e.MergeRequest.IID == 0 {
+		return nil
+	}
+
+	v := strings.Split(e.Project.PathWithNamespace, "/")
+	if len(v) < 2 {
+		return nil
+	}
+
+	org, repo := strings.Join(v[:len(v)-1], "/"), v[len(v)-1]
+
+	cfg, err := bot.botConfigFor(org, repo, log)
+	if err != nil || cfg == nil || !cfg.PipelineHelp.Enabled {
+		return err
+	}
+
+	if bot.deferIfStandby(func() error { return bot.handlePipelineEvent(e, log) }, log) {
+		return nil
+	}
+
+	pid, number := e.Project.ID, e.MergeRequest.IID
+
+	mr, err := bot.cli.GetMergeRequest(pid, number)
+	if err != nil {
+		return err
+	}
+
+	if !containsString(mr.Labels, newcomerLabel) || mr.Author == nil {
+		return nil
+	}
+
+	notes, err := bot.cli.ListMergeRequestNotes(pid, number)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range notes {
+		if !n.System && strings.Contains(n.Body, ciHelpMarker) {
+			return nil
+		}
+	}
+
+	comment, err := renderTemplate(ciHelpTemplate, map[string]interface{}{
+		"Author":  escapeUsername(mr.Author.Username),
+		"DocsURL": cfg.PipelineHelp.DocsURL,
+	})
+	if err != nil {
+		return err
+	}
+
+	if cfg.DryRun {
+		log.WithField("comment", comment).Info("ci help")
+
+		return nil
+	}
+
+	return bot.clientFor(org).CreateMergeRequestComment(pid, number, comment)
+}
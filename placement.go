@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	placementNote    = "note"
+	placementFirst   = "first"
+	placementSummary = "summary"
+
+	// summarySeparator separates the welcome comment from the summary note
+	// it is appended to.
+	summarySeparator = "\n\n---\n"
+)
+
+func (bot *robot) postComment(comment string, acts actions, botCfg *botConfig, log *logrus.Entry) error {
+	cfg := &botCfg.Placement
+
+	if cfg.Delay > 0 {
+		time.Sleep(time.Duration(cfg.Delay) * time.Second)
+	}
+
+	post := func(c string) error {
+		return bot.placeComment(c, acts, cfg, log)
+	}
+
+	return postCommentOnce(comment, acts, &botCfg.CommentRetry, post, log)
+}
+
+func (bot *robot) placeComment(comment string, acts actions, cfg *placementConfig, log *logrus.Entry) error {
+	if cfg.Mode != placementSummary && cfg.Mode != placementCard {
+		return acts.addComment(comment)
+	}
+
+	editor, ok := acts.(noteEditor)
+	if !ok {
+		log.Warnf("the %s placement is not supported, fall back to a new note", cfg.Mode)
+
+		return acts.addComment(comment)
+	}
+
+	if cfg.Mode == placementCard {
+		return bot.writeCardSection(editor, acts, welcomeCardSection, comment)
+	}
+
+	notes, err := editor.listNotes()
+	if err != nil {
+		return err
+	}
+
+	for _, n := range notes {
+		if !n.System && strings.Contains(n.Body, cfg.SummaryMarker) {
+			return editor.updateNote(n.ID, n.Body+summarySeparator+comment)
+		}
+	}
+
+	return acts.addComment(cfg.SummaryMarker + "\n" + comment)
+}
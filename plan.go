@@ -0,0 +1,432 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ActionPlan is the result of handling an event. It describes everything
+// the robot is going to do, so that it can be previewed, audited or
+// executed separately.
+type ActionPlan struct {
+	SigName string `json:"sig_name"`
+
+	// ExtraSigs are the other sigs owning the files changed by the merge
+	// request of monorepo, which are labeled too.
+	ExtraSigs []string `json:"extra_sigs,omitempty"`
+
+	// Newcomer means the author is a newcomer of the community.
+	Newcomer bool `json:"newcomer,omitempty"`
+
+	// Cooldown means the author was welcomed recently, so only the labels
+	// are added.
+	Cooldown bool `json:"cooldown,omitempty"`
+
+	// Comment is the welcome comment. It is empty if there is no need to
+	// comment.
+	Comment string `json:"comment"`
+
+	// Labels are the labels to add.
+	Labels []string `json:"labels,omitempty"`
+
+	// Assign means the merge request will be assigned to Assignees.
+	Assign    bool  `json:"assign,omitempty"`
+	Assignees []int `json:"assignees,omitempty"`
+
+	// Reviewers are the users whose review of the merge request is
+	// requested.
+	Reviewers []string `json:"reviewers,omitempty"`
+
+	// Reaction is the award emoji to add.
+	Reaction string `json:"reaction,omitempty"`
+
+	// Notifications are the users mentioned by the comment.
+	Notifications []string `json:"notifications,omitempty"`
+
+	// Away are the members out of office by the directory, who are not
+	// mentioned.
+	Away []string `json:"away,omitempty"`
+
+	// Degraded are the steps of planning which failed, so that the plan
+	// falls back to the safe welcome.
+	Degraded []stepResult `json:"degraded,omitempty"`
+}
+
+func (p *ActionPlan) log(log *logrus.Entry) {
+	log.WithFields(logrus.Fields{
+		"sig":           p.SigName,
+		"extra_sigs":    p.ExtraSigs,
+		"newcomer":      p.Newcomer,
+		"cooldown":      p.Cooldown,
+		"labels":        p.Labels,
+		"assign":        p.Assign,
+		"assignees":     p.Assignees,
+		"reviewers":     p.Reviewers,
+		"reaction":      p.Reaction,
+		"notifications": p.Notifications,
+		"away":          p.Away,
+		"degraded":      p.Degraded,
+	}).Info("welcome action plan")
+}
+
+func (bot *robot) genPlan(
+	org, repo, author string, number, pid int, changes []string, meta *itemMeta,
+	cfg *botConfig, log *logrus.Entry,
+) (*ActionPlan, error) {
+	plan, err := bot.genPlanForSig(org, repo, author, "", number, pid, changes, meta, cfg, log)
+	if err != nil || cfg.Reaction.Emoji == "" {
+		return plan, err
+	}
+
+	// the emoji acknowledges the item even in the cooldown.
+	plan.Reaction = cfg.Reaction.Emoji
+	if cfg.Reaction.instead() {
+		plan.Comment = ""
+	}
+
+	return plan, nil
+}
+
+// genPlanForSig generates the plan for the sig, which is resolved from the
+// repo if it is empty.
+func (bot *robot) genPlanForSig(
+	org, repo, author, sigName string, number, pid int, changes []string, meta *itemMeta,
+	cfg *botConfig, log *logrus.Entry,
+) (*ActionPlan, error) {
+	plan := new(ActionPlan)
+
+	askDescription := false
+	if number > 0 && cfg.MRTemplate.Enabled {
+		if v, err := bot.descriptionUnfilled(pid, number, cfg); err != nil {
+			log.WithError(err).Error("check the description of merge request")
+		} else if v {
+			askDescription = true
+			plan.Labels = append(plan.Labels, cfg.MRTemplate.Label)
+		}
+	}
+
+	// it is -1 if the contributions are unknown.
+	contributions := -1
+
+	if checkNewcomer(number, meta, &cfg.Newcomer) {
+		n, err := bot.countContributions(org, author, number > 0, cfg)
+		if err != nil {
+			log.WithError(err).Error("check if the author is a newcomer")
+		} else {
+			contributions = n
+		}
+
+		plan.Newcomer = n == 0 && err == nil
+
+		if bot.labelNewcomer(org, author, plan.Newcomer, &cfg.Newcomer, log) {
+			plan.Labels = append(plan.Labels, newcomerLabel)
+		}
+	}
+
+	// the author is still welcomed by the safe message without the sig
+	// or its maintainers.
+	degrade := func(step string, err error) (*ActionPlan, error) {
+		log.WithError(err).Errorf("step %s failed, degrade to the safe welcome", step)
+
+		plan.Degraded = append(plan.Degraded, stepResult{
+			Step: step, Result: stepResultDegraded, Error: err.Error(),
+		})
+
+		if bot.welcomes.inCooldown(author, cfg) {
+			plan.Cooldown = true
+		} else {
+			plan.Comment = genSafeComment(author, cfg)
+		}
+
+		return plan, nil
+	}
+
+	var maintainers, committers []string
+
+	// it is nil if the sig has no file of its own.
+	var sf *sigFile
+
+	if cfg.OwnershipOnly {
+		// the sig is skipped, and the owners of changed files are all.
+		owners, labels, err := bot.getOwnershipOwners(pid, number, changes, cfg, log)
+		if err != nil {
+			return degrade(stepGetMaintainers, err)
+		}
+
+		maintainers = owners
+		plan.Labels = append(plan.Labels, labels...)
+		plan.Labels = append(plan.Labels, cfg.Labels...)
+	} else {
+		// the sig directed by the description overrides the inferred one.
+		if sigName == "" && cfg.SigDirective.Enabled && meta != nil {
+			sigName = bot.directedSig(pid, meta.Description, log)
+		}
+
+		if sigName == "" && number > 0 && len(cfg.SigPaths) > 0 {
+			if changes == nil {
+				v, err := bot.cli.GetMergeRequestChanges(pid, number)
+				if err != nil {
+					return degrade(stepResolveSig, err)
+				}
+
+				changes = v
+			}
+
+			if sigs := inferSigs(changes, cfg.SigPaths); len(sigs) > 0 {
+				sigName, plan.ExtraSigs = sigs[0], sigs[1:]
+			}
+		}
+
+		if sigName == "" {
+			v, err := bot.getSigOfRepo(org, repo, pid, cfg)
+			if err == nil && v == "" {
+				err = fmt.Errorf("cant get sig name of repo: %s/%s", org, repo)
+			}
+			if err != nil {
+				return degrade(stepResolveSig, err)
+			}
+
+			sigName = v
+		}
+
+		plan.SigName = sigName
+		// the sig labels of legacy prefix applied before are kept.
+		var labeled []string
+		if meta != nil {
+			labeled = meta.Labels
+		}
+
+		plan.Labels = append(plan.Labels, bot.existingSigLabel(sigName, labeled, cfg))
+		for _, s := range plan.ExtraSigs {
+			plan.Labels = append(plan.Labels, bot.existingSigLabel(s, labeled, cfg))
+		}
+		plan.Labels = append(plan.Labels, cfg.Labels...)
+
+		v, w, err := bot.getMaintainers(org, repo, sigName, number, pid, changes, cfg, log)
+		if err != nil {
+			return degrade(stepGetMaintainers, err)
+		}
+
+		maintainers, committers = v, w
+
+		// the sig tunes the welcomes of its repos by its own file.
+		if sf = bot.sigFileFor(org, repo, sigName, pid, cfg, log); sf != nil {
+			maintainers, committers, cfg = sf.apply(maintainers, committers, cfg)
+		}
+	}
+
+	// the members are mentioned and assigned by their usernames in the
+	// directory, and the ones out of office are left out.
+	maintainers, committers, plan.Away = bot.applyDirectory(maintainers, committers, cfg, log)
+
+	if number > 0 {
+		bot.planAssignment(plan, author, maintainers, committers, cfg, log)
+	}
+
+	if bot.welcomes.inCooldown(author, cfg) {
+		// the prolific contributors are welcomed only once in the cooldown.
+		plan.Cooldown = true
+
+		return plan, nil
+	}
+
+	sole := cfg.SoleMaintainer.Action != "" && isSoleMaintainer(author, maintainers, committers)
+	if sole {
+		log.Infof("the author is the sole maintainer, %s", cfg.SoleMaintainer.Action)
+	}
+
+	// there is no sig to name when welcoming by the ownership only.
+	minimal := func() string {
+		if sigName == "" {
+			return genSafeComment(author, cfg)
+		}
+
+		return genMinimalComment(author, sigName)
+	}
+
+	if cfg.MemberAuthor != "" && isSigMember(author, maintainers, committers) {
+		// the maintainers need not be welcomed to their own sig.
+		if cfg.MemberAuthor == memberAuthorMinimal {
+			plan.Comment = minimal()
+		}
+	} else if sole && cfg.SoleMaintainer.Action == soleMaintainerSkipMentions {
+		plan.Comment = minimal()
+	} else {
+		if sole {
+			maintainers, committers, cfg = cfg.SoleMaintainer.apply(maintainers, committers, cfg)
+		}
+
+		if f := &cfg.Fairness; f.MaxMentions > 0 {
+			// the members mentioned the least recently are preferred.
+			maintainers = bot.mentions.pick(maintainers, f.MaxMentions, f.Days)
+			committers = bot.mentions.pick(committers, f.MaxMentions, f.Days)
+		}
+
+		var roles []roleMembers
+		if cfg.Roles.enabled() && sigName != "" {
+			if info, err := bot.getSigInfo(pid, sigName); err != nil {
+				log.WithError(err).Errorf("get sig info of %s", sigName)
+			} else {
+				roles = genRoles(info, &cfg.Roles, maintainers, committers)
+			}
+		}
+
+		plan.Comment = bot.genSections(&sectionContext{
+			author:         author,
+			sigName:        sigName,
+			pid:            pid,
+			maintainers:    maintainers,
+			committers:     committers,
+			roles:          roles,
+			meta:           meta,
+			cfg:            cfg,
+			log:            log,
+			contributions:  contributions,
+			askDescription: askDescription,
+			sigFile:        sf,
+		})
+
+		if group := bot.sigGroup(sigName, &cfg.Mention); group != "" {
+			plan.Notifications = []string{group}
+		} else {
+			plan.Notifications = append(maintainers, committers...)
+		}
+		plan.Notifications = append(plan.Notifications, mentionedMembers(roles)...)
+	}
+
+	if cfg.NeedAssign && number != 0 {
+		plan.Assign = true
+		plan.Assignees = []int{}
+	}
+
+	return plan, nil
+}
+
+// execute runs the plan as independent steps. The comment is the only
+// fatal step, the failures of the others are reported but don't fail the
+// event.
+func (bot *robot) execute(plan *ActionPlan, acts actions, projectID int, cfg *botConfig, log *logrus.Entry) *stepReport {
+	report := newStepReport()
+
+	run := func(step, target string, fatal bool, f func() error) error {
+		err := report.run(step, target, fatal, &cfg.StepRetry, f, log)
+		if err != nil {
+			bot.errorBudget.record(projectID)
+		}
+
+		return err
+	}
+
+	comment := func() {
+		if plan.Comment == "" {
+			return
+		}
+
+		// postComment retries by itself, so it is run once here.
+		run(stepComment, "", true, func() error {
+			return bot.postComment(plan.Comment, acts, cfg, log)
+		})
+	}
+
+	// comment before any other action, so that the comment precedes the
+	// system notes generated by them.
+	if cfg.Placement.Mode == placementFirst {
+		comment()
+	}
+
+	// only the welcome comment is attempted when the project has used up
+	// its error budget.
+	if bot.errorBudget.exhausted(projectID, &cfg.ErrorBudget) {
+		log.Warn("the error budget of project is exhausted, skip the non-essential actions")
+
+		if cfg.Placement.Mode != placementFirst {
+			comment()
+		}
+
+		if plan.Assign {
+			report.skip(stepAssign, "", errBudgetExhausted)
+		}
+
+		if len(plan.Reviewers) > 0 {
+			report.skip(stepSetReviewers, "", errBudgetExhausted)
+		}
+
+		if plan.Reaction != "" {
+			report.skip(stepReact, plan.Reaction, errBudgetExhausted)
+		}
+
+		for _, label := range plan.Labels {
+			report.skip(stepAddLabel, label, errBudgetExhausted)
+		}
+
+		return report
+	}
+
+	var steps []bestEffortStep
+
+	if plan.Assign {
+		steps = append(steps, bestEffortStep{step: stepAssign, do: func() error {
+			return acts.assign(plan.Assignees)
+		}})
+	}
+
+	if len(plan.Reviewers) > 0 {
+		steps = append(steps, bestEffortStep{step: stepSetReviewers, do: func() error {
+			return bot.setReviewers(plan, acts, log)
+		}})
+	}
+
+	if r, ok := acts.(reactor); ok && plan.Reaction != "" {
+		steps = append(steps, bestEffortStep{step: stepReact, target: plan.Reaction, do: func() error {
+			return r.react(plan.Reaction)
+		}})
+	}
+
+	for _, label := range plan.Labels {
+		label := label
+
+		if bot.labelCreationDenied(projectID) {
+			report.skip(stepCreateLabel, label, errLabelCreationDenied)
+		} else {
+			steps = append(steps, bestEffortStep{step: stepCreateLabel, target: label, do: func() error {
+				return bot.createLabelIfNeed(acts, projectID, label, log)
+			}})
+		}
+
+		// the label may exist though it can't be created, so add it anyway.
+		steps = append(steps, bestEffortStep{step: stepAddLabel, target: label, do: func() error {
+			return acts.addLabel(label)
+		}})
+	}
+
+	// the best-effort steps are queued if possible, so that the comment
+	// doesn't wait for them.
+	queued := bot.bestEffort != nil && len(steps) > 0 && bot.bestEffort.enqueue(&bestEffortTask{
+		projectID: projectID,
+		retry:     cfg.StepRetry,
+		steps:     steps,
+		log:       log,
+	})
+
+	if queued {
+		for _, s := range steps {
+			report.add(s.step, s.target, stepResultQueued, nil)
+		}
+	} else if len(steps) > 0 && steps[0].step == stepAssign {
+		run(stepAssign, "", false, steps[0].do)
+		steps = steps[1:]
+	}
+
+	if cfg.Placement.Mode != placementFirst {
+		comment()
+	}
+
+	if !queued {
+		for _, s := range steps {
+			run(s.step, s.target, false, s.do)
+		}
+	}
+
+	return report
+}
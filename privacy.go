@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+)
+
+// privacyLogFields are the fields of logs which hold the usernames or the
+// text mentioning them.
+var privacyLogFields = map[string]bool{
+	"author":         true,
+	"blocked_author": true,
+	"triager":        true,
+	"notifications":  true,
+	"reviewers":      true,
+	"away":           true,
+	"mentor":         true,
+	"handoff":        true,
+	"user":           true,
+}
+
+// privacyTextFields are the fields of logs which hold the texts of comments,
+// which are dropped as a whole.
+var privacyTextFields = []string{"rendered", "comment"}
+
+var mentionInLogRe = regexp.MustCompile(`@[A-Za-z0-9_][A-Za-z0-9_.-]*`)
+
+// redactUsername returns the pseudonym of the user, which is stable, so
+// that the logs of a user can still be correlated.
+func redactUsername(name string) string {
+	h := sha256.Sum256([]byte(name))
+
+	return "user-" + hex.EncodeToString(h[:])[:8]
+}
+
+// privacyHook redacts the usernames in the logs in the privacy mode. The
+// fields holding the usernames are replaced with their pseudonyms, and so
+// are the mentions in the messages.
+type privacyHook struct{}
+
+func (h privacyHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire is called with a copy of the data of entry, so it can be changed.
+func (h privacyHook) Fire(e *logrus.Entry) error {
+	for k, v := range e.Data {
+		if !privacyLogFields[k] {
+			continue
+		}
+
+		switch x := v.(type) {
+		case string:
+			e.Data[k] = redactUsername(x)
+		case []string:
+			r := make([]string, len(x))
+			for i := range x {
+				r[i] = redactUsername(x[i])
+			}
+			e.Data[k] = r
+		default:
+			e.Data[k] = "[redacted]"
+		}
+	}
+
+	for _, k := range privacyTextFields {
+		if _, ok := e.Data[k]; ok {
+			e.Data[k] = "[redacted]"
+		}
+	}
+
+	// the errors may mention the users too.
+	if err, ok := e.Data[logrus.ErrorKey].(error); ok && err != nil {
+		e.Data[logrus.ErrorKey] = mentionInLogRe.ReplaceAllStringFunc(err.Error(), redactMention)
+	}
+
+	e.Message = mentionInLogRe.ReplaceAllStringFunc(e.Message, redactMention)
+
+	return nil
+}
+
+func redactMention(s string) string {
+	return "@" + redactUsername(s[1:])
+}
+
+// contributionSource returns the source of the newcomer check. The external
+// one is replaced with GitLab in the privacy mode, so that the usernames
+// are not sent out.
+func (bot *robot) contributionSource(cfg *newcomerConfig) string {
+	if bot.privacy && cfg.Source == newcomerSourceIPB {
+		return newcomerSourceGitlab
+	}
+
+	return cfg.Source
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TestPrivacyModeSendsNoUsername runs the moderation, digest and alert
+// against a server recording every request, and checks that the username
+// is in none of them in the privacy mode.
+func TestPrivacyModeSendsNoUsername(t *testing.T) {
+	const author = "newbie"
+
+	var (
+		lock     sync.Mutex
+		requests []string
+	)
+
+	alerted := make(chan struct{}, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		lock.Lock()
+		requests = append(requests, r.URL.String()+"\n"+string(body))
+		lock.Unlock()
+
+		if r.URL.Path == "/alert" {
+			alerted <- struct{}{}
+		}
+
+		_, _ = w.Write([]byte(`{"blocked": false}`))
+	}))
+	defer srv.Close()
+
+	bot := newRobot(nil, nil)
+	bot.privacy = true
+	bot.hc = srv.Client()
+
+	cfg := &botConfig{CommunityName: "openEuler"}
+	cfg.Moderation.ServiceURL = srv.URL + "/moderation"
+	cfg.Digest.Enabled = true
+	cfg.Digest.URL = srv.URL + "/digest"
+	cfg.Alert.URL = srv.URL + "/alert"
+	cfg.Alert.setDefault()
+	cfg.Alert.MaxFailures = 1
+
+	if blocked, err := bot.isBlocked(author, cfg); blocked || err != nil {
+		t.Fatalf("isBlocked = %v, %v", blocked, err)
+	}
+
+	acts := &mrActions{projectID: 1, number: 2}
+	bot.recordDigest("openeuler", "community", author, acts, &ActionPlan{Newcomer: true}, cfg)
+
+	if err := bot.sendDigest(bot.digests.state.Entries[cfg.CommunityName], cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	log := logrus.NewEntry(logrus.New())
+	err := errors.New("assign the merge request to @" + author + ": " + author + " is not a member")
+	for i := 0; i <= cfg.Alert.MaxFailures; i++ {
+		bot.alertFailure("openeuler", "community", err, cfg, log)
+	}
+
+	select {
+	case <-alerted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the alert is not sent")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	for _, r := range requests {
+		if strings.Contains(r, author) {
+			t.Errorf("the username is sent out by the request:\n%s", r)
+		}
+	}
+}
+
+func TestPrivacyHookRedactsUsernames(t *testing.T) {
+	const user = "newbie"
+
+	l := logrus.New()
+	l.Out = ioutil.Discard
+
+	var got *logrus.Entry
+	l.AddHook(privacyHook{})
+	l.AddHook(recordHook(func(e *logrus.Entry) { got = e }))
+
+	(&ActionPlan{
+		Reviewers:     []string{user},
+		Notifications: []string{user},
+		Away:          []string{user},
+	}).log(logrus.NewEntry(l).WithFields(logrus.Fields{
+		"mentor":  user,
+		"user":    user,
+		"comment": "hi @" + user,
+	}).WithError(errors.New("@" + user + " is not a member")))
+
+	for k, v := range got.Data {
+		if strings.Contains(fmt.Sprint(v), user) {
+			t.Errorf("the username is logged by the field %s: %v", k, v)
+		}
+	}
+}
+
+// recordHook is called after the hooks added before it.
+type recordHook func(*logrus.Entry)
+
+func (h recordHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (h recordHook) Fire(e *logrus.Entry) error {
+	h(e)
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (bot *robot) getSigInfo(pid int, sig string) (*SigInfos, error) {
+	f, err := bot.getPathContent(pid, sigInfoFile(sig), sigFileBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := bot.fileContent(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSigInfoFile(c)
+}
+
+// genQuickLinks renders the links of sig as a markdown list. It returns
+// empty string if there is no link at all.
+func genQuickLinks(info *SigInfos) string {
+	items := []struct {
+		name string
+		link string
+	}{
+		{"Mailing list", info.MailingList},
+		{"Chat", info.ChatURL},
+		{"Meeting", info.MeetingURL},
+		{"Calendar", info.CalendarURL},
+		{"Docs", info.DocsURL},
+	}
+
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.link == "" {
+			continue
+		}
+
+		link := item.link
+		if strings.Contains(link, "@") && !strings.Contains(link, "://") {
+			link = "mailto:" + link
+		}
+
+		// the links of sig-info are dropped if they aren't safe.
+		if link = sanitizeURL(link); link == "" {
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf("- %s: [%s](%s)", item.name, sanitizeText(item.link), link))
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return "\n\n**Quick links**\n" + strings.Join(lines, "\n")
+}
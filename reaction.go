@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	reactionModeWithComment = "with_comment"
+	reactionModeInstead     = "instead"
+)
+
+// emojiNameRe matches the names of the award emoji of GitLab, such as wave
+// and thumbsup.
+var emojiNameRe = regexp.MustCompile(`^[a-z0-9_+-]+$`)
+
+type reactionConfig struct {
+	// Emoji is the name of the award emoji added to the new merge requests
+	// and issues as a lightweight acknowledgment, such as wave. It is
+	// disabled if not set.
+	Emoji string `json:"emoji,omitempty"`
+
+	// Mode is with_comment, which adds the emoji besides the welcome
+	// comment, or instead, which adds the emoji only. The default value
+	// is with_comment.
+	Mode string `json:"mode,omitempty"`
+}
+
+func (c *reactionConfig) setDefault() {
+	if c.Mode == "" {
+		c.Mode = reactionModeWithComment
+	}
+}
+
+func (c *reactionConfig) validate() error {
+	if c.Emoji != "" && !emojiNameRe.MatchString(c.Emoji) {
+		return fmt.Errorf("invalid emoji of reaction: %s", c.Emoji)
+	}
+
+	switch c.Mode {
+	case reactionModeWithComment, reactionModeInstead:
+	default:
+		return fmt.Errorf("unsupported mode of reaction: %s", c.Mode)
+	}
+
+	return nil
+}
+
+// instead reports whether the emoji is added in place of the comment.
+func (c *reactionConfig) instead() bool {
+	return c.Emoji != "" && c.Mode == reactionModeInstead
+}
+
+// reactor is implemented by the actions which can add the award emoji.
+type reactor interface {
+	react(emoji string) error
+}
+
+func (a *mrActions) react(emoji string) error {
+	return a.cli.CreateMergeRequestAwardEmoji(a.projectID, a.number, emoji)
+}
+
+func (a *issueActions) react(emoji string) error {
+	return a.cli.CreateIssueAwardEmoji(a.projectID, a.number, emoji)
+}
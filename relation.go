@@ -0,0 +1,171 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+// ownersAliases is the repo-local file in the format of OWNERS_ALIASES,
+// which names groups of members.
+type ownersAliases struct {
+	Aliases map[string][]string `json:"aliases,omitempty"`
+}
+
+// expand replaces the aliases among the owners with their members.
+func (a *ownersAliases) expand(owners sets.String) sets.String {
+	if a == nil || len(a.Aliases) == 0 {
+		return owners
+	}
+
+	r := sets.NewString()
+	for o := range owners {
+		if v, ok := a.Aliases[o]; ok {
+			r.Insert(v...)
+		} else {
+			r.Insert(o)
+		}
+	}
+
+	return r
+}
+
+// communityAliases returns the aliases of the community, which are shared
+// by the OWNERS, sig-info and relation files. It is nil if there are none.
+func (bot *robot) communityAliases(pid int, cfg *botConfig) (*ownersAliases, error) {
+	if cfg.OwnersAliasesFile == "" {
+		return nil, nil
+	}
+
+	a := new(ownersAliases)
+	if err := bot.decodeYAMLFile(pid, cfg.OwnersAliasesFile, sigFileBranch, a); err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// decodeYAMLFile reads the yaml file of the project into v.
+func (bot *robot) decodeYAMLFile(pid int, file, branch string, v interface{}) error {
+	content, err := bot.getPathContent(pid, file, branch)
+	if err != nil {
+		return err
+	}
+
+	c, err := bot.fileContent(content)
+	if err != nil {
+		return err
+	}
+
+	return yaml.Unmarshal(c, v)
+}
+
+// relationSourceOf returns the relation source of the changed file. The
+// one with the longest matching prefix takes precedence, and the global
+// FilePath is used if none matches.
+func relationSourceOf(file string, cfg *botConfig) relationSource {
+	r := relationSource{FilePath: cfg.FilePath, Branch: cfg.FileBranch}
+	n := -1
+
+	for _, s := range cfg.RelationSources {
+		if strings.HasPrefix(file, s.Prefix) && len(s.Prefix) > n {
+			r, n = s, len(s.Prefix)
+		}
+	}
+
+	return r
+}
+
+// matchRelation returns the entries of the relation matching the changed
+// file.
+func matchRelation(file string, r *Relation) []*FileOwner {
+	var mo []*FileOwner
+	for i := range r.Relations {
+		f := &r.Relations[i]
+		for _, ff := range f.Path {
+			if strings.Contains(file, ff) {
+				mo = append(mo, f)
+			}
+			if strings.Contains(ff, "/*/") {
+				reg := regexp.MustCompile(strings.Replace(ff, "/*/", "/[^\\s]+/", -1))
+				if ok := reg.MatchString(file); ok {
+					mo = append(mo, f)
+				}
+			}
+		}
+	}
+
+	return mo
+}
+
+// findRelationOwners returns the owners of the changed files looked up in
+// the relation sources, with the aliases expanded.
+func (bot *robot) findRelationOwners(pid int, changes []string, cfg *botConfig, log *logrus.Entry) (sets.String, error) {
+	owners, _, err := bot.findRelationMatches(pid, changes, cfg, log)
+
+	return owners, err
+}
+
+// findRelationMatches returns the owners and labels of the entries matching
+// the changed files in the relation sources.
+func (bot *robot) findRelationMatches(pid int, changes []string, cfg *botConfig, log *logrus.Entry) (sets.String, sets.String, error) {
+	relations := make(map[relationSource]*Relation)
+
+	owners := sets.NewString()
+	labels := sets.NewString()
+	for _, c := range changes {
+		src := relationSourceOf(c, cfg)
+		if src.FilePath == "" {
+			continue
+		}
+
+		r, ok := relations[src]
+		if !ok {
+			r = new(Relation)
+			if err := bot.decodeYAMLFile(pid, src.FilePath, src.Branch, r); err != nil {
+				log.Errorf("load the relation file %s/%s failed, err: %v", src.Branch, src.FilePath, err)
+
+				return nil, nil, err
+			}
+
+			relations[src] = r
+		}
+
+		for _, f := range matchRelation(c, r) {
+			for _, m := range f.Owner {
+				owners.Insert(m.GiteeID)
+			}
+
+			labels.Insert(f.Labels...)
+		}
+	}
+
+	if owners.Len() == 0 {
+		return owners, labels, nil
+	}
+
+	if cfg.AliasesFile != "" {
+		aliases := new(ownersAliases)
+		if err := bot.decodeYAMLFile(pid, cfg.AliasesFile, cfg.FileBranch, aliases); err != nil {
+			if !isNotFound(err) {
+				return nil, nil, err
+			}
+		} else {
+			owners = aliases.expand(owners)
+		}
+	}
+
+	aliases, err := bot.communityAliases(pid, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return aliases.expand(owners), labels, nil
+}
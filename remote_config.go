@@ -0,0 +1,240 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// remoteConfigSignatureSuffix is appended to the config url to get the
+	// base64 encoded ed25519 signature of the config.
+	remoteConfigSignatureSuffix = ".sig"
+
+	maxRemoteConfigSize = 8 << 20
+)
+
+var remoteConfigFetchesTotal = newCounterVec(
+	"welcome_remote_config_fetches_total",
+	"Number of fetches of the remote config by result.",
+	"result",
+)
+
+// remoteConfigCache is the last known good config persisted, so that the
+// robot can start even if the remote config is not available.
+type remoteConfigCache struct {
+	ETag string `json:"etag"`
+	Data []byte `json:"data"`
+}
+
+// remoteConfig is the configuration fetched from a url which is shared by
+// many deployments. It is refreshed periodically with ETag, and the last
+// known good one is used when the fetching, verifying or parsing fails.
+type remoteConfig struct {
+	url       string
+	cacheFile string
+	publicKey ed25519.PublicKey
+	hc        *http.Client
+
+	lock sync.RWMutex
+	etag string
+	cfg  *configuration
+}
+
+func (r *remoteConfig) get() (*configuration, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if r.cfg == nil {
+		return nil, errors.New("the remote config is not loaded")
+	}
+
+	return r.cfg, nil
+}
+
+// start loads the config persisted, then fetches the remote one. It fails
+// only if neither is available.
+func (r *remoteConfig) start() error {
+	if r.cacheFile != "" {
+		var c remoteConfigCache
+		if err := loadStateFile(r.cacheFile, &c); err != nil {
+			logrus.WithError(err).Error("load the cached remote config")
+		} else if len(c.Data) > 0 {
+			if err := r.apply(c.Data, c.ETag); err != nil {
+				logrus.WithError(err).Error("apply the cached remote config")
+			}
+		}
+	}
+
+	err := r.refresh()
+	if err == nil {
+		return nil
+	}
+
+	if _, gerr := r.get(); gerr != nil {
+		return err
+	}
+
+	logrus.WithError(err).Warn("fetch the remote config, use the cached one")
+
+	return nil
+}
+
+func (r *remoteConfig) run(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for range t.C {
+		if err := r.refresh(); err != nil {
+			logrus.WithError(err).Error("refresh the remote config, keep the last known good one")
+		}
+	}
+}
+
+func (r *remoteConfig) refresh() error {
+	r.lock.RLock()
+	etag := r.etag
+	r.lock.RUnlock()
+
+	data, newETag, err := r.fetch(r.url, etag)
+	if err != nil {
+		remoteConfigFetchesTotal.inc("error")
+
+		return err
+	}
+
+	if data == nil {
+		remoteConfigFetchesTotal.inc("not_modified")
+
+		return nil
+	}
+
+	if r.publicKey != nil {
+		if err := r.verify(data); err != nil {
+			remoteConfigFetchesTotal.inc("invalid")
+
+			return err
+		}
+	}
+
+	if err := r.apply(data, newETag); err != nil {
+		remoteConfigFetchesTotal.inc("invalid")
+
+		return err
+	}
+
+	remoteConfigFetchesTotal.inc("updated")
+
+	if r.cacheFile != "" {
+		if err := saveStateFile(r.cacheFile, &remoteConfigCache{ETag: newETag, Data: data}); err != nil {
+			logrus.WithError(err).Error("save the remote config")
+		}
+	}
+
+	return nil
+}
+
+// fetch gets the content of url. The content is nil if it is not
+// modified since etag.
+func (r *remoteConfig) fetch(url, etag string) ([]byte, string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := r.hc.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, etag, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetch %s, status code: %d", url, resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(http.MaxBytesReader(nil, resp.Body, maxRemoteConfigSize))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, resp.Header.Get("ETag"), nil
+}
+
+func (r *remoteConfig) verify(data []byte) error {
+	v, _, err := r.fetch(r.url+remoteConfigSignatureSuffix, "")
+	if err != nil {
+		return fmt.Errorf("fetch the signature of remote config: %v", err)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(v)))
+	if err != nil {
+		return fmt.Errorf("decode the signature of remote config: %v", err)
+	}
+
+	if !ed25519.Verify(r.publicKey, data, sig) {
+		return errors.New("the signature of remote config is invalid")
+	}
+
+	return nil
+}
+
+func (r *remoteConfig) apply(data []byte, etag string) error {
+	cfg := new(configuration)
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("parse the remote config: %v", err)
+	}
+
+	cfg.SetDefault()
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid remote config: %v", err)
+	}
+
+	r.lock.Lock()
+	r.cfg = cfg
+	r.etag = etag
+	r.lock.Unlock()
+
+	return nil
+}
+
+// parseEd25519PublicKey parses the PEM encoded PKIX public key, such as the
+// one generated by `openssl pkey -pubout`.
+func parseEd25519PublicKey(b []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM data of public key")
+	}
+
+	k, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := k.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("the public key is not ed25519")
+	}
+
+	return v, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+)
+
+const safeWelcomeMessage = `
+Hi ***%s***, welcome to the %s Community. You can find the instructions on how to interact with me at **[Here](%s)**.`
+
+var renderProblemsTotal = newCounterVec(
+	"welcome_template_render_problems_total",
+	"Number of welcome comments which fall back to the safe message because of the rendering problems.",
+	"problem",
+)
+
+// renderProblems are the patterns of the odd substitutions in the rendered
+// comment, such as a mention of empty username.
+var renderProblems = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"empty_mention", regexp.MustCompile(`(^|[\s,(])@($|[\s,.;:)])`)},
+	{"empty_link_text", regexp.MustCompile(`\[\s*\]\(`)},
+	{"empty_link_url", regexp.MustCompile(`\]\(\s*\)`)},
+	{"empty_sig_link", regexp.MustCompile(`/sig/\)`)},
+	{"empty_list", regexp.MustCompile(`:\s*(,|$)`)},
+	{"no_value", regexp.MustCompile(`<no value>`)},
+	{"empty_emphasis", regexp.MustCompile(`\*\*\*\*\*\*`)},
+}
+
+func findRenderProblem(comment string) string {
+	for _, p := range renderProblems {
+		if p.pattern.MatchString(comment) {
+			return p.name
+		}
+	}
+
+	return ""
+}
+
+func genSafeComment(author string, cfg *botConfig) string {
+	return fmt.Sprintf(safeWelcomeMessage, escapeUsername(author), cfg.CommunityName, cfg.commandLink())
+}
+
+// checkRendered returns the rendered comment if it has no problem, or the
+// safe message otherwise. Each problem is counted and logged.
+func checkRendered(comment string, renderErr error, author string, cfg *botConfig, log *logrus.Entry) string {
+	problem := "render_error"
+	if renderErr == nil {
+		if problem = findRenderProblem(comment); problem == "" {
+			return comment
+		}
+	}
+
+	renderProblemsTotal.inc(problem)
+
+	log.WithFields(logrus.Fields{
+		"problem":  problem,
+		"author":   author,
+		"rendered": comment,
+	}).WithError(renderErr).Warn("the welcome comment is not rendered as expected, fall back to the safe message")
+
+	return genSafeComment(author, cfg)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+	"sigs.k8s.io/yaml"
+)
+
+const defaultRepoFilePath = ".welcome-bot.yaml"
+
+// repoFile is the file in the repo itself which disables or tunes the
+// robot without touching the central config. The fields set in it take
+// precedence over the ones of the central config, and the others are
+// left as they are.
+type repoFile struct {
+	// Disabled stops welcoming in the repo.
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Triggers decide whether to welcome the merge requests and issues.
+	Triggers struct {
+		MergeRequests *bool `json:"merge_requests,omitempty"`
+		Issues        *bool `json:"issues,omitempty"`
+	} `json:"triggers,omitempty"`
+
+	// WelcomeTemplate replaces the welcome_template of central config.
+	WelcomeTemplate string `json:"welcome_template,omitempty"`
+
+	// Labels replace the labels of central config.
+	Labels []string `json:"labels,omitempty"`
+}
+
+func (f *repoFile) validate() error {
+	if f.WelcomeTemplate != "" {
+		if _, err := parseTemplate(f.WelcomeTemplate); err != nil {
+			return fmt.Errorf("invalid welcome_template: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// skip reports whether the event is turned off by the file.
+func (f *repoFile) skip(isIssue bool) bool {
+	if f.Disabled {
+		return true
+	}
+
+	v := f.Triggers.MergeRequests
+	if isIssue {
+		v = f.Triggers.Issues
+	}
+
+	return v != nil && !*v
+}
+
+// merge returns a copy of cfg tuned by the file.
+func (f *repoFile) merge(cfg *botConfig) *botConfig {
+	c := *cfg
+
+	// the template of repo takes precedence over the ones of event types.
+	if f.WelcomeTemplate != "" {
+		c.WelcomeTemplate = f.WelcomeTemplate
+		c.Templates = nil
+	}
+
+	if f.Labels != nil {
+		c.Labels = f.Labels
+	}
+
+	return &c
+}
+
+func isNotFound(err error) bool {
+	var e *gitlab.ErrorResponse
+
+	return errors.As(err, &e) && e.Response != nil && e.Response.StatusCode == http.StatusNotFound
+}
+
+// getRepoFile returns the file of the repo, which is nil if it does not
+// exist. Both are cached until the file is pushed.
+func (bot *robot) getRepoFile(pid int, cfg *repoFileConfig) (*repoFile, error) {
+	key := fileCacheKey(pid, cfg.Path, cfg.Branch)
+	if v, ok := bot.repoFiles.get(key); ok {
+		return v.(*repoFile), nil
+	}
+
+	var r *repoFile
+
+	f, err := bot.cli.GetPathContent(pid, cfg.Path, cfg.Branch)
+	if err != nil {
+		if !isNotFound(err) {
+			return nil, err
+		}
+	} else {
+		b, err := bot.fileContent(f)
+		if err != nil {
+			return nil, err
+		}
+
+		r = new(repoFile)
+		if err := yaml.Unmarshal(b, r); err != nil {
+			return nil, err
+		}
+
+		if err := r.validate(); err != nil {
+			return nil, newConfigError(err)
+		}
+	}
+
+	bot.repoFiles.set(key, r, fileCacheTTL)
+
+	return r, nil
+}
+
+// applyRepoFile returns the config tuned by the file of the repo, and
+// whether the event is turned off by it. The central config is used as it
+// is if the file is invalid.
+func (bot *robot) applyRepoFile(pid int, isIssue bool, cfg *botConfig, log *logrus.Entry) (*botConfig, bool) {
+	if !cfg.RepoFile.Enabled {
+		return cfg, false
+	}
+
+	f, err := bot.getRepoFile(pid, &cfg.RepoFile)
+	if err != nil {
+		log.WithError(err).Errorf("load the repo file %s, ignore it", cfg.RepoFile.Path)
+
+		return cfg, false
+	}
+
+	if f == nil {
+		return cfg, false
+	}
+
+	if f.skip(isIssue) {
+		log.Infof("the welcome is turned off by the repo file %s", cfg.RepoFile.Path)
+
+		return cfg, true
+	}
+
+	return f.merge(cfg), false
+}
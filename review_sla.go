@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxResponseSamples is the number of the latest first responses kept
+	// for each sig.
+	maxResponseSamples = 100
+
+	defaultReviewSLATemplate = "The maintainers of sig ***{{ .Sig }}*** usually respond within ***{{ .Duration }}***."
+)
+
+type reviewSLAConfig struct {
+	// Enabled means to tell the contributor how soon the maintainers of
+	// sig usually respond to the merge request, by the median of their
+	// first responses in the past, so that the contributor knows what to
+	// expect.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// MinSamples is the number of the first responses of sig needed
+	// before the estimate is shown. The default value is 5.
+	MinSamples int `json:"min_samples,omitempty"`
+
+	// Days is the days to wait for the first response, after which the
+	// merge request is not counted. The default value is 30.
+	Days int `json:"days,omitempty"`
+
+	// Template is the go template of the estimate, which can use .Sig and
+	// .Duration, such as "~2 days".
+	Template string `json:"template,omitempty"`
+}
+
+func (c *reviewSLAConfig) setDefault() {
+	if c.MinSamples <= 0 {
+		c.MinSamples = 5
+	}
+
+	if c.Days <= 0 {
+		c.Days = 30
+	}
+
+	if c.Template == "" {
+		c.Template = defaultReviewSLATemplate
+	}
+}
+
+func (c *reviewSLAConfig) validate() error {
+	if _, err := parseTemplate(c.Template); err != nil {
+		return fmt.Errorf("invalid template of review_sla: %v", err)
+	}
+
+	return nil
+}
+
+// awaitedResponse is a merge request welcomed whose first response of the
+// maintainers is awaited. The author is not kept, but read from the merge
+// request, so that it is kept in the privacy mode too.
+type awaitedResponse struct {
+	Sig       string    `json:"sig"`
+	Org       string    `json:"org"`
+	Repo      string    `json:"repo"`
+	ProjectID int       `json:"project_id"`
+	Number    int       `json:"number"`
+	OpenedAt  time.Time `json:"opened_at"`
+}
+
+func (v *awaitedResponse) is(o *awaitedResponse) bool {
+	return v.ProjectID == o.ProjectID && v.Number == o.Number
+}
+
+type responseState struct {
+	Pending []awaitedResponse `json:"pending"`
+
+	// Samples are the latest first responses of each sig in seconds.
+	Samples map[string][]float64 `json:"samples"`
+}
+
+// responseTracker tracks how soon the maintainers of sigs respond to the
+// merge requests welcomed, which is saved to the file if the path is set.
+type responseTracker struct {
+	lock  sync.Mutex
+	path  string
+	state responseState
+}
+
+func newResponseTracker(path string) *responseTracker {
+	t := &responseTracker{path: path}
+
+	if path != "" {
+		if err := loadStateFile(path, &t.state); err != nil {
+			logrus.WithError(err).Errorf("load the responses: %s", path)
+
+			t.state = responseState{}
+		}
+	}
+
+	if t.state.Samples == nil {
+		t.state.Samples = make(map[string][]float64)
+	}
+
+	return t
+}
+
+// save must be called with the lock held.
+func (t *responseTracker) save() {
+	if t.path == "" {
+		return
+	}
+
+	if err := saveStateFile(t.path, &t.state); err != nil {
+		logrus.WithError(err).Errorf("save the responses: %s", t.path)
+	}
+}
+
+func (t *responseTracker) record(v awaitedResponse) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for i := range t.state.Pending {
+		if t.state.Pending[i].is(&v) {
+			return
+		}
+	}
+
+	t.state.Pending = append(t.state.Pending, v)
+
+	t.save()
+}
+
+func (t *responseTracker) pending() []awaitedResponse {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	r := make([]awaitedResponse, len(t.state.Pending))
+	copy(r, t.state.Pending)
+
+	return r
+}
+
+// done removes the merge request, and counts the first response if it is
+// positive.
+func (t *responseTracker) done(v awaitedResponse, response time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for i := range t.state.Pending {
+		if t.state.Pending[i].is(&v) {
+			t.state.Pending = append(t.state.Pending[:i], t.state.Pending[i+1:]...)
+
+			break
+		}
+	}
+
+	if response > 0 {
+		s := append(t.state.Samples[v.Sig], response.Seconds())
+		if n := len(s) - maxResponseSamples; n > 0 {
+			s = s[n:]
+		}
+
+		t.state.Samples[v.Sig] = s
+	}
+
+	t.save()
+}
+
+// median returns the median of the first responses of sig. It fails if
+// there are less than min samples.
+func (t *responseTracker) median(sig string, min int) (time.Duration, bool) {
+	t.lock.Lock()
+	s := append([]float64(nil), t.state.Samples[sig]...)
+	t.lock.Unlock()
+
+	if len(s) == 0 || len(s) < min {
+		return 0, false
+	}
+
+	sort.Float64s(s)
+
+	m := s[len(s)/2]
+	if len(s)%2 == 0 {
+		m = (s[len(s)/2-1] + m) / 2
+	}
+
+	return time.Duration(m * float64(time.Second)), true
+}
+
+// humanizeDuration rounds d to the largest unit, such as "2 days".
+func humanizeDuration(d time.Duration) string {
+	unit := func(n int, name string) string {
+		if n <= 1 {
+			return "1 " + name
+		}
+
+		return fmt.Sprintf("%d %ss", n, name)
+	}
+
+	switch {
+	case d >= 36*time.Hour:
+		return unit(int((d+12*time.Hour)/(24*time.Hour)), "day")
+	case d >= 90*time.Minute:
+		return unit(int((d+30*time.Minute)/time.Hour), "hour")
+	default:
+		return unit(int((d+30*time.Second)/time.Minute), "minute")
+	}
+}
+
+// genReviewSLA renders the estimate of the first response of sig. It is
+// empty if there are not enough samples.
+func (bot *robot) genReviewSLA(sigName string, cfg *reviewSLAConfig) (string, error) {
+	d, ok := bot.responses.median(sigName, cfg.MinSamples)
+	if !ok {
+		return "", nil
+	}
+
+	return renderTemplate(cfg.Template, map[string]interface{}{
+		"Sig":      sanitizeText(sigName),
+		"Duration": "~" + humanizeDuration(d),
+	})
+}
+
+// recordAwaitedResponse starts waiting for the first response of the
+// maintainers of sig to the merge request welcomed.
+func (bot *robot) recordAwaitedResponse(org, repo string, acts actions, plan *ActionPlan) {
+	a, ok := acts.(*mrActions)
+	if !ok || plan.SigName == "" {
+		return
+	}
+
+	bot.responses.record(awaitedResponse{
+		Sig:       plan.SigName,
+		Org:       org,
+		Repo:      repo,
+		ProjectID: a.projectID,
+		Number:    a.number,
+		OpenedAt:  time.Now(),
+	})
+}
+
+// checkResponses checks every interval whether the maintainers respond to
+// the merge requests welcomed. Only the leader runs it if the leader
+// election is enabled.
+func (bot *robot) checkResponses(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for range t.C {
+		if bot.elector != nil && !bot.elector.isLeader() {
+			continue
+		}
+
+		c, err := bot.getConfig()
+		if err != nil {
+			logrus.WithError(err).Error("get config to check the responses")
+
+			continue
+		}
+
+		for _, v := range bot.responses.pending() {
+			cfg := c.configFor(v.Org, v.Repo)
+			if cfg == nil || !cfg.ReviewSLA.Enabled {
+				bot.responses.done(v, 0)
+
+				continue
+			}
+
+			log := logrus.WithFields(logrus.Fields{
+				"org":    v.Org,
+				"repo":   v.Repo,
+				"number": v.Number,
+			})
+
+			d, err := bot.firstResponse(&v)
+			if err != nil {
+				log.WithError(err).Error("check the first response")
+
+				continue
+			}
+
+			if d > 0 || time.Since(v.OpenedAt) >= time.Duration(cfg.ReviewSLA.Days)*24*time.Hour {
+				bot.responses.done(v, d)
+			}
+		}
+	}
+}
+
+// firstResponse returns how soon somebody other than the author and the
+// robot commented on the merge request. It is 0 if nobody has.
+func (bot *robot) firstResponse(v *awaitedResponse) (time.Duration, error) {
+	cli := bot.clientFor(v.Org)
+
+	mr, err := cli.GetMergeRequest(v.ProjectID, v.Number)
+	if err != nil {
+		return 0, err
+	}
+
+	notes, err := cli.ListMergeRequestNotes(v.ProjectID, v.Number)
+	if err != nil {
+		return 0, err
+	}
+
+	author := ""
+	if mr.Author != nil {
+		author = mr.Author.Username
+	}
+
+	// the notes are not sorted by time ascending, so the earliest is found.
+	var first time.Duration
+	for _, n := range notes {
+		if n.System || n.CreatedAt == nil || n.Author.Username == author ||
+			strings.Contains(n.Body, "<!-- "+botName+"-robot:") || strings.Contains(n.Body, commentFooterPrefix) {
+			continue
+		}
+
+		if d := n.CreatedAt.Sub(v.OpenedAt); d > 0 && (first == 0 || d < first) {
+			first = d
+		}
+	}
+
+	return first, nil
+}
@@ -1,32 +1,21 @@
 package main
 
 import (
-	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"github.com/opensourceways/community-robot-lib/gitlabclient"
-	"github.com/opensourceways/community-robot-lib/utils"
 	"github.com/sirupsen/logrus"
 	"github.com/xanzy/go-gitlab"
-	"io/ioutil"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"net/http"
-	"regexp"
-	"sigs.k8s.io/yaml"
-	"strings"
+	"sync"
+	"time"
 )
 
 const (
-	botName        = "welcome"
-	actionOpen     = "open"
-	welcomeMessage = `
-Hi ***%s***, welcome to the %s Community.
-I'm the Bot here serving you. You can find the instructions on how to interact with me at **[Here](%s)**.
-If you have any questions, please contact the SIG: [%s](https://gitee.com/openeuler/community/tree/master/sig/%s), and any of the maintainers: @%s`
-	welcomeMessage2 = `
-Hi ***%s***, welcome to the %s Community.
-I'm the Bot here serving you. You can find the instructions on how to interact with me at **[Here](%s)**.
-If you have any questions, please contact the SIG: [%s](https://gitee.com/openeuler/community/tree/master/sig/%s), and any of the maintainers: @%s, any of the committers: @%s`
+	botName    = "welcome"
+	actionOpen = "open"
+
+	gitlabAPIURL = "https://source.openeuler.sh/api/v4"
 )
 
 type iClient interface {
@@ -34,25 +23,220 @@ type iClient interface {
 	AddMergeRequestLabel(projectID interface{}, mrID int, labels gitlab.Labels) error
 	GetProjectLabels(projectID interface{}) ([]*gitlab.Label, error)
 	CreateProjectLabel(pid interface{}, label, color string) error
+	UpdateProjectLabel(pid interface{}, opt *gitlab.UpdateLabelOptions) error
 	GetDirectoryTree(projectID interface{}, opts gitlab.ListTreeOptions) ([]*gitlab.TreeNode, error)
-	ListCollaborators(projectID interface{}) ([]*gitlab.ProjectMember, error)
+	ListCollaborators(projectID interface{}) ([]*projectMember, error)
+	ListMemberRoles(gid int) ([]*memberRole, error)
+	GetGroup(gid interface{}) (*gitlab.Group, error)
 	CreateIssueComment(projectID interface{}, issueID int, comment string) error
 	AddIssueLabels(projectID interface{}, issueID int, labels gitlab.Labels) error
 	GetPathContent(projectID interface{}, file, branch string) (*gitlab.File, error)
+	GetFileLastCommit(projectID interface{}, file, branch string) (string, error)
 	GetMergeRequestChanges(projectID interface{}, mrID int) ([]string, error)
 	AssignMergeRequest(projectID interface{}, mrID int, ids []int) error
+	SetReviewers(projectID interface{}, mrID int, ids []int) error
+	CountGroupMergeRequests(gid interface{}, author, state string) (int, error)
+	GetProjectID(path string) (int, error)
+	ListMergeRequestNotes(pid interface{}, mrID int) ([]*gitlab.Note, error)
+	ListIssueNotes(pid interface{}, issueID int) ([]*gitlab.Note, error)
+	UpdateMergeRequestNote(pid interface{}, mrID, noteID int, body string) error
+	UpdateIssueNote(pid interface{}, issueID, noteID int, body string) error
+	CreateEpicNote(gid interface{}, epicID int, body string) error
+	AssignIssue(projectID interface{}, issueID int, ids []int) error
+	GetUserID(username string) (int, error)
+	ListProjectHookURLs(pid interface{}) ([]string, error)
+	AddProjectHook(pid interface{}, url, token string) error
+	CreateIssue(pid interface{}, title, description string, assignees []int, labels []string) (*gitlab.Issue, error)
+	ListClosedMergeRequests(pid interface{}, label string, updatedBefore time.Time) ([]*gitlab.MergeRequest, error)
+	ListClosedIssues(pid interface{}, label string, updatedBefore time.Time) ([]*gitlab.Issue, error)
+	RemoveMergeRequestLabel(projectID interface{}, mrID int, labels gitlab.Labels) error
+	RemoveIssueLabels(projectID interface{}, issueID int, labels gitlab.Labels) error
+	DeleteMergeRequestNote(pid interface{}, mrID, noteID int) error
+	DeleteIssueNote(pid interface{}, issueID, noteID int) error
+	GetMergeRequest(pid interface{}, mrID int) (*gitlab.MergeRequest, error)
+	GetIssue(pid interface{}, issueID int) (*gitlab.Issue, error)
+	ListOpenMergeRequests(pid interface{}, createdAfter time.Time) ([]*gitlab.MergeRequest, error)
+	ListOpenIssues(pid interface{}, createdAfter time.Time) ([]*gitlab.Issue, error)
+	ListOpenLabeledMergeRequests(pid interface{}, label string) ([]*gitlab.MergeRequest, error)
+	ListOpenLabeledIssues(pid interface{}, label string) ([]*gitlab.Issue, error)
+	SetCommitStatus(pid interface{}, sha string, opt *gitlab.SetCommitStatusOptions) error
+	CloseMergeRequest(pid interface{}, mrID int) error
+	CloseIssue(pid interface{}, issueID int) error
+	ListMergeRequestNoteAwardEmoji(pid interface{}, mrID, noteID int) ([]*gitlab.AwardEmoji, error)
+	ListIssueNoteAwardEmoji(pid interface{}, issueID, noteID int) ([]*gitlab.AwardEmoji, error)
+	CreateMergeRequestAwardEmoji(pid interface{}, mrID int, name string) error
+	CreateIssueAwardEmoji(pid interface{}, issueID int, name string) error
 }
 
 func newRobot(cli iClient, gc func() (*configuration, error)) *robot {
-	return &robot{getConfig: gc, cli: cli}
+	return &robot{
+		getConfig:     gc,
+		cli:           cli,
+		contributions: newExpiringCache(),
+		files:         newExpiringCache(),
+		labelDenied:   newExpiringCache(),
+		handoffs:      newExpiringCache(),
+		mentorships:   newExpiringCache(),
+		repoFiles:     newExpiringCache(),
+		sigFiles:      newExpiringCache(),
+		sigTrees:      newExpiringCache(),
+		moderation:    newExpiringCache(),
+		sigData:       newExpiringCache(),
+		commands:      newExpiringCache(),
+		directory:     newExpiringCache(),
+		unconfigured:  newUnconfiguredRepos(),
+		errorBudget:   newErrorBudget(),
+		throttle:      newWelcomeThrottle(),
+		alerts:        newAlerter(),
+		standby:       new(standbyBuffer),
+		welcomes:      newWelcomeHistory(""),
+		triage:        newTriageRotation(""),
+		mentions:      newMentionLedger(""),
+		conversions:   newConversionTracker(""),
+		engagements:   newEngagementTracker(""),
+		responses:     newResponseTracker(""),
+		digests:       newDigestLog(""),
+		limiter:       newProjectLimiter(0, nil),
+		hc:            http.DefaultClient,
+		newcomerHC:    http.DefaultClient,
+		content:       contentDecoder{maxSize: defaultMaxFileSize, encoding: fileEncodingAuto},
+	}
 }
 
 type robot struct {
-	getConfig func() (*configuration, error)
-	cli       iClient
+	getConfig     func() (*configuration, error)
+	cli           iClient
+	orgClients    map[string]iClient
+	contributions *expiringCache
+	files         *expiringCache
+	repoFiles     *expiringCache
+	sigFiles      *expiringCache
+	sigTrees      *expiringCache
+	moderation    *expiringCache
+	sigData       *expiringCache
+	commands      *expiringCache
+	directory     *expiringCache
+	labelDenied   *expiringCache
+	handoffs      *expiringCache
+	mentorships   *expiringCache
+	sigLabels     sigLabelNames
+	cardLock      sync.Mutex
+	welcomes      *welcomeHistory
+	triage        *triageRotation
+	mentions      *mentionLedger
+	conversions   *conversionTracker
+	engagements   *engagementTracker
+	responses     *responseTracker
+	digests       *digestLog
+	limiter       *projectLimiter
+	publisher     eventPublisher
+	unconfigured  *unconfiguredRepos
+	errorBudget   *errorBudget
+	throttle      *welcomeThrottle
+	alerts        *alerter
+
+	// ordering is nil if the events of the same target are not ordered.
+	ordering *eventOrdering
+
+	// ingestOrdering dispatches the events consumed from the message
+	// broker in order. It is apart from ordering, whose queue of the
+	// target the handlers of the events wait in.
+	ingestOrdering *eventOrdering
+
+	// bestEffort is nil if the best-effort actions are run inline.
+	bestEffort *bestEffortQueue
+
+	// content decodes the files read from the repos.
+	content contentDecoder
+
+	// fileCacheByCommit keys the cached files by their last commit
+	// instead of expiring them by TTL.
+	fileCacheByCommit bool
+
+	// webhookSecret is the secret of the webhooks registered to the new
+	// projects. It is nil if not set.
+	webhookSecret func() []byte
+
+	// router dispatches the events consumed to the tenants. It is nil if
+	// the robot serves a single community.
+	router gitlabEventHandler
+
+	// privacy means no username is sent to the external services or kept
+	// in the per-user history.
+	privacy bool
+
+	// elector is nil when the leader election is disabled.
+	elector *leaderElector
+	standby *standbyBuffer
+
+	// consumer is nil if the events are not consumed from a message
+	// broker.
+	consumer *consumerRunner
+
+	// hc is the client of the outbound calls other than GitLab.
+	hc *http.Client
+
+	// newcomerHC is the client of the external newcomer service, which is
+	// rate limited separately.
+	newcomerHC *http.Client
 }
 
+// HandleMergeEvent handles the event after the earlier ones of the same
+// merge request.
 func (bot *robot) HandleMergeEvent(e *gitlab.MergeEvent, log *logrus.Entry) error {
+	return bot.ordering.run(mrTarget(e.Project.ID, e.ObjectAttributes.IID), func() error {
+		return bot.handleMergeEvent(e, log)
+	})
+}
+
+func (bot *robot) handleMergeEvent(e *gitlab.MergeEvent, log *logrus.Entry) error {
+	if e.ObjectAttributes.Action == actionUpdate {
+		org, repo := gitlabclient.GetMROrgAndRepo(e)
+		botCfg, err := bot.botConfigFor(org, repo, log)
+		if err != nil || botCfg == nil {
+			return err
+		}
+
+		mErr := newMultiError()
+		if err := bot.handleDescriptionUpdate(e, botCfg, log); err != nil {
+			mErr.add(err)
+		}
+
+		number := e.ObjectAttributes.IID
+		d := &e.Changes.Description
+		err = bot.handleSigDirectiveUpdate(
+			org, repo, gitlabclient.GetMRAuthor(e), d.Previous, d.Current, e.Project.ID, number,
+			func() (*itemMeta, error) { return bot.getMRMeta(e.Project.ID, number) },
+			&mrActions{cli: bot.clientFor(org), projectID: e.Project.ID, number: number},
+			botCfg, log,
+		)
+		if err != nil {
+			mErr.add(err)
+		}
+
+		err = bot.handleSigRelabel(
+			org, repo, gitlabclient.GetMRAuthor(e), e.Project.ID, number,
+			e.Changes.Labels.Previous, e.Changes.Labels.Current,
+			&mrActions{cli: bot.clientFor(org), projectID: e.Project.ID, number: number},
+			botCfg, log,
+		)
+		if err != nil {
+			mErr.add(err)
+		}
+
+		return mErr.err()
+	}
+
+	if e.ObjectAttributes.Action == actionMerge {
+		org, repo := gitlabclient.GetMROrgAndRepo(e)
+		botCfg, err := bot.botConfigFor(org, repo, log)
+		if err != nil || botCfg == nil {
+			return err
+		}
+
+		return bot.handleNewcomerMerged(e, org, botCfg, log)
+	}
+
 	if e.ObjectAttributes.Action != actionOpen {
 		return nil
 	}
@@ -62,51 +246,86 @@ func (bot *robot) HandleMergeEvent(e *gitlab.MergeEvent, log *logrus.Entry) erro
 	author := gitlabclient.GetMRAuthor(e)
 
 	org, repo := gitlabclient.GetMROrgAndRepo(e)
-	c, err := bot.getConfig()
-	if err != nil {
+	botCfg, err := bot.botConfigFor(org, repo, log)
+	if err != nil || botCfg == nil {
 		return err
 	}
-	botCfg := c.configFor(org, repo)
 
-	return bot.handle(
-		org, repo, author, projectID, botCfg, log,
+	if target := e.ObjectAttributes.TargetBranch; !botCfg.isTargetBranch(target) {
+		log.Infof("the target branch %s is not configured, skip it", target)
+
+		return nil
+	}
 
-		func(c string) error {
-			return bot.cli.CreateMergeRequestComment(projectID, mrNumber, c)
-		},
+	meta, err := bot.getMRMeta(projectID, mrNumber)
+	if err != nil {
+		log.WithError(err).Error("get the metadata of merge request")
+	}
 
-		func(label string) error {
-			return bot.cli.AddMergeRequestLabel(projectID, mrNumber, gitlab.Labels{label})
-		},
-		mrNumber,
+	return bot.handle(
+		org, repo, author, projectID, botCfg, log,
+		&mrActions{cli: bot.clientFor(org), projectID: projectID, number: mrNumber},
+		mrNumber, meta,
 	)
 }
 
+// HandleIssueEvent handles the event after the earlier ones of the same
+// issue.
 func (bot *robot) HandleIssueEvent(e *gitlab.IssueEvent, log *logrus.Entry) error {
-	if e.ObjectAttributes.Action != actionOpen {
+	return bot.ordering.run(issueTarget(e.Project.ID, e.ObjectAttributes.IID), func() error {
+		return bot.handleIssueEvent(e, log)
+	})
+}
+
+func (bot *robot) handleIssueEvent(e *gitlab.IssueEvent, log *logrus.Entry) error {
+	action := e.ObjectAttributes.Action
+	if action != actionOpen && action != actionUpdate {
 		return nil
 	}
 	org, repo := gitlabclient.GetIssueOrgAndRepo(e)
 	projectID := e.Project.ID
 	number := gitlabclient.GetIssueNumber(e)
 	author := gitlabclient.GetIssueAuthor(e)
-	c, err := bot.getConfig()
-	if err != nil {
+	botCfg, err := bot.botConfigFor(org, repo, log)
+	if err != nil || botCfg == nil {
 		return err
 	}
-	botCfg := c.configFor(org, repo)
 
-	return bot.handle(
-		org, repo, author, projectID, botCfg, log,
+	if action == actionUpdate {
+		acts := &issueActions{cli: bot.clientFor(org), projectID: projectID, number: number}
+
+		// the issue is planned without the number, as it is welcomed.
+		mErr := newMultiError()
+		d := &e.Changes.Description
+		mErr.add(bot.handleSigDirectiveUpdate(
+			org, repo, author, d.Previous, d.Current, projectID, 0,
+			func() (*itemMeta, error) { return bot.getIssueMeta(projectID, number) },
+			acts, botCfg, log,
+		))
+		mErr.add(bot.handleSigRelabel(
+			org, repo, author, projectID, 0,
+			e.Changes.Labels.Previous, e.Changes.Labels.Current,
+			acts, botCfg, log,
+		))
+
+		return mErr.err()
+	}
+
+	meta, err := bot.getIssueMeta(projectID, number)
+	if err != nil {
+		log.WithError(err).Error("get the metadata of issue")
 
-		func(c string) error {
-			return bot.cli.CreateIssueComment(projectID, number, c)
-		},
+		// it is still known to be an issue.
+		meta = &itemMeta{
+			Title:       e.ObjectAttributes.Title,
+			Description: e.ObjectAttributes.Description,
+		}
+	}
 
-		func(label string) error {
-			return bot.cli.AddIssueLabels(projectID, number, gitlab.Labels{label})
-		},
-		0,
+	return bot.handle(
+		org, repo, author, projectID, botCfg, log,
+		&issueActions{cli: bot.clientFor(org), projectID: projectID, number: number},
+		0, meta,
 	)
 }
 
@@ -114,196 +333,284 @@ func (bot *robot) handle(
 	org, repo, author string,
 	projectID int,
 	cfg *botConfig, log *logrus.Entry,
-	addMsg, addLabel func(string) error,
+	acts actions,
 	number int,
+	meta *itemMeta,
 ) error {
+	// the welcome is replayed unless the previous leader has posted it.
+	if bot.deferIfStandbyUnless(func() error {
+		return bot.handle(org, repo, author, projectID, cfg, log, acts, number, meta)
+	}, func() (bool, error) {
+		return welcomePosted(acts)
+	}, log) {
+		return nil
+	}
 
-	mErr := utils.NewMultiErrors()
-	if number > 0 {
-		resp, err := http.Get(fmt.Sprintf("https://ipb.osinfra.cn/pulls?author=%s", author))
-		if err != nil {
-			mErr.AddError(err)
-		}
-		defer resp.Body.Close()
-		body, _ := ioutil.ReadAll(resp.Body)
-		type T struct {
-			Total int `json:"total,omitempty"`
-		}
+	defer bot.limiter.acquire(org + "/" + repo)()
 
-		var t T
-		err = json.Unmarshal(body, &t)
-		if err != nil {
-			mErr.AddError(err)
-		}
+	_, isIssue := acts.(*issueActions)
 
-		if t.Total == 0 {
-			if err = bot.cli.AddMergeRequestLabel(projectID, number, []string{"newcomer"}); err != nil {
-				mErr.AddError(err)
-			}
-		}
+	cfg, skip := bot.applyRepoFile(projectID, isIssue, cfg, log)
+	if skip {
+		return nil
+	}
+
+	if blocked, err := bot.isBlocked(author, cfg); err != nil {
+		log.WithError(err).Error("check if the author is blocked")
+	} else if blocked {
+		webhookOutcomeOf(log).skip("blocked_author")
+
+		return bot.handleBlockedAuthor(author, projectID, acts, cfg, log)
 	}
 
-	sigName, comment, err := bot.genComment(org, repo, author, number, projectID, cfg, log)
+	if cfg.Imported.isImported(author, meta) {
+		webhookOutcomeOf(log).skip("imported")
+
+		return bot.handleImported(author, projectID, acts, cfg, log)
+	}
+
+	if label := cfg.exemptLabel(meta); label != "" {
+		webhookOutcomeOf(log).skip("exempt_label")
+
+		return bot.handleExempt(org, repo, author, label, projectID, number, meta, acts, cfg, log)
+	}
+
+	plan, err := bot.genPlan(org, repo, author, number, projectID, nil, meta, cfg, log)
 	if err != nil {
+		bot.errorBudget.record(projectID)
+		bot.alertFailure(org, repo, err, cfg, log)
+
 		return err
 	}
 
-	if err := addMsg(comment); err != nil {
-		mErr.AddError(err)
+	for range plan.Degraded {
+		bot.errorBudget.record(projectID)
 	}
 
-	label := fmt.Sprintf("sig/%s", sigName)
+	plan.log(log)
 
-	if err := bot.createLabelIfNeed(projectID, label); err != nil {
-		log.Errorf("create repo label:%s, err:%s", label, err.Error())
+	if cfg.DryRun {
+		webhookOutcomeOf(log).skip("dry_run")
+
+		return nil
 	}
 
-	if err := addLabel(label); err != nil {
-		mErr.AddError(err)
+	// the config may be changed by the time the welcome put off is done.
+	retry := func() error {
+		cfg, err := bot.botConfigFor(org, repo, log)
+		if err != nil || cfg == nil {
+			return err
+		}
+
+		return bot.handle(org, repo, author, projectID, cfg, log, acts, number, meta)
 	}
+	if bot.throttleWelcome(plan, author, projectID, acts, cfg, retry, log) {
+		webhookOutcomeOf(log).skip("throttled")
 
-	return mErr.Err()
-}
+		return nil
+	}
 
-func (bot robot) genComment(org, repo, author string, number, pid int, cfg *botConfig, log *logrus.Entry) (string, string, error) {
+	report := bot.execute(plan, acts, projectID, cfg, log)
+	report.addDegraded(plan.Degraded)
+	bot.setWelcomeStatus(projectID, number, plan, report, cfg, log)
+	report.log(log)
+	webhookOutcomeOf(log).addSteps(report.results)
 
-	sigName, err := bot.getSigOfRepo(org, repo, pid, cfg)
+	err = report.err()
 	if err != nil {
-		return "", "", err
+		bot.alertFailure(org, repo, err, cfg, log)
 	}
 
-	if sigName == "" {
-		return "", "", fmt.Errorf("cant get sig name of repo: %s/%s", org, repo)
+	if err == nil && plan.Comment != "" && cfg.LateMentions.Enabled && needMentionRecheck(plan) {
+		bot.scheduleMentionRecheck(&mentionRecheck{
+			org: org, repo: repo, author: author, pid: projectID,
+			number: number, meta: meta, acts: acts, cfg: cfg,
+			labels: plan.Labels,
+		})
 	}
 
-	maintainers, committers, err := bot.getMaintainers(org, repo, sigName, number, pid, cfg, log)
-	if err != nil {
-		return "", "", err
+	if err == nil && plan.Newcomer {
+		bot.forgetContributions(org, author, &cfg.Newcomer)
 	}
 
-	if cfg.NeedAssign && number != 0 {
-		if err = bot.cli.AssignMergeRequest(pid, number, []int{}); err != nil {
-			return "", "", err
+	// the history of users is not kept in the privacy mode.
+	if err == nil && plan.Comment != "" && !bot.privacy {
+		bot.welcomes.record(author, cfg)
+		bot.mentions.record(plan.Notifications)
+
+		if plan.Newcomer && cfg.Conversion.Enabled {
+			bot.conversions.record(org, repo, author, isIssue, cfg)
+		}
+
+		if plan.Newcomer && cfg.Mentorship.Enabled {
+			if err := bot.openMentorship(org, repo, author, projectID, acts, plan, cfg, log); err != nil {
+				log.WithError(err).Error("open the mentorship issue")
+			}
+		}
+
+		bot.recordDigest(org, repo, author, acts, plan, cfg)
+
+		if cfg.Engagement.Enabled {
+			if v, ok := welcomedItemOf(org, repo, author, acts, cfg); ok {
+				bot.engagements.record(v)
+			}
 		}
 	}
 
-	if len(committers) != 0 {
-		return sigName, fmt.Sprintf(
-			welcomeMessage2, author, cfg.CommunityName, cfg.CommandLink,
-			sigName, sigName, strings.Join(maintainers, " , @"), strings.Join(committers, " , @"),
-		), nil
+	// no user is kept, so it is recorded in the privacy mode too.
+	if err == nil && plan.Comment != "" && cfg.ReviewSLA.Enabled {
+		bot.recordAwaitedResponse(org, repo, acts, plan)
 	}
 
-	return sigName, fmt.Sprintf(
-		welcomeMessage, author, cfg.CommunityName, cfg.CommandLink,
-		sigName, sigName, strings.Join(maintainers, " , @"),
-	), nil
+	bot.publishWelcomeEvent(org, repo, author, number, cfg, plan, err, log)
+
+	if err != nil {
+		return err
+	}
+
+	// the issue is triaged only after it is welcomed, so the blocked,
+	// imported, exempt and throttled ones are not.
+	if a, ok := acts.(*issueActions); ok {
+		return bot.assignTriager(org, repo, projectID, a.number, cfg, log)
+	}
+
+	return nil
 }
 
-func (bot *robot) getMaintainers(org, repo, sig string, number, pid int, cfg *botConfig, log *logrus.Entry) ([]string, []string, error) {
+// getMaintainers returns the maintainers and committers of sig. The files
+// changed by the merge request are fetched by number if changes is nil.
+func (bot *robot) getMaintainers(org, repo, sig string, number, pid int, changes []string, cfg *botConfig, log *logrus.Entry) ([]string, []string, error) {
 	if cfg.WelcomeSimpler {
-		membersToContact, err := bot.findSpecialContact(org, repo, number, pid, cfg, log)
+		membersToContact, err := bot.findSpecialContact(org, repo, number, pid, changes, cfg, log)
 		if err == nil && len(membersToContact) != 0 {
 			return membersToContact.UnsortedList(), nil, nil
 		}
 	}
 
-	v, err := bot.cli.ListCollaborators(pid)
-	if err != nil {
-		return nil, nil, err
-	}
+	// the calls are sent concurrently, and the collaborators are not waited
+	// for if the sig-info provides the maintainers.
+	timeout := time.Duration(cfg.MaintainersTimeout) * time.Second
+
+	collaborators := callWithTimeout(timeout, func() (interface{}, error) {
+		return bot.cli.ListCollaborators(pid)
+	})
 
-	r := make([]string, 0, len(v))
-	for i := range v {
-		p := v[i]
-		if p != nil && (p.AccessLevel == 30 || p.AccessLevel == 40 || p.AccessLevel == 50) {
-			r = append(r, v[i].Username)
+	fallback := func(err error) ([]string, []string, error) {
+		res := <-collaborators
+		if res.err != nil {
+			return nil, nil, res.err
 		}
-	}
 
-	f, err := bot.cli.GetPathContent(pid, fmt.Sprintf("sig/%s/OWNERS", sig), "master")
-	if err != nil || len(f.Content) == 0 {
-		return r, nil, err
-	}
+		r := bot.filterByRoles(res.value.([]*projectMember), cfg.MaintainerRoles)
 
-	s, err := bot.cli.GetPathContent(pid, fmt.Sprintf("sig/%s/sig-info.yaml", sig), "master")
-	if err != nil || len(s.Content) == 0 {
 		return r, nil, err
 	}
 
-	maintainers, committers := decodeSigInfoFile(s.Content)
-	return maintainers.UnsortedList(), committers.UnsortedList(), nil
-}
+	// the members of the other sources than the community repo are taken
+	// as they are.
+	if cfg.SigSource.Type != sigSourceRepo {
+		maintainers, committers, err := bot.sigDataOf(cfg).sigMembers(sig, pid)
+		if err != nil || len(maintainers) == 0 {
+			return fallback(err)
+		}
 
-func (bot *robot) createLabelIfNeed(pid int, label string) error {
-	repoLabels, err := bot.cli.GetProjectLabels(pid)
-	if err != nil {
-		return err
+		return maintainers, committers, nil
 	}
 
-	for _, v := range repoLabels {
-		if v.Name == label {
-			return nil
-		}
-	}
+	owners := callWithTimeout(timeout, func() (interface{}, error) {
+		return bot.getPathContent(pid, sigOwnersFile(sig), sigFileBranch)
+	})
+	sigInfo := callWithTimeout(timeout, func() (interface{}, error) {
+		return bot.getPathContent(pid, sigInfoFile(sig), sigFileBranch)
+	})
 
-	return bot.cli.CreateProjectLabel(pid, label, "")
-}
+	if res := <-owners; res.err != nil || len(res.value.(*gitlab.File).Content) == 0 {
+		return fallback(res.err)
+	}
 
-func (bot *robot) findSpecialContact(org, repo string, number, pid int, cfg *botConfig, log *logrus.Entry) (sets.String, error) {
-	if number == 0 {
-		return nil, nil
+	res := <-sigInfo
+	if res.err != nil || len(res.value.(*gitlab.File).Content) == 0 {
+		return fallback(res.err)
 	}
 
-	changes, err := bot.cli.GetMergeRequestChanges(pid, number)
+	content, err := bot.fileContent(res.value.(*gitlab.File))
 	if err != nil {
-		log.Errorf("get pr changes failed: %v", err)
-		return nil, err
+		return fallback(err)
 	}
 
-	filePath := cfg.FilePath
-	branch := cfg.FileBranch
+	maintainers, committers := decodeSigInfoFile(content)
 
-	content, err := bot.cli.GetPathContent(pid, filePath, branch)
-	if err != nil {
-		log.Errorf("get file %s/%s/%s failed, err: %v", org, repo, filePath, err)
-		return nil, err
+	if aliases, err := bot.communityAliases(pid, cfg); err != nil {
+		log.WithError(err).Errorf("load the aliases file %s", cfg.OwnersAliasesFile)
+	} else {
+		maintainers, committers = aliases.expand(maintainers), aliases.expand(committers)
 	}
 
-	c, err := base64.StdEncoding.DecodeString(content.Content)
-	if err != nil {
-		log.Errorf("decode string err: %v", err)
-		return nil, err
+	return maintainers.UnsortedList(), committers.UnsortedList(), nil
+}
+
+type callResult struct {
+	value interface{}
+	err   error
+}
+
+// callWithTimeout runs f in background and returns the channel of its
+// result. An error is sent instead if f does not finish in time.
+func callWithTimeout(timeout time.Duration, f func() (interface{}, error)) <-chan callResult {
+	r := make(chan callResult, 1)
+	done := make(chan callResult, 1)
+
+	go func() {
+		v, err := f()
+		done <- callResult{value: v, err: err}
+	}()
+
+	go func() {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+
+		select {
+		case v := <-done:
+			r <- v
+		case <-t.C:
+			r <- callResult{err: fmt.Errorf("timeout after %v", timeout)}
+		}
+	}()
+
+	return r
+}
+
+func (bot *robot) findSpecialContact(org, repo string, number, pid int, changes []string, cfg *botConfig, log *logrus.Entry) (sets.String, error) {
+	if changes == nil {
+		if number == 0 {
+			return nil, nil
+		}
+
+		v, err := bot.cli.GetMergeRequestChanges(pid, number)
+		if err != nil {
+			log.Errorf("get pr changes failed: %v", err)
+			return nil, err
+		}
+
+		changes = v
 	}
 
-	var r Relation
+	limits := &cfg.SpecialContact
+	if limits.MaxFiles > 0 && len(changes) > limits.MaxFiles {
+		log.Infof("%d changed files exceed the limit of special contact", len(changes))
+
+		return limits.overflow(), nil
+	}
 
-	err = yaml.Unmarshal(c, &r)
+	owners, err := bot.findRelationOwners(pid, changes, cfg, log)
 	if err != nil {
-		log.Errorf("yaml unmarshal failed: %v", err)
 		return nil, err
 	}
 
-	owners := sets.NewString()
-	var mo []Maintainer
-	for _, c := range changes {
-		for _, f := range r.Relations {
-			for _, ff := range f.Path {
-				if strings.Contains(c, ff) {
-					mo = append(mo, f.Owner...)
-				}
-				if strings.Contains(ff, "/*/") {
-					reg := regexp.MustCompile(strings.Replace(ff, "/*/", "/[^\\s]+/", -1))
-					if ok := reg.MatchString(c); ok {
-						mo = append(mo, f.Owner...)
-					}
-				}
-			}
-		}
-	}
+	if limits.MaxOwners > 0 && owners.Len() > limits.MaxOwners {
+		log.Infof("%d owners exceed the limit of special contact", owners.Len())
 
-	for _, m := range mo {
-		owners.Insert(m.GiteeID)
+		return limits.overflow(), nil
 	}
 
 	return owners, nil
@@ -0,0 +1,89 @@
+package main
+
+import "strings"
+
+const (
+	roleReviewers        = "reviewers"
+	roleTriagers         = "triagers"
+	roleSecurityContacts = "security_contacts"
+)
+
+// extraRoles are the roles of sig-info besides maintainers and committers,
+// in the order they appear in the welcome comment.
+var extraRoles = []string{roleReviewers, roleTriagers, roleSecurityContacts}
+
+// roleMembers is the members of a role of sig to show in the welcome
+// comment. They are mentioned if Mention is true, or listed as links.
+type roleMembers struct {
+	Name    string
+	Mention bool
+	Members []string
+}
+
+func (s *SigInfos) roleMembers(role string) []Maintainer {
+	switch role {
+	case roleReviewers:
+		return s.Reviewers
+	case roleTriagers:
+		return s.Triagers
+	case roleSecurityContacts:
+		return s.SecurityContacts
+	default:
+		return nil
+	}
+}
+
+// genRoles returns the members of the extra roles configured. A member who
+// has appeared in a former role, including the maintainers and committers
+// in seen, is not repeated.
+func genRoles(info *SigInfos, cfg *rolesConfig, seen ...[]string) []roleMembers {
+	if info == nil {
+		return nil
+	}
+
+	dup := make(map[string]bool)
+	for _, v := range seen {
+		for _, m := range v {
+			dup[m] = true
+		}
+	}
+
+	var r []roleMembers
+
+	for _, role := range extraRoles {
+		mention := cfg.isMentioned(role)
+		if !mention && !cfg.isListed(role) {
+			continue
+		}
+
+		var members []string
+		for _, m := range info.roleMembers(role) {
+			if id := m.GiteeID; id != "" && !dup[id] {
+				dup[id] = true
+				members = append(members, id)
+			}
+		}
+
+		if len(members) > 0 {
+			r = append(r, roleMembers{
+				Name:    strings.Replace(role, "_", " ", -1),
+				Mention: mention,
+				Members: members,
+			})
+		}
+	}
+
+	return r
+}
+
+// mentionedMembers returns the members who are mentioned.
+func mentionedMembers(roles []roleMembers) []string {
+	var r []string
+	for _, v := range roles {
+		if v.Mention {
+			r = append(r, v.Members...)
+		}
+	}
+
+	return r
+}
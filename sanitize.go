@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// textEscaper escapes the markdown of the text provided by users, besides
+// the characters escaped for the usernames. The @ is replaced by its entity,
+// so that the text can't mention anyone, and the parentheses can't close
+// the link around it.
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "~", `\~`, "|", `\|`, "(", `\(`, ")", `\)`,
+	"#", `\#`, "!", `\!`, "@", "&#64;", "&", "&amp;",
+)
+
+// urlEscaper encodes the characters which break the destination of the
+// markdown link.
+var urlEscaper = strings.NewReplacer(
+	" ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E",
+	"[", "%5B", "]", "%5D", `\`, "%5C", "`", "%60",
+)
+
+// sanitizeText makes the text provided by users, such as the title of merge
+// request and the sig name, safe to interpolate into markdown. It is
+// flattened into a line, without the control characters, and its markdown
+// is escaped, so that it is shown as is.
+func sanitizeText(v string) string {
+	v = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return ' '
+		}
+
+		if unicode.IsControl(r) {
+			return -1
+		}
+
+		return r
+	}, v)
+
+	return textEscaper.Replace(strings.Join(strings.Fields(v), " "))
+}
+
+// sanitizeURL returns the link which is safe to be the destination of the
+// markdown link. Only the http, https and mailto links are kept, and the
+// others, such as javascript:, are dropped as empty.
+func sanitizeURL(v string) string {
+	v = strings.TrimSpace(v)
+
+	u, err := url.Parse(v)
+	if err != nil {
+		return ""
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+	default:
+		return ""
+	}
+
+	if strings.ContainsAny(v, "\r\n\t") {
+		return ""
+	}
+
+	return urlEscaper.Replace(v)
+}
+
+// sanitizePathSegment returns v escaped to be a segment of the path of URL,
+// such as the sig in the link to its directory.
+func sanitizePathSegment(v string) string {
+	return urlEscaper.Replace(url.PathEscape(v))
+}
+
+// sanitizeMeta returns a copy of the metadata whose free text is sanitized.
+// The target branch, labels and issue type are kept as is, because they are
+// set by the maintainers and matched by the templates.
+func sanitizeMeta(m itemMeta) itemMeta {
+	m.Milestone = sanitizeText(m.Milestone)
+	m.Title = sanitizeText(m.Title)
+	m.Description = sanitizeText(m.Description)
+
+	return m
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// adversarialValues are the values which break the markdown of comment if
+// they are interpolated as is.
+var adversarialValues = []string{
+	"[click me](javascript:alert(1))",
+	"@everyone",
+	"@all please look",
+	"line\n# heading",
+	"line\r\n\r\n---\n",
+	"<!-- welcome-robot: id=123456789abc -->",
+	"<img src=x onerror=alert(1)>",
+	"a_b*c~d|e`f`",
+	"&#64;all",
+	"![image](http://example.com/x.png)",
+	"close !12 and #34",
+	"tab\there\x00\x1b[31m",
+	"trailing backslash\\",
+}
+
+func TestSanitizeText(t *testing.T) {
+	for _, v := range adversarialValues {
+		s := sanitizeText(v)
+
+		if strings.ContainsAny(s, "\r\n\t\x00\x1b") {
+			t.Errorf("sanitizeText(%q) = %q keeps the line breaks or control characters", v, s)
+		}
+
+		// the entities produced by escaping have # of their own.
+		e := strings.NewReplacer("&#64;", "", "&amp;", "").Replace(s)
+
+		for _, c := range []string{"[", "]", "(", ")", "<", ">", "*", "_", "`", "~", "|", "#", "!"} {
+			if strings.Count(e, c) != strings.Count(e, `\`+c) {
+				t.Errorf("sanitizeText(%q) = %q has unescaped %s", v, s, c)
+			}
+		}
+
+		if strings.Contains(s, "@") {
+			t.Errorf("sanitizeText(%q) = %q can mention", v, s)
+		}
+
+		if strings.Contains(s, "&#64;") && !strings.Contains(v, "@") {
+			t.Errorf("sanitizeText(%q) = %q keeps the entity of user", v, s)
+		}
+	}
+}
+
+func TestSanitizeTextKeepsPlainText(t *testing.T) {
+	for _, v := range []string{"storage", "sig-Kernel", "fix typo in README.md", "修复文档"} {
+		if s := sanitizeText(v); s != v {
+			t.Errorf("sanitizeText(%q) = %q, want it unchanged", v, s)
+		}
+	}
+}
+
+func TestSanitizeURL(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"https://example.com/docs", "https://example.com/docs"},
+		{"mailto:dev@example.com", "mailto:dev@example.com"},
+		{"javascript:alert(1)", ""},
+		{"JavaScript:alert(1)", ""},
+		{"data:text/html,<script>alert(1)</script>", ""},
+		{"//example.com/x", ""},
+		{"https://example.com/a b)[x](http://evil)", "https://example.com/a%20b%29%5Bx%5D%28http://evil%29"},
+		{"https://example.com/\nx", ""},
+	}
+
+	for _, c := range cases {
+		if got := sanitizeURL(c.in); got != c.want {
+			t.Errorf("sanitizeURL(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestSanitizePathSegment(t *testing.T) {
+	for _, v := range adversarialValues {
+		s := sanitizePathSegment(v)
+
+		if strings.ContainsAny(s, " ()[]<>\\`/\n") {
+			t.Errorf("sanitizePathSegment(%q) = %q breaks the link", v, s)
+		}
+	}
+}
+
+func TestGenQuickLinksDropsUnsafeLinks(t *testing.T) {
+	s := genQuickLinks(&SigInfos{
+		MailingList: "dev@example.com",
+		ChatURL:     "javascript:alert(1)",
+		DocsURL:     "https://example.com/docs)[evil](https://evil.example.com",
+	})
+
+	if strings.Contains(s, "javascript") {
+		t.Errorf("the unsafe link is kept:\n%s", s)
+	}
+
+	if !strings.Contains(s, "(mailto:dev@example.com)") {
+		t.Errorf("the mailing list is dropped:\n%s", s)
+	}
+
+	if strings.Contains(s, "](https://evil.example.com") {
+		t.Errorf("the link is injected:\n%s", s)
+	}
+}
+
+func TestGenMinimalCommentSanitizes(t *testing.T) {
+	s := genMinimalComment("user_name", "[x](http://evil)")
+
+	if !strings.Contains(s, `***user\_name***`) {
+		t.Errorf("the author is not escaped:\n%s", s)
+	}
+
+	if strings.Contains(s, "[x](http://evil)") {
+		t.Errorf("the sig is not sanitized:\n%s", s)
+	}
+}
+
+func TestRenderWelcomeSanitizesInputs(t *testing.T) {
+	cfg := &botConfig{CommunityName: "openEuler", CommandLink: "https://example.com/commands"}
+	cfg.setDefault()
+
+	bot := newRobot(nil, nil)
+
+	for _, v := range adversarialValues {
+		meta := &itemMeta{IsMergeRequest: true, Title: v, Description: v, Milestone: v}
+
+		s, err := bot.renderWelcome(
+			`{{ .Author }} {{ .Sig }} {{ .SigPath }} {{ .Title }} {{ .Description }} {{ .Milestone }}`,
+			"user_"+v, v, []string{"alice"}, nil, nil, meta, cfg,
+		)
+		if err != nil {
+			t.Fatalf("render %q: %v", v, err)
+		}
+
+		if strings.ContainsAny(s, "\n\r") {
+			t.Errorf("the value %q breaks the line:\n%s", v, s)
+		}
+
+		if strings.Count(s, "<") != strings.Count(s, `\<`) || strings.Count(s, "[") != strings.Count(s, `\[`) {
+			t.Errorf("the value %q injects markdown:\n%s", v, s)
+		}
+	}
+}
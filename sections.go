@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	sectionWelcome    = "welcome"
+	sectionLadder     = "ladder"
+	sectionMRTemplate = "mr_template"
+	sectionQuickLinks = "quick_links"
+	sectionReviewSLA  = "review_sla"
+	sectionFAQ        = "faq"
+	sectionsSeparator = "\n\n"
+)
+
+// defaultSections is the order of the builtin sections of welcome comment.
+var defaultSections = []sectionConfig{
+	{Name: sectionWelcome},
+	{Name: sectionLadder},
+	{Name: sectionMRTemplate},
+	{Name: sectionQuickLinks},
+	{Name: sectionCommands},
+	{Name: sectionReviewSLA},
+	{Name: sectionFAQ},
+}
+
+// sectionContext is what the sections of welcome comment are generated
+// from.
+type sectionContext struct {
+	author      string
+	sigName     string
+	pid         int
+	maintainers []string
+	committers  []string
+	roles       []roleMembers
+	meta        *itemMeta
+	cfg         *botConfig
+	log         *logrus.Entry
+
+	// sigFile is nil if the sig has no file of its own.
+	sigFile *sigFile
+
+	// contributions is -1 if it is unknown.
+	contributions  int
+	askDescription bool
+}
+
+// sectionProvider generates a section of welcome comment. The section is
+// left out if it is empty.
+type sectionProvider func(bot *robot, ctx *sectionContext) (string, error)
+
+// sectionProviders are the builtin sections. Each of them is still toggled
+// by its own config, such as quick_links.
+var sectionProviders = map[string]sectionProvider{
+	sectionWelcome: func(bot *robot, ctx *sectionContext) (string, error) {
+		comment, err := bot.genComment(
+			ctx.author, ctx.sigName, ctx.maintainers, ctx.committers, ctx.roles, ctx.meta, ctx.cfg,
+		)
+
+		// the safe message is used instead if the rendering goes wrong.
+		return checkRendered(comment, err, ctx.author, ctx.cfg, ctx.log), nil
+	},
+
+	sectionLadder: func(bot *robot, ctx *sectionContext) (string, error) {
+		return genLadderHint(ctx.author, ctx.sigName, ctx.contributions, ctx.cfg)
+	},
+
+	sectionMRTemplate: func(bot *robot, ctx *sectionContext) (string, error) {
+		if !ctx.askDescription {
+			return "", nil
+		}
+
+		return ctx.cfg.MRTemplate.Message, nil
+	},
+
+	sectionQuickLinks: func(bot *robot, ctx *sectionContext) (string, error) {
+		if !ctx.cfg.QuickLinks || ctx.sigName == "" {
+			return "", nil
+		}
+
+		info, err := bot.getSigInfo(ctx.pid, ctx.sigName)
+		if err != nil {
+			return "", err
+		}
+
+		if ctx.sigFile != nil {
+			info = ctx.sigFile.Links.overlay(info)
+		}
+
+		return strings.TrimLeft(genQuickLinks(info), "\n"), nil
+	},
+
+	sectionCommands: func(bot *robot, ctx *sectionContext) (string, error) {
+		return bot.genCommandsSection(ctx)
+	},
+
+	sectionReviewSLA: func(bot *robot, ctx *sectionContext) (string, error) {
+		if !ctx.cfg.ReviewSLA.Enabled || ctx.sigName == "" || ctx.meta == nil || !ctx.meta.IsMergeRequest {
+			return "", nil
+		}
+
+		return bot.genReviewSLA(ctx.sigName, &ctx.cfg.ReviewSLA)
+	},
+
+	sectionFAQ: func(bot *robot, ctx *sectionContext) (string, error) {
+		return genFAQAnswers(ctx.meta, &ctx.cfg.FAQ), nil
+	},
+}
+
+type sectionConfig struct {
+	// Name is the name of a builtin section, which are welcome, ladder,
+	// mr_template, quick_links, commands, review_sla and faq, or of a custom
+	// section.
+	Name string `json:"name" required:"true"`
+
+	// Disabled leaves the section out.
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Template is the go template of a custom section, which can use the
+	// same variables as welcome_template.
+	Template string `json:"template,omitempty"`
+}
+
+func (c *sectionConfig) validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("the name of section can not be empty")
+	}
+
+	if _, ok := sectionProviders[c.Name]; ok {
+		if c.Template != "" {
+			return fmt.Errorf("the builtin section %s can not have template", c.Name)
+		}
+
+		return nil
+	}
+
+	if c.Template == "" {
+		return fmt.Errorf("the template of custom section %s can not be empty", c.Name)
+	}
+
+	if _, err := parseTemplate(c.Template); err != nil {
+		return fmt.Errorf("invalid template of section %s: %v", c.Name, err)
+	}
+
+	return nil
+}
+
+func (c *sectionConfig) provider() sectionProvider {
+	if p, ok := sectionProviders[c.Name]; ok {
+		return p
+	}
+
+	text := c.Template
+
+	return func(bot *robot, ctx *sectionContext) (string, error) {
+		return bot.renderWelcome(
+			text, ctx.author, ctx.sigName, ctx.maintainers, ctx.committers, ctx.roles, ctx.meta, ctx.cfg,
+		)
+	}
+}
+
+// genSections generates the welcome comment by the sections in order. A
+// section which fails is left out.
+func (bot *robot) genSections(ctx *sectionContext) string {
+	var parts []string
+
+	for i := range ctx.cfg.Sections {
+		s := &ctx.cfg.Sections[i]
+		if s.Disabled {
+			continue
+		}
+
+		v, err := s.provider()(bot, ctx)
+		if err != nil {
+			ctx.log.WithError(err).Errorf("generate the section %s of welcome", s.Name)
+
+			continue
+		}
+
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+
+		// the leading newlines of the first section, such as the ones of
+		// the welcome template, are kept.
+		v = strings.TrimRight(v, " \n")
+		if len(parts) > 0 {
+			v = strings.TrimLeft(v, "\n")
+		}
+
+		parts = append(parts, v)
+	}
+
+	return strings.Join(parts, sectionsSeparator)
+}
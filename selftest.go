@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const selftestLabel = "welcome-robot-selftest"
+
+// selftestCheck is the result of checking whether the token can perform
+// an operation required by the robot.
+type selftestCheck struct {
+	Operation string `json:"operation"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+
+	// Missing is the scope or permission the token is likely missing.
+	Missing string `json:"missing,omitempty"`
+}
+
+type selftestReport struct {
+	Project string          `json:"project"`
+	Issue   int             `json:"issue"`
+	OK      bool            `json:"ok"`
+	Checks  []selftestCheck `json:"checks"`
+}
+
+// selftestOperation is an operation required by the robot, and the scope
+// and role it needs.
+type selftestOperation struct {
+	name    string
+	missing string
+	do      func(pid, issue int) error
+}
+
+func (bot *robot) selftestOperations() []selftestOperation {
+	return []selftestOperation{
+		{
+			name:    "read_repository_tree",
+			missing: "the read_api or read_repository scope, or the Reporter role of the project",
+			do: func(pid, issue int) error {
+				_, err := bot.cli.GetDirectoryTree(pid, gitlab.ListTreeOptions{})
+
+				return err
+			},
+		},
+		{
+			name:    "list_members",
+			missing: "the read_api scope, or the membership of the project",
+			do: func(pid, issue int) error {
+				_, err := bot.cli.ListCollaborators(pid)
+
+				return err
+			},
+		},
+		{
+			name:    "create_note",
+			missing: "the api scope, or the Guest role of the project",
+			do:      bot.selftestNote,
+		},
+		{
+			name:    "add_label",
+			missing: "the api scope, or the Reporter role of the project",
+			do: func(pid, issue int) error {
+				labels := gitlab.Labels{selftestLabel}
+				if err := bot.cli.AddIssueLabels(pid, issue, labels); err != nil {
+					return err
+				}
+
+				return bot.cli.RemoveIssueLabels(pid, issue, labels)
+			},
+		},
+	}
+}
+
+// selftestNote creates a note on the issue and deletes it.
+func (bot *robot) selftestNote(pid, issue int) error {
+	body := fmt.Sprintf("self-test of %s robot at %s, it will be deleted at once.", botName, time.Now().Format(time.RFC3339Nano))
+
+	if err := bot.cli.CreateIssueComment(pid, issue, body); err != nil {
+		return err
+	}
+
+	notes, err := bot.cli.ListIssueNotes(pid, issue)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range notes {
+		if n.Body == body {
+			return bot.cli.DeleteIssueNote(pid, issue, n.ID)
+		}
+	}
+
+	return errors.New("the note created is not found")
+}
+
+// selftest checks each operation required by the robot against the issue
+// of the test project, and reports which scopes or permissions are
+// missing.
+func (bot *robot) selftest(project string, issue int) *selftestReport {
+	r := &selftestReport{Project: project, Issue: issue, OK: true}
+
+	pid, err := bot.cli.GetProjectID(project)
+	if err != nil {
+		r.OK = false
+		r.Checks = append(r.Checks, selftestCheck{
+			Operation: "get_project",
+			Error:     err.Error(),
+			Missing:   selftestMissing(err, "the read_api scope, or the access to the project"),
+		})
+
+		return r
+	}
+
+	for _, op := range bot.selftestOperations() {
+		c := selftestCheck{Operation: op.name, OK: true}
+
+		if err := op.do(pid, issue); err != nil {
+			r.OK = false
+			c.OK = false
+			c.Error = err.Error()
+			c.Missing = selftestMissing(err, op.missing)
+		}
+
+		r.Checks = append(r.Checks, c)
+	}
+
+	return r
+}
+
+// selftestMissing tells what is missing by the status code of the error.
+func selftestMissing(err error, missing string) string {
+	var e *gitlab.ErrorResponse
+	if !errors.As(err, &e) || e.Response == nil {
+		return ""
+	}
+
+	switch e.Response.StatusCode {
+	case http.StatusUnauthorized:
+		return "a valid token, it is invalid, expired or revoked"
+	case http.StatusForbidden, http.StatusNotFound:
+		return missing
+	}
+
+	return ""
+}
+
+func (r *selftestReport) log() {
+	if r.OK {
+		logrus.Infof("self-test against %s passed", r.Project)
+
+		return
+	}
+
+	var failed []string
+	for _, c := range r.Checks {
+		if !c.OK {
+			failed = append(failed, c.Operation)
+
+			logrus.WithFields(logrus.Fields{
+				"operation": c.Operation,
+				"missing":   c.Missing,
+			}).Error(c.Error)
+		}
+	}
+
+	logrus.Errorf("self-test against %s failed: %s", r.Project, strings.Join(failed, ", "))
+}
+
+// selftestHandler runs the self-test against the test project.
+func (bot *robot) selftestHandler(project string, issue int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		v := bot.selftest(project, issue)
+		if !v.OK {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		writeJSON(w, v)
+	}
+}
@@ -1,17 +1,26 @@
 package main
 
 import (
-	"github.com/xanzy/go-gitlab"
+	"fmt"
 	"strings"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
 )
 
-func (bot *robot) getSigOfRepo(org, repo string, pid int, cfg *botConfig) (string, error) {
-	sigName, err := bot.findSigName(org, repo, pid, cfg, true)
-	if err != nil {
-		return sigName, err
-	}
+// sigTreeCacheTTL is how long the sig mapping listed from the tree of repo
+// is cached. It is invalidated by the pushes before that.
+const sigTreeCacheTTL = 24 * time.Hour
 
-	return sigName, nil
+// sigTreeKey is the key of the sig mapping cached, which starts with the
+// project and branch as the keys of the files do, so that the pushes to
+// the branch can invalidate it.
+func sigTreeKey(pid int, cfg *botConfig) string {
+	return fmt.Sprintf("%d/%s/%s", pid, cfg.Branch, cfg.Path)
+}
+
+func (bot *robot) getSigOfRepo(org, repo string, pid int, cfg *botConfig) (string, error) {
+	return bot.sigDataOf(cfg).sigOfRepo(org, repo, pid)
 }
 
 func (bot *robot) listAllFilesOfRepo(pid int, cfg *botConfig) (map[string]string, error) {
@@ -36,19 +45,29 @@ func (bot *robot) listAllFilesOfRepo(pid int, cfg *botConfig) (map[string]string
 }
 
 func (bot *robot) findSigName(org, repo string, pid int, cfg *botConfig, needRefreshTree bool) (sigName string, err error) {
-	if len(cfg.reposSig) == 0 {
+	key := sigTreeKey(pid, cfg)
+
+	// the map cached is shared by the handlers, so it is never changed but
+	// replaced.
+	var reposSig map[string]string
+	if v, ok := bot.sigTrees.get(key); ok {
+		reposSig = v.(map[string]string)
+	}
+
+	if len(reposSig) == 0 {
 		files, err := bot.listAllFilesOfRepo(pid, cfg)
 		if err != nil {
 			return "", err
 		}
 
-		cfg.reposSig = files
+		reposSig = files
+		bot.sigTrees.set(key, files, sigTreeCacheTTL)
 	}
 
-	for i := range cfg.reposSig {
+	for i := range reposSig {
 		//if strings.Split(i, "/")[2] == org && strings.Split(strings.Split(i, "/")[4], ".yaml")[0] == repo {
 		if strings.Split(i, "/")[2] == "openeuler" && strings.Split(strings.Split(i, "/")[4], ".yaml")[0] == "community" {
-			sigName = cfg.reposSig[i]
+			sigName = reposSig[i]
 			needRefreshTree = false
 
 			break
@@ -61,9 +80,9 @@ func (bot *robot) findSigName(org, repo string, pid int, cfg *botConfig, needRef
 			return "", err
 		}
 
-		cfg.reposSig = files
+		bot.sigTrees.set(key, files, sigTreeCacheTTL)
 
-		sigName = bot.fillData(cfg.reposSig, org, repo)
+		sigName = bot.fillData(files, org, repo)
 	}
 
 	return sigName, nil
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// sigDirectiveRe matches the /sig directive taking a line by itself, such
+// as "/sig storage".
+var sigDirectiveRe = regexp.MustCompile(`(?m)^[ \t]*/sig[ \t]+([\p{L}\p{N}][\p{L}\p{N}_.-]*)[ \t]*$`)
+
+type sigDirectiveConfig struct {
+	// Enabled means the sig can be set by the /sig directive in the
+	// description or comments of the merge request or issue, which
+	// overrides the sig inferred. The directive of comment is accepted
+	// from the author and the members of maintainer_roles only.
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+// parseSigDirective returns the sig of the last /sig directive in text. It
+// is empty if there is none.
+func parseSigDirective(text string) string {
+	m := sigDirectiveRe.FindAllStringSubmatch(text, -1)
+	if len(m) == 0 {
+		return ""
+	}
+
+	return m[len(m)-1][1]
+}
+
+// directedSig returns the sig named by the /sig directive in text, if the
+// sig exists.
+func (bot *robot) directedSig(pid int, text string, log *logrus.Entry) string {
+	sig := parseSigDirective(text)
+	if sig == "" {
+		return ""
+	}
+
+	if _, err := bot.getSigInfo(pid, sig); err != nil {
+		log.WithError(err).Warnf("ignore the /sig directive of unknown sig %s", sig)
+
+		return ""
+	}
+
+	return sig
+}
+
+// labelRemover is implemented by the actions which can remove labels.
+type labelRemover interface {
+	removeLabel(label string) error
+}
+
+func (a *mrActions) removeLabel(label string) error {
+	return a.cli.RemoveMergeRequestLabel(a.projectID, a.number, gitlab.Labels{label})
+}
+
+func (a *issueActions) removeLabel(label string) error {
+	return a.cli.RemoveIssueLabels(a.projectID, a.number, gitlab.Labels{label})
+}
+
+// applySigDirective relabels the merge request or issue with the sig
+// directed, and updates the welcome by it. It does nothing if the sig is
+// labeled already.
+func (bot *robot) applySigDirective(
+	org, repo, author, sigName string, pid, number int, meta *itemMeta,
+	acts actions, cfg *botConfig, log *logrus.Entry,
+) error {
+	var labels []string
+	if meta != nil {
+		labels = meta.Labels
+	}
+
+	label := bot.existingSigLabel(sigName, labels, cfg)
+	if containsString(labels, label) {
+		return nil
+	}
+
+	if bot.deferIfStandby(func() error {
+		return bot.applySigDirective(org, repo, author, sigName, pid, number, meta, acts, cfg, log)
+	}, log) {
+		return nil
+	}
+
+	log = log.WithField("sig", sigName)
+	log.Info("the sig is set by the /sig directive, update the welcome")
+
+	if cfg.DryRun {
+		return nil
+	}
+
+	mErr := newMultiError()
+
+	if r, ok := acts.(labelRemover); ok {
+		for _, l := range labels {
+			if sig, ok := bot.sigOfLabel(l, cfg); ok && sig != sigName {
+				mErr.add(r.removeLabel(l))
+			}
+		}
+	}
+
+	if err := bot.createLabelIfNeed(acts, pid, label, log); err != nil {
+		log.WithError(err).Errorf("create label %s", label)
+	}
+	mErr.add(acts.addLabel(label))
+
+	mErr.add(bot.switchSig(org, repo, author, sigName, pid, number, meta, acts, cfg, log))
+
+	return mErr.err()
+}
+
+// canDirectSig reports whether the user can set the sig by the comment,
+// who is the author or a member of maintainer_roles.
+func (bot *robot) canDirectSig(user, author string, pid int, cfg *botConfig) (bool, error) {
+	if user == author {
+		return true, nil
+	}
+
+	return bot.isMaintainer(user, pid, cfg)
+}
+
+// isMaintainer reports whether the user is a member of maintainer_roles of
+// the project.
+func (bot *robot) isMaintainer(user string, pid int, cfg *botConfig) (bool, error) {
+	members, err := bot.cli.ListCollaborators(pid)
+	if err != nil {
+		return false, err
+	}
+
+	return sets.NewString(bot.filterByRoles(members, cfg.MaintainerRoles)...).Has(user), nil
+}
+
+// mrGetter returns the author, metadata and actions of the merge request
+// commented.
+func mrGetter(pid, number int) func(iClient) (string, *itemMeta, actions, error) {
+	return func(cli iClient) (string, *itemMeta, actions, error) {
+		mr, err := cli.GetMergeRequest(pid, number)
+		if err != nil || mr.Author == nil {
+			return "", nil, nil, err
+		}
+
+		return mr.Author.Username, mrMetaOf(mr), &mrActions{cli: cli, projectID: pid, number: number}, nil
+	}
+}
+
+// issueGetter returns the author, metadata and actions of the issue
+// commented.
+func issueGetter(pid, number int) func(iClient) (string, *itemMeta, actions, error) {
+	return func(cli iClient) (string, *itemMeta, actions, error) {
+		issue, err := cli.GetIssue(pid, number)
+		if err != nil || issue.Author == nil {
+			return "", nil, nil, err
+		}
+
+		return issue.Author.Username, issueMetaOf(issue), &issueActions{cli: cli, projectID: pid, number: number}, nil
+	}
+}
+
+// HandleMergeCommentEvent applies the /sig directive and answers the
+// /welcome-info command in the comment of merge request, after the earlier
+// events of it.
+func (bot *robot) HandleMergeCommentEvent(e *gitlab.MergeCommentEvent, log *logrus.Entry) error {
+	if e.ObjectAttributes.System || e.User == nil {
+		return nil
+	}
+
+	return bot.ordering.run(mrTarget(e.ProjectID, e.MergeRequest.IID), func() error {
+		return bot.handleMergeComment(e, log)
+	})
+}
+
+func (bot *robot) handleMergeComment(e *gitlab.MergeCommentEvent, log *logrus.Entry) error {
+	pid, number := e.ProjectID, e.MergeRequest.IID
+	path, user, note := e.Project.PathWithNamespace, e.User.Username, e.ObjectAttributes.Note
+
+	mErr := newMultiError()
+	mErr.add(bot.handleSigDirectiveComment(path, user, note, pid, number, mrGetter(pid, number), log))
+	mErr.add(bot.handleWelcomeInfo(path, user, note, pid, number, mrGetter(pid, number), log))
+
+	return mErr.err()
+}
+
+// HandleIssueCommentEvent applies the /sig directive and answers the
+// /welcome-info command in the comment of issue, and welcomes the human
+// taking over the issue of bot, after the earlier events of it.
+func (bot *robot) HandleIssueCommentEvent(e *gitlab.IssueCommentEvent, log *logrus.Entry) error {
+	if e.ObjectAttributes.System || e.User == nil {
+		return nil
+	}
+
+	return bot.ordering.run(issueTarget(e.ProjectID, e.Issue.IID), func() error {
+		return bot.handleIssueComment(e, log)
+	})
+}
+
+func (bot *robot) handleIssueComment(e *gitlab.IssueCommentEvent, log *logrus.Entry) error {
+	pid, number := e.ProjectID, e.Issue.IID
+	path, user, note := e.Project.PathWithNamespace, e.User.Username, e.ObjectAttributes.Note
+
+	mErr := newMultiError()
+	mErr.add(bot.handleSigDirectiveComment(path, user, note, pid, number, issueGetter(pid, number), log))
+	mErr.add(bot.handleWelcomeInfo(path, user, note, pid, number, issueGetter(pid, number), log))
+	mErr.add(bot.handleHandoff(e, log))
+
+	return mErr.err()
+}
+
+// handleSigDirectiveComment applies the /sig directive in the comment of
+// user. get returns the author, metadata and actions of the item commented,
+// and the author is empty if it can't be found.
+func (bot *robot) handleSigDirectiveComment(
+	path, user, note string, pid, number int,
+	get func(iClient) (string, *itemMeta, actions, error),
+	log *logrus.Entry,
+) error {
+	if parseSigDirective(note) == "" {
+		return nil
+	}
+
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return nil
+	}
+
+	org, repo := path[:i], path[i+1:]
+
+	cfg, err := bot.botConfigFor(org, repo, log)
+	if err != nil || cfg == nil || !cfg.SigDirective.Enabled {
+		return err
+	}
+
+	sigName := bot.directedSig(pid, note, log)
+	if sigName == "" {
+		return nil
+	}
+
+	author, meta, acts, err := get(bot.clientFor(org))
+	if err != nil || author == "" {
+		return err
+	}
+
+	ok, err := bot.canDirectSig(user, author, pid, cfg)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		log.WithField("user", user).Info("the user can't set the sig by the /sig directive")
+
+		return nil
+	}
+
+	// the issue is planned without the number, as it is welcomed.
+	if meta != nil && !meta.IsMergeRequest {
+		number = 0
+	}
+
+	return bot.applySigDirective(org, repo, author, sigName, pid, number, meta, acts, cfg, log)
+}
+
+// handleSigDirectiveUpdate applies the /sig directive added to the
+// description of merge request or issue. number is the one which the item
+// is planned with, which is 0 for the issue.
+func (bot *robot) handleSigDirectiveUpdate(
+	org, repo, author, previous, current string, pid, number int,
+	getMeta func() (*itemMeta, error), acts actions, cfg *botConfig, log *logrus.Entry,
+) error {
+	if !cfg.SigDirective.Enabled {
+		return nil
+	}
+
+	sig := parseSigDirective(current)
+	if previous == current || sig == "" || sig == parseSigDirective(previous) {
+		return nil
+	}
+
+	sigName := bot.directedSig(pid, current, log)
+	if sigName == "" {
+		return nil
+	}
+
+	meta, err := getMeta()
+	if err != nil {
+		return err
+	}
+
+	return bot.applySigDirective(org, repo, author, sigName, pid, number, meta, acts, cfg, log)
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestParseSigDirective(t *testing.T) {
+	cases := map[string]string{
+		"/sig storage":                       "storage",
+		"fix the bug\n  /sig sig-Kernel_2.x": "sig-Kernel_2.x",
+		"/sig 基础设施":                          "基础设施",
+		"/sig a\n/sig b":                     "b",
+		"/sig -storage":                      "",
+		"/sig storage please":                "",
+		"see /sig storage":                   "",
+	}
+
+	for text, want := range cases {
+		if got := parseSigDirective(text); got != want {
+			t.Errorf("parseSigDirective(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+const defaultSigFileName = "welcome.yaml"
+
+type sigFileConfig struct {
+	// Enabled means to read the file of each sig in the community repo,
+	// by which the sig tunes the welcomes of its repos by itself without
+	// changing the config of robot.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Name is the name of file in the directory of sig. The default value
+	// is welcome.yaml.
+	Name string `json:"name,omitempty"`
+}
+
+func (c *sigFileConfig) setDefault() {
+	if c.Name == "" {
+		c.Name = defaultSigFileName
+	}
+}
+
+func (c *sigFileConfig) path(sig string) string {
+	return fmt.Sprintf("sig/%s/%s", sig, c.Name)
+}
+
+// sigLinks are the quick links of sig which override the ones of sig-info.
+type sigLinks struct {
+	MailingList string `json:"mailing_list,omitempty"`
+	ChatURL     string `json:"chat_url,omitempty"`
+	MeetingURL  string `json:"meeting_url,omitempty"`
+	CalendarURL string `json:"calendar_url,omitempty"`
+	DocsURL     string `json:"docs_url,omitempty"`
+}
+
+// overlay returns a copy of info whose links are replaced by the ones set.
+func (l *sigLinks) overlay(info *SigInfos) *SigInfos {
+	v := *info
+
+	set := func(dst *string, s string) {
+		if s != "" {
+			*dst = s
+		}
+	}
+
+	set(&v.MailingList, l.MailingList)
+	set(&v.ChatURL, l.ChatURL)
+	set(&v.MeetingURL, l.MeetingURL)
+	set(&v.CalendarURL, l.CalendarURL)
+	set(&v.DocsURL, l.DocsURL)
+
+	return &v
+}
+
+// sigFile is the file in the directory of sig, which is protected as the
+// other files of sig in the community repo. The fields set in it take
+// precedence over the central config for the repos of sig.
+type sigFile struct {
+	// Repos are the repos, as org/repo, the file applies to. It applies
+	// to all the repos of sig if empty.
+	Repos []string `json:"repos,omitempty"`
+
+	// Mentions are the usernames mentioned instead of the maintainers and
+	// committers of sig.
+	Mentions []string `json:"mentions,omitempty"`
+
+	// Links override the quick links of sig-info.
+	Links sigLinks `json:"links,omitempty"`
+
+	// WelcomeTemplate replaces the welcome_template of central config.
+	WelcomeTemplate string `json:"welcome_template,omitempty"`
+}
+
+func (f *sigFile) validate() error {
+	for _, v := range f.Mentions {
+		if v == "" || escapeUsername(v) != v {
+			return fmt.Errorf("invalid username to mention: %q", v)
+		}
+	}
+
+	for _, v := range []string{
+		f.Links.ChatURL, f.Links.MeetingURL, f.Links.CalendarURL, f.Links.DocsURL,
+	} {
+		if v != "" && sanitizeURL(v) == "" {
+			return fmt.Errorf("unsupported link: %s", v)
+		}
+	}
+
+	if f.WelcomeTemplate != "" {
+		if _, err := parseTemplate(f.WelcomeTemplate); err != nil {
+			return fmt.Errorf("invalid welcome_template: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (f *sigFile) appliesTo(org, repo string) bool {
+	return len(f.Repos) == 0 || containsString(f.Repos, org+"/"+repo)
+}
+
+// apply returns the members to mention and the config tuned by the file.
+func (f *sigFile) apply(maintainers, committers []string, cfg *botConfig) ([]string, []string, *botConfig) {
+	c := *cfg
+
+	// the members of file are mentioned instead of the group of sig too.
+	if len(f.Mentions) > 0 {
+		maintainers, committers = f.Mentions, nil
+		c.Mention.Groups = nil
+		c.Mention.GroupMention = nil
+	}
+
+	if f.WelcomeTemplate != "" {
+		c.WelcomeTemplate = f.WelcomeTemplate
+		c.Templates = nil
+	}
+
+	return maintainers, committers, &c
+}
+
+// getSigFile returns the file of sig, which is nil if it does not exist.
+// Both are cached as the other files of sig.
+func (bot *robot) getSigFile(pid int, sig string, cfg *sigFileConfig) (*sigFile, error) {
+	key := fileCacheKey(pid, cfg.path(sig), sigFileBranch)
+	if v, ok := bot.sigFiles.get(key); ok {
+		return v.(*sigFile), nil
+	}
+
+	var r *sigFile
+
+	f, err := bot.getPathContent(pid, cfg.path(sig), sigFileBranch)
+	if err != nil {
+		if !isNotFound(err) {
+			return nil, err
+		}
+	} else {
+		b, err := bot.fileContent(f)
+		if err != nil {
+			return nil, err
+		}
+
+		r = new(sigFile)
+		if err := yaml.Unmarshal(b, r); err != nil {
+			return nil, newConfigError(err)
+		}
+
+		if err := r.validate(); err != nil {
+			return nil, newConfigError(err)
+		}
+	}
+
+	bot.sigFiles.set(key, r, fileCacheTTL)
+
+	return r, nil
+}
+
+// sigFileFor returns the file of sig applying to the repo. It is nil if
+// the file is disabled, missing or invalid, so that the central config is
+// used as it is.
+func (bot *robot) sigFileFor(org, repo, sig string, pid int, cfg *botConfig, log *logrus.Entry) *sigFile {
+	if !cfg.SigFile.Enabled || sig == "" {
+		return nil
+	}
+
+	f, err := bot.getSigFile(pid, sig, &cfg.SigFile)
+	if err != nil {
+		log.WithError(err).Errorf("load the sig file %s, ignore it", cfg.SigFile.path(sig))
+
+		return nil
+	}
+
+	if f == nil || !f.appliesTo(org, repo) {
+		return nil
+	}
+
+	return f
+}
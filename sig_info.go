@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/base64"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/yaml"
 )
@@ -12,10 +11,17 @@ type SigInfos struct {
 	Description  string       `json:"description,omitempty"`
 	MailingList  string       `json:"mailing_list,omitempty"`
 	MeetingURL   string       `json:"meeting_url,omitempty"`
+	ChatURL      string       `json:"chat_url,omitempty"`
+	CalendarURL  string       `json:"calendar_url,omitempty"`
+	DocsURL      string       `json:"docs_url,omitempty"`
 	MatureLevel  string       `json:"mature_level,omitempty"`
 	Mentors      []Mentor     `json:"mentors,omitempty"`
 	Maintainers  []Maintainer `json:"maintainers,omitempty"`
 	Repositories []RepoAdmin  `json:"repositories,omitempty"`
+
+	Reviewers        []Maintainer `json:"reviewers,omitempty"`
+	Triagers         []Maintainer `json:"triagers,omitempty"`
+	SecurityContacts []Maintainer `json:"security_contacts,omitempty"`
 }
 
 // Maintainer struct.
@@ -66,20 +72,23 @@ type Admin struct {
 	Email        string `json:"email,omitempty"`
 }
 
-func decodeSigInfoFile(content string) (sets.String, sets.String) {
-	maintainers := sets.NewString()
+func parseSigInfoFile(content []byte) (*SigInfos, error) {
+	var m SigInfos
 
-	c, err := base64.StdEncoding.DecodeString(content)
-	if err != nil {
-		return nil, nil
+	if err := yaml.Unmarshal(content, &m); err != nil {
+		return nil, err
 	}
 
-	var m SigInfos
+	return &m, nil
+}
 
-	if err = yaml.Unmarshal(c, &m); err != nil {
+func decodeSigInfoFile(content []byte) (sets.String, sets.String) {
+	m, err := parseSigInfoFile(content)
+	if err != nil {
 		return nil, nil
 	}
 
+	maintainers := sets.NewString()
 	committers := sets.NewString()
 
 	for _, v := range m.Maintainers {
@@ -105,4 +114,8 @@ type FileOwner struct {
 	// Path can be a file name or a dir name
 	Path  []string     `json:"path" required:"true"`
 	Owner []Maintainer `json:"owner,omitempty"`
+
+	// Labels are added to the merge requests changing the path when the
+	// robot welcomes by the ownership only.
+	Labels []string `json:"labels,omitempty"`
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type sigPath struct {
+	// Prefix is the directory prefix of the files owned by Sig, such as
+	// drivers/net/.
+	Prefix string `json:"prefix" required:"true"`
+	Sig    string `json:"sig" required:"true"`
+}
+
+func (p *sigPath) validate() error {
+	if p.Prefix == "" || p.Sig == "" {
+		return fmt.Errorf("prefix and sig of sig_paths must be set")
+	}
+
+	return nil
+}
+
+// sigOfPath returns the sig owning the changed file. The one with the
+// longest matching prefix takes precedence. It is empty if none matches.
+func sigOfPath(file string, paths []sigPath) string {
+	r, n := "", -1
+
+	for i := range paths {
+		if p := &paths[i]; strings.HasPrefix(file, p.Prefix) && len(p.Prefix) > n {
+			r, n = p.Sig, len(p.Prefix)
+		}
+	}
+
+	return r
+}
+
+// inferSigs returns the sigs owning the changed files, ordered by the
+// number of files each of them owns. The first one is the primary sig.
+func inferSigs(changes []string, paths []sigPath) []string {
+	counts := make(map[string]int)
+
+	for _, f := range changes {
+		if s := sigOfPath(f, paths); s != "" {
+			counts[s]++
+		}
+	}
+
+	r := make([]string, 0, len(counts))
+	for s := range counts {
+		r = append(r, s)
+	}
+
+	sort.Slice(r, func(i, j int) bool {
+		if counts[r[i]] != counts[r[j]] {
+			return counts[r[i]] > counts[r[j]]
+		}
+
+		return r[i] < r[j]
+	})
+
+	return r
+}
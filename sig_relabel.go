@@ -0,0 +1,194 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+// swappedSig returns the sig of the sig label added in place of another
+// sig label.
+func (bot *robot) swappedSig(previous, current []*gitlab.EventLabel, cfg *botConfig) (string, bool) {
+	sigsOf := func(labels []*gitlab.EventLabel) map[string]bool {
+		r := make(map[string]bool)
+		for _, l := range labels {
+			if l == nil {
+				continue
+			}
+
+			if sig, ok := bot.sigOfLabel(l.Title, cfg); ok && sig != "" {
+				r[sig] = true
+			}
+		}
+
+		return r
+	}
+
+	prev, cur := sigsOf(previous), sigsOf(current)
+
+	removed := false
+	for sig := range prev {
+		if !cur[sig] {
+			removed = true
+
+			break
+		}
+	}
+
+	if !removed {
+		return "", false
+	}
+
+	for sig := range cur {
+		if !prev[sig] {
+			return sig, true
+		}
+	}
+
+	return "", false
+}
+
+// replaceWelcome replaces the welcome comment, which ends with its footer,
+// in the body of note.
+func replaceWelcome(body, comment, mode string) (string, bool) {
+	i := strings.Index(body, commentFooterPrefix)
+	if i < 0 {
+		return "", false
+	}
+
+	end := strings.Index(body[i:], "-->")
+	if end < 0 {
+		return "", false
+	}
+	end += i + len("-->")
+
+	start := 0
+	if mode == placementSummary {
+		if j := strings.LastIndex(body[:i], summarySeparator); j >= 0 {
+			start = j + len(summarySeparator)
+		}
+	}
+
+	return body[:start] + comment + "\n\n" + commentFooter(comment) + body[end:], true
+}
+
+// handleSigRelabel updates the welcome comment and reassigns the merge
+// request when a maintainer swaps the sig label, so that they reflect the
+// new sig.
+func (bot *robot) handleSigRelabel(
+	org, repo, author string, pid, number int,
+	previous, current []*gitlab.EventLabel,
+	acts actions, cfg *botConfig, log *logrus.Entry,
+) error {
+	sigName, ok := bot.swappedSig(previous, current, cfg)
+	if !ok {
+		return nil
+	}
+
+	if bot.deferIfStandby(func() error {
+		return bot.handleSigRelabel(org, repo, author, pid, number, previous, current, acts, cfg, log)
+	}, log) {
+		return nil
+	}
+
+	log = log.WithField("sig", sigName)
+	log.Info("the sig label is changed, update the welcome")
+
+	var meta *itemMeta
+	if number > 0 {
+		v, err := bot.getMRMeta(pid, number)
+		if err != nil {
+			log.WithError(err).Error("get the metadata of merge request")
+		}
+		meta = v
+	}
+
+	return bot.switchSig(org, repo, author, sigName, pid, number, meta, acts, cfg, log)
+}
+
+// switchSig regenerates the welcome for the sig, and updates the comment,
+// reviewers and assignees by it. The number is 0 for the issue.
+func (bot *robot) switchSig(
+	org, repo, author, sigName string, pid, number int, meta *itemMeta,
+	acts actions, cfg *botConfig, log *logrus.Entry,
+) error {
+	plan, err := bot.genPlanForSig(org, repo, author, sigName, number, pid, nil, meta, cfg, log)
+	if err != nil {
+		return err
+	}
+
+	if cfg.DryRun || plan.Comment == "" {
+		return nil
+	}
+
+	if err := bot.updateWelcome(plan.Comment, acts, cfg, log); err != nil {
+		return err
+	}
+
+	bot.mentions.record(plan.Notifications)
+
+	if err := bot.setReviewers(plan, acts, log); err != nil {
+		return err
+	}
+
+	if !plan.Assign {
+		return nil
+	}
+
+	// the assignee of policy stays, while need_assign follows the sig.
+	if len(plan.Assignees) > 0 {
+		return acts.assign(plan.Assignees)
+	}
+
+	return acts.assign(bot.userIDs(plan.Notifications, log))
+}
+
+// updateWelcome replaces the welcome comment posted before with comment.
+func (bot *robot) updateWelcome(comment string, acts actions, cfg *botConfig, log *logrus.Entry) error {
+	editor, ok := acts.(noteEditor)
+	if !ok {
+		return nil
+	}
+
+	if cfg.Placement.Mode == placementCard {
+		return bot.writeCardSection(editor, acts, welcomeCardSection, comment+"\n\n"+commentFooter(comment))
+	}
+
+	notes, err := editor.listNotes()
+	if err != nil {
+		return err
+	}
+
+	for _, n := range notes {
+		if n.System {
+			continue
+		}
+
+		if body, ok := replaceWelcome(n.Body, comment, cfg.Placement.Mode); ok {
+			return editor.updateNote(n.ID, body)
+		}
+	}
+
+	log.Info("no welcome comment to update")
+
+	return nil
+}
+
+// userIDs returns the ids of users. The ones which can't be found, such as
+// the groups, are skipped.
+func (bot *robot) userIDs(usernames []string, log *logrus.Entry) []int {
+	r := make([]int, 0, len(usernames))
+	for _, u := range usernames {
+		id, err := bot.cli.GetUserID(u)
+		if err != nil {
+			log.WithError(err).WithField("user", u).Warn("get the id of user")
+
+			continue
+		}
+
+		r = append(r, id)
+	}
+
+	return r
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	sigSourceRepo = "repo"
+	sigSourceREST = "rest"
+)
+
+type sigSourceConfig struct {
+	// Type is where the sig of repo and the members of sig are read from.
+	// It can be repo which reads the files of the community repo, or rest
+	// which queries the community metadata service at URL. The default
+	// value is repo.
+	Type string `json:"type,omitempty"`
+
+	// URL is the base URL of the metadata service. It serves
+	// GET {url}/repos?path={org}/{repo} responding {"sig": "storage"} and
+	// GET {url}/sigs/{sig} responding {"maintainers": [], "committers": []}.
+	URL string `json:"url,omitempty"`
+
+	// CacheTTL is the seconds to cache the responses of the service. The
+	// default value is 600.
+	CacheTTL int `json:"cache_ttl,omitempty"`
+}
+
+func (c *sigSourceConfig) setDefault() {
+	if c.Type == "" {
+		c.Type = sigSourceRepo
+	}
+
+	if c.CacheTTL <= 0 {
+		c.CacheTTL = 600
+	}
+}
+
+func (c *sigSourceConfig) validate() error {
+	switch c.Type {
+	case sigSourceRepo:
+		return nil
+
+	case sigSourceREST:
+		if c.URL == "" || sanitizeURL(c.URL) == "" {
+			return fmt.Errorf("url of sig_source must be set to an http or https url for rest")
+		}
+
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported type of sig_source: %s", c.Type)
+	}
+}
+
+// sigDataProvider tells the sig of repo and the members of sig.
+type sigDataProvider interface {
+	sigOfRepo(org, repo string, pid int) (string, error)
+	sigMembers(sig string, pid int) ([]string, []string, error)
+}
+
+// sigDataOf returns the provider of the sig data chosen by the community.
+func (bot *robot) sigDataOf(cfg *botConfig) sigDataProvider {
+	if cfg.SigSource.Type == sigSourceREST {
+		return &restSigData{
+			hc:    bot.hc,
+			url:   strings.TrimSuffix(cfg.SigSource.URL, "/"),
+			cache: bot.sigData,
+			ttl:   time.Duration(cfg.SigSource.CacheTTL) * time.Second,
+		}
+	}
+
+	return &repoSigData{bot: bot, cfg: cfg}
+}
+
+// repoSigData reads the sig data from the files of the community repo.
+type repoSigData struct {
+	bot *robot
+	cfg *botConfig
+}
+
+func (d *repoSigData) sigOfRepo(org, repo string, pid int) (string, error) {
+	return d.bot.findSigName(org, repo, pid, d.cfg, true)
+}
+
+func (d *repoSigData) sigMembers(sig string, pid int) ([]string, []string, error) {
+	f, err := d.bot.getPathContent(pid, sigInfoFile(sig), sigFileBranch)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := d.bot.fileContent(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	maintainers, committers := decodeSigInfoFile(content)
+
+	return maintainers.UnsortedList(), committers.UnsortedList(), nil
+}
+
+// restSigData queries the sig data of the community metadata service.
+type restSigData struct {
+	hc    *http.Client
+	url   string
+	cache *expiringCache
+	ttl   time.Duration
+}
+
+// get decodes the response of the service to v, which is left as it is if
+// the service doesn't know the object.
+func (d *restSigData) get(u string, v interface{}) error {
+	resp, err := d.hc.Get(u)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("query the sig data service, status code: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return newDataFormatError(err)
+	}
+
+	return nil
+}
+
+func (d *restSigData) sigOfRepo(org, repo string, pid int) (string, error) {
+	u := fmt.Sprintf("%s/repos?path=%s", d.url, url.QueryEscape(org+"/"+repo))
+	if v, ok := d.cache.get(u); ok {
+		return v.(string), nil
+	}
+
+	var v struct {
+		Sig string `json:"sig"`
+	}
+
+	if err := d.get(u, &v); err != nil {
+		return "", err
+	}
+
+	d.cache.set(u, v.Sig, d.ttl)
+
+	return v.Sig, nil
+}
+
+type sigMembersResponse struct {
+	Maintainers []string `json:"maintainers"`
+	Committers  []string `json:"committers"`
+}
+
+func (d *restSigData) sigMembers(sig string, pid int) ([]string, []string, error) {
+	u := fmt.Sprintf("%s/sigs/%s", d.url, url.PathEscape(sig))
+	if v, ok := d.cache.get(u); ok {
+		r := v.(*sigMembersResponse)
+
+		return r.Maintainers, r.Committers, nil
+	}
+
+	r := new(sigMembersResponse)
+	if err := d.get(u, r); err != nil {
+		return nil, nil, err
+	}
+
+	d.cache.set(u, r, d.ttl)
+
+	return r.Maintainers, r.Committers, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	soleMaintainerOrgContacts  = "org_contacts"
+	soleMaintainerSkipMentions = "skip_mentions"
+	soleMaintainerTemplate     = "template"
+)
+
+// isSoleMaintainer reports whether the author is the only maintainer or
+// committer found, to whom the welcome would tell to contact themselves.
+func isSoleMaintainer(author string, maintainers, committers []string) bool {
+	all := sets.NewString(maintainers...).Insert(committers...)
+
+	return all.Len() == 1 && all.Has(author)
+}
+
+type soleMaintainerConfig struct {
+	// Action is how to welcome the author who is the sole maintainer. It
+	// can be org_contacts, which mentions the Contacts instead; skip_mentions,
+	// which posts the minimal welcome; or template, which renders the
+	// Template instead. Nothing changes if it is not set.
+	Action string `json:"action,omitempty"`
+
+	// Contacts are the org-level contacts for org_contacts.
+	Contacts []string `json:"contacts,omitempty"`
+
+	// Template is the go template of welcome comment for template, which
+	// can use the same variables as welcome_template.
+	Template string `json:"template,omitempty"`
+}
+
+func (c *soleMaintainerConfig) validate() error {
+	switch c.Action {
+	case "", soleMaintainerSkipMentions:
+	case soleMaintainerOrgContacts:
+		if len(c.Contacts) == 0 {
+			return fmt.Errorf("contacts of sole_maintainer must be set for %s", c.Action)
+		}
+	case soleMaintainerTemplate:
+		if c.Template == "" {
+			return fmt.Errorf("template of sole_maintainer must be set for %s", c.Action)
+		}
+
+		if _, err := parseTemplate(c.Template); err != nil {
+			return fmt.Errorf("invalid template of sole_maintainer: %v", err)
+		}
+	default:
+		return fmt.Errorf("unsupported action of sole_maintainer: %s", c.Action)
+	}
+
+	return nil
+}
+
+// apply returns the maintainers, committers and config to generate the
+// welcome with, when the author is the sole maintainer.
+func (c *soleMaintainerConfig) apply(maintainers, committers []string, cfg *botConfig) ([]string, []string, *botConfig) {
+	switch c.Action {
+	case soleMaintainerOrgContacts:
+		return c.Contacts, nil, cfg
+
+	case soleMaintainerTemplate:
+		v := *cfg
+		v.WelcomeTemplate = c.Template
+		v.Templates = nil
+
+		return maintainers, committers, &v
+
+	default:
+		return maintainers, committers, cfg
+	}
+}
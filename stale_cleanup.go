@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	staleActionRemove   = "remove"
+	staleActionFollowup = "followup"
+
+	followupMessage = `
+Hi ***%s***, this was closed a while ago. Thanks again for your interest in the %s Community, and come back anytime!`
+)
+
+// staleWelcome is a merge request or an issue which is labeled as newcomer
+// and has been closed without merge for a long time.
+type staleWelcome struct {
+	number int
+	author string
+	isMR   bool
+}
+
+// cleanupStaleWelcomes runs the cleanup of stale welcomes every interval.
+// Only the leader runs it if the leader election is enabled.
+func (bot *robot) cleanupStaleWelcomes(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for range t.C {
+		if bot.elector != nil && !bot.elector.isLeader() {
+			continue
+		}
+
+		c, err := bot.getConfig()
+		if err != nil {
+			logrus.WithError(err).Error("get config to cleanup stale welcomes")
+
+			continue
+		}
+
+		for i := range c.ConfigItems {
+			cfg := &c.ConfigItems[i]
+			if !cfg.StaleCleanup.Enabled {
+				continue
+			}
+
+			for _, repo := range cfg.Repos {
+				// only the repos like org/repo can be scanned.
+				if strings.Count(repo, "/") != 1 {
+					continue
+				}
+
+				log := logrus.WithField("stale-cleanup", repo)
+				if err := bot.cleanupRepo(repo, cfg, log); err != nil {
+					log.WithError(err).Error("cleanup stale welcomes")
+				}
+			}
+		}
+	}
+}
+
+func (bot *robot) cleanupRepo(repo string, cfg *botConfig, log *logrus.Entry) error {
+	pid, err := bot.cli.GetProjectID(repo)
+	if err != nil {
+		return err
+	}
+
+	before := time.Now().Add(-time.Duration(cfg.StaleCleanup.Days) * 24 * time.Hour)
+
+	var items []staleWelcome
+
+	mrs, err := bot.cli.ListClosedMergeRequests(pid, newcomerLabel, before)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range mrs {
+		// the state of merge request merged is not closed.
+		if v.ClosedAt != nil && v.ClosedAt.Before(before) && v.Author != nil {
+			items = append(items, staleWelcome{number: v.IID, author: v.Author.Username, isMR: true})
+		}
+	}
+
+	issues, err := bot.cli.ListClosedIssues(pid, newcomerLabel, before)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range issues {
+		if v.ClosedAt != nil && v.ClosedAt.Before(before) && v.Author != nil {
+			items = append(items, staleWelcome{number: v.IID, author: v.Author.Username})
+		}
+	}
+
+	// the stale welcomes are cleaned up by the bot identity which posted
+	// them.
+	cli := bot.clientFor(repo[:strings.Index(repo, "/")])
+
+	for _, item := range items {
+		l := log.WithField("number", item.number)
+
+		if cfg.DryRun {
+			l.Infof("%s the stale welcome", cfg.StaleCleanup.Action)
+
+			continue
+		}
+
+		if err := bot.cleanupStaleWelcome(cli, pid, &item, cfg); err != nil {
+			l.WithError(err).Error("cleanup the stale welcome")
+		}
+	}
+
+	return nil
+}
+
+// cleanupStaleWelcome removes the newcomer label, so that it is not handled
+// again, and then deletes the welcome comment or posts the follow-up.
+func (bot *robot) cleanupStaleWelcome(cli iClient, pid int, item *staleWelcome, cfg *botConfig) error {
+	labels := gitlab.Labels{newcomerLabel}
+
+	var err error
+	if item.isMR {
+		err = cli.RemoveMergeRequestLabel(pid, item.number, labels)
+	} else {
+		err = cli.RemoveIssueLabels(pid, item.number, labels)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if cfg.StaleCleanup.Action == staleActionFollowup {
+		comment := fmt.Sprintf(followupMessage, escapeUsername(item.author), cfg.CommunityName)
+
+		if item.isMR {
+			return cli.CreateMergeRequestComment(pid, item.number, comment)
+		}
+
+		return cli.CreateIssueComment(pid, item.number, comment)
+	}
+
+	return bot.deleteWelcomeComments(cli, pid, item)
+}
+
+// deleteWelcomeComments deletes the notes posted as the welcome comment.
+// The summary note and card shared with other robots are kept.
+func (bot *robot) deleteWelcomeComments(cli iClient, pid int, item *staleWelcome) error {
+	var notes []*gitlab.Note
+	var err error
+
+	if item.isMR {
+		notes, err = bot.cli.ListMergeRequestNotes(pid, item.number)
+	} else {
+		notes, err = bot.cli.ListIssueNotes(pid, item.number)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, n := range notes {
+		if n.System || !strings.Contains(n.Body, commentFooterPrefix) || strings.HasPrefix(n.Body, "<!--") {
+			continue
+		}
+
+		if item.isMR {
+			err = cli.DeleteMergeRequestNote(pid, item.number, n.ID)
+		} else {
+			err = cli.DeleteIssueNote(pid, item.number, n.ID)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	stepResolveSig     = "resolve_sig"
+	stepGetMaintainers = "get_maintainers"
+	stepAssign         = "assign"
+	stepSetReviewers   = "set_reviewers"
+	stepReact          = "react"
+	stepComment        = "comment"
+	stepCreateLabel    = "create_label"
+	stepAddLabel       = "add_label"
+
+	stepResultOK       = "ok"
+	stepResultFailed   = "failed"
+	stepResultDegraded = "degraded"
+	stepResultSkipped  = "skipped"
+	stepResultQueued   = "queued"
+)
+
+var stepsTotal = newCounterVec(
+	"welcome_steps_total",
+	"Number of the steps of handling an event by result.",
+	"step", "result",
+)
+
+// errBudgetExhausted is the reason of the steps skipped because the error
+// budget of project is exhausted.
+var errBudgetExhausted = errors.New("error budget is exhausted")
+
+// stepResult is the result of a step of handling an event.
+type stepResult struct {
+	Step   string `json:"step"`
+	Target string `json:"target,omitempty"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// stepReport collects the results of the steps executed for an event. A
+// fatal step failing fails the event, while a degradable one only makes
+// it a partial success. The steps are independent, so a failed one
+// doesn't stop the others.
+type stepReport struct {
+	results []stepResult
+	fatal   *multiError
+	failed  int
+}
+
+func newStepReport() *stepReport {
+	return &stepReport{fatal: newMultiError()}
+}
+
+// run runs the step f and retries it up to retries times on failure.
+func (r *stepReport) run(step, target string, fatal bool, retry *stepRetryConfig, f func() error, log *logrus.Entry) error {
+	err := f()
+	for i := 1; err != nil && i <= retry.Retries; i++ {
+		log.WithError(err).Warnf("step %s failed, retry %d/%d", step, i, retry.Retries)
+
+		time.Sleep(time.Duration(retry.Interval) * time.Second)
+
+		err = f()
+	}
+
+	if err == nil {
+		r.add(step, target, stepResultOK, nil)
+
+		return nil
+	}
+
+	r.failed++
+
+	if fatal {
+		r.fatal.add(err)
+		r.add(step, target, stepResultFailed, err)
+	} else {
+		r.add(step, target, stepResultDegraded, err)
+	}
+
+	return err
+}
+
+func (r *stepReport) skip(step, target string, reason error) {
+	r.add(step, target, stepResultSkipped, reason)
+}
+
+func (r *stepReport) add(step, target, result string, err error) {
+	v := stepResult{Step: step, Target: target, Result: result}
+	if err != nil {
+		v.Error = err.Error()
+	}
+
+	r.results = append(r.results, v)
+
+	stepsTotal.inc(step, result)
+}
+
+// addDegraded adds the steps of planning which degraded.
+func (r *stepReport) addDegraded(v []stepResult) {
+	for i := range v {
+		r.failed++
+		r.results = append(r.results, v[i])
+
+		stepsTotal.inc(v[i].Step, v[i].Result)
+	}
+}
+
+// err returns the errors of the fatal steps.
+func (r *stepReport) err() error {
+	return r.fatal.err()
+}
+
+// partial reports whether some steps failed but the others succeeded.
+func (r *stepReport) partial() bool {
+	return r.failed > 0 && r.failed < len(r.results)
+}
+
+func (r *stepReport) log(log *logrus.Entry) {
+	entry := log.WithFields(logrus.Fields{
+		"steps":   r.results,
+		"partial": r.partial(),
+	})
+
+	if r.failed > 0 {
+		entry.Warn("some steps of welcome failed")
+	} else {
+		entry.Info("all steps of welcome succeeded")
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	placementCard = "card"
+
+	summaryCardMarker = "<!-- robot-summary-card -->"
+	summaryCardTitle  = "### Robot Summary"
+
+	welcomeCardSection = botName
+
+	cardKindMergeRequest = "merge_request"
+	cardKindIssue        = "issue"
+)
+
+var cardSectionName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+func cardSectionStart(name string) string {
+	return fmt.Sprintf("<!-- section:%s -->", name)
+}
+
+func cardSectionEnd(name string) string {
+	return fmt.Sprintf("<!-- /section:%s -->", name)
+}
+
+// upsertCardSection replaces the content of the section in the summary
+// card, or appends the section if it does not exist. The other sections
+// are kept as they are.
+func upsertCardSection(card, name, content string) string {
+	start, end := cardSectionStart(name), cardSectionEnd(name)
+	section := fmt.Sprintf("%s\n#### %s\n%s\n%s", start, name, strings.TrimSpace(content), end)
+
+	if i := strings.Index(card, start); i >= 0 {
+		if j := strings.Index(card[i:], end); j >= 0 {
+			return card[:i] + section + card[i+j+len(end):]
+		}
+	}
+
+	return strings.TrimRight(card, "\n") + "\n\n" + section
+}
+
+// writeCardSection writes the section into the summary card note, which is
+// created if it does not exist. The updates are serialized, so that the
+// sections written concurrently are not lost.
+func (bot *robot) writeCardSection(editor noteEditor, acts actions, name, content string) error {
+	bot.cardLock.Lock()
+	defer bot.cardLock.Unlock()
+
+	notes, err := editor.listNotes()
+	if err != nil {
+		return err
+	}
+
+	for _, n := range notes {
+		if !n.System && strings.HasPrefix(n.Body, summaryCardMarker) {
+			return editor.updateNote(n.ID, upsertCardSection(n.Body, name, content))
+		}
+	}
+
+	card := summaryCardMarker + "\n" + summaryCardTitle
+
+	return acts.addComment(upsertCardSection(card, name, content))
+}
+
+type cardSectionRequest struct {
+	ProjectID int    `json:"project_id"`
+	Kind      string `json:"kind"`
+	Number    int    `json:"number"`
+	Section   string `json:"section"`
+	Content   string `json:"content"`
+}
+
+func (req *cardSectionRequest) validate() error {
+	if req.ProjectID <= 0 || req.Number <= 0 {
+		return fmt.Errorf("project_id and number must be set")
+	}
+
+	if req.Kind != cardKindMergeRequest && req.Kind != cardKindIssue {
+		return fmt.Errorf("unsupported kind: %s", req.Kind)
+	}
+
+	if !cardSectionName.MatchString(req.Section) {
+		return fmt.Errorf("invalid section: %s", req.Section)
+	}
+
+	return nil
+}
+
+// summaryCardHandler lets the other robots write their sections into the
+// summary card of a merge request or an issue.
+func (bot *robot) summaryCardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	var req cardSectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	a := gitlabActions{cli: bot.cli, projectID: req.ProjectID, number: req.Number}
+
+	var acts interface {
+		actions
+		noteEditor
+	}
+	if req.Kind == cardKindMergeRequest {
+		acts = (*mrActions)(&a)
+	} else {
+		acts = (*issueActions)(&a)
+	}
+
+	if err := bot.writeCardSection(acts, acts, req.Section, req.Content); err != nil {
+		logrus.WithError(err).WithField("section", req.Section).Error("write the section of summary card")
+
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	systemHookProjectCreate = "project_create"
+	systemHookUserCreate    = "user_create"
+)
+
+var systemHookEventsTotal = newCounterVec(
+	"welcome_system_hook_events_total",
+	"Number of the system hook events of GitLab by event name.",
+	"event",
+)
+
+// systemHookEvent is the payload of the project_create and user_create
+// events of the system hooks of self-hosted GitLab, which have no
+// object_kind but event_name.
+type systemHookEvent struct {
+	EventName         string `json:"event_name"`
+	ProjectID         int    `json:"project_id"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	Username          string `json:"username"`
+}
+
+type bootstrapConfig struct {
+	// Enabled means to set up the projects created in the repos of the
+	// config, which are told by the system hooks of GitLab.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// SyncLabels creates the labels of label_taxonomy in the project.
+	SyncLabels bool `json:"sync_labels,omitempty"`
+
+	// WebhookURL is the URL of the webhook of robot, which is registered
+	// to the project with the secret of bootstrap-webhook-secret-file if
+	// the project has none of it. It is not registered if not set.
+	WebhookURL string `json:"webhook_url,omitempty"`
+}
+
+func (c *bootstrapConfig) validate() error {
+	if c.WebhookURL != "" && sanitizeURL(c.WebhookURL) == "" {
+		return fmt.Errorf("unsupported webhook_url of bootstrap: %s", c.WebhookURL)
+	}
+
+	return nil
+}
+
+func systemHookEventOf(payload []byte) (*systemHookEvent, error) {
+	e := new(systemHookEvent)
+	if err := decodeEvent(payload, e); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// systemHookHandler returns the handler of the system hook event. It is nil
+// if the event is not supported.
+func (bot *robot) systemHookHandler(payload []byte, log *logrus.Entry) (func() error, error) {
+	// the system hooks bootstrap the projects, so they are accepted only
+	// from the webhook whose secret token is verified.
+	if !webhookOutcomeOf(log).isVerified() {
+		log.Warn("refuse the system hook which is not verified by the secret token")
+
+		return nil, nil
+	}
+
+	e, err := systemHookEventOf(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.EventName {
+	case systemHookProjectCreate:
+		systemHookEventsTotal.inc(e.EventName)
+
+		return func() error { return bot.handleProjectCreate(e, log) }, nil
+
+	case systemHookUserCreate:
+		// the new user is counted only, who is welcomed on the first
+		// contribution as the others.
+		systemHookEventsTotal.inc(e.EventName)
+
+		return nil, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// handleProjectCreate sets up the project created, or suggests the config
+// of it if it is not configured.
+func (bot *robot) handleProjectCreate(e *systemHookEvent, log *logrus.Entry) error {
+	i := strings.LastIndex(e.PathWithNamespace, "/")
+	if i < 0 {
+		return nil
+	}
+
+	org, repo := e.PathWithNamespace[:i], e.PathWithNamespace[i+1:]
+	log = log.WithFields(logrus.Fields{"org": org, "repo": repo})
+
+	c, err := bot.getConfig()
+	if err != nil {
+		return newConfigError(err)
+	}
+
+	cfg := c.configFor(org, repo)
+	if cfg == nil {
+		s, err := suggestConfig(c, org, repo)
+		if err != nil {
+			return err
+		}
+
+		bot.unconfigured.record(org, repo)
+		log.Infof("the new project is not configured, suggest the config:\n%s", s)
+
+		return nil
+	}
+
+	if !cfg.Bootstrap.Enabled {
+		return nil
+	}
+
+	if bot.deferIfStandby(func() error { return bot.handleProjectCreate(e, log) }, log) {
+		return nil
+	}
+
+	mErr := newMultiError()
+
+	if cfg.Bootstrap.SyncLabels {
+		mErr.add(bot.syncLabels(org, e.ProjectID, cfg.DryRun, cfg, log))
+	}
+
+	if cfg.Bootstrap.WebhookURL != "" {
+		mErr.add(bot.registerWebhook(org, e.ProjectID, cfg, log))
+	}
+
+	return mErr.err()
+}
+
+// registerWebhook adds the webhook of robot to the project unless it has
+// one of the same URL.
+func (bot *robot) registerWebhook(org string, pid int, cfg *botConfig, log *logrus.Entry) error {
+	cli := bot.clientFor(org)
+	url := cfg.Bootstrap.WebhookURL
+
+	urls, err := cli.ListProjectHookURLs(pid)
+	if err != nil {
+		return err
+	}
+
+	if containsString(urls, url) {
+		return nil
+	}
+
+	log.Infof("register the webhook %s", url)
+
+	if cfg.DryRun {
+		return nil
+	}
+
+	token := ""
+	if bot.webhookSecret != nil {
+		token = string(bot.webhookSecret())
+	}
+
+	return cli.AddProjectHook(pid, url, token)
+}
+
+// suggestedConfig is the config item suggested for the new project, which
+// is the minimal one copied from the config of the same org.
+type suggestedConfig struct {
+	Repos         []string          `json:"repos"`
+	CommunityName string            `json:"community_name"`
+	CommunityRepo string            `json:"community_repo"`
+	Branch        string            `json:"branch"`
+	Links         map[string]string `json:"links,omitempty"`
+}
+
+// suggestConfig returns the config item of the new project as YAML. The
+// settings are copied from the first config item of the org, and are left
+// for the operators to fill if there is none.
+func suggestConfig(c *configuration, org, repo string) (string, error) {
+	v := suggestedConfig{
+		Repos:         []string{org + "/" + repo},
+		CommunityName: "<community name>",
+		CommunityRepo: "<org/community>",
+		Branch:        sigFileBranch,
+	}
+
+	for i := range c.ConfigItems {
+		item := &c.ConfigItems[i]
+		if !configCoversOrg(item, org) {
+			continue
+		}
+
+		v.CommunityName = item.CommunityName
+		v.CommunityRepo = item.CommunityRepo
+		v.Branch = item.Branch
+		v.Links = map[string]string{linkCommands: item.commandLink()}
+
+		break
+	}
+
+	b, err := yaml.Marshal([]suggestedConfig{v})
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+func configCoversOrg(cfg *botConfig, org string) bool {
+	for _, r := range cfg.Repos {
+		if r == org || strings.HasPrefix(r, org+"/") {
+			return true
+		}
+	}
+
+	return false
+}
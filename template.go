@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"unicode"
+)
+
+const defaultWelcomeTemplate = `
+Hi ***{{ .Author }}***, welcome to the {{ .Community }} Community.
+I'm the Bot here serving you. You can find the instructions on how to interact with me at **[Here]({{ .CommandLink }})**.
+If you have any questions, please contact the SIG: [{{ .Sig }}](https://gitee.com/openeuler/community/tree/master/sig/{{ .SigPath }}), and any of the maintainers: {{ mention .Maintainers | join " , " }}
+{{- if .Committers }}, any of the committers: {{ mention .Committers | join " , " }}{{ end }}
+{{- range .Roles }}{{ if .Mention }}, any of the {{ .Name }}: {{ mention .Members | join " , " }}{{ end }}{{ end }}
+{{- range .Roles }}{{ if not .Mention }}
+The {{ .Name }}: {{ link .Members | join " , " }}{{ end }}{{ end }}`
+
+// welcomeData is the data to render the welcome template. The usernames in
+// it have been escaped for markdown, and the text provided by users, such
+// as the sig and title, has been sanitized.
+type welcomeData struct {
+	Author      string
+	Community   string
+	CommandLink string
+	Links       map[string]string
+	Sig         string
+	Maintainers []string
+	Committers  []string
+
+	// SigPath is the sig escaped to be the path of URL.
+	SigPath string
+
+	// SigGroup is the full path of the GitLab group mirroring the sig. It is
+	// empty if the sig has none.
+	SigGroup string
+
+	// Roles are the extra roles of sig configured to show.
+	Roles []roleMembers
+
+	// EventType is the type of event welcomed, merge_request or issue.
+	EventType string
+
+	// Vars are the variables of the event type, such as .Vars.thanks.
+	Vars map[string]string
+
+	// the metadata of the merge request or issue, such as .TargetBranch
+	// and .ChangedFiles.
+	itemMeta
+}
+
+var templateFuncs = template.FuncMap{
+	"join": func(sep string, v []string) string {
+		return strings.Join(v, sep)
+	},
+	"truncateList": func(n int, v []string) []string {
+		if n >= 0 && len(v) > n {
+			return v[:n]
+		}
+
+		return v
+	},
+	"mention": func(v []string) []string {
+		r := make([]string, len(v))
+		for i := range v {
+			r[i] = "@" + v[i]
+		}
+
+		return r
+	},
+	"link": func(v []string) []string {
+		r := make([]string, len(v))
+		for i := range v {
+			r[i] = fmt.Sprintf("[%s](/%s)", v[i], v[i])
+		}
+
+		return r
+	},
+	"pluralize": func(n int, singular, plural string) string {
+		if n == 1 {
+			return singular
+		}
+
+		return plural
+	},
+}
+
+var parsedTemplates sync.Map
+
+func parseTemplate(text string) (*template.Template, error) {
+	if v, ok := parsedTemplates.Load(text); ok {
+		return v.(*template.Template), nil
+	}
+
+	t, err := template.New("welcome").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedTemplates.Store(text, t)
+
+	return t, nil
+}
+
+func renderTemplate(text string, data interface{}) (string, error) {
+	return renderTemplateWith(text, data, nil)
+}
+
+// renderTemplateWith renders the template with funcs overriding the
+// builtin template functions.
+func renderTemplateWith(text string, data interface{}, funcs template.FuncMap) (string, error) {
+	t, err := parseTemplate(text)
+	if err != nil {
+		return "", err
+	}
+
+	if len(funcs) > 0 {
+		if t, err = t.Clone(); err != nil {
+			return "", err
+		}
+
+		t.Funcs(funcs)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := t.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("render template: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "~", `\~`, "|", `\|`,
+)
+
+// escapeUsername escapes the characters of username which could break
+// the markdown, such as the underscore in ***user_name***. The spaces and
+// control characters, which no username has, are dropped.
+func escapeUsername(v string) string {
+	v = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return -1
+		}
+
+		return r
+	}, v)
+
+	return markdownEscaper.Replace(v)
+}
+
+func escapeUsernames(v []string) []string {
+	r := make([]string, len(v))
+	for i := range v {
+		r[i] = escapeUsername(v[i])
+	}
+
+	return r
+}
+
+func (bot *robot) genComment(
+	author, sigName string, maintainers, committers []string, roles []roleMembers,
+	meta *itemMeta, cfg *botConfig,
+) (string, error) {
+	text := cfg.welcomeTemplate(meta.eventType())
+
+	return bot.renderWelcome(text, author, sigName, maintainers, committers, roles, meta, cfg)
+}
+
+// renderWelcome renders the template with the welcome data.
+func (bot *robot) renderWelcome(
+	text, author, sigName string, maintainers, committers []string, roles []roleMembers,
+	meta *itemMeta, cfg *botConfig,
+) (string, error) {
+	eventType := meta.eventType()
+
+	data := &welcomeData{
+		EventType:   eventType,
+		Vars:        cfg.eventVariables(eventType),
+		Author:      escapeUsername(author),
+		Community:   cfg.CommunityName,
+		CommandLink: cfg.commandLink(),
+		Links:       cfg.Links,
+		Sig:         sanitizeText(sigName),
+		SigPath:     sanitizePathSegment(sigName),
+		SigGroup:    escapeUsername(bot.mirrorGroup(sigName, &cfg.Mention)),
+		Maintainers: escapeUsernames(maintainers),
+		Committers:  escapeUsernames(committers),
+		Roles:       escapeRoles(roles),
+	}
+
+	if meta != nil {
+		data.itemMeta = sanitizeMeta(*meta)
+	}
+
+	// the whole group is mentioned instead of its members.
+	group := escapeUsername(bot.sigGroup(sigName, &cfg.Mention))
+	if group != "" {
+		data.Maintainers = []string{group}
+		data.Committers = nil
+	}
+
+	return renderTemplateWith(text, data, mentionFuncs(&cfg.Mention, group))
+}
+
+func escapeRoles(v []roleMembers) []roleMembers {
+	r := make([]roleMembers, len(v))
+	for i := range v {
+		r[i] = v[i]
+		r[i].Members = escapeUsernames(v[i].Members)
+	}
+
+	return r
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	tenantEventsTotal = newCounterVec(
+		"welcome_tenant_events_total",
+		"Number of the events handled by the tenant by kind.",
+		"tenant", "kind",
+	)
+	tenantFailuresTotal = newCounterVec(
+		"welcome_tenant_failures_total",
+		"Number of the events failed or panicked in the tenant by kind.",
+		"tenant", "kind",
+	)
+)
+
+// defaultTenant serves the orgs of no tenant by the top-level options.
+const defaultTenant = "default"
+
+// tenantSpec is an independent community served by the deployment. It has
+// its own config, token, rate limits and admin endpoints, so that its
+// misconfig or failures don't affect the welcomes of the others.
+type tenantSpec struct {
+	// Name identifies the tenant in the metrics, logs and the admin
+	// endpoints under /tenants/<name>/.
+	Name string `json:"name" required:"true"`
+
+	// Orgs are the GitLab namespaces, such as openeuler or group/subgroup,
+	// whose events are served by the tenant. The longest one matching the
+	// project wins.
+	Orgs []string `json:"orgs" required:"true"`
+
+	// ConfigFile is the config of robot of the tenant.
+	ConfigFile string `json:"config_file" required:"true"`
+
+	// TokenPath is the file of the GitLab token of the tenant.
+	TokenPath string `json:"token_path" required:"true"`
+
+	// AdminTokenPath is the file of the bearer token of the admin
+	// endpoints of the tenant. They are not served if not set.
+	AdminTokenPath string `json:"admin_token_path,omitempty"`
+
+	// RateLimitGitlab and RateLimitCommunityRepo are the same as the
+	// flags, but for the tenant only. The flags apply if not set.
+	RateLimitGitlab        float64 `json:"rate_limit_gitlab,omitempty"`
+	RateLimitCommunityRepo float64 `json:"rate_limit_community_repo,omitempty"`
+}
+
+type tenantsFile struct {
+	Tenants []tenantSpec `json:"tenants,omitempty"`
+}
+
+func (f *tenantsFile) validate() error {
+	if len(f.Tenants) == 0 {
+		return errors.New("the tenants file has no tenant")
+	}
+
+	names := map[string]bool{defaultTenant: true}
+	orgs := map[string]string{}
+
+	for i := range f.Tenants {
+		t := &f.Tenants[i]
+
+		if t.Name == "" || strings.ContainsAny(t.Name, "/ ") {
+			return fmt.Errorf("invalid name of tenant: %q", t.Name)
+		}
+
+		if names[t.Name] {
+			return fmt.Errorf("duplicate tenant: %s", t.Name)
+		}
+		names[t.Name] = true
+
+		if t.ConfigFile == "" || t.TokenPath == "" {
+			return fmt.Errorf("config_file and token_path of tenant %s must be set", t.Name)
+		}
+
+		if t.RateLimitGitlab < 0 || t.RateLimitCommunityRepo < 0 {
+			return fmt.Errorf("the rate limits of tenant %s can't be negative", t.Name)
+		}
+
+		if len(t.Orgs) == 0 {
+			return fmt.Errorf("orgs of tenant %s can't be empty", t.Name)
+		}
+
+		for _, org := range t.Orgs {
+			org = strings.Trim(org, "/")
+			if org == "" {
+				return fmt.Errorf("empty org of tenant %s", t.Name)
+			}
+
+			if v, ok := orgs[org]; ok {
+				return fmt.Errorf("org %s is served by both tenant %s and %s", org, v, t.Name)
+			}
+			orgs[org] = t.Name
+		}
+	}
+
+	return nil
+}
+
+func (f *tenantsFile) secretPaths() []string {
+	var r []string
+	for i := range f.Tenants {
+		r = append(r, nonEmpty(f.Tenants[i].TokenPath, f.Tenants[i].AdminTokenPath)...)
+	}
+
+	return r
+}
+
+func loadTenantsFile(path string) (*tenantsFile, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := new(tenantsFile)
+	if err := yaml.Unmarshal(b, f); err != nil {
+		return nil, err
+	}
+
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// tenantRouter dispatches the events to the robot of the tenant serving
+// the project. The events of the other projects go to the default robot.
+type tenantRouter struct {
+	fallback *robot
+	robots   map[string]*robot
+
+	// orgs are the namespaces of tenants sorted by length in descending
+	// order, so that the longest one matches first.
+	orgs     []string
+	tenantOf map[string]string
+}
+
+func newTenantRouter(fallback *robot) *tenantRouter {
+	return &tenantRouter{
+		fallback: fallback,
+		robots:   map[string]*robot{},
+		tenantOf: map[string]string{},
+	}
+}
+
+func (t *tenantRouter) add(spec *tenantSpec, bot *robot) {
+	t.robots[spec.Name] = bot
+
+	for _, org := range spec.Orgs {
+		org = strings.Trim(org, "/")
+		t.tenantOf[org] = spec.Name
+		t.orgs = append(t.orgs, org)
+	}
+
+	sort.Slice(t.orgs, func(i, j int) bool { return len(t.orgs[i]) > len(t.orgs[j]) })
+}
+
+// route returns the tenant serving the project of the path with namespace.
+func (t *tenantRouter) route(path string) (string, *robot) {
+	for _, org := range t.orgs {
+		if strings.HasPrefix(path, org+"/") {
+			name := t.tenantOf[org]
+
+			return name, t.robots[name]
+		}
+	}
+
+	return defaultTenant, t.fallback
+}
+
+// dispatch runs the handler of the tenant. A panic is recovered as the
+// failure of the tenant, so that it doesn't take down the others.
+func (t *tenantRouter) dispatch(
+	path, kind string, log *logrus.Entry, h func(*robot, *logrus.Entry) error,
+) (err error) {
+	name, bot := t.route(path)
+	log = log.WithField("tenant", name)
+
+	tenantEventsTotal.inc(name, kind)
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in tenant %s: %v", name, r)
+			log.Error(err)
+		}
+
+		if err != nil {
+			tenantFailuresTotal.inc(name, kind)
+		}
+	}()
+
+	return h(bot, log)
+}
+
+func (t *tenantRouter) HandleMergeEvent(e *gitlab.MergeEvent, log *logrus.Entry) error {
+	return t.dispatch(e.Project.PathWithNamespace, "merge_request", log, func(bot *robot, log *logrus.Entry) error {
+		return bot.HandleMergeEvent(e, log)
+	})
+}
+
+func (t *tenantRouter) HandleIssueEvent(e *gitlab.IssueEvent, log *logrus.Entry) error {
+	return t.dispatch(e.Project.PathWithNamespace, "issue", log, func(bot *robot, log *logrus.Entry) error {
+		return bot.HandleIssueEvent(e, log)
+	})
+}
+
+func (t *tenantRouter) HandleMergeCommentEvent(e *gitlab.MergeCommentEvent, log *logrus.Entry) error {
+	return t.dispatch(e.Project.PathWithNamespace, "merge_request_note", log, func(bot *robot, log *logrus.Entry) error {
+		return bot.HandleMergeCommentEvent(e, log)
+	})
+}
+
+func (t *tenantRouter) HandleIssueCommentEvent(e *gitlab.IssueCommentEvent, log *logrus.Entry) error {
+	return t.dispatch(e.Project.PathWithNamespace, "issue_note", log, func(bot *robot, log *logrus.Entry) error {
+		return bot.HandleIssueCommentEvent(e, log)
+	})
+}
+
+func (t *tenantRouter) HandlePushEvent(e *gitlab.PushEvent, log *logrus.Entry) error {
+	return t.dispatch(e.Project.PathWithNamespace, "push", log, func(bot *robot, log *logrus.Entry) error {
+		return bot.HandlePushEvent(e, log)
+	})
+}
+
+// tenantsHandler lists the tenants and their orgs.
+func (t *tenantRouter) tenantsHandler(w http.ResponseWriter, r *http.Request) {
+	v := map[string][]string{}
+	for org, name := range t.tenantOf {
+		v[name] = append(v[name], org)
+	}
+
+	for _, orgs := range v {
+		sort.Strings(orgs)
+	}
+
+	writeJSON(w, v)
+}
+
+// newTenantRobot builds the robot of the tenant, which shares nothing with
+// the others but the process.
+func newTenantRobot(
+	spec *tenantSpec, o *options, hc *http.Client, chaos *chaosScenario,
+	getToken func() []byte,
+) (*robot, error) {
+	getConfig, err := startConfigAgent(spec.ConfigFile)
+	if err != nil {
+		return nil, fmt.Errorf("start config of tenant %s: %v", spec.Name, err)
+	}
+
+	limits := o.limits
+	limits.tenant = spec.Name
+	if spec.RateLimitGitlab > 0 {
+		limits.gitlab = spec.RateLimitGitlab
+	}
+	if spec.RateLimitCommunityRepo > 0 {
+		limits.communityRepo = spec.RateLimitCommunityRepo
+	}
+
+	c, err := newGitlabClient(getToken, gitlabAPIURL, limits.gitlabClient(withChaos(hc, chaos, gitlabDependency)))
+	if err != nil {
+		return nil, fmt.Errorf("init gitlab client of tenant %s: %v", spec.Name, err)
+	}
+
+	r := newRobot(newInstrumentedClient(c, getToken), getConfig)
+	r.publisher = o.events.publisher(hc)
+	r.hc = hc
+	r.newcomerHC = limits.newcomerClient(withChaos(hc, chaos, func(*http.Request) string {
+		return dependencyNewcomer
+	}))
+	r.limiter = newProjectLimiter(o.projectConcurrency, o.projectConcurrencyLimits)
+	r.ordering = newEventOrdering(o.eventPartitions)
+	r.content = contentDecoder{maxSize: o.maxFileSize, encoding: o.fileEncoding}
+	r.fileCacheByCommit = o.fileCacheByCommit
+	r.privacy = o.privacyMode
+
+	// the state of the tenants, such as the welcome history, is kept in
+	// memory only, as the files of flags belong to the default one.
+	return r, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// triageRotation keeps the pointer of the triage rotation of each sig, which
+// is saved to the file if the path is set.
+type triageRotation struct {
+	lock sync.Mutex
+	path string
+	next map[string]int
+}
+
+func newTriageRotation(path string) *triageRotation {
+	r := &triageRotation{path: path, next: make(map[string]int)}
+
+	if path == "" {
+		return r
+	}
+
+	if err := loadStateFile(path, &r.next); err != nil || r.next == nil {
+		logrus.WithError(err).Errorf("load the triage rotation: %s", path)
+
+		r.next = make(map[string]int)
+	}
+
+	return r
+}
+
+// pick returns the member whose turn it is and moves the pointer to the
+// next one.
+func (r *triageRotation) pick(key string, members []string) string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	i := r.next[key] % len(members)
+	r.next[key] = (i + 1) % len(members)
+
+	if r.path != "" {
+		if err := saveStateFile(r.path, r.next); err != nil {
+			logrus.WithError(err).Errorf("save the triage rotation: %s", r.path)
+		}
+	}
+
+	return members[i]
+}
+
+// triageMembers returns the rotation of the sig. The one of config takes
+// precedence over the triagers of sig-info.
+func (bot *robot) triageMembers(pid int, sigName string, cfg *botConfig) ([]string, error) {
+	if v := cfg.Triage.Rotations[sigName]; len(v) > 0 {
+		return v, nil
+	}
+
+	info, err := bot.getSigInfo(pid, sigName)
+	if err != nil {
+		return nil, err
+	}
+
+	var r []string
+	for _, m := range info.Triagers {
+		if m.GiteeID != "" {
+			r = append(r, m.GiteeID)
+		}
+	}
+
+	return r, nil
+}
+
+// assignTriager assigns the issue to the member of the triage rotation of
+// sig whose turn it is. cfg is the effective one of the welcome.
+func (bot *robot) assignTriager(org, repo string, pid, number int, cfg *botConfig, log *logrus.Entry) error {
+	if !cfg.Triage.Enabled {
+		return nil
+	}
+
+	sigName, err := bot.getSigOfRepo(org, repo, pid, cfg)
+	if err != nil || sigName == "" {
+		return err
+	}
+
+	members, err := bot.triageMembers(pid, sigName, cfg)
+	if err != nil {
+		return err
+	}
+
+	if len(members) == 0 {
+		log.Infof("no triage rotation of sig %s", sigName)
+
+		return nil
+	}
+
+	triager := bot.triage.pick(fmt.Sprintf("%s/%s", cfg.CommunityName, sigName), members)
+
+	log = log.WithField("triager", triager)
+	if cfg.DryRun {
+		log.Info("assign the issue to triager")
+
+		return nil
+	}
+
+	cli := bot.clientFor(org)
+
+	id, err := cli.GetUserID(triager)
+	if err != nil {
+		return err
+	}
+
+	if err := cli.AssignIssue(pid, number, []int{id}); err != nil {
+		return err
+	}
+
+	bot.mentions.record([]string{triager})
+
+	return nil
+}
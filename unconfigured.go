@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const maxUnconfiguredRepos = 200
+
+var unconfiguredEventsTotal = newCounterVec(
+	"welcome_unconfigured_repo_events_total",
+	"Number of events of the repos which are not configured.",
+	"org",
+)
+
+type unconfiguredRepo struct {
+	Repo     string    `json:"repo"`
+	Events   int       `json:"events"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// unconfiguredRepos records the repos recently seen but not configured,
+// which helps to onboard new projects.
+type unconfiguredRepos struct {
+	lock  sync.Mutex
+	repos map[string]*unconfiguredRepo
+}
+
+func newUnconfiguredRepos() *unconfiguredRepos {
+	return &unconfiguredRepos{repos: make(map[string]*unconfiguredRepo)}
+}
+
+func (u *unconfiguredRepos) record(org, repo string) {
+	k := fmt.Sprintf("%s/%s", org, repo)
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	item, ok := u.repos[k]
+	if !ok {
+		if len(u.repos) >= maxUnconfiguredRepos {
+			u.evictOldest()
+		}
+
+		item = &unconfiguredRepo{Repo: k}
+		u.repos[k] = item
+	}
+
+	item.Events++
+	item.LastSeen = time.Now()
+}
+
+func (u *unconfiguredRepos) evictOldest() {
+	oldest := ""
+	for k, v := range u.repos {
+		if oldest == "" || v.LastSeen.Before(u.repos[oldest].LastSeen) {
+			oldest = k
+		}
+	}
+
+	delete(u.repos, oldest)
+}
+
+func (u *unconfiguredRepos) list() []unconfiguredRepo {
+	u.lock.Lock()
+	r := make([]unconfiguredRepo, 0, len(u.repos))
+	for _, v := range u.repos {
+		r = append(r, *v)
+	}
+	u.lock.Unlock()
+
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].LastSeen.After(r[j].LastSeen)
+	})
+
+	return r
+}
+
+func (bot *robot) unconfiguredReposHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, bot.unconfigured.list())
+}
+
+// botConfigFor returns the config of org/repo. It returns nil if the repo
+// is not configured and there is no default config.
+func (bot *robot) botConfigFor(org, repo string, log *logrus.Entry) (*botConfig, error) {
+	c, err := bot.getConfig()
+	if err != nil {
+		return nil, newConfigError(err)
+	}
+
+	if cfg := c.configFor(org, repo); cfg != nil {
+		return cfg, nil
+	}
+
+	bot.unconfigured.record(org, repo)
+	unconfiguredEventsTotal.inc(org)
+
+	log.WithFields(logrus.Fields{"org": org, "repo": repo}).Warn("the repo is not configured, skip it")
+
+	return nil, nil
+}
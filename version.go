@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+)
+
+// The build info is set by the linker, such as
+// -ldflags "-X main.version=v1.2.0 -X main.gitCommit=abc1234".
+var (
+	version   = "dev"
+	gitCommit = "unknown"
+	buildDate = "unknown"
+)
+
+var buildInfo = newGaugeVec(
+	"welcome_build_info",
+	"The build info of the running robot, whose value is always 1.",
+	"version", "git_commit",
+)
+
+func init() {
+	buildInfo.set(1, version, gitCommit)
+}
+
+type versionInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"git_commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// versionHandler serves the build info, so that the behaviors on GitLab
+// can be correlated with the deployments.
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, versionInfo{
+		Version:   version,
+		GitCommit: gitCommit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const warmupMaxRetries = 3
+
+type warmupTask struct {
+	repo string
+	cfg  *botConfig
+}
+
+// warmCache pre-fetches the sig directory tree, OWNERS and sig-info files of
+// all the repos configured explicitly, so that the first events after a
+// deploy are not slowed down by cold lookups. The requests sent by all the
+// workers are limited to one per interval, and a failed task is retried
+// with exponential back-off.
+func (bot *robot) warmCache(c *configuration, workers int, interval time.Duration) {
+	if c == nil || workers <= 0 {
+		return
+	}
+
+	tasks := make(chan warmupTask)
+	limiter := time.NewTicker(interval)
+	defer limiter.Stop()
+
+	wait := func() { <-limiter.C }
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for t := range tasks {
+				bot.warmRepo(t, wait)
+			}
+		}()
+	}
+
+	for i := range c.ConfigItems {
+		cfg := &c.ConfigItems[i]
+
+		for _, repo := range cfg.Repos {
+			// only the repos like org/repo can be warmed up.
+			if strings.Count(repo, "/") == 1 {
+				tasks <- warmupTask{repo: repo, cfg: cfg}
+			}
+		}
+	}
+
+	close(tasks)
+	wg.Wait()
+}
+
+func (bot *robot) warmRepo(t warmupTask, wait func()) {
+	log := logrus.WithField("repo", t.repo)
+
+	backoff := time.Second
+	for i := 0; i < warmupMaxRetries; i++ {
+		err := bot.warmRepoOnce(t, wait)
+		if err == nil {
+			log.Debug("cache is warmed up")
+
+			return
+		}
+
+		log.WithError(err).Warn("warm up cache")
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (bot *robot) warmRepoOnce(t warmupTask, wait func()) error {
+	org := strings.Split(t.repo, "/")[0]
+	repo := strings.Split(t.repo, "/")[1]
+
+	wait()
+	pid, err := bot.cli.GetProjectID(t.repo)
+	if err != nil {
+		return err
+	}
+
+	wait()
+	sigName, err := bot.getSigOfRepo(org, repo, pid, t.cfg)
+	if err != nil || sigName == "" {
+		return err
+	}
+
+	for _, file := range []string{sigOwnersFile(sigName), sigInfoFile(sigName)} {
+		wait()
+		if _, err := bot.getPathContent(pid, file, sigFileBranch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	webhookStatusHandled = "handled"
+	webhookStatusPartial = "partial"
+	webhookStatusSkipped = "skipped"
+	webhookStatusIgnored = "ignored"
+	webhookStatusFailed  = "failed"
+)
+
+// webhookOutcome collects the steps and the skips of handling an event
+// served by the webhook, which are told to GitLab in the response. It is
+// carried by the context of log, as the log is what is passed all the way
+// down.
+type webhookOutcome struct {
+	lock    sync.Mutex
+	steps   []stepResult
+	skipped []string
+
+	// verified means the request carried the secret token of the webhook.
+	verified bool
+}
+
+type webhookOutcomeKey struct{}
+
+// withWebhookOutcome returns the log carrying a new outcome.
+func withWebhookOutcome(log *logrus.Entry) (*logrus.Entry, *webhookOutcome) {
+	o := new(webhookOutcome)
+
+	ctx := log.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return log.WithContext(context.WithValue(ctx, webhookOutcomeKey{}, o)), o
+}
+
+// webhookOutcomeOf returns the outcome carried by the log. It is nil if the
+// event is not served by the webhook, such as the ones consumed from the
+// message broker, and the methods of the nil outcome do nothing.
+func webhookOutcomeOf(log *logrus.Entry) *webhookOutcome {
+	if log == nil || log.Context == nil {
+		return nil
+	}
+
+	o, _ := log.Context.Value(webhookOutcomeKey{}).(*webhookOutcome)
+
+	return o
+}
+
+func (o *webhookOutcome) addSteps(v []stepResult) {
+	if o == nil {
+		return
+	}
+
+	o.lock.Lock()
+	o.steps = append(o.steps, v...)
+	o.lock.Unlock()
+}
+
+// isVerified reports whether the event came from the webhook whose secret
+// token is verified. The events consumed from the message broker are not.
+func (o *webhookOutcome) isVerified() bool {
+	return o != nil && o.verified
+}
+
+// skip records why the event is not welcomed, such as imported.
+func (o *webhookOutcome) skip(reason string) {
+	if o == nil {
+		return
+	}
+
+	o.lock.Lock()
+	o.skipped = append(o.skipped, reason)
+	o.lock.Unlock()
+}
+
+// webhookResponse is the body of the response to GitLab, which tells what
+// was done for the event.
+type webhookResponse struct {
+	Status     string       `json:"status"`
+	Retryable  bool         `json:"retryable"`
+	Error      string       `json:"error,omitempty"`
+	ErrorKinds []string     `json:"error_kinds,omitempty"`
+	Skipped    []string     `json:"skipped,omitempty"`
+	Steps      []stepResult `json:"steps,omitempty"`
+}
+
+func (o *webhookOutcome) response(err error) *webhookResponse {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	v := &webhookResponse{Skipped: o.skipped, Steps: o.steps}
+
+	if err != nil {
+		v.Status = webhookStatusFailed
+		v.Retryable = isRetryable(err)
+		v.Error = err.Error()
+		v.ErrorKinds = errorKinds(err)
+
+		return v
+	}
+
+	failed := 0
+	for i := range o.steps {
+		if r := o.steps[i].Result; r == stepResultFailed || r == stepResultDegraded {
+			failed++
+		}
+	}
+
+	switch {
+	case failed > 0:
+		v.Status = webhookStatusPartial
+	case len(o.steps) > 0:
+		v.Status = webhookStatusHandled
+	case len(o.skipped) > 0:
+		v.Status = webhookStatusSkipped
+	default:
+		v.Status = webhookStatusIgnored
+	}
+
+	return v
+}
+
+func writeWebhookResponse(w http.ResponseWriter, code int, v *webhookResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.WithError(err).Error("write response")
+	}
+}
+
+// webhookHandler serves the GitLab webhook whose events are handled before
+// responding. The requests must carry the secret as the token. It responds 5xx on the retryable failures, so that GitLab
+// retries them, and 2xx with what was skipped or failed otherwise.
+func webhookHandler(
+	getSecret func() []byte, handlerOf func([]byte, *logrus.Entry) (func() error, error),
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// the requests are refused if the secret is missing, such as
+		// failing to be read.
+		secret := getSecret()
+		if len(secret) == 0 || subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Gitlab-Token")), secret) != 1 {
+			http.Error(w, "invalid token", http.StatusForbidden)
+
+			return
+		}
+
+		payload, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		payload, adapted := adaptPayload(payload)
+
+		fields := logrus.Fields{
+			"event-type":     r.Header.Get("X-Gitlab-Event"),
+			"event-id":       r.Header.Get("X-Gitlab-Event-UUID"),
+			"gitlab-version": gitlabVersionOf(r.UserAgent()),
+		}
+		if len(adapted) > 0 {
+			fields["adapted"] = adapted
+		}
+
+		log, outcome := withWebhookOutcome(logrus.WithFields(fields))
+		outcome.verified = true
+
+		handle, err := handlerOf(payload, log)
+		if err != nil {
+			observeError(err, "decode the event", log)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		if handle != nil {
+			err = handle()
+			observeError(err, "handle gitlab event", log)
+		}
+
+		writeWebhookResponse(w, httpStatusOf(err), outcome.response(err))
+	}
+}
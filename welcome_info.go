@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// welcomeInfoRe matches the /welcome-info command taking a line by itself.
+var welcomeInfoRe = regexp.MustCompile(`(?m)^[ \t]*/welcome-info[ \t]*$`)
+
+type welcomeInfoConfig struct {
+	// Enabled means the members of maintainer_roles can ask the robot by
+	// the /welcome-info command how it welcomes the merge request or
+	// issue, such as the config matched, the sig resolved and the data
+	// sources used, so that the wrong mentions are debugged easily.
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+// welcomeInfo is what the robot tells by the /welcome-info command.
+type welcomeInfo struct {
+	user     string
+	source   string
+	repoFile string
+	plan     *ActionPlan
+	cfg      *botConfig
+}
+
+func codeList(v []string) string {
+	if len(v) == 0 {
+		return "none"
+	}
+
+	r := make([]string, len(v))
+	for i := range v {
+		r[i] = "`" + strings.Replace(v[i], "`", "", -1) + "`"
+	}
+
+	return strings.Join(r, ", ")
+}
+
+// sigDataSource describes where the sig data is read from.
+func sigDataSource(cfg *botConfig) string {
+	if cfg.SigSource.Type == sigSourceREST {
+		// the url encrypted may carry a token, so it is not shown.
+		if cfg.isSecret("sig_source.url") {
+			return "the metadata service"
+		}
+
+		return fmt.Sprintf("the metadata service %s", cfg.SigSource.URL)
+	}
+
+	return fmt.Sprintf("the files of %s on %s", cfg.CommunityRepo, cfg.Branch)
+}
+
+// render renders the info as a markdown list. The users are in code spans,
+// so that they are not mentioned.
+func (v *welcomeInfo) render() string {
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+
+		return "no"
+	}
+
+	plan := v.plan
+
+	lines := []string{
+		fmt.Sprintf("**Welcome info** asked by `%s`", v.user),
+		"",
+		fmt.Sprintf("- Config: `%s`", v.source),
+	}
+
+	if v.repoFile != "" {
+		lines = append(lines, fmt.Sprintf("- Repo file: `%s`", v.repoFile))
+	}
+
+	sig := "none"
+	if plan.SigName != "" {
+		sig = "`" + sanitizeText(plan.SigName) + "`"
+	}
+	if len(plan.ExtraSigs) > 0 {
+		sig += ", and " + codeList(plan.ExtraSigs)
+	}
+
+	lines = append(lines,
+		"- SIG: "+sig,
+		"- SIG data: "+sigDataSource(v.cfg),
+	)
+
+	if v.cfg.SigFile.Enabled && plan.SigName != "" {
+		lines = append(lines, fmt.Sprintf("- SIG file: `%s`", v.cfg.SigFile.path(plan.SigName)))
+	}
+
+	lines = append(lines,
+		"- Mentioned: "+codeList(plan.Notifications),
+	)
+
+	if len(plan.Away) > 0 {
+		lines = append(lines, "- Out of office: "+codeList(plan.Away))
+	}
+
+	lines = append(lines,
+		"- Labels: "+codeList(plan.Labels),
+		"- Newcomer: "+yesNo(plan.Newcomer),
+		"- Cooldown: "+yesNo(plan.Cooldown),
+	)
+
+	for _, d := range plan.Degraded {
+		lines = append(lines, fmt.Sprintf("- Degraded: %s, %s", d.Step, sanitizeText(d.Error)))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// handleWelcomeInfo replies to the /welcome-info command in the comment of
+// user with how the robot welcomes the item commented. get is the same as
+// the one of handleSigDirectiveComment.
+func (bot *robot) handleWelcomeInfo(
+	path, user, note string, pid, number int,
+	get func(iClient) (string, *itemMeta, actions, error),
+	log *logrus.Entry,
+) error {
+	if !welcomeInfoRe.MatchString(note) {
+		return nil
+	}
+
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return nil
+	}
+
+	org, repo := path[:i], path[i+1:]
+
+	c, err := bot.getConfig()
+	if err != nil {
+		return newConfigError(err)
+	}
+
+	cfg, source := c.resolve(org, repo)
+	if cfg == nil || !cfg.WelcomeInfo.Enabled {
+		return nil
+	}
+
+	ok, err := bot.isMaintainer(user, pid, cfg)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		log.WithField("user", user).Info("the user can't ask the welcome info")
+
+		return nil
+	}
+
+	author, meta, acts, err := get(bot.clientFor(org))
+	if err != nil || author == "" {
+		return err
+	}
+
+	info := &welcomeInfo{user: user, source: source, cfg: cfg}
+
+	if v, err := bot.effectiveConfig(org, repo, cfg); err != nil {
+		log.WithError(err).Error("get the effective config")
+	} else {
+		info.repoFile, info.cfg = v.RepoFile, v.Config
+	}
+
+	// the issue is planned without the number, as it is welcomed.
+	if meta != nil && !meta.IsMergeRequest {
+		number = 0
+	}
+
+	plan, err := bot.genPlan(org, repo, author, number, pid, nil, meta, info.cfg, log)
+	if err != nil {
+		return err
+	}
+
+	info.plan = plan
+
+	return acts.addComment(info.render())
+}
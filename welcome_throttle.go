@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	throttleOverflowQueue     = "queue"
+	throttleOverflowLabelOnly = "label_only"
+	throttleOverflowSkip      = "skip"
+
+	throttleWindow = time.Hour
+
+	delayedJobThrottled = "throttled"
+)
+
+var throttledWelcomesTotal = newCounterVec(
+	"welcome_throttled_total",
+	"Number of the welcomes over the hourly cap of project by the overflow behavior.",
+	"project", "overflow",
+)
+
+type throttleConfig struct {
+	// MaxPerHour is the maximum welcome comments posted to a project in an
+	// hour, which protects against the storms of webhook caused by the
+	// integrations reopening merge requests again and again. It is
+	// disabled if not set.
+	MaxPerHour int `json:"max_per_hour,omitempty"`
+
+	// Overflow is what to do with the welcome over the cap. It can be
+	// queue, which posts it once the cap allows; label_only, which adds the
+	// labels only; or skip, which does nothing. The default value is
+	// label_only.
+	Overflow string `json:"overflow,omitempty"`
+
+	// MaxQueued is the maximum welcomes of a project queued, beyond which
+	// the overflow is handled as label_only. The default value is 100.
+	MaxQueued int `json:"max_queued,omitempty"`
+}
+
+func (c *throttleConfig) setDefault() {
+	if c.Overflow == "" {
+		c.Overflow = throttleOverflowLabelOnly
+	}
+
+	if c.MaxQueued <= 0 {
+		c.MaxQueued = 100
+	}
+}
+
+func (c *throttleConfig) validate() error {
+	if c.MaxPerHour < 0 {
+		return fmt.Errorf("max_per_hour of throttle can't be negative")
+	}
+
+	switch c.Overflow {
+	case throttleOverflowQueue, throttleOverflowLabelOnly, throttleOverflowSkip:
+	default:
+		return fmt.Errorf("unsupported overflow of throttle: %s", c.Overflow)
+	}
+
+	return nil
+}
+
+// welcomeThrottle counts the welcomes of each project in the last hour, and
+// the welcomes queued for the cap.
+type welcomeThrottle struct {
+	lock     sync.Mutex
+	welcomes map[int][]time.Time
+	queued   map[int]map[string]bool
+}
+
+func newWelcomeThrottle() *welcomeThrottle {
+	return &welcomeThrottle{
+		welcomes: make(map[int][]time.Time),
+		queued:   make(map[int]map[string]bool),
+	}
+}
+
+// take records a welcome of the project if the cap allows it. Otherwise, it
+// returns how long to wait until the cap allows one.
+func (t *welcomeThrottle) take(pid, max int) (bool, time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := time.Now()
+	start := now.Add(-throttleWindow)
+
+	v := t.welcomes[pid]
+	i := 0
+	for i < len(v) && v[i].Before(start) {
+		i++
+	}
+	v = v[i:]
+
+	if len(v) >= max {
+		t.welcomes[pid] = v
+
+		return false, v[0].Sub(start)
+	}
+
+	t.welcomes[pid] = append(v, now)
+
+	return true, 0
+}
+
+// queue records the item queued for the project, and returns the number of
+// items queued before it. It fails if the item is queued already, which dup
+// tells, or the queue of project is full.
+func (t *welcomeThrottle) queue(pid int, item string, max int) (pos int, ok, dup bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	q := t.queued[pid]
+	if q == nil {
+		q = make(map[string]bool)
+		t.queued[pid] = q
+	}
+
+	if q[item] {
+		return 0, false, true
+	}
+
+	if len(q) >= max {
+		return 0, false, false
+	}
+
+	pos = len(q)
+	q[item] = true
+
+	return pos, true, false
+}
+
+func (t *welcomeThrottle) dequeue(pid int, item string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if q := t.queued[pid]; q != nil {
+		delete(q, item)
+
+		if len(q) == 0 {
+			delete(t.queued, pid)
+		}
+	}
+}
+
+// queuedDelay returns the delay of the welcome queued at the position. The
+// queued ones are spread over the window by the cap, rather than retried at
+// the same time and put off again but one.
+func queuedDelay(wait time.Duration, pos, max int) time.Duration {
+	return wait + time.Duration(pos)*throttleWindow/time.Duration(max)
+}
+
+// throttleItemKey identifies the merge request or issue queued, so that the
+// reopening of it is queued only once.
+func throttleItemKey(author string, acts actions) string {
+	switch a := acts.(type) {
+	case *mrActions:
+		return "mr/" + strconv.Itoa(a.number)
+	case *issueActions:
+		return "issue/" + strconv.Itoa(a.number)
+	default:
+		return "author/" + author
+	}
+}
+
+// throttleWelcome applies the hourly cap of project to the plan. It returns
+// true if the welcome is put off or skipped, so that nothing else is done
+// now. The welcome put off is retried after the events of the same item.
+func (bot *robot) throttleWelcome(
+	plan *ActionPlan, author string, pid int, acts actions, cfg *botConfig,
+	retry func() error, log *logrus.Entry,
+) bool {
+	c := &cfg.Throttle
+	if c.MaxPerHour <= 0 || plan.Comment == "" {
+		return false
+	}
+
+	ok, wait := bot.throttle.take(pid, c.MaxPerHour)
+	if ok {
+		return false
+	}
+
+	overflow := c.Overflow
+	item := throttleItemKey(author, acts)
+
+	if overflow == throttleOverflowQueue {
+		// the welcome queued already will be done by the retry, so the
+		// event of the same item is skipped.
+		pos, ok, dup := bot.throttle.queue(pid, item, c.MaxQueued)
+		if dup {
+			overflow = throttleOverflowSkip
+		} else if !ok {
+			overflow = throttleOverflowLabelOnly
+		}
+
+		wait = queuedDelay(wait, pos, c.MaxPerHour)
+	}
+
+	throttledWelcomesTotal.inc(strconv.Itoa(pid), overflow)
+	log = log.WithField("overflow", overflow)
+
+	switch overflow {
+	case throttleOverflowQueue:
+		log.Infof("the welcomes of project reach the cap, put it off by %s", wait)
+
+		target := actionsTarget(pid, author, acts)
+
+		scheduleJob(delayedJobThrottled, wait, func() {
+			bot.throttle.dequeue(pid, item)
+
+			if err := bot.ordering.run(target, retry); err != nil {
+				log.WithError(err).Error("handle the welcome put off")
+			}
+		})
+
+		return true
+
+	case throttleOverflowSkip:
+		log.Info("the welcomes of project reach the cap, skip it")
+
+		return true
+
+	default:
+		log.Info("the welcomes of project reach the cap, add the labels only")
+
+		plan.Comment = ""
+
+		return false
+	}
+}
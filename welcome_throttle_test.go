@@ -0,0 +1,127 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestWelcomeThrottleTake(t *testing.T) {
+	th := newWelcomeThrottle()
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := th.take(1, 2); !ok {
+			t.Fatalf("welcome %d is over the cap", i)
+		}
+	}
+
+	ok, wait := th.take(1, 2)
+	if ok {
+		t.Fatal("the third welcome is not over the cap")
+	}
+
+	if wait <= 0 || wait > throttleWindow {
+		t.Errorf("wait = %s", wait)
+	}
+
+	if ok, _ := th.take(2, 2); !ok {
+		t.Error("the cap of another project is taken")
+	}
+
+	// the welcomes out of the window are not counted.
+	th.welcomes[1][0] = time.Now().Add(-2 * throttleWindow)
+	if ok, _ := th.take(1, 2); !ok {
+		t.Error("the welcome out of the window is counted")
+	}
+}
+
+func TestWelcomeThrottleQueue(t *testing.T) {
+	th := newWelcomeThrottle()
+
+	for i, item := range []string{"mr/1", "mr/2"} {
+		pos, ok, dup := th.queue(1, item, 2)
+		if pos != i || !ok || dup {
+			t.Errorf("queue(%s) = %d, %v, %v", item, pos, ok, dup)
+		}
+	}
+
+	if _, ok, dup := th.queue(1, "mr/1", 2); ok || !dup {
+		t.Errorf("the item queued again: ok = %v, dup = %v", ok, dup)
+	}
+
+	if _, ok, dup := th.queue(1, "mr/3", 2); ok || dup {
+		t.Errorf("the item is queued to the full queue: ok = %v, dup = %v", ok, dup)
+	}
+
+	th.dequeue(1, "mr/1")
+	th.dequeue(1, "mr/2")
+
+	if len(th.queued) != 0 {
+		t.Errorf("the empty queue is kept: %v", th.queued)
+	}
+}
+
+func TestQueuedDelay(t *testing.T) {
+	wait := time.Minute
+
+	if d := queuedDelay(wait, 0, 10); d != wait {
+		t.Errorf("the first one is put off by %s, want %s", d, wait)
+	}
+
+	if d := queuedDelay(wait, 3, 10); d != wait+3*throttleWindow/10 {
+		t.Errorf("the fourth one is put off by %s", d)
+	}
+}
+
+func TestThrottleWelcomeOverflow(t *testing.T) {
+	bot := newRobot(nil, nil)
+	log := logrus.NewEntry(logrus.New())
+
+	cfg := &botConfig{}
+	cfg.Throttle.MaxPerHour = 1
+	cfg.Throttle.Overflow = throttleOverflowLabelOnly
+	cfg.Throttle.setDefault()
+
+	var retried int32
+	retry := func() error {
+		atomic.AddInt32(&retried, 1)
+
+		return nil
+	}
+
+	plan := &ActionPlan{Comment: "welcome"}
+	acts := &mrActions{projectID: 1, number: 1}
+	if bot.throttleWelcome(plan, "a", 1, acts, cfg, retry, log) || plan.Comment == "" {
+		t.Fatal("the first welcome is throttled")
+	}
+
+	plan = &ActionPlan{Comment: "welcome"}
+	if bot.throttleWelcome(plan, "a", 1, &mrActions{projectID: 1, number: 2}, cfg, retry, log) || plan.Comment != "" {
+		t.Errorf("label_only: the comment %q is kept", plan.Comment)
+	}
+
+	cfg.Throttle.Overflow = throttleOverflowSkip
+	if !bot.throttleWelcome(&ActionPlan{Comment: "welcome"}, "a", 1, &mrActions{projectID: 1, number: 3}, cfg, retry, log) {
+		t.Error("skip: the welcome is not skipped")
+	}
+
+	cfg.Throttle.Overflow = throttleOverflowQueue
+	if !bot.throttleWelcome(&ActionPlan{Comment: "welcome"}, "a", 1, &mrActions{projectID: 1, number: 4}, cfg, retry, log) {
+		t.Error("queue: the welcome is not put off")
+	}
+
+	// the event of the item queued is skipped.
+	if !bot.throttleWelcome(&ActionPlan{Comment: "welcome"}, "a", 1, &mrActions{projectID: 1, number: 4}, cfg, retry, log) {
+		t.Error("queue: the event of the item queued is not skipped")
+	}
+
+	if n := len(bot.throttle.queued[1]); n != 1 {
+		t.Errorf("%d items are queued, want 1", n)
+	}
+
+	if n := atomic.LoadInt32(&retried); n != 0 {
+		t.Errorf("retried %d times before the cap allows", n)
+	}
+}